@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/admin"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/service"
+)
+
+// adminAddrEnv is read for the admin API address when -admin-addr is not given,
+// the same variable that sets global.admin_addr for the daemon
+const adminAddrEnv = "TSBRIDGE_GLOBAL_ADMIN_ADDR"
+
+// adminFlags are the flags shared by commands that talk to a running instance
+type adminFlags struct {
+	addr string
+	json bool
+}
+
+// addAdminFlags registers the admin API flags on fs
+func addAdminFlags(fs *flag.FlagSet, flags *adminFlags) {
+	fs.StringVar(&flags.addr, "admin-addr", os.Getenv(adminAddrEnv), "Admin API address of the running instance (defaults to "+adminAddrEnv+")")
+	fs.BoolVar(&flags.json, "json", false, "Print JSON instead of a table")
+}
+
+// client returns an admin API client for the configured address
+func (f *adminFlags) client() (*admin.Client, error) {
+	if f.addr == "" {
+		return nil, fmt.Errorf("-admin-addr flag or %s is required", adminAddrEnv)
+	}
+	return admin.NewClient(f.addr)
+}
+
+// parseInterspersed parses args allowing flags after positional arguments, as
+// in "tsbridge services restart api -json", and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// runStatus prints a summary of the running instance
+func runStatus(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge status", flag.ContinueOnError)
+	flags := &adminFlags{}
+	addAdminFlags(fs, flags)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	client, err := flags.client()
+	if err != nil {
+		return err
+	}
+	status, err := client.Status(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(stdout, status)
+	}
+	version := status.Version
+	if version == "" {
+		version = "unknown"
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%s\n", version)
+	fmt.Fprintf(tw, "Uptime:\t%s\n", formatUptime(status.UptimeSeconds))
+	fmt.Fprintf(tw, "Services:\t%d (%d healthy, %d draining)\n", status.Services, status.Healthy, status.Draining)
+	if status.LastReload != nil {
+		fmt.Fprintf(tw, "Last reload:\t%s\n", formatReload(status.LastReload))
+	}
+	return tw.Flush()
+}
+
+// formatReload summarizes the outcome of a configuration reload
+func formatReload(reload *admin.ReloadStatus) string {
+	outcome := "succeeded"
+	switch {
+	case reload.RolledBack:
+		outcome = "failed, rolled back"
+	case !reload.Success:
+		outcome = "failed"
+	}
+	summary := fmt.Sprintf("%s at %s (%d of %d services applied)",
+		outcome, reload.Time.Format(time.RFC3339), reload.Applied, reload.Desired)
+	if len(reload.OutOfSync) > 0 {
+		summary += ", out of sync: " + strings.Join(reload.OutOfSync, ", ")
+	}
+	return summary
+}
+
+// runServicesList prints the status of every running service
+func runServicesList(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge services list", flag.ContinueOnError)
+	flags := &adminFlags{}
+	addAdminFlags(fs, flags)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	client, err := flags.client()
+	if err != nil {
+		return err
+	}
+	statuses, err := client.Services(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(stdout, statuses)
+	}
+	return writeServiceTable(stdout, statuses)
+}
+
+// runServicesRestart restarts a service with its current configuration
+func runServicesRestart(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge services restart", flag.ContinueOnError)
+	flags := &adminFlags{}
+	addAdminFlags(fs, flags)
+	names, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) != 1 {
+		return fmt.Errorf("usage: tsbridge services restart <name>")
+	}
+
+	client, err := flags.client()
+	if err != nil {
+		return err
+	}
+	status, err := client.RestartService(context.Background(), names[0])
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(stdout, status)
+	}
+	fmt.Fprintf(stdout, "Restarted %s\n", status.Name)
+	return writeServiceTable(stdout, []service.Status{status})
+}
+
+// runReload makes the running instance reload its configuration
+func runReload(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge reload", flag.ContinueOnError)
+	flags := &adminFlags{}
+	addAdminFlags(fs, flags)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	client, err := flags.client()
+	if err != nil {
+		return err
+	}
+	statuses, err := client.Reload(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return writeJSON(stdout, statuses)
+	}
+	fmt.Fprintln(stdout, "Configuration reloaded")
+	return writeServiceTable(stdout, statuses)
+}
+
+// runLogs prints the running instance's recent log output, optionally
+// following new lines until interrupted
+func runLogs(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge logs", flag.ContinueOnError)
+	addr := fs.String("admin-addr", os.Getenv(adminAddrEnv), "Admin API address of the running instance (defaults to "+adminAddrEnv+")")
+	lines := fs.Int("lines", 100, "Number of recent lines to print")
+	follow := fs.Bool("follow", false, "Keep printing new lines until interrupted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *lines < 0 {
+		return fmt.Errorf("-lines must not be negative")
+	}
+
+	client, err := (&adminFlags{addr: *addr}).client()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return client.Logs(ctx, stdout, *lines, *follow)
+}
+
+// runEvents prints the running instance's recent lifecycle events, optionally
+// following new ones until interrupted
+func runEvents(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge events", flag.ContinueOnError)
+	flags := &adminFlags{}
+	fs.StringVar(&flags.addr, "admin-addr", os.Getenv(adminAddrEnv), "Admin API address of the running instance (defaults to "+adminAddrEnv+")")
+	fs.BoolVar(&flags.json, "json", false, "Print each event as a line of JSON")
+	types := fs.String("type", "", "Comma-separated event types or categories to print, such as service,config.reloaded")
+	serviceName := fs.String("service", "", "Only print events about this service")
+	limit := fs.Int("limit", 20, "Number of recent events to print")
+	follow := fs.Bool("follow", false, "Keep printing new events until interrupted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *limit < 0 {
+		return fmt.Errorf("-limit must not be negative")
+	}
+
+	client, err := flags.client()
+	if err != nil {
+		return err
+	}
+	query := admin.EventQuery{Service: *serviceName, Limit: *limit}
+	if *types != "" {
+		query.Types = strings.Split(*types, ",")
+	}
+
+	output := func(e events.Event) {
+		if flags.json {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(stdout, "%s\n", data)
+			return
+		}
+		writeEvent(stdout, e)
+	}
+
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return client.FollowEvents(ctx, query, output)
+	}
+
+	// A limit of zero would ask the admin API for its default
+	if *limit == 0 {
+		return nil
+	}
+	list, err := client.Events(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		output(e)
+	}
+	return nil
+}
+
+// writeEvent prints an event on one line as its time, type and key=value details
+func writeEvent(w io.Writer, e events.Event) {
+	parts := []string{e.Time.Format(time.RFC3339), e.Type}
+	if e.Service != "" {
+		parts = append(parts, "service="+e.Service)
+	}
+	for _, key := range slices.Sorted(maps.Keys(e.Data)) {
+		value, ok := e.Data[key].(string)
+		if !ok {
+			data, _ := json.Marshal(e.Data[key])
+			value = string(data)
+		}
+		parts = append(parts, key+"="+value)
+	}
+	if e.Trigger != nil {
+		by := e.Trigger.Source
+		if e.Trigger.Actor != "" {
+			by += ":" + e.Trigger.Actor
+		}
+		parts = append(parts, "by="+by)
+	}
+	if e.Error != "" {
+		parts = append(parts, fmt.Sprintf("error=%q", e.Error))
+	}
+	fmt.Fprintln(w, strings.Join(parts, " "))
+}
+
+// writeServiceTable prints service statuses as a table
+func writeServiceTable(w io.Writer, statuses []service.Status) error {
+	if len(statuses) == 0 {
+		fmt.Fprintln(w, "No services running")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tUPTIME\tIN-FLIGHT\tBACKEND\tFQDN")
+	for _, status := range statuses {
+		fqdn := status.FQDN
+		if fqdn == "" {
+			fqdn = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			status.Name, serviceState(status), formatUptime(status.UptimeSeconds),
+			status.InFlight, status.Backend, fqdn)
+	}
+	return tw.Flush()
+}
+
+// serviceState summarises a service's health for writeServiceTable
+func serviceState(status service.Status) string {
+	switch {
+	case status.Draining:
+		return "draining"
+	case status.Idle:
+		return "idle"
+	case status.Healthy:
+		return "healthy"
+	default:
+		return "unhealthy"
+	}
+}
+
+// formatUptime formats an uptime in seconds as a duration such as 3h2m5s
+func formatUptime(seconds int64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
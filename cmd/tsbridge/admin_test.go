@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/admin"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubController is an admin.Controller over a fixed list of services
+type stubController struct {
+	services   []service.Status
+	restarts   []string
+	reloads    int
+	lastReload *admin.ReloadStatus
+	settings   map[string]any
+}
+
+func (c *stubController) Services(context.Context) []service.Status { return c.services }
+
+func (c *stubController) Service(_ context.Context, name string) (service.Status, error) {
+	for _, status := range c.services {
+		if status.Name == name {
+			return status, nil
+		}
+	}
+	return service.Status{}, fmt.Errorf("service %s %w", name, service.ErrNotFound)
+}
+
+func (c *stubController) Settings() map[string]any { return c.settings }
+
+func (c *stubController) Reload(context.Context) error {
+	c.reloads++
+	return nil
+}
+
+func (c *stubController) LastReload() *admin.ReloadStatus { return c.lastReload }
+
+func (c *stubController) RestartService(_ context.Context, name string) error {
+	if _, err := c.Service(context.Background(), name); err != nil {
+		return err
+	}
+	c.restarts = append(c.restarts, name)
+	return nil
+}
+
+func (c *stubController) DrainService(context.Context, string, bool) error { return nil }
+func (c *stubController) RemoveService(context.Context, string) error      { return nil }
+func (c *stubController) SetAccessLog(context.Context, string, bool) error { return nil }
+
+// startAdminServer starts an admin API on a unix socket and returns its address
+func startAdminServer(t *testing.T, controller admin.Controller, logs *admin.LogBuffer, bus *events.Bus) string {
+	t.Helper()
+	// Keep the path short enough for the unix socket limit
+	dir, err := os.MkdirTemp("", "tsbridge")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	addr := "unix://" + filepath.Join(dir, "admin.sock")
+
+	s := admin.NewServer(admin.Options{Addr: addr, Version: "1.2.3", Logs: logs, Events: bus}, controller, nil)
+	require.NoError(t, s.Start(context.Background()))
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	return addr
+}
+
+func TestAdminCommands(t *testing.T) {
+	controller := &stubController{services: []service.Status{
+		{Name: "api", Backend: "localhost:8080", FQDN: "api.example.ts.net", Healthy: true, UptimeSeconds: 3725, InFlight: 2},
+		{Name: "web", Backend: "localhost:8081", HealthError: "connection refused"},
+		{Name: "wiki", Backend: "localhost:8082", Healthy: true, Draining: true},
+	}}
+	logs := admin.NewLogBuffer(10)
+	_, _ = logs.Write([]byte("first\nsecond\nthird\n"))
+	bus := events.NewBus(10)
+	started := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	bus.Publish(events.Event{Time: started, Type: events.ServiceStarted, Service: "api", Data: map[string]any{"backend": "localhost:8080"}})
+	bus.Publish(events.Event{
+		Time:    started.Add(time.Minute),
+		Type:    events.ConfigReloadFailed,
+		Trigger: &events.Trigger{Source: events.SourceAdminAPI, Actor: "alice@example.com"},
+		Error:   "service web failed to start",
+		Data:    map[string]any{"plan": map[string]any{"add": []string{"web"}}},
+	})
+	addr := startAdminServer(t, controller, logs, bus)
+
+	t.Run("status", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runStatus([]string{"-admin-addr", addr}, &out))
+		assert.Contains(t, out.String(), "Version:   1.2.3\n")
+		assert.Contains(t, out.String(), "Services:  3 (2 healthy, 1 draining)\n")
+	})
+
+	t.Run("status json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runStatus([]string{"-admin-addr", addr, "-json"}, &out))
+
+		var status admin.InstanceStatus
+		require.NoError(t, json.Unmarshal(out.Bytes(), &status))
+		assert.Equal(t, "1.2.3", status.Version)
+		assert.Equal(t, 3, status.Services)
+	})
+
+	t.Run("status after reload", func(t *testing.T) {
+		controller.lastReload = &admin.ReloadStatus{
+			Time:       started,
+			Mode:       "transactional",
+			RolledBack: true,
+			Desired:    3,
+			Applied:    2,
+			OutOfSync:  []string{"web"},
+		}
+		t.Cleanup(func() { controller.lastReload = nil })
+
+		var out bytes.Buffer
+		require.NoError(t, runStatus([]string{"-admin-addr", addr}, &out))
+		assert.Contains(t, out.String(),
+			"Last reload:  failed, rolled back at 2026-05-01T12:00:00Z (2 of 3 services applied), out of sync: web\n")
+	})
+
+	t.Run("services list", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runServicesList([]string{"-admin-addr", addr}, &out))
+		assert.Equal(t, `NAME  STATE      UPTIME  IN-FLIGHT  BACKEND         FQDN
+api   healthy    1h2m5s  2          localhost:8080  api.example.ts.net
+web   unhealthy  0s      0          localhost:8081  -
+wiki  draining   0s      0          localhost:8082  -
+`, out.String())
+	})
+
+	t.Run("services list json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runServicesList([]string{"-admin-addr", addr, "-json"}, &out))
+
+		var statuses []service.Status
+		require.NoError(t, json.Unmarshal(out.Bytes(), &statuses))
+		assert.Equal(t, controller.services, statuses)
+	})
+
+	t.Run("services restart", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runServicesRestart([]string{"api", "-admin-addr", addr}, &out))
+		assert.Contains(t, out.String(), "Restarted api\n")
+		assert.Contains(t, out.String(), "api   healthy")
+		assert.Equal(t, []string{"api"}, controller.restarts)
+	})
+
+	t.Run("services restart unknown service", func(t *testing.T) {
+		err := runServicesRestart([]string{"-admin-addr", addr, "missing"}, &bytes.Buffer{})
+		assert.EqualError(t, err, "admin API: service missing not found")
+	})
+
+	t.Run("services restart requires a name", func(t *testing.T) {
+		err := runServicesRestart([]string{"-admin-addr", addr}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "usage: tsbridge services restart <name>")
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runReload([]string{"-admin-addr", addr}, &out))
+		assert.Contains(t, out.String(), "Configuration reloaded\n")
+		assert.Contains(t, out.String(), "NAME")
+		assert.Equal(t, 1, controller.reloads)
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runLogs([]string{"-admin-addr", addr, "-lines", "2"}, &out))
+		assert.Equal(t, "second\nthird\n", out.String())
+	})
+
+	t.Run("events", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runEvents([]string{"-admin-addr", addr}, &out))
+		assert.Equal(t, `2026-05-01T12:00:00Z service.started service=api backend=localhost:8080
+2026-05-01T12:01:00Z config.reload_failed plan={"add":["web"]} by=admin_api:alice@example.com error="service web failed to start"
+`, out.String())
+	})
+
+	t.Run("events filtered", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runEvents([]string{"-admin-addr", addr, "-type", "admin,config", "-json"}, &out))
+
+		var e events.Event
+		require.NoError(t, json.Unmarshal(out.Bytes(), &e))
+		assert.Equal(t, events.ConfigReloadFailed, e.Type)
+
+		out.Reset()
+		require.NoError(t, runEvents([]string{"-admin-addr", addr, "-service", "web"}, &out))
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("events rejects negative limit", func(t *testing.T) {
+		err := runEvents([]string{"-admin-addr", addr, "-limit", "-1"}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "-limit must not be negative")
+	})
+
+	t.Run("logs rejects negative lines", func(t *testing.T) {
+		err := runLogs([]string{"-admin-addr", addr, "-lines", "-1"}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "-lines must not be negative")
+	})
+}
+
+func TestAdminCommandsAddress(t *testing.T) {
+	t.Run("address from environment", func(t *testing.T) {
+		addr := startAdminServer(t, &stubController{}, admin.NewLogBuffer(1), nil)
+		t.Setenv(adminAddrEnv, addr)
+
+		var out bytes.Buffer
+		require.NoError(t, runServicesList(nil, &out))
+		assert.Equal(t, "No services running\n", out.String())
+	})
+
+	t.Run("address is required", func(t *testing.T) {
+		t.Setenv(adminAddrEnv, "")
+		err := runStatus(nil, &bytes.Buffer{})
+		assert.EqualError(t, err, "-admin-addr flag or TSBRIDGE_GLOBAL_ADMIN_ADDR is required")
+	})
+
+	t.Run("instance not running", func(t *testing.T) {
+		addr := "unix://" + filepath.Join(t.TempDir(), "missing.sock")
+		err := runStatus([]string{"-admin-addr", addr}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "connecting to admin API")
+	})
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "")
+	timeout := fs.Duration("timeout", 0, "")
+
+	positional, err := parseInterspersed(fs, []string{"api", "-json", "web", "-timeout", "5s"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "web"}, positional)
+	assert.True(t, *jsonOut)
+	assert.Equal(t, 5*time.Second, *timeout)
+}
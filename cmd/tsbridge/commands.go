@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/app"
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/docker"
+	"github.com/knadh/koanf/parsers/toml"
+)
+
+// subcommand is a tool command such as "tsbridge config schema" that runs
+// instead of the proxy
+type subcommand struct {
+	name    string
+	summary string
+	run     func(args []string, stdout io.Writer) error
+}
+
+// subcommands lists the available tool commands. Anything else on the command
+// line is treated as flags for running the proxy.
+var subcommands = []subcommand{
+	{name: "config show", summary: "Print the effective configuration with defaults applied and secrets redacted", run: runConfigShow},
+	{name: "config diff", summary: "Print the changes reloading to the current configuration would make", run: runConfigDiff},
+	{name: "config schema", summary: "Print the configuration JSON Schema or Docker label catalogue", run: runConfigSchema},
+	{name: "status", summary: "Print a summary of the running instance", run: runStatus},
+	{name: "services list", summary: "List the running services and their health", run: runServicesList},
+	{name: "services restart", summary: "Restart a running service", run: runServicesRestart},
+	{name: "reload", summary: "Make the running instance reload its configuration", run: runReload},
+	{name: "logs", summary: "Print the running instance's recent logs", run: runLogs},
+	{name: "events", summary: "Print the running instance's lifecycle events and who triggered them", run: runEvents},
+}
+
+// runSubcommand runs the subcommand named by the leading arguments. It reports
+// false if args do not name a subcommand.
+func runSubcommand(args []string, stdout io.Writer) (bool, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false, nil
+	}
+
+	for _, cmd := range subcommands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return true, cmd.run(args[len(words):], stdout)
+		}
+	}
+
+	return true, fmt.Errorf("unknown command %q, run tsbridge -help for usage", strings.Join(args, " "))
+}
+
+// printSubcommandUsage writes the list of subcommands to w
+func printSubcommandUsage(w io.Writer) {
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range subcommands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// writeJSON writes v to w as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// runConfigSchema prints the JSON Schema for the TOML configuration, or with
+// -docker-labels the catalogue of supported Docker labels
+func runConfigSchema(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge config schema", flag.ContinueOnError)
+	dockerLabels := fs.Bool("docker-labels", false, "Print the Docker label catalogue instead of the JSON Schema")
+	labelPrefix := fs.String("docker-label-prefix", docker.DefaultLabelPrefix, "Docker label prefix used in the label catalogue")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *dockerLabels {
+		return writeJSON(stdout, docker.LabelCatalog(*labelPrefix))
+	}
+	return writeJSON(stdout, config.Schema())
+}
+
+// loadCommandConfig loads the configuration selected by the provider flags. Logs
+// go to stderr so they never mix with the command's output.
+func loadCommandConfig(args *cliArgs) (*config.Config, error) {
+	level := slog.LevelWarn
+	if args.verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if args.provider == "file" && args.configPath == "" {
+		return nil, fmt.Errorf("-config flag is required for file provider")
+	}
+
+	registerProviders()
+	configProvider, err := createProvider(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := configProvider.Load(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// runConfigShow prints the fully processed configuration from any provider
+func runConfigShow(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge config show", flag.ContinueOnError)
+	cli := &cliArgs{}
+	addProviderFlags(fs, cli)
+	format := fs.String("format", "toml", "Output format (toml or json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg, err := loadCommandConfig(cli)
+	if err != nil {
+		return err
+	}
+	settings := cfg.Redacted().Settings()
+
+	switch *format {
+	case "json":
+		return writeJSON(stdout, settings)
+	case "toml":
+		data, err := toml.Parser().Marshal(settings)
+		if err != nil {
+			return fmt.Errorf("encoding configuration: %w", err)
+		}
+		_, err = stdout.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q: must be toml or json", *format)
+	}
+}
+
+// runConfigDiff prints the plan for reloading the running instance, reached
+// through its admin API, to the configuration selected by the provider flags.
+// With -against it compares with a configuration file instead.
+func runConfigDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tsbridge config diff", flag.ContinueOnError)
+	cli := &cliArgs{}
+	addProviderFlags(fs, cli)
+	adminAddr := fs.String("admin-addr", os.Getenv(adminAddrEnv), "Admin API address of the running instance to compare with (defaults to "+adminAddrEnv+")")
+	against := fs.String("against", "", "Compare with this TOML configuration file instead of the running instance")
+	format := fs.String("format", "text", "Output format (text or json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q: must be text or json", *format)
+	}
+
+	newCfg, err := loadCommandConfig(cli)
+	if err != nil {
+		return err
+	}
+	// Both sides are compared as the admin API shows them, with interpolated
+	// values as written
+	newServices := newCfg.Redacted().Services
+
+	var oldServices []config.Service
+	if *against != "" {
+		oldCfg, err := config.Load(*against)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration to compare with: %w", err)
+		}
+		oldServices = oldCfg.Redacted().Services
+	} else {
+		client, err := (&adminFlags{addr: *adminAddr}).client()
+		if err != nil {
+			return fmt.Errorf("%w, or -against to compare with a file", err)
+		}
+		settings, err := client.Config(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to get running configuration: %w", err)
+		}
+		if oldServices, err = config.ServicesFromSettings(settings); err != nil {
+			return fmt.Errorf("failed to read running configuration: %w", err)
+		}
+	}
+
+	plan := app.PlanReload(&config.Config{Services: oldServices}, &config.Config{Services: newServices})
+	if *format == "json" {
+		return writeJSON(stdout, plan)
+	}
+	writeReloadPlan(stdout, plan)
+	return nil
+}
+
+// writeReloadPlan prints a reload plan in a human readable form
+func writeReloadPlan(w io.Writer, plan app.ReloadPlan) {
+	if plan.Empty() {
+		fmt.Fprintln(w, "No service changes")
+		return
+	}
+
+	for _, name := range plan.Remove {
+		fmt.Fprintf(w, "- remove %s\n", name)
+	}
+	for _, name := range plan.Add {
+		fmt.Fprintf(w, "+ add    %s\n", name)
+	}
+	for _, update := range plan.Update {
+		restart := ""
+		if update.RestartsNode {
+			restart = " (node restart)"
+		}
+		fmt.Fprintf(w, "~ update %s%s\n", update.Name, restart)
+		for _, change := range update.Changes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", change.Field, formatSetting(change.Old), formatSetting(change.New))
+		}
+	}
+}
+
+// formatSetting formats a setting value for writeReloadPlan
+func formatSetting(v any) string {
+	if v == nil {
+		return "(unset)"
+	}
+	return fmt.Sprintf("%v", v)
+}
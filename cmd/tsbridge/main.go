@@ -13,10 +13,13 @@ import (
 
 	"log/slog"
 
+	"github.com/jtdowney/tsbridge/internal/admin"
 	"github.com/jtdowney/tsbridge/internal/app"
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	"github.com/jtdowney/tsbridge/internal/docker"
+	"github.com/jtdowney/tsbridge/internal/logging"
+	"github.com/jtdowney/tsbridge/internal/systemd"
 )
 
 var version = "dev"
@@ -60,12 +63,7 @@ func parseCLIArgs(args []string) (*cliArgs, error) {
 	fs := flag.NewFlagSet("tsbridge", flag.ContinueOnError)
 
 	result := &cliArgs{}
-	fs.StringVar(&result.configPath, "config", "", "Path to TOML configuration file (required for file provider)")
-	fs.StringVar(&result.provider, "provider", "file", "Configuration provider (file or docker)")
-	fs.StringVar(&result.dockerEndpoint, "docker-socket", "", "Docker socket endpoint (falls back to DOCKER_HOST env var, then unix:///var/run/docker.sock)")
-	fs.StringVar(&result.labelPrefix, "docker-label-prefix", "tsbridge", "Docker label prefix for configuration")
-	fs.DurationVar(&result.dockerPollInterval, "docker-poll-interval", constants.DockerPollInterval, "Docker config poll interval (0 to disable)")
-	fs.BoolVar(&result.verbose, "verbose", false, "Enable debug logging")
+	addProviderFlags(fs, result)
 	fs.BoolVar(&result.help, "help", false, "Show usage information")
 	fs.BoolVar(&result.help, "h", false, "Show usage information")
 	fs.BoolVar(&result.version, "version", false, "Show version information")
@@ -75,6 +73,7 @@ func parseCLIArgs(args []string) (*cliArgs, error) {
 	usage := func() {
 		fmt.Fprintf(os.Stdout, "Usage of %s:\n", fs.Name())
 		fs.PrintDefaults()
+		printSubcommandUsage(os.Stdout)
 	}
 	fs.Usage = usage
 
@@ -93,21 +92,50 @@ func parseCLIArgs(args []string) (*cliArgs, error) {
 	return result, nil
 }
 
-// setupLogging configures the global logger based on the verbose flag
+// addProviderFlags registers the flags that select and configure the configuration
+// provider, shared by the proxy and the config subcommands
+func addProviderFlags(fs *flag.FlagSet, args *cliArgs) {
+	fs.StringVar(&args.configPath, "config", "", "Path to TOML configuration file (required for file provider)")
+	fs.StringVar(&args.provider, "provider", "file", "Configuration provider (file or docker)")
+	fs.StringVar(&args.dockerEndpoint, "docker-socket", "", "Docker socket endpoint (falls back to DOCKER_HOST env var, then unix:///var/run/docker.sock)")
+	fs.StringVar(&args.labelPrefix, "docker-label-prefix", "tsbridge", "Docker label prefix for configuration")
+	fs.DurationVar(&args.dockerPollInterval, "docker-poll-interval", constants.DockerPollInterval, "Docker config poll interval (0 to disable)")
+	fs.BoolVar(&args.verbose, "verbose", false, "Enable debug logging")
+}
+
+// logHandler is the root log handler, reconfigured from the loaded configuration
+var logHandler *logging.Handler
+
+// setupLogging configures the global logger based on the verbose flag. The
+// configuration's logging settings are applied later by configureLogging.
 func setupLogging(verbose bool) {
 	loggingOnce.Do(func() {
-		opts := &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		}
-		if verbose {
-			opts.Level = slog.LevelDebug
-		}
-		handler := slog.NewTextHandler(os.Stdout, opts)
-		logger := slog.New(handler)
-		slog.SetDefault(logger)
+		// Logs are also kept for `tsbridge logs` through the admin API. The
+		// default text format on stdout cannot fail to configure.
+		logHandler, _ = logging.NewHandler(loggingOptions(config.Global{}, verbose))
+		slog.SetDefault(slog.New(logHandler))
 	})
 }
 
+// loggingOptions returns the logging settings of g, with debug logging forced
+// on by the verbose flag
+func loggingOptions(g config.Global, verbose bool) logging.Options {
+	opts := g.LoggingOptions()
+	opts.Tee = admin.DefaultLogBuffer
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+	return opts
+}
+
+// configureLogging returns the function the application uses to apply the
+// logging settings of each loaded configuration
+func configureLogging(verbose bool) func(config.Global) error {
+	return func(g config.Global) error {
+		return logHandler.Configure(loggingOptions(g, verbose))
+	}
+}
+
 // setupCommon configures logging and validates provider-specific flags
 func setupCommon(args *cliArgs) error {
 	// Configure logging
@@ -172,6 +200,7 @@ func validateConfig(args *cliArgs) error {
 type Application interface {
 	Start(ctx context.Context) error
 	Shutdown(ctx context.Context) error
+	ShutdownTimeout() time.Duration
 }
 
 // Allow replacing the app factory for tests.
@@ -217,7 +246,10 @@ func run(args *cliArgs, sigCh <-chan os.Signal) error {
 	// Create the application with the provider
 	slog.Debug("creating application")
 	application, err := newApp(nil, app.Options{
-		Provider: configProvider,
+		Provider:         configProvider,
+		Version:          version,
+		Notifier:         systemd.NewNotifierFromEnv(),
+		ConfigureLogging: configureLogging(args.verbose),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create application: %w", err)
@@ -242,8 +274,8 @@ func run(args *cliArgs, sigCh <-chan os.Signal) error {
 		return err
 	}
 
-	// Create shutdown context with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), constants.DefaultShutdownTimeout)
+	// Create shutdown context with the configured timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), application.ShutdownTimeout())
 	defer cancel()
 
 	// Call shutdown
@@ -255,6 +287,17 @@ func run(args *cliArgs, sigCh <-chan os.Signal) error {
 }
 
 func main() {
+	// Tool subcommands such as "config schema" run instead of the proxy
+	if handled, err := runSubcommand(os.Args[1:], os.Stdout); handled {
+		if err != nil && err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "tsbridge: %v\n", err)
+			exitFunc(1)
+			return
+		}
+		exitFunc(0)
+		return
+	}
+
 	args, err := parseCLIArgs(os.Args[1:])
 	if err != nil {
 		// Check if this is a help request
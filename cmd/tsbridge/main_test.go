@@ -1233,12 +1233,14 @@ func TestMainDockerProvider(t *testing.T) {
 
 // mockApp is a test implementation that allows simulating errors
 type mockApp struct {
-	mu          sync.Mutex
-	startErr    error
-	shutdownErr error
-	started     bool
-	shutdown    bool
-	startDelay  time.Duration // Add delay to simulate blocking Start()
+	mu               sync.Mutex
+	startErr         error
+	shutdownErr      error
+	started          bool
+	shutdown         bool
+	startDelay       time.Duration // Add delay to simulate blocking Start()
+	shutdownTimeout  time.Duration
+	shutdownDeadline time.Time // Deadline of the context Shutdown was given
 }
 
 func (m *mockApp) Start(ctx context.Context) error {
@@ -1255,11 +1257,16 @@ func (m *mockApp) Start(ctx context.Context) error {
 func (m *mockApp) Shutdown(ctx context.Context) error {
 	m.mu.Lock()
 	m.shutdown = true
+	m.shutdownDeadline, _ = ctx.Deadline()
 	m.mu.Unlock()
 
 	return m.shutdownErr
 }
 
+func (m *mockApp) ShutdownTimeout() time.Duration {
+	return m.shutdownTimeout
+}
+
 func (m *mockApp) isStarted() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -1287,6 +1294,10 @@ func (m *trackingMockApp) Start(ctx context.Context) error {
 	return nil
 }
 
+func (m *trackingMockApp) ShutdownTimeout() time.Duration {
+	return time.Second
+}
+
 func (m *trackingMockApp) Shutdown(ctx context.Context) error {
 	m.mu.Lock()
 	m.shutdown = true
@@ -1399,6 +1410,38 @@ backend_addr = "localhost:8080"
 	}
 }
 
+// TestRunUsesConfiguredShutdownTimeout tests that shutdown is given the
+// application's shutdown_timeout
+func TestRunUsesConfiguredShutdownTimeout(t *testing.T) {
+	oldNewApp := newApp
+	defer func() { newApp = oldNewApp }()
+
+	mockApplication := &mockApp{shutdownTimeout: 42 * time.Second}
+	newApp = func(c *config.Config, opts app.Options) (Application, error) {
+		return mockApplication, nil
+	}
+
+	configPath := filepath.Join(t.TempDir(), "test.toml")
+	configContent := `
+[tailscale]
+auth_key = "test-auth-key"
+[[services]]
+name = "test-service"
+backend_addr = "localhost:8080"
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	sigCh := make(chan os.Signal, 1)
+	sigCh <- syscall.SIGTERM
+	signalled := time.Now()
+	require.NoError(t, run(&cliArgs{provider: "file", configPath: configPath}, sigCh))
+
+	assert.True(t, mockApplication.isShutdown())
+	mockApplication.mu.Lock()
+	defer mockApplication.mu.Unlock()
+	assert.WithinDuration(t, signalled.Add(42*time.Second), mockApplication.shutdownDeadline, 5*time.Second)
+}
+
 // TestMultipleSignals tests handling of multiple signals sent in quick succession
 func TestMultipleSignals(t *testing.T) {
 	// Save and restore the original newApp function
@@ -1781,6 +1824,36 @@ func TestSetupLoggingOnce(t *testing.T) {
 	slog.SetDefault(currentLogger)
 }
 
+// TestConfigureLogging tests applying the logging settings of a configuration
+func TestConfigureLogging(t *testing.T) {
+	oldLoggingOnce := loggingOnce
+	oldLogger := slog.Default()
+	defer func() {
+		loggingOnce = oldLoggingOnce
+		slog.SetDefault(oldLogger)
+	}()
+	loggingOnce = &sync.Once{}
+	setupLogging(true)
+
+	path := filepath.Join(t.TempDir(), "tsbridge.log")
+	err := configureLogging(true)(config.Global{
+		LogFormat: "json",
+		LogLevel:  "warn",
+		LogLevels: map[string]string{"tsnet": "error"},
+		LogFile:   path,
+	})
+	require.NoError(t, err)
+	defer logHandler.Close()
+
+	slog.Debug("debug message")
+	slog.With("component", "tsnet").Warn("tsnet warning")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"debug message"`, "verbose forces debug logging")
+	assert.NotContains(t, string(data), "tsnet warning", "component levels still apply with verbose")
+}
+
 // TestCreateProvider tests the createProvider function
 func TestCreateProvider(t *testing.T) {
 	// Save original registry
@@ -0,0 +1,181 @@
+// Package accesslog writes a dedicated access log of proxied requests, separate
+// from tsbridge's own logs, in JSON, Apache combined or a custom template
+// format to a file, stdout, stderr or syslog.
+package accesslog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/logging"
+)
+
+// Entry is a single request written to the access log. It is also the data of
+// access_log_template templates, e.g. {{.Method}} {{.Path}} {{.Status}}.
+type Entry struct {
+	Time            time.Time     // When the request started
+	Service         string        // Service that handled the request
+	Method          string        // Request method
+	Host            string        // Host the request was sent to
+	Path            string        // Request path
+	Query           string        // Raw query string, without the leading "?"
+	Proto           string        // Protocol, e.g. HTTP/1.1
+	Status          int           // Response status code
+	BytesIn         int64         // Request body bytes read
+	BytesOut        int64         // Response body bytes written
+	Duration        time.Duration // Time to serve the request
+	UpstreamLatency time.Duration // Time until the backend's response headers, 0 if the backend was not reached
+	RemoteAddr      string        // Address of the connection the request arrived on
+	RequestID       string        // X-Request-ID of the request
+	UserAgent       string        // User-Agent header
+	Referer         string        // Referer header
+	UserLogin       string        // Tailnet login name of the caller, from a whois lookup
+	FunnelSource    string        // Public address of a caller that connected through Funnel
+	TLSVersion      string        // TLS version of the connection, empty without TLS
+}
+
+// ClientIP returns the IP address of the caller: the Funnel source for Funnel
+// requests and the connection's address otherwise
+func (e Entry) ClientIP() string {
+	addr := e.RemoteAddr
+	if e.FunnelSource != "" {
+		addr = e.FunnelSource
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// Options configures a Logger
+type Options struct {
+	Format      string   // "json" (default), "combined" or "template"
+	Template    string   // text/template executed for each entry with the template format
+	Output      string   // "stdout", "stderr", "syslog", "syslog://host:port", "syslog+tcp://host:port" or a file path
+	MaxSize     int64    // Size an output file is rotated at, 0 to never rotate
+	MaxFiles    int      // Rotated output files kept
+	Statuses    []string // Statuses to log, as codes like 404 or classes like 5xx (default: all)
+	SampleRatio *float64 // Fraction of matching requests to log (default: 1)
+}
+
+// Logger writes access log entries to its output. It is safe for concurrent use.
+type Logger struct {
+	out      io.Writer
+	closer   io.Closer // Closes out, nil for stdout and stderr
+	format   formatter
+	statuses StatusFilter
+	ratio    float64
+	buffers  sync.Pool
+}
+
+// New creates a Logger that writes to the output in opts
+func New(opts Options) (*Logger, error) {
+	format, err := newFormatter(opts.Format, opts.Template)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := ParseStatusFilter(opts.Statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	out, closer, err := openOutput(opts.Output, opts.MaxSize, opts.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Logger{
+		out:      out,
+		closer:   closer,
+		format:   format,
+		statuses: statuses,
+		ratio:    1,
+		buffers:  sync.Pool{New: func() any { return new(bytes.Buffer) }},
+	}
+	if opts.SampleRatio != nil {
+		l.ratio = *opts.SampleRatio
+	}
+	return l, nil
+}
+
+// Log writes e if its status passes the status filter and it is sampled
+func (l *Logger) Log(e Entry) {
+	if !l.statuses.Match(e.Status) {
+		return
+	}
+	if l.ratio < 1 && rand.Float64() >= l.ratio {
+		return
+	}
+
+	buf := l.buffers.Get().(*bytes.Buffer)
+	defer l.buffers.Put(buf)
+	buf.Reset()
+
+	if err := l.format(buf, e); err != nil {
+		logging.Logger(logging.ComponentProxy).Warn("failed to format access log entry", "service", e.Service, "error", err)
+		return
+	}
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	// A single write keeps lines from concurrent requests whole
+	if _, err := l.out.Write(buf.Bytes()); err != nil {
+		logging.Logger(logging.ComponentProxy).Warn("failed to write access log entry", "service", e.Service, "error", err)
+	}
+}
+
+// Close closes the output, unless it is stdout or stderr
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// statusRange is an inclusive range of status codes
+type statusRange struct {
+	low, high int
+}
+
+// StatusFilter matches response statuses against codes such as 404 and
+// classes such as 4xx. An empty filter matches every status.
+type StatusFilter []statusRange
+
+// ParseStatusFilter parses status codes and classes, e.g. ["4xx", "5xx", "302"]
+func ParseStatusFilter(specs []string) (StatusFilter, error) {
+	var filter StatusFilter
+	for _, spec := range specs {
+		spec = strings.ToLower(strings.TrimSpace(spec))
+		if class, ok := strings.CutSuffix(spec, "xx"); ok && len(class) == 1 && class[0] >= '1' && class[0] <= '5' {
+			low := int(class[0]-'0') * 100
+			filter = append(filter, statusRange{low: low, high: low + 99})
+			continue
+		}
+		code, err := strconv.Atoi(spec)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status %q, must be a code like 404 or a class like 5xx", spec)
+		}
+		filter = append(filter, statusRange{low: code, high: code})
+	}
+	return filter, nil
+}
+
+// Match reports whether status passes the filter
+func (f StatusFilter) Match(status int) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, r := range f {
+		if status >= r.low && status <= r.high {
+			return true
+		}
+	}
+	return false
+}
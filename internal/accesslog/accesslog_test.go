@@ -0,0 +1,177 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEntry returns an entry with every field set
+func testEntry() Entry {
+	return Entry{
+		Time:            time.Date(2026, 10, 10, 13, 55, 36, 0, time.FixedZone("PDT", -7*3600)),
+		Service:         "api",
+		Method:          "GET",
+		Host:            "api.example.ts.net",
+		Path:            "/users",
+		Query:           "page=2",
+		Proto:           "HTTP/1.1",
+		Status:          200,
+		BytesIn:         12,
+		BytesOut:        2326,
+		Duration:        1500 * time.Microsecond,
+		UpstreamLatency: 1200 * time.Microsecond,
+		RemoteAddr:      "100.64.0.2:51234",
+		RequestID:       "req-1",
+		UserAgent:       "curl/8.0",
+		Referer:         "https://example.com/",
+		UserLogin:       "alice@example.com",
+		TLSVersion:      "TLS 1.3",
+	}
+}
+
+// readLog opens a Logger writing to a temporary file, logs entries and
+// returns what was written
+func readLog(t *testing.T, opts Options, entries ...Entry) string {
+	t.Helper()
+	opts.Output = filepath.Join(t.TempDir(), "access.log")
+	logger, err := New(opts)
+	require.NoError(t, err)
+	for _, e := range entries {
+		logger.Log(e)
+	}
+	require.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(opts.Output)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestFormats(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		out := readLog(t, Options{}, testEntry())
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(out), &record))
+		assert.Equal(t, "2026-10-10T20:55:36Z", record["time"])
+		assert.Equal(t, "api", record["service"])
+		assert.Equal(t, "page=2", record["query"])
+		assert.Equal(t, 200.0, record["status"])
+		assert.Equal(t, 12.0, record["bytes_in"])
+		assert.Equal(t, 2326.0, record["bytes_out"])
+		assert.Equal(t, 1.5, record["duration_ms"])
+		assert.Equal(t, 1.2, record["upstream_ms"])
+		assert.Equal(t, "alice@example.com", record["user_login"])
+		assert.Equal(t, "TLS 1.3", record["tls_version"])
+		assert.NotContains(t, record, "funnel_source")
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		out := readLog(t, Options{Format: constants.AccessLogFormatCombined}, testEntry())
+		assert.Equal(t, `100.64.0.2 - alice@example.com [10/Oct/2026:13:55:36 -0700] "GET /users?page=2 HTTP/1.1" 200 2326 "https://example.com/" "curl/8.0"`+"\n", out)
+	})
+
+	t.Run("combined with funnel and empty fields", func(t *testing.T) {
+		e := Entry{Time: testEntry().Time, Method: "POST", Path: "/", Proto: "HTTP/2.0", Status: 204,
+			RemoteAddr: "100.64.0.9:443", FunnelSource: "203.0.113.7:41000"}
+		out := readLog(t, Options{Format: constants.AccessLogFormatCombined}, e)
+		assert.Equal(t, `203.0.113.7 - - [10/Oct/2026:13:55:36 -0700] "POST / HTTP/2.0" 204 - "-" "-"`+"\n", out)
+	})
+
+	t.Run("template", func(t *testing.T) {
+		out := readLog(t, Options{
+			Format:   constants.AccessLogFormatTemplate,
+			Template: "{{.Service}} {{.Method}} {{.Path}} {{.Status}} {{.ClientIP}} {{.UpstreamLatency}}",
+		}, testEntry())
+		assert.Equal(t, "api GET /users 200 100.64.0.2 1.2ms\n", out)
+	})
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{name: "unknown format", opts: Options{Format: "xml"}, wantErr: `unsupported access log format "xml"`},
+		{name: "bad template", opts: Options{Format: constants.AccessLogFormatTemplate, Template: "{{.Path"}, wantErr: "invalid access log template"},
+		{name: "unknown field", opts: Options{Format: constants.AccessLogFormatTemplate, Template: "{{.Bogus}}"}, wantErr: "invalid access log template"},
+		{name: "bad status", opts: Options{Statuses: []string{"abc"}}, wantErr: `invalid status "abc"`},
+		{name: "bad syslog url", opts: Options{Output: "syslog+tcp://logs"}, wantErr: "syslog access log output must be"},
+		{name: "unwritable file", opts: Options{Output: filepath.Join(t.TempDir(), "missing", "access.log")}, wantErr: "failed to open access log"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestStatusFilter(t *testing.T) {
+	filter, err := ParseStatusFilter([]string{"4xx", " 5XX ", "302"})
+	require.NoError(t, err)
+
+	for status, want := range map[int]bool{200: false, 301: false, 302: true, 404: true, 499: true, 503: true} {
+		assert.Equal(t, want, filter.Match(status), "status %d", status)
+	}
+	assert.True(t, StatusFilter(nil).Match(200), "an empty filter matches everything")
+
+	for _, spec := range []string{"6xx", "0xx", "99", "600", "4x"} {
+		_, err := ParseStatusFilter([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFilteringAndSampling(t *testing.T) {
+	ok, notFound, failed := testEntry(), testEntry(), testEntry()
+	notFound.Status, failed.Status = 404, 502
+
+	t.Run("status", func(t *testing.T) {
+		out := readLog(t, Options{Format: constants.AccessLogFormatTemplate, Template: "{{.Status}}", Statuses: []string{"4xx", "5xx"}}, ok, notFound, failed)
+		assert.Equal(t, "404\n502\n", out)
+	})
+
+	t.Run("sample none", func(t *testing.T) {
+		out := readLog(t, Options{SampleRatio: new(0.0)}, ok, notFound, failed)
+		assert.Empty(t, out)
+	})
+
+	t.Run("sample all", func(t *testing.T) {
+		out := readLog(t, Options{SampleRatio: new(1.0)}, ok, notFound, failed)
+		assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 3)
+	})
+}
+
+func TestSyslogOutput(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	logger, err := New(Options{
+		Format:   constants.AccessLogFormatTemplate,
+		Template: "{{.Method}} {{.Path}} {{.Status}}",
+		Output:   "syslog://" + conn.LocalAddr().String(),
+	})
+	require.NoError(t, err)
+	defer logger.Close()
+
+	logger.Log(testEntry())
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	assert.Contains(t, msg, constants.AccessLogSyslogTag+"[")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(msg), "GET /users 200"), msg)
+}
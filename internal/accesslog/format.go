@@ -0,0 +1,147 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// combinedTimeFormat is the timestamp format of the Apache combined log format
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// formatter writes an entry to buf
+type formatter func(buf *bytes.Buffer, e Entry) error
+
+// newFormatter returns the formatter for format
+func newFormatter(format, text string) (formatter, error) {
+	switch format {
+	case "", constants.AccessLogFormatJSON:
+		return formatJSON, nil
+	case constants.AccessLogFormatCombined:
+		return formatCombined, nil
+	case constants.AccessLogFormatTemplate:
+		tmpl, err := ParseTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		return func(buf *bytes.Buffer, e Entry) error {
+			return tmpl.Execute(buf, e)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported access log format %q", format)
+	}
+}
+
+// ParseTemplate parses an access log template, which is executed with an Entry
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, fmt.Errorf("access log template is empty")
+	}
+	tmpl, err := template.New("access_log").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access log template: %w", err)
+	}
+	// Execute once so references to fields that do not exist are reported now
+	// rather than on every request
+	if err := tmpl.Execute(&bytes.Buffer{}, Entry{}); err != nil {
+		return nil, fmt.Errorf("invalid access log template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// jsonEntry is the JSON encoding of an Entry
+type jsonEntry struct {
+	Time         string  `json:"time"`
+	Service      string  `json:"service"`
+	Method       string  `json:"method"`
+	Host         string  `json:"host,omitempty"`
+	Path         string  `json:"path"`
+	Query        string  `json:"query,omitempty"`
+	Proto        string  `json:"proto"`
+	Status       int     `json:"status"`
+	BytesIn      int64   `json:"bytes_in"`
+	BytesOut     int64   `json:"bytes_out"`
+	DurationMS   float64 `json:"duration_ms"`
+	UpstreamMS   float64 `json:"upstream_ms,omitempty"`
+	RemoteAddr   string  `json:"remote_addr"`
+	RequestID    string  `json:"request_id,omitempty"`
+	UserAgent    string  `json:"user_agent,omitempty"`
+	Referer      string  `json:"referer,omitempty"`
+	UserLogin    string  `json:"user_login,omitempty"`
+	FunnelSource string  `json:"funnel_source,omitempty"`
+	TLSVersion   string  `json:"tls_version,omitempty"`
+}
+
+// formatJSON writes e as a single line JSON object
+func formatJSON(buf *bytes.Buffer, e Entry) error {
+	return json.NewEncoder(buf).Encode(jsonEntry{
+		Time:         e.Time.UTC().Format(time.RFC3339Nano),
+		Service:      e.Service,
+		Method:       e.Method,
+		Host:         e.Host,
+		Path:         e.Path,
+		Query:        e.Query,
+		Proto:        e.Proto,
+		Status:       e.Status,
+		BytesIn:      e.BytesIn,
+		BytesOut:     e.BytesOut,
+		DurationMS:   milliseconds(e.Duration),
+		UpstreamMS:   milliseconds(e.UpstreamLatency),
+		RemoteAddr:   e.RemoteAddr,
+		RequestID:    e.RequestID,
+		UserAgent:    e.UserAgent,
+		Referer:      e.Referer,
+		UserLogin:    e.UserLogin,
+		FunnelSource: e.FunnelSource,
+		TLSVersion:   e.TLSVersion,
+	})
+}
+
+// formatCombined writes e in the Apache combined log format, with the whois
+// login as the user
+func formatCombined(buf *bytes.Buffer, e Entry) error {
+	uri := e.Path
+	if e.Query != "" {
+		uri += "?" + e.Query
+	}
+
+	buf.WriteString(orDash(e.ClientIP()))
+	buf.WriteString(" - ")
+	buf.WriteString(orDash(e.UserLogin))
+	buf.WriteString(" [")
+	buf.WriteString(e.Time.Format(combinedTimeFormat))
+	buf.WriteString("] ")
+	buf.WriteString(strconv.Quote(e.Method + " " + uri + " " + e.Proto))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(e.Status))
+	buf.WriteByte(' ')
+	if e.BytesOut > 0 {
+		buf.WriteString(strconv.FormatInt(e.BytesOut, 10))
+	} else {
+		buf.WriteByte('-')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Quote(orDash(e.Referer)))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Quote(orDash(e.UserAgent)))
+	buf.WriteByte('\n')
+	return nil
+}
+
+// orDash returns s, or "-" if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// milliseconds returns d in fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
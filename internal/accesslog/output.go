@@ -0,0 +1,76 @@
+package accesslog
+
+import (
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/logging"
+)
+
+// syslogNetworks maps syslog URL schemes to the network they dial
+var syslogNetworks = map[string]string{
+	"syslog":     "udp",
+	"syslog+udp": "udp",
+	"syslog+tcp": "tcp",
+}
+
+// IsSyslogOutput reports whether output sends the access log to syslog
+func IsSyslogOutput(output string) bool {
+	return output == constants.AccessLogOutputSyslog || strings.HasPrefix(output, "syslog:") || strings.HasPrefix(output, "syslog+")
+}
+
+// openOutput opens the writer an output setting names, returning the closer
+// to call on shutdown, which is nil for stdout and stderr
+func openOutput(output string, maxSize int64, maxFiles int) (io.Writer, io.Closer, error) {
+	switch {
+	case output == "" || output == constants.AccessLogOutputStdout:
+		return os.Stdout, nil, nil
+	case output == constants.AccessLogOutputStderr:
+		return os.Stderr, nil, nil
+	case IsSyslogOutput(output):
+		w, err := dialSyslog(output)
+		if err != nil {
+			return nil, nil, err
+		}
+		return w, w, nil
+	default:
+		f, err := logging.OpenFile(output, maxSize, maxFiles)
+		if err != nil {
+			return nil, nil, tserrors.WrapResource(err, "failed to open access log")
+		}
+		return f, f, nil
+	}
+}
+
+// dialSyslog connects to the local syslog daemon for "syslog", or to the
+// remote daemon in a syslog://host:port or syslog+tcp://host:port URL
+func dialSyslog(output string) (*syslog.Writer, error) {
+	network, addr := "", ""
+	if output != constants.AccessLogOutputSyslog {
+		u, err := ParseSyslogURL(output)
+		if err != nil {
+			return nil, err
+		}
+		network, addr = syslogNetworks[u.Scheme], u.Host
+	}
+
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, constants.AccessLogSyslogTag)
+	if err != nil {
+		return nil, tserrors.WrapResource(err, "failed to connect to syslog")
+	}
+	return w, nil
+}
+
+// ParseSyslogURL parses a remote syslog output such as syslog://host:514
+func ParseSyslogURL(output string) (*url.URL, error) {
+	u, err := url.Parse(output)
+	if err != nil || syslogNetworks[u.Scheme] == "" || u.Host == "" || u.Port() == "" {
+		return nil, tserrors.NewConfigError("syslog access log output must be syslog, syslog://host:port or syslog+tcp://host:port, got " + output)
+	}
+	return u, nil
+}
@@ -0,0 +1,376 @@
+// Package admin serves the HTTP API and web dashboard for inspecting and controlling a running tsbridge.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/middleware"
+	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+)
+
+// Controller is the running tsbridge instance the admin API inspects and
+// controls. The contexts of changes carry an events.Trigger naming the caller.
+type Controller interface {
+	Services(ctx context.Context) []service.Status
+	Service(ctx context.Context, name string) (service.Status, error)
+	Settings() map[string]any // Redacted effective configuration
+	Reload(ctx context.Context) error
+	LastReload() *ReloadStatus // Outcome of the last reload, nil before the first
+	RestartService(ctx context.Context, name string) error
+	DrainService(ctx context.Context, name string, drain bool) error
+	RemoveService(ctx context.Context, name string) error
+	SetAccessLog(ctx context.Context, name string, enabled bool) error
+}
+
+// Options configures an admin Server
+type Options struct {
+	Addr           string         // admin_addr value
+	Allowed        []string       // Login names and tags allowed when served on the tailnet
+	Tags           []string       // Tags for the tailnet node
+	StartupTimeout *time.Duration // Max time for the tailnet node to start
+	Version        string         // tsbridge version reported by /v1/status
+	Logs           *LogBuffer     // Log lines served by /v1/logs (default: DefaultLogBuffer)
+	Events         *events.Bus    // Lifecycle events served by /v1/events (default: none)
+}
+
+// Server serves the admin API on a unix socket, a loopback address or a
+// dedicated tailnet node
+type Server struct {
+	opts       Options
+	controller Controller
+	tsServer   *tailscale.Server
+	server     *http.Server
+	listener   net.Listener
+	nodeName   string // Name of the tailnet node when served on the tailnet
+	startedAt  time.Time
+	newConns   map[net.Conn]struct{} // Connections that have not sent a request yet
+	closing    bool                  // Shutdown was called
+	mu         sync.RWMutex
+}
+
+// NewServer creates an admin server. The tailscale server is only used when the
+// API is served on the tailnet.
+func NewServer(opts Options, controller Controller, tsServer *tailscale.Server) *Server {
+	if opts.Logs == nil {
+		opts.Logs = DefaultLogBuffer
+	}
+	if opts.Events == nil {
+		opts.Events = events.NewBus(0)
+	}
+	return &Server{
+		opts:       opts,
+		controller: controller,
+		tsServer:   tsServer,
+	}
+}
+
+// Start starts listening and serving the admin API in the background
+func (s *Server) Start(ctx context.Context) error {
+	network, address, err := config.ParseAdminAddr(s.opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	handler := recordCaller(s.routes())
+	var listener net.Listener
+	switch network {
+	case config.AdminNetworkUnix:
+		listener, err = listenUnix(address)
+	case config.AdminNetworkTailnet:
+		var whois middleware.WhoisClient
+		listener, whois, err = s.listenTailnet(address)
+		if err == nil {
+			handler = authorize(whois, "admin_allowed", s.opts.Allowed, handler)
+		}
+	default:
+		listener, err = net.Listen("tcp", address)
+		if err == nil {
+			handler = guardLoopback([]string{address, listener.Addr().String()}, handler)
+		}
+	}
+	if err != nil {
+		return tserrors.WrapResource(err, fmt.Sprintf("failed to listen on %s", s.opts.Addr))
+	}
+
+	// Cancel request contexts on shutdown so log followers disconnect
+	baseCtx, cancel := context.WithCancel(context.Background())
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: constants.DefaultReadHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		ConnState:         s.trackConn,
+	}
+	server.RegisterOnShutdown(cancel)
+
+	s.mu.Lock()
+	s.listener = listener
+	s.server = server
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("admin server error", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// chmod is a variable to allow inspecting the socket as its mode is set in tests
+var chmod = os.Chmod
+
+// listenUnix listens on a unix socket only the current user can connect to,
+// replacing a socket left behind by a previous run. The socket is bound in a
+// private directory and only linked to path once its mode is set, so it is
+// never reachable with the permissions the umask would give it.
+func listenUnix(path string) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".tsbridge")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	bound := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", bound)
+	if err != nil {
+		return nil, err
+	}
+	// The bound name is gone once dir is removed; unixListener removes path instead
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := chmod(bound, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	// Unlike a rename, linking fails rather than replace a socket that another
+	// process created at path in the meantime
+	if err := os.Link(bound, path); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: listener, path: path}, nil
+}
+
+// removeStaleSocket removes the unix socket at path if nothing accepts
+// connections on it, and fails if another process is still listening there
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("another process is listening on %s", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("checking existing socket %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// unixListener is a listener on a unix socket linked to path, which it removes
+// when closed
+type unixListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	// Closing again must not remove a socket a later run created at path
+	l.once.Do(func() { _ = os.Remove(l.path) })
+	return err
+}
+
+// listenTailnet brings up a dedicated tailnet node for the admin API and returns
+// a listener on it along with a whois client for authorizing callers
+func (s *Server) listenTailnet(address string) (net.Listener, middleware.WhoisClient, error) {
+	if s.tsServer == nil {
+		return nil, nil, tserrors.NewInternalError("serving the admin API on the tailnet requires a tailscale server")
+	}
+
+	hostname, port, _ := net.SplitHostPort(address)
+	node := config.Service{
+		Name:           hostname,
+		ListenAddr:     ":" + port,
+		Tags:           s.opts.Tags,
+		StartupTimeout: s.opts.StartupTimeout,
+	}
+	listener, err := s.tsServer.Listen(node, constants.TLSModeOff, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	s.nodeName = hostname
+	s.mu.Unlock()
+	return listener, tailscale.NewWhoisClientAdapter(s.tsServer.GetServiceServer(hostname)), nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// trackConn closes connections that have not sent a request once the server
+// is shutting down, as http.Server.Shutdown waits seconds before counting them
+// as idle
+func (s *Server) trackConn(conn net.Conn, state http.ConnState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case state == http.StateNew && s.closing:
+		_ = conn.Close()
+	case state == http.StateNew:
+		if s.newConns == nil {
+			s.newConns = make(map[net.Conn]struct{})
+		}
+		s.newConns[conn] = struct{}{}
+	default:
+		delete(s.newConns, conn)
+	}
+}
+
+// Shutdown gracefully shuts down the admin server and its tailnet node, if any
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	server, nodeName := s.server, s.nodeName
+	s.closing = true
+	for conn := range s.newConns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	err := server.Shutdown(ctx)
+	if nodeName != "" {
+		err = errors.Join(err, s.tsServer.CloseService(nodeName))
+	}
+	return err
+}
+
+// authorize only lets through callers whose tailnet login name or node tags are
+// in the allowed list, recording the matching identity as the trigger of their
+// changes. setting names the option holding the list, for errors.
+func authorize(whois middleware.WhoisClient, setting string, allowed []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		who, err := whois.WhoIs(r.Context(), r.RemoteAddr)
+		if err != nil || who == nil {
+			slog.Warn("tailnet whois lookup failed", "setting", setting, "remote_addr", r.RemoteAddr, "error", err)
+			writeError(w, http.StatusForbidden, errors.New("unable to identify caller"))
+			return
+		}
+
+		var identities []string
+		if who.UserProfile != nil {
+			identities = append(identities, who.UserProfile.LoginName)
+		}
+		if who.Node != nil {
+			identities = append(identities, who.Node.Tags...)
+		}
+		for _, identity := range identities {
+			if slices.Contains(allowed, identity) {
+				ctx := events.WithTrigger(r.Context(), events.Trigger{Source: events.SourceAdminAPI, Actor: identity})
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		slog.Warn("tailnet request denied", "setting", setting, "remote_addr", r.RemoteAddr, "identities", identities)
+		writeError(w, http.StatusForbidden, fmt.Errorf("caller is not in %s", setting))
+	})
+}
+
+// guardLoopback keeps web pages from using an admin API on a loopback address,
+// which any browser on the machine can reach. Every request must be addressed
+// to one of hosts rather than a name rebound to the loopback address, so pages
+// cannot read configuration, logs or events through DNS rebinding. Requests
+// that change anything must also not carry an Origin and must carry
+// constants.AdminClientHeader.
+func guardLoopback(hosts []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reading := r.Method == http.MethodGet || r.Method == http.MethodHead
+		var err error
+		switch {
+		case !slices.Contains(hosts, r.Host):
+			err = fmt.Errorf("requests must be addressed to %s", hosts[0])
+		case reading:
+			// Reading needs no more than the address, which pages cannot
+			// read responses from without rebinding a name to it
+		case r.Header.Get("Origin") != "":
+			err = errors.New("requests from web pages are not allowed")
+		case r.Header.Get(constants.AdminClientHeader) == "":
+			err = fmt.Errorf("requests must carry the %s header", constants.AdminClientHeader)
+		}
+		if err != nil {
+			slog.Warn("admin request denied", "method", r.Method, "path", r.URL.Path, "host", r.Host, "error", err)
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write admin response", "error", err)
+	}
+}
+
+// errorResponse is the body of every admin API error
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes err as a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// errorStatus maps a controller error to an HTTP status code
+func errorStatus(err error) int {
+	if errors.Is(err, service.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return tserrors.HTTPStatus(err)
+}
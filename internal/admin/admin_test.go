@@ -0,0 +1,672 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+// fakeController records admin actions against an in-memory set of services
+type fakeController struct {
+	services   map[string]*service.Status
+	reloadErr  error
+	reloads    int
+	lastReload *ReloadStatus
+	removed    []string
+	restarted  []string
+}
+
+func newFakeController(names ...string) *fakeController {
+	c := &fakeController{services: make(map[string]*service.Status)}
+	for _, name := range names {
+		c.services[name] = &service.Status{Name: name, Backend: "localhost:8080", Healthy: true, AccessLog: true}
+	}
+	return c
+}
+
+func (c *fakeController) lookup(name string) (*service.Status, error) {
+	status, ok := c.services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s %w", name, service.ErrNotFound)
+	}
+	return status, nil
+}
+
+func (c *fakeController) Services(ctx context.Context) []service.Status {
+	var statuses []service.Status
+	for _, status := range c.services {
+		statuses = append(statuses, *status)
+	}
+	return statuses
+}
+
+func (c *fakeController) Service(ctx context.Context, name string) (service.Status, error) {
+	status, err := c.lookup(name)
+	if err != nil {
+		return service.Status{}, err
+	}
+	return *status, nil
+}
+
+func (c *fakeController) Settings() map[string]any {
+	return map[string]any{"tailscale": map[string]any{"auth_key": "[REDACTED]"}}
+}
+
+func (c *fakeController) Reload(ctx context.Context) error {
+	c.reloads++
+	return c.reloadErr
+}
+
+func (c *fakeController) LastReload() *ReloadStatus {
+	return c.lastReload
+}
+
+func (c *fakeController) RestartService(_ context.Context, name string) error {
+	if _, err := c.lookup(name); err != nil {
+		return err
+	}
+	c.restarted = append(c.restarted, name)
+	return nil
+}
+
+func (c *fakeController) DrainService(_ context.Context, name string, drain bool) error {
+	status, err := c.lookup(name)
+	if err != nil {
+		return err
+	}
+	status.Draining = drain
+	return nil
+}
+
+func (c *fakeController) RemoveService(_ context.Context, name string) error {
+	if _, err := c.lookup(name); err != nil {
+		return err
+	}
+	delete(c.services, name)
+	c.removed = append(c.removed, name)
+	return nil
+}
+
+func (c *fakeController) SetAccessLog(_ context.Context, name string, enabled bool) error {
+	status, err := c.lookup(name)
+	if err != nil {
+		return err
+	}
+	status.AccessLog = enabled
+	return nil
+}
+
+// serveAdmin sends a request to the admin API routes and returns the response
+func serveAdmin(t *testing.T, controller Controller, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	s := NewServer(Options{}, controller, nil)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestRoutes(t *testing.T) {
+	t.Run("list services", func(t *testing.T) {
+		rec := serveAdmin(t, newFakeController("api"), http.MethodGet, "/v1/services", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var statuses []service.Status
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "api", statuses[0].Name)
+	})
+
+	t.Run("get service", func(t *testing.T) {
+		rec := serveAdmin(t, newFakeController("api"), http.MethodGet, "/v1/services/api", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"api"`)
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		rec := serveAdmin(t, newFakeController("api"), http.MethodGet, "/v1/services/missing", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"service missing not found"}`, rec.Body.String())
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		controller := newFakeController("api")
+		rec := serveAdmin(t, controller, http.MethodPost, "/v1/services/api/restart", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"api"}, controller.restarted)
+	})
+
+	t.Run("drain and resume", func(t *testing.T) {
+		controller := newFakeController("api")
+		rec := serveAdmin(t, controller, http.MethodPost, "/v1/services/api/drain", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"draining":true`)
+
+		rec = serveAdmin(t, controller, http.MethodDelete, "/v1/services/api/drain", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"draining":false`)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		controller := newFakeController("api")
+		rec := serveAdmin(t, controller, http.MethodDelete, "/v1/services/api", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{"api"}, controller.removed)
+	})
+
+	t.Run("access log", func(t *testing.T) {
+		controller := newFakeController("api")
+		rec := serveAdmin(t, controller, http.MethodPut, "/v1/services/api/access-log", `{"enabled": false}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.False(t, controller.services["api"].AccessLog)
+
+		rec = serveAdmin(t, controller, http.MethodPut, "/v1/services/api/access-log", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `must set \"enabled\"`)
+
+		rec = serveAdmin(t, controller, http.MethodPut, "/v1/services/api/access-log", `not json`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		rec := serveAdmin(t, newFakeController(), http.MethodGet, "/v1/config", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"tailscale":{"auth_key":"[REDACTED]"}}`, rec.Body.String())
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		controller := newFakeController("api")
+		rec := serveAdmin(t, controller, http.MethodPost, "/v1/reload", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, controller.reloads)
+
+		controller.reloadErr = tserrors.NewValidationError("no configuration provider to reload from")
+		rec = serveAdmin(t, controller, http.MethodPost, "/v1/reload", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("last reload", func(t *testing.T) {
+		controller := newFakeController("api")
+		rec := serveAdmin(t, controller, http.MethodGet, "/v1/reload", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = serveAdmin(t, controller, http.MethodGet, "/v1/status", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "last_reload")
+
+		controller.lastReload = &ReloadStatus{
+			Mode:       "transactional",
+			RolledBack: true,
+			Error:      "configuration reload failed and was rolled back",
+			Desired:    2,
+			Applied:    1,
+			OutOfSync:  []string{"web"},
+		}
+		rec = serveAdmin(t, controller, http.MethodGet, "/v1/reload", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var status ReloadStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, *controller.lastReload, status)
+
+		rec = serveAdmin(t, controller, http.MethodGet, "/v1/status", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var instance InstanceStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &instance))
+		assert.Equal(t, controller.lastReload, instance.LastReload)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		rec := serveAdmin(t, newFakeController(), http.MethodPut, "/v1/reload", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+// fakeWhois returns a fixed whois response
+type fakeWhois struct {
+	response *apitype.WhoIsResponse
+	err      error
+}
+
+func (f *fakeWhois) WhoIs(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error) {
+	return f.response, f.err
+}
+
+func TestAuthorize(t *testing.T) {
+	allowed := []string{"alice@example.com", "tag:ops"}
+	tests := []struct {
+		name       string
+		whois      *fakeWhois
+		wantStatus int
+		wantActor  string
+	}{
+		{
+			name: "allowed user",
+			whois: &fakeWhois{response: &apitype.WhoIsResponse{
+				UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"},
+				Node:        &tailcfg.Node{},
+			}},
+			wantStatus: http.StatusOK,
+			wantActor:  "alice@example.com",
+		},
+		{
+			name: "allowed tag",
+			whois: &fakeWhois{response: &apitype.WhoIsResponse{
+				UserProfile: &tailcfg.UserProfile{LoginName: "tagged-devices"},
+				Node:        &tailcfg.Node{Tags: []string{"tag:server", "tag:ops"}},
+			}},
+			wantStatus: http.StatusOK,
+			wantActor:  "tag:ops",
+		},
+		{
+			name: "other user",
+			whois: &fakeWhois{response: &apitype.WhoIsResponse{
+				UserProfile: &tailcfg.UserProfile{LoginName: "mallory@example.com"},
+			}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "whois failure",
+			whois:      &fakeWhois{err: errors.New("lookup failed")},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actor string
+			handler := authorize(tt.whois, "admin_allowed", allowed, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trigger, _ := events.TriggerFromContext(r.Context())
+				actor = trigger.Actor
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/services", nil))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantActor, actor)
+		})
+	}
+}
+
+func TestRecordCaller(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		trigger    *events.Trigger
+		want       events.Trigger
+	}{
+		{
+			name:       "loopback caller",
+			remoteAddr: "127.0.0.1:54321",
+			want:       events.Trigger{Source: events.SourceAdminAPI, Actor: "127.0.0.1"},
+		},
+		{
+			name:       "unix socket caller",
+			remoteAddr: "@",
+			want:       events.Trigger{Source: events.SourceAdminAPI, Actor: "local"},
+		},
+		{
+			name:       "caller already identified",
+			remoteAddr: "100.64.0.1:54321",
+			trigger:    &events.Trigger{Source: events.SourceAdminAPI, Actor: "alice@example.com"},
+			want:       events.Trigger{Source: events.SourceAdminAPI, Actor: "alice@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got events.Trigger
+			handler := recordCaller(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = events.TriggerFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/reload", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.trigger != nil {
+				req = req.WithContext(events.WithTrigger(req.Context(), *tt.trigger))
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// newEventBus returns a bus holding a service.started and an admin.restart
+// event for api and a service.started event for web
+func newEventBus() *events.Bus {
+	bus := events.NewBus(10)
+	bus.Publish(events.Event{Type: events.ServiceStarted, Service: "api"})
+	bus.Publish(events.Event{Type: events.AdminRestart, Service: "api"})
+	bus.Publish(events.Event{Type: events.ServiceStarted, Service: "web"})
+	return bus
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []uint64
+	}{
+		{name: "all", query: "", wantStatus: http.StatusOK, wantIDs: []uint64{1, 2, 3}},
+		{name: "by type", query: "?type=service.started", wantStatus: http.StatusOK, wantIDs: []uint64{1, 3}},
+		{name: "by category", query: "?type=admin&type=config", wantStatus: http.StatusOK, wantIDs: []uint64{2}},
+		{name: "by service", query: "?service=api", wantStatus: http.StatusOK, wantIDs: []uint64{1, 2}},
+		{name: "limit keeps the most recent", query: "?limit=2", wantStatus: http.StatusOK, wantIDs: []uint64{2, 3}},
+		{name: "no matches", query: "?service=missing", wantStatus: http.StatusOK, wantIDs: []uint64{}},
+		{name: "invalid limit", query: "?limit=-1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(Options{Events: newEventBus()}, newFakeController(), nil)
+			rec := httptest.NewRecorder()
+			s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/events"+tt.query, nil))
+			require.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var list []events.Event
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+			ids := []uint64{}
+			for _, e := range list {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	// readEvents reads the ids of n server-sent events from body
+	readEvents := func(t *testing.T, body *bufio.Reader, n int) []string {
+		t.Helper()
+		var ids []string
+		for len(ids) < n {
+			line, err := body.ReadString('\n')
+			require.NoError(t, err)
+			if id, ok := strings.CutPrefix(strings.TrimSpace(line), "id: "); ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		wantBacklog []string
+	}{
+		{name: "no history", query: "", wantBacklog: nil},
+		{name: "history", query: "?history=2", wantBacklog: []string{"2", "3"}},
+		{name: "history with filter", query: "?history=5&service=api", wantBacklog: []string{"1", "2"}},
+		{name: "resume after last event", query: "?service=api", lastEventID: "1", wantBacklog: []string{"2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := newEventBus()
+			s := NewServer(Options{Events: bus}, newFakeController(), nil)
+			ts := httptest.NewServer(s.routes())
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/events/stream"+tt.query, nil)
+			require.NoError(t, err)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			body := bufio.NewReader(resp.Body)
+			if len(tt.wantBacklog) > 0 {
+				assert.Equal(t, tt.wantBacklog, readEvents(t, body, len(tt.wantBacklog)))
+			}
+
+			// The headers are written after subscribing, so new events are
+			// delivered from here on
+			bus.Publish(events.Event{Type: events.ServiceStopped, Service: "api"})
+			assert.Equal(t, []string{"4"}, readEvents(t, body, 1))
+		})
+	}
+
+	t.Run("invalid history", func(t *testing.T) {
+		rec := serveAdmin(t, newFakeController(), http.MethodGet, "/v1/events/stream?history=x", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// freeLoopbackAddr returns a loopback address with a port nothing is listening
+// on, since admin addresses must name one
+func freeLoopbackAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	return addr
+}
+
+func TestServer_Start(t *testing.T) {
+	t.Run("loopback", func(t *testing.T) {
+		// Reserve a free port, since admin addresses must name one
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		require.NoError(t, ln.Close())
+
+		s := NewServer(Options{Addr: addr}, newFakeController("api"), nil)
+		require.NoError(t, s.Start(context.Background()))
+		defer s.Shutdown(context.Background())
+
+		resp, err := http.Get("http://" + s.Addr() + "/v1/services")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("loopback changes come from the CLI", func(t *testing.T) {
+		addr := freeLoopbackAddr(t)
+		controller := newFakeController("api")
+		s := NewServer(Options{Addr: addr}, controller, nil)
+		require.NoError(t, s.Start(context.Background()))
+		defer s.Shutdown(context.Background())
+
+		reload := func(header http.Header, host string) int {
+			req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/v1/reload", nil)
+			require.NoError(t, err)
+			req.Header = header
+			if host != "" {
+				req.Host = host
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		cli := func() http.Header { return http.Header{constants.AdminClientHeader: {"cli"}} }
+
+		assert.Equal(t, http.StatusForbidden, reload(http.Header{}, ""), "form posts from web pages")
+		withOrigin := cli()
+		withOrigin.Set("Origin", "http://evil.example")
+		assert.Equal(t, http.StatusForbidden, reload(withOrigin, ""))
+		assert.Equal(t, http.StatusForbidden, reload(cli(), "evil.example:80"), "DNS rebinding")
+		assert.Zero(t, controller.reloads)
+
+		assert.Equal(t, http.StatusOK, reload(cli(), ""))
+		assert.Equal(t, 1, controller.reloads)
+
+		// Reading needs no header, but pages cannot read through a rebound name
+		read := func(host string) int {
+			req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/v1/config", nil)
+			require.NoError(t, err)
+			if host != "" {
+				req.Host = host
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		assert.Equal(t, http.StatusForbidden, read("evil.example:80"), "DNS rebinding")
+		assert.NotEqual(t, http.StatusForbidden, read(""))
+
+		// The CLI's client sends the header
+		client, err := NewClient(addr)
+		require.NoError(t, err)
+		_, err = client.Reload(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, controller.reloads)
+	})
+
+	t.Run("unix socket", func(t *testing.T) {
+		// Keep the path short enough for the unix socket limit
+		dir, err := os.MkdirTemp("", "tsbridge")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "admin.sock")
+
+		// A socket left behind by a previous run is replaced
+		stale, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, stale.Close())
+
+		s := NewServer(Options{Addr: "unix://" + path}, newFakeController("api"), nil)
+		require.NoError(t, s.Start(context.Background()))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+		resp, err := client.Get("http://admin/v1/config")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Contains(t, string(body), "REDACTED")
+
+		require.NoError(t, s.Shutdown(context.Background()))
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "socket is removed on shutdown")
+	})
+
+	t.Run("unix socket is private from creation", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "tsbridge")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "admin.sock")
+
+		chmods := 0
+		chmod = func(name string, mode os.FileMode) error {
+			chmods++
+			// Until its mode is set, the socket is only reachable through a private directory
+			_, err := os.Stat(path)
+			assert.True(t, os.IsNotExist(err), "socket is at its path before its mode is set")
+			info, err := os.Stat(filepath.Dir(name))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+			return os.Chmod(name, mode)
+		}
+		defer func() { chmod = os.Chmod }()
+
+		s := NewServer(Options{Addr: "unix://" + path}, newFakeController("api"), nil)
+		require.NoError(t, s.Start(context.Background()))
+		defer s.Shutdown(context.Background())
+
+		assert.Equal(t, 1, chmods)
+		assert.Equal(t, path, s.Addr())
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "the private directory is removed")
+	})
+
+	t.Run("unix socket of a running instance is kept", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "tsbridge")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "admin.sock")
+
+		running := NewServer(Options{Addr: "unix://" + path}, newFakeController("api"), nil)
+		require.NoError(t, running.Start(context.Background()))
+		defer running.Shutdown(context.Background())
+
+		s := NewServer(Options{Addr: "unix://" + path}, newFakeController("api"), nil)
+		assert.ErrorContains(t, s.Start(context.Background()), "another process is listening on "+path)
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+		resp, err := client.Get("http://admin/v1/config")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "the running instance still serves the socket")
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		s := NewServer(Options{Addr: "0.0.0.0:9000"}, newFakeController(), nil)
+		assert.ErrorContains(t, s.Start(context.Background()), "must be a loopback address")
+	})
+
+	t.Run("tailnet requires tailscale server", func(t *testing.T) {
+		s := NewServer(Options{Addr: "tailnet://tsbridge-admin"}, newFakeController(), nil)
+		assert.ErrorContains(t, s.Start(context.Background()), "requires a tailscale server")
+	})
+
+	t.Run("shutdown before start", func(t *testing.T) {
+		s := NewServer(Options{Addr: "127.0.0.1:0"}, newFakeController(), nil)
+		assert.NoError(t, s.Shutdown(context.Background()))
+		assert.Empty(t, s.Addr())
+	})
+}
+
+func TestServer_ShutdownClosesUnusedConnections(t *testing.T) {
+	s := NewServer(Options{Addr: freeLoopbackAddr(t)}, newFakeController(), nil)
+	require.NoError(t, s.Start(context.Background()))
+
+	// A connection that never sends a request does not hold up shutdown
+	conn, err := net.Dial("tcp", s.Addr())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.newConns) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx))
+}
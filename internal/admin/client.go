@@ -0,0 +1,203 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/service"
+)
+
+// Client talks to the admin API of a running tsbridge
+type Client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// NewClient creates a client for an admin API listening on addr, in any of the
+// forms accepted for admin_addr. A tailnet address is reached through the local
+// machine's Tailscale connection.
+func NewClient(addr string) (*Client, error) {
+	network, address, err := config.ParseAdminAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{}
+	baseURL := "http://" + address
+	if network == config.AdminNetworkUnix {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", address)
+		}
+		baseURL = "http://tsbridge"
+	}
+
+	return &Client{
+		http:    &http.Client{Transport: transport},
+		baseURL: baseURL,
+	}, nil
+}
+
+// Status returns a summary of the running instance
+func (c *Client) Status(ctx context.Context) (InstanceStatus, error) {
+	var status InstanceStatus
+	err := c.do(ctx, http.MethodGet, "/v1/status", &status)
+	return status, err
+}
+
+// Services returns the status of every running service
+func (c *Client) Services(ctx context.Context) ([]service.Status, error) {
+	var statuses []service.Status
+	err := c.do(ctx, http.MethodGet, "/v1/services", &statuses)
+	return statuses, err
+}
+
+// Config returns the redacted configuration of the running instance, in the
+// form of config.RedactedConfig.Settings
+func (c *Client) Config(ctx context.Context) (map[string]any, error) {
+	var settings map[string]any
+	err := c.do(ctx, http.MethodGet, "/v1/config", &settings)
+	return settings, err
+}
+
+// RestartService restarts a service and returns its new status
+func (c *Client) RestartService(ctx context.Context, name string) (service.Status, error) {
+	var status service.Status
+	err := c.do(ctx, http.MethodPost, "/v1/services/"+url.PathEscape(name)+"/restart", &status)
+	return status, err
+}
+
+// Reload reloads the configuration and returns the status of the services
+// running afterwards
+func (c *Client) Reload(ctx context.Context) ([]service.Status, error) {
+	var statuses []service.Status
+	err := c.do(ctx, http.MethodPost, "/v1/reload", &statuses)
+	return statuses, err
+}
+
+// Logs copies the most recent log lines to w. With follow it keeps copying new
+// lines until ctx is cancelled or the instance shuts down.
+func (c *Client) Logs(ctx context.Context, w io.Writer, lines int, follow bool) error {
+	query := url.Values{"lines": {strconv.Itoa(lines)}}
+	if follow {
+		query.Set("follow", "true")
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/v1/logs?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("reading logs: %w", err)
+	}
+	return nil
+}
+
+// EventQuery selects the events returned by Events and FollowEvents
+type EventQuery struct {
+	Types   []string // Event types or categories, all if empty
+	Service string   // Service the events are about, all if empty
+	Limit   int      // Most recent events to return, or to replay before following
+}
+
+// values encodes q as query parameters, with the limit named count
+func (q EventQuery) values(count string) url.Values {
+	query := url.Values{}
+	for _, t := range q.Types {
+		query.Add("type", t)
+	}
+	if q.Service != "" {
+		query.Set("service", q.Service)
+	}
+	if q.Limit > 0 {
+		query.Set(count, strconv.Itoa(q.Limit))
+	}
+	return query
+}
+
+// Events returns the most recent events matching q, oldest first
+func (c *Client) Events(ctx context.Context, q EventQuery) ([]events.Event, error) {
+	var list []events.Event
+	err := c.do(ctx, http.MethodGet, "/v1/events?"+q.values("limit").Encode(), &list)
+	return list, err
+}
+
+// FollowEvents calls fn with the most recent q.Limit events matching q and
+// then with every new one until ctx is cancelled or the instance shuts down
+func (c *Client) FollowEvents(ctx context.Context, q EventQuery, fn func(events.Event)) error {
+	resp, err := c.send(ctx, http.MethodGet, "/v1/events/stream?"+q.values("history").Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e events.Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return fmt.Errorf("decoding event: %w", err)
+		}
+		fn(e)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("reading events: %w", err)
+	}
+	return nil
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, constants.AdminClientTimeout)
+	defer cancel()
+
+	resp, err := c.send(ctx, method, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding admin API response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request and returns the response, turning error responses into errors
+func (c *Client) send(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(constants.AdminClientHeader, "cli")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to admin API: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		var apiErr errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return nil, fmt.Errorf("admin API returned %s", resp.Status)
+		}
+		return nil, fmt.Errorf("admin API: %s", apiErr.Error)
+	}
+	return resp, nil
+}
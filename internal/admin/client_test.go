@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestServer starts an admin server on a unix socket and returns a client for it
+func startTestServer(t *testing.T, controller Controller, logs *LogBuffer, bus *events.Bus) *Client {
+	t.Helper()
+	// Keep the path short enough for the unix socket limit
+	dir, err := os.MkdirTemp("", "tsbridge")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	addr := "unix://" + filepath.Join(dir, "admin.sock")
+
+	s := NewServer(Options{Addr: addr, Version: "1.2.3", Logs: logs, Events: bus}, controller, nil)
+	require.NoError(t, s.Start(context.Background()))
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	client, err := NewClient(addr)
+	require.NoError(t, err)
+	return client
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func TestClient(t *testing.T) {
+	controller := newFakeController("api", "web")
+	controller.services["web"].Healthy = false
+	logs := NewLogBuffer(10)
+	_, _ = logs.Write([]byte("line one\nline two\n"))
+	bus := events.NewBus(10)
+	bus.Publish(events.Event{Type: events.ServiceStarted, Service: "api"})
+	bus.Publish(events.Event{Type: events.ServiceStarted, Service: "web"})
+	client := startTestServer(t, controller, logs, bus)
+	ctx := context.Background()
+
+	t.Run("status", func(t *testing.T) {
+		status, err := client.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.3", status.Version)
+		assert.Equal(t, 2, status.Services)
+		assert.Equal(t, 1, status.Healthy)
+		assert.False(t, status.StartedAt.IsZero())
+	})
+
+	t.Run("services", func(t *testing.T) {
+		statuses, err := client.Services(ctx)
+		require.NoError(t, err)
+		assert.Len(t, statuses, 2)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		status, err := client.RestartService(ctx, "api")
+		require.NoError(t, err)
+		assert.Equal(t, "api", status.Name)
+
+		_, err = client.RestartService(ctx, "missing")
+		assert.EqualError(t, err, "admin API: service missing not found")
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		_, err := client.Reload(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, controller.reloads)
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, client.Logs(ctx, &out, 1, false))
+		assert.Equal(t, "line two\n", out.String())
+	})
+
+	t.Run("follow logs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		out := &syncBuffer{}
+		done := make(chan error, 1)
+		go func() { done <- client.Logs(ctx, out, 0, true) }()
+
+		assert.Eventually(t, func() bool {
+			_, _ = logs.Write([]byte("streamed\n"))
+			return bytes.Contains(out.Bytes(), []byte("streamed\n"))
+		}, 2*time.Second, 10*time.Millisecond)
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		list, err := client.Events(ctx, EventQuery{Service: "web"})
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "web", list[0].Service)
+
+		list, err = client.Events(ctx, EventQuery{Types: []string{"service"}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "web", list[0].Service)
+	})
+
+	t.Run("follow events", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		received := make(chan events.Event, 10)
+		done := make(chan error, 1)
+		go func() {
+			done <- client.FollowEvents(ctx, EventQuery{Types: []string{"admin"}}, func(e events.Event) { received <- e })
+		}()
+
+		// The subscription starts once the stream is open, so publish until one arrives
+		require.Eventually(t, func() bool {
+			bus.Publish(events.Event{Type: events.ServiceStopped, Service: "api"})
+			bus.Publish(events.Event{Type: events.AdminRestart, Service: "api"})
+			return len(received) > 0
+		}, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, events.AdminRestart, (<-received).Type)
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		client, err := NewClient("unix:///nonexistent/admin.sock")
+		require.NoError(t, err)
+		_, err = client.Status(ctx)
+		assert.ErrorContains(t, err, "connecting to admin API")
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		_, err := NewClient("admin.sock")
+		assert.Error(t, err)
+	})
+}
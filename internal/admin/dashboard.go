@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/middleware"
+	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"uptime": func(seconds int64) string { return (time.Duration(seconds) * time.Second).String() },
+	"rate":   func(perSecond float64) string { return fmt.Sprintf("%.2f/s", perSecond) },
+}).Parse(dashboardHTML))
+
+// DashboardSource provides the data shown on the dashboard
+type DashboardSource interface {
+	Services(ctx context.Context) []service.Status
+	RecentRequests(name string) ([]middleware.AccessEntry, error)
+}
+
+// DashboardOptions configures a Dashboard
+type DashboardOptions struct {
+	Hostname       string              // Tailnet hostname of the dashboard node
+	Allowed        []string            // Login names and tags allowed to view the dashboard
+	Tags           []string            // Tags for the tailnet node
+	StartupTimeout *time.Duration      // Max time for the tailnet node to start
+	Version        string              // tsbridge version shown on the page
+	Metrics        prometheus.Gatherer // Source of the request counters rates are computed from
+}
+
+// DashboardService is a service as shown on the dashboard
+type DashboardService struct {
+	service.Status
+	RequestRate    float64                  `json:"request_rate"` // Requests per second over the rate window
+	ErrorRate      float64                  `json:"error_rate"`   // 5xx responses per second over the rate window
+	RecentRequests []middleware.AccessEntry `json:"recent_requests"`
+}
+
+// dashboardPage is the data the dashboard template renders
+type dashboardPage struct {
+	Version        string
+	Services       []DashboardService
+	RefreshSeconds int
+	RateWindow     time.Duration
+	GeneratedAt    time.Time
+}
+
+// Dashboard serves a read-only web view of every service on its own tailnet
+// node, for callers allowed by a whois lookup
+type Dashboard struct {
+	opts     DashboardOptions
+	source   DashboardSource
+	tsServer *tailscale.Server
+	rates    *rateTracker
+	server   *http.Server
+	listener net.Listener
+	stop     context.CancelFunc // Stops sampling request counters
+	mu       sync.RWMutex
+}
+
+// NewDashboard creates a dashboard served through tsServer
+func NewDashboard(opts DashboardOptions, source DashboardSource, tsServer *tailscale.Server) *Dashboard {
+	d := &Dashboard{
+		opts:     opts,
+		source:   source,
+		tsServer: tsServer,
+	}
+	if opts.Metrics != nil {
+		d.rates = newRateTracker(opts.Metrics, constants.DashboardRateWindow)
+	}
+	return d
+}
+
+// Start brings up the dashboard's tailnet node and serves the dashboard over
+// HTTPS in the background
+func (d *Dashboard) Start(ctx context.Context) error {
+	if d.tsServer == nil {
+		return tserrors.NewInternalError("serving the dashboard requires a tailscale server")
+	}
+
+	node := config.Service{
+		Name:           d.opts.Hostname,
+		Tags:           d.opts.Tags,
+		StartupTimeout: d.opts.StartupTimeout,
+	}
+	listener, err := d.tsServer.Listen(node, constants.TLSModeAuto, false)
+	if err != nil {
+		return tserrors.WrapResource(err, fmt.Sprintf("failed to listen on dashboard node %s", d.opts.Hostname))
+	}
+	whois := tailscale.NewWhoisClientAdapter(d.tsServer.GetServiceServer(d.opts.Hostname))
+
+	server := &http.Server{
+		Handler:           authorize(whois, "dashboard_allowed", d.opts.Allowed, d.routes()),
+		ReadHeaderTimeout: constants.DefaultReadHeaderTimeout,
+	}
+
+	sampleCtx, stop := context.WithCancel(context.Background())
+	if d.rates != nil {
+		go d.rates.run(sampleCtx, constants.DashboardSampleInterval)
+	}
+
+	d.mu.Lock()
+	d.listener = listener
+	d.server = server
+	d.stop = stop
+	d.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("dashboard server error", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the dashboard is listening on
+func (d *Dashboard) Addr() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.listener == nil {
+		return ""
+	}
+	return d.listener.Addr().String()
+}
+
+// Shutdown stops the dashboard and its tailnet node
+func (d *Dashboard) Shutdown(ctx context.Context) error {
+	d.mu.RLock()
+	server, stop := d.server, d.stop
+	d.mu.RUnlock()
+
+	if server == nil {
+		return nil
+	}
+	stop()
+	return errors.Join(server.Shutdown(ctx), d.tsServer.CloseService(d.opts.Hostname))
+}
+
+// routes returns the dashboard handler
+func (d *Dashboard) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.handlePage)
+	mux.HandleFunc("GET /api/services", d.handleServices)
+	return mux
+}
+
+func (d *Dashboard) handlePage(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{
+		Version:        d.opts.Version,
+		Services:       d.services(r.Context()),
+		RefreshSeconds: int(constants.DashboardRefreshInterval.Seconds()),
+		RateWindow:     constants.DashboardRateWindow,
+		GeneratedAt:    time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		slog.Error("failed to render dashboard", "error", err)
+	}
+}
+
+func (d *Dashboard) handleServices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.services(r.Context()))
+}
+
+// services collects the status, rates and recent requests of every service
+func (d *Dashboard) services(ctx context.Context) []DashboardService {
+	var rates map[string]serviceRate
+	if d.rates != nil {
+		rates = d.rates.rates()
+	}
+
+	statuses := d.source.Services(ctx)
+	services := make([]DashboardService, 0, len(statuses))
+	for _, status := range statuses {
+		recent, err := d.source.RecentRequests(status.Name)
+		if err != nil {
+			// The service was removed since the statuses were collected
+			continue
+		}
+		services = append(services, DashboardService{
+			Status:         status,
+			RequestRate:    rates[status.Name].requests,
+			ErrorRate:      rates[status.Name].errors,
+			RecentRequests: recent,
+		})
+	}
+	return services
+}
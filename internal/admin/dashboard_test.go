@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/jtdowney/tsbridge/internal/middleware"
+	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/jtdowney/tsbridge/internal/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDashboardSource serves fixed statuses and recent requests
+type fakeDashboardSource struct {
+	services []service.Status
+	recent   map[string][]middleware.AccessEntry
+}
+
+func (s *fakeDashboardSource) Services(ctx context.Context) []service.Status {
+	return s.services
+}
+
+func (s *fakeDashboardSource) RecentRequests(name string) ([]middleware.AccessEntry, error) {
+	for _, status := range s.services {
+		if status.Name == name {
+			return s.recent[name], nil
+		}
+	}
+	return nil, service.ErrNotFound
+}
+
+func newTestDashboard(t *testing.T) *Dashboard {
+	t.Helper()
+	source := &fakeDashboardSource{
+		services: []service.Status{
+			{Name: "api", URL: "https://api.example.ts.net", Backend: "localhost:8080", Healthy: true, AccessLog: true, Funnel: true},
+			{Name: "web", Backend: "localhost:8081", HealthError: "connection refused"},
+		},
+		recent: map[string][]middleware.AccessEntry{
+			"api": {{Time: time.Now(), Method: http.MethodPost, Path: "/v1/orders", Status: http.StatusBadGateway}},
+		},
+	}
+
+	reg := prometheus.NewRegistry()
+	collector := metrics.NewCollector()
+	require.NoError(t, collector.Register(reg))
+	d := NewDashboard(DashboardOptions{Hostname: "tsbridge-dashboard", Version: "1.2.3", Metrics: reg}, source, nil)
+
+	start := time.Now()
+	d.rates.sample(start)
+	collector.RequestsTotal.WithLabelValues("api", "200").Add(30)
+	collector.RequestsTotal.WithLabelValues("api", "502").Add(10)
+	d.rates.sample(start.Add(10 * time.Second))
+	return d
+}
+
+func TestDashboard_Routes(t *testing.T) {
+	d := newTestDashboard(t)
+	handler := d.routes()
+
+	t.Run("page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "Version 1.2.3")
+		assert.Contains(t, body, `<a href="https://api.example.ts.net">api</a>`)
+		assert.Contains(t, body, "4.00/s")
+		assert.Contains(t, body, "1.00/s")
+		assert.Contains(t, body, "/v1/orders")
+		assert.Contains(t, body, "connection refused")
+		assert.Contains(t, body, "access log off")
+	})
+
+	t.Run("services json", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/services", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var services []DashboardService
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &services))
+		require.Len(t, services, 2)
+		assert.Equal(t, "api", services[0].Name)
+		assert.True(t, services[0].Funnel)
+		assert.InDelta(t, 4.0, services[0].RequestRate, 0.001)
+		assert.InDelta(t, 1.0, services[0].ErrorRate, 0.001)
+		require.Len(t, services[0].RecentRequests, 1)
+		assert.Equal(t, "/v1/orders", services[0].RecentRequests[0].Path)
+		assert.Zero(t, services[1].RequestRate)
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestDashboard_Start(t *testing.T) {
+	t.Run("tailnet node", func(t *testing.T) {
+		tsServer := testutil.CreateMockTailscaleServer(t, config.Tailscale{})
+		d := NewDashboard(DashboardOptions{Hostname: "tsbridge-dashboard", Allowed: []string{"tag:ops"}}, &fakeDashboardSource{}, tsServer)
+
+		require.NoError(t, d.Start(context.Background()))
+		assert.NotEmpty(t, d.Addr())
+		assert.NotNil(t, tsServer.GetServiceServer("tsbridge-dashboard"))
+
+		require.NoError(t, d.Shutdown(context.Background()))
+		assert.Nil(t, tsServer.GetServiceServer("tsbridge-dashboard"))
+	})
+
+	t.Run("requires tailscale server", func(t *testing.T) {
+		d := NewDashboard(DashboardOptions{Hostname: "tsbridge-dashboard"}, &fakeDashboardSource{}, nil)
+		assert.ErrorContains(t, d.Start(context.Background()), "requires a tailscale server")
+	})
+
+	t.Run("shutdown before start", func(t *testing.T) {
+		d := NewDashboard(DashboardOptions{Hostname: "tsbridge-dashboard"}, &fakeDashboardSource{}, nil)
+		assert.NoError(t, d.Shutdown(context.Background()))
+	})
+}
@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/events"
+)
+
+// eventFilter selects the events returned by /v1/events and /v1/events/stream
+type eventFilter struct {
+	types   []string // Event types or categories, all if empty
+	service string   // Service the events are about, all if empty
+}
+
+// parseEventFilter reads the type and service query parameters of r
+func parseEventFilter(r *http.Request) eventFilter {
+	return eventFilter{
+		types:   r.URL.Query()["type"],
+		service: r.URL.Query().Get("service"),
+	}
+}
+
+// match reports whether e passes the filter
+func (f eventFilter) match(e events.Event) bool {
+	return e.Match(f.types) && (f.service == "" || e.Service == f.service)
+}
+
+// parseCount reads a non-negative integer query parameter, returning def when
+// it is not set
+func parseCount(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
+
+// handleEvents writes the most recent events matching the filter as JSON,
+// oldest first
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseCount(r, "limit", 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	filter := parseEventFilter(r)
+	matched := []events.Event{}
+	for _, e := range s.opts.Events.Recent(-1) {
+		if filter.match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	writeJSON(w, http.StatusOK, matched)
+}
+
+// handleEventStream streams events matching the filter as server-sent events
+// until the client disconnects. A reconnecting client's Last-Event-ID header
+// replays the kept events it missed; otherwise history sets how many recent
+// events are sent first.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	history, err := parseCount(r, "history", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := parseEventFilter(r)
+
+	// Subscribe before reading the backlog so no event is lost between the two
+	updates, unsubscribe := s.opts.Events.Subscribe()
+	defer unsubscribe()
+
+	var backlog []events.Event
+	if lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		backlog = s.opts.Events.Since(lastID)
+	} else {
+		for _, e := range s.opts.Events.Recent(-1) {
+			if filter.match(e) {
+				backlog = append(backlog, e)
+			}
+		}
+		if len(backlog) > history {
+			backlog = backlog[len(backlog)-history:]
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	var sent uint64
+	for _, e := range backlog {
+		if filter.match(e) {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		sent = e.ID
+	}
+
+	flusher, _ := w.(http.Flusher)
+	keepAlive := time.NewTicker(constants.EventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e := <-updates:
+			// Skip events already sent from the backlog
+			if e.ID <= sent || !filter.match(e) {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes e as a server-sent event
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// recordCaller makes requests carry an admin API trigger naming the caller,
+// unless authorize has already identified them on the tailnet
+func recordCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := events.TriggerFromContext(r.Context()); !ok {
+			actor := "local"
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				actor = host
+			}
+			r = r.WithContext(events.WithTrigger(r.Context(), events.Trigger{Source: events.SourceAdminAPI, Actor: actor}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,218 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// InstanceStatus summarises the running tsbridge instance
+type InstanceStatus struct {
+	Version       string        `json:"version"`
+	StartedAt     time.Time     `json:"started_at"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	Services      int           `json:"services"` // Running services
+	Healthy       int           `json:"healthy"`  // Running services whose backend is reachable
+	Draining      int           `json:"draining"`
+	LastReload    *ReloadStatus `json:"last_reload,omitempty"` // Outcome of the last configuration reload, if any
+}
+
+// ReloadStatus is the outcome of a configuration reload and how the running
+// services compare with the configuration it loaded
+type ReloadStatus struct {
+	Time       time.Time `json:"time"`
+	Mode       string    `json:"mode"` // reload_mode the reload ran with
+	Success    bool      `json:"success"`
+	RolledBack bool      `json:"rolled_back,omitempty"` // A transactional reload failed and kept the previous services
+	Error      string    `json:"error,omitempty"`
+	Desired    int       `json:"desired"`               // Services in the loaded configuration
+	Applied    int       `json:"applied"`               // Services running with their settings from the loaded configuration
+	OutOfSync  []string  `json:"out_of_sync,omitempty"` // Services missing, running with other settings, or running but no longer configured
+}
+
+// accessLogRequest is the body of PUT /v1/services/{name}/access-log
+type accessLogRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// routes returns the admin API handler
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("GET /v1/services", s.handleListServices)
+	mux.HandleFunc("GET /v1/services/{name}", s.handleGetService)
+	mux.HandleFunc("DELETE /v1/services/{name}", s.handleRemoveService)
+	mux.HandleFunc("POST /v1/services/{name}/restart", s.handleRestartService)
+	mux.HandleFunc("POST /v1/services/{name}/drain", s.handleDrainService(true))
+	mux.HandleFunc("DELETE /v1/services/{name}/drain", s.handleDrainService(false))
+	mux.HandleFunc("PUT /v1/services/{name}/access-log", s.handleSetAccessLog)
+	mux.HandleFunc("GET /v1/config", s.handleGetConfig)
+	mux.HandleFunc("GET /v1/reload", s.handleLastReload)
+	mux.HandleFunc("POST /v1/reload", s.handleReload)
+	mux.HandleFunc("GET /v1/logs", s.handleLogs)
+	mux.HandleFunc("GET /v1/events", s.handleEvents)
+	mux.HandleFunc("GET /v1/events/stream", s.handleEventStream)
+	return mux
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	startedAt := s.startedAt
+	s.mu.RUnlock()
+
+	status := InstanceStatus{
+		Version:   s.opts.Version,
+		StartedAt: startedAt,
+	}
+	if !startedAt.IsZero() {
+		status.UptimeSeconds = int64(time.Since(startedAt).Seconds())
+	}
+	status.LastReload = s.controller.LastReload()
+	for _, svc := range s.controller.Services(r.Context()) {
+		status.Services++
+		if svc.Healthy {
+			status.Healthy++
+		}
+		if svc.Draining {
+			status.Draining++
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleListServices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.controller.Services(r.Context()))
+}
+
+func (s *Server) handleGetService(w http.ResponseWriter, r *http.Request) {
+	s.writeServiceStatus(w, r)
+}
+
+func (s *Server) handleRemoveService(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := s.controller.RemoveService(r.Context(), name); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	slog.Info("service removed through admin API", "service", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRestartService(w http.ResponseWriter, r *http.Request) {
+	if err := s.controller.RestartService(r.Context(), r.PathValue("name")); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	s.writeServiceStatus(w, r)
+}
+
+func (s *Server) handleDrainService(drain bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.controller.DrainService(r.Context(), r.PathValue("name"), drain); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		s.writeServiceStatus(w, r)
+	}
+}
+
+func (s *Server) handleSetAccessLog(w http.ResponseWriter, r *http.Request) {
+	var req accessLogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, errors.New(`request body must set "enabled"`))
+		return
+	}
+
+	if err := s.controller.SetAccessLog(r.Context(), r.PathValue("name"), *req.Enabled); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	s.writeServiceStatus(w, r)
+}
+
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.controller.Settings())
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	slog.Info("reload requested through admin API")
+	if err := s.controller.Reload(r.Context()); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.controller.Services(r.Context()))
+}
+
+func (s *Server) handleLastReload(w http.ResponseWriter, r *http.Request) {
+	status := s.controller.LastReload()
+	if status == nil {
+		writeError(w, http.StatusNotFound, errors.New("the configuration has not been reloaded"))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleLogs writes recent log lines as plain text. With follow=true it keeps
+// the response open and streams new lines until the client disconnects.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	lines := 100
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid lines %q", v))
+			return
+		}
+		lines = n
+	}
+	follow := r.URL.Query().Get("follow") == "true"
+
+	// Subscribe before reading the tail so no line is lost between the two
+	var updates <-chan string
+	if follow {
+		ch, unsubscribe := s.opts.Logs.Subscribe()
+		defer unsubscribe()
+		updates = ch
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, line := range s.opts.Logs.Tail(lines) {
+		fmt.Fprintln(w, line)
+	}
+	if !follow {
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-updates:
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeServiceStatus responds with the status of the service named in the path
+func (s *Server) writeServiceStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.controller.Service(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
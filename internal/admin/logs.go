@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// DefaultLogBuffer collects the process's log output for the admin API. The
+// daemon's logger writes to it alongside stdout.
+var DefaultLogBuffer = NewLogBuffer(constants.DefaultAdminLogLines)
+
+// LogBuffer is an io.Writer that keeps the most recent log lines and fans new
+// lines out to followers
+type LogBuffer struct {
+	lines       []string
+	next        int // Index in lines the next line is written to once full
+	size        int
+	subscribers map[chan string]struct{}
+	mu          sync.Mutex
+}
+
+// NewLogBuffer creates a LogBuffer that keeps the last size lines
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{
+		lines:       make([]string, 0, size),
+		size:        size,
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+// Write records each complete line in p. Log handlers write one record per call.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for line := range bytes.Lines(p) {
+		b.add(string(bytes.TrimRight(line, "\r\n")))
+	}
+	return len(p), nil
+}
+
+// add appends a line to the ring and sends it to followers, dropping it for
+// followers that are not keeping up
+func (b *LogBuffer) add(line string) {
+	if len(b.lines) < b.size {
+		b.lines = append(b.lines, line)
+	} else if b.size > 0 {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % b.size
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// Tail returns up to n of the most recent lines, oldest first
+func (b *LogBuffer) Tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := append(append([]string{}, b.lines[b.next:]...), b.lines[:b.next]...)
+	if n >= 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// Subscribe returns a channel that receives every line written from now on and
+// a function that stops the subscription
+func (b *LogBuffer) Subscribe() (<-chan string, func()) {
+	ch := make(chan string, constants.DefaultAdminLogFollowBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogBuffer(t *testing.T) {
+	t.Run("keeps the most recent lines", func(t *testing.T) {
+		b := NewLogBuffer(3)
+		for _, line := range []string{"one\n", "two\n", "three\n", "four\nfive\n"} {
+			n, err := b.Write([]byte(line))
+			require.NoError(t, err)
+			assert.Equal(t, len(line), n)
+		}
+
+		assert.Equal(t, []string{"three", "four", "five"}, b.Tail(-1))
+		assert.Equal(t, []string{"four", "five"}, b.Tail(2))
+		assert.Empty(t, b.Tail(0))
+	})
+
+	t.Run("before the buffer fills", func(t *testing.T) {
+		b := NewLogBuffer(10)
+		_, _ = b.Write([]byte("one\ntwo\n"))
+		assert.Equal(t, []string{"one", "two"}, b.Tail(5))
+	})
+
+	t.Run("followers receive new lines", func(t *testing.T) {
+		b := NewLogBuffer(10)
+		_, _ = b.Write([]byte("before\n"))
+
+		lines, unsubscribe := b.Subscribe()
+		_, _ = b.Write([]byte("after\n"))
+
+		select {
+		case line := <-lines:
+			assert.Equal(t, "after", line)
+		case <-time.After(time.Second):
+			t.Fatal("no line received")
+		}
+
+		unsubscribe()
+		_, _ = b.Write([]byte("unsubscribed\n"))
+		assert.Empty(t, lines)
+	})
+}
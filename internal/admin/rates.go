@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestsMetric is the counter request and error rates are derived from
+const requestsMetric = "tsbridge_requests_total"
+
+// serviceRate is the request and error rate of a service
+type serviceRate struct {
+	requests float64 // Requests per second
+	errors   float64 // 5xx responses per second
+}
+
+// counterSample holds the per-service request counters at a point in time
+type counterSample struct {
+	at       time.Time
+	requests map[string]float64
+	errors   map[string]float64
+}
+
+// rateTracker turns the request counters of a Prometheus gatherer into rates by
+// sampling them periodically and comparing samples across a window
+type rateTracker struct {
+	gatherer prometheus.Gatherer
+	window   time.Duration
+	samples  []counterSample // Oldest first
+	mu       sync.Mutex
+}
+
+// newRateTracker creates a rateTracker averaging over window
+func newRateTracker(gatherer prometheus.Gatherer, window time.Duration) *rateTracker {
+	return &rateTracker{
+		gatherer: gatherer,
+		window:   window,
+	}
+}
+
+// run samples the counters every interval until ctx is cancelled
+func (t *rateTracker) run(ctx context.Context, interval time.Duration) {
+	t.sample(time.Now())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.sample(now)
+		}
+	}
+}
+
+// sample records the current counters and forgets samples that have left the window
+func (t *rateTracker) sample(now time.Time) {
+	families, err := t.gatherer.Gather()
+	if err != nil {
+		slog.Debug("failed to gather metrics for dashboard", "error", err)
+		return
+	}
+
+	sample := counterSample{
+		at:       now,
+		requests: make(map[string]float64),
+		errors:   make(map[string]float64),
+	}
+	for _, family := range families {
+		if family.GetName() != requestsMetric {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			var name string
+			var code int
+			for _, label := range metric.GetLabel() {
+				switch label.GetName() {
+				case "service":
+					name = label.GetValue()
+				case "status":
+					code, _ = strconv.Atoi(label.GetValue())
+				}
+			}
+			value := metric.GetCounter().GetValue()
+			sample.requests[name] += value
+			if code >= 500 {
+				sample.errors[name] += value
+			}
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, sample)
+	for len(t.samples) > 2 && now.Sub(t.samples[0].at) > t.window {
+		t.samples = t.samples[1:]
+	}
+}
+
+// rates returns the rates of every service seen in the window. It is empty
+// until two samples have been taken.
+func (t *rateTracker) rates() map[string]serviceRate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rates := make(map[string]serviceRate)
+	if len(t.samples) < 2 {
+		return rates
+	}
+	oldest, newest := t.samples[0], t.samples[len(t.samples)-1]
+	elapsed := newest.at.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return rates
+	}
+
+	// A counter lower than before means the collector was reset, so count
+	// from zero rather than report a negative rate
+	perSecond := func(newer, older float64) float64 {
+		if newer < older {
+			older = 0
+		}
+		return (newer - older) / elapsed
+	}
+	for name, requests := range newest.requests {
+		rates[name] = serviceRate{
+			requests: perSecond(requests, oldest.requests[name]),
+			errors:   perSecond(newest.errors[name], oldest.errors[name]),
+		}
+	}
+	return rates
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateTracker(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	collector := metrics.NewCollector()
+	require.NoError(t, collector.Register(reg))
+	tracker := newRateTracker(reg, time.Minute)
+
+	count := func(service, status string, n int) {
+		for range n {
+			collector.RequestsTotal.WithLabelValues(service, status).Inc()
+		}
+	}
+
+	start := time.Now()
+	count("api", "200", 5)
+	tracker.sample(start)
+	assert.Empty(t, tracker.rates(), "rates need two samples")
+
+	count("api", "200", 15)
+	count("api", "502", 5)
+	count("web", "404", 10)
+	tracker.sample(start.Add(10 * time.Second))
+
+	rates := tracker.rates()
+	assert.InDelta(t, 2.0, rates["api"].requests, 0.001)
+	assert.InDelta(t, 0.5, rates["api"].errors, 0.001)
+	assert.InDelta(t, 1.0, rates["web"].requests, 0.001)
+	assert.Zero(t, rates["web"].errors, "4xx responses are not errors")
+
+	t.Run("old samples leave the window", func(t *testing.T) {
+		tracker.sample(start.Add(50 * time.Second))
+		tracker.sample(start.Add(70 * time.Second))
+
+		// Only the samples at 10s, 50s and 70s remain, and nothing happened since 10s
+		rates := tracker.rates()
+		assert.Zero(t, rates["api"].requests)
+		assert.Zero(t, rates["web"].requests)
+	})
+}
@@ -4,26 +4,55 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jtdowney/tsbridge/internal/accesslog"
+	"github.com/jtdowney/tsbridge/internal/admin"
 	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
 	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
 	"github.com/jtdowney/tsbridge/internal/metrics"
 	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/jtdowney/tsbridge/internal/systemd"
 	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/jtdowney/tsbridge/internal/tracing"
+	"github.com/jtdowney/tsbridge/internal/webhook"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // App encapsulates the tsbridge application lifecycle
 type App struct {
 	cfg           *config.Config
+	cfgSnapshot   atomic.Pointer[config.Config] // cfg, read without the lock by readiness probes and the admin API
 	provider      config.Provider
 	tsServer      *tailscale.Server
 	registry      *service.Registry
 	metricsServer *metrics.Server
+	adminServer   *admin.Server
+	dashboard     *admin.Dashboard
+	gatherer      prometheus.Gatherer                // Metrics registry, shared by the metrics server and dashboard
+	tracing       *sdktrace.TracerProvider           // Exports spans when tracing_endpoint is set
+	accessLog     *accesslog.Logger                  // Dedicated access log when access_log_output is set
+	events        *events.Bus                        // Lifecycle events, served by the admin API
+	auditLog      *events.AuditLog                   // Records every event when audit_log is set
+	webhooks      *webhook.Notifier                  // Posts events to the configured webhooks
+	retiring      sync.WaitGroup                     // Notifiers replaced by a reload delivering their queued events
+	lastReload    atomic.Pointer[admin.ReloadStatus] // Outcome of the last reload, nil before the first
+	ready         atomic.Bool                        // Startup finished and shutdown has not begun
+	notifier      *systemd.Notifier
+	stopWatchdog  context.CancelFunc
+	configureLogs func(config.Global) error
 	startOnce     sync.Once
 	stopOnce      sync.Once
 	configWatcher context.CancelFunc
@@ -35,6 +64,14 @@ type Options struct {
 	TSServer *tailscale.Server
 	Registry *service.Registry
 	Provider config.Provider
+	Version  string            // Reported by the admin API and dashboard
+	Notifier *systemd.Notifier // Receives readiness and reload notifications for systemd (default: none)
+	// TracerProvider records request spans instead of one created from the
+	// tracing settings
+	TracerProvider trace.TracerProvider
+	// ConfigureLogging applies the logging settings of the loaded configuration
+	// and of every reload (default: logging settings are ignored)
+	ConfigureLogging func(config.Global) error
 }
 
 // NewApp creates a new App instance with the given configuration
@@ -68,6 +105,12 @@ func NewAppWithOptions(cfg *config.Config, opts Options) (*App, error) {
 		return nil, err
 	}
 
+	if opts.ConfigureLogging != nil {
+		if err := opts.ConfigureLogging(cfg.Global); err != nil {
+			return nil, tserrors.WrapConfig(err, "failed to configure logging")
+		}
+	}
+
 	var tsServer *tailscale.Server
 	var registry *service.Registry
 	var err error
@@ -93,14 +136,49 @@ func NewAppWithOptions(cfg *config.Config, opts Options) (*App, error) {
 	}
 
 	app := &App{
-		cfg:      cfg,
-		provider: opts.Provider,
-		tsServer: tsServer,
-		registry: registry,
+		cfg:           cfg,
+		provider:      opts.Provider,
+		tsServer:      tsServer,
+		registry:      registry,
+		notifier:      opts.Notifier,
+		configureLogs: opts.ConfigureLogging,
+	}
+	app.cfgSnapshot.Store(cfg)
+	if app.notifier == nil {
+		app.notifier = systemd.NewNotifier("", 0)
+	}
+
+	// Publish lifecycle events from the services and, if it supports them, the
+	// configuration provider
+	app.events = events.NewBus(constants.DefaultEventHistory)
+	registry.SetEventBus(app.events)
+	if publisher, ok := opts.Provider.(eventPublisher); ok {
+		publisher.SetEventBus(app.events)
+	}
+
+	// On-demand services have their containers started and stopped by the
+	// provider that found them
+	if controller, ok := opts.Provider.(service.ContainerController); ok {
+		registry.SetContainerController(controller)
+	}
+	if cfg.Global.AuditLog != "" {
+		auditLog, err := events.OpenAuditLog(cfg.Global.AuditLog)
+		if err != nil {
+			if opts.TSServer == nil {
+				tsServer.Close()
+			}
+			return nil, tserrors.WrapResource(err, "failed to open audit log")
+		}
+		app.auditLog = auditLog
+		app.events.AddSink(auditLog)
+	}
+	if len(cfg.Webhooks) > 0 {
+		app.webhooks = webhook.New(cfg.Webhooks)
+		app.events.AddSink(app.webhooks)
 	}
 
-	// Setup metrics if configured
-	if cfg.Global.MetricsAddr != "" {
+	// Setup metrics if configured. The dashboard derives its rates from them.
+	if cfg.Global.MetricsAddr != "" || cfg.Global.DashboardHostname != "" {
 		if err := app.setupMetrics(); err != nil {
 			// Clean up tsServer if metrics setup fails and we created it
 			if opts.TSServer == nil {
@@ -110,6 +188,55 @@ func NewAppWithOptions(cfg *config.Config, opts Options) (*App, error) {
 		}
 	}
 
+	// Setup tracing if configured
+	if opts.TracerProvider != nil {
+		registry.SetTracerProvider(opts.TracerProvider)
+	} else if cfg.Global.TracingEndpoint != "" {
+		if err := app.setupTracing(opts.Version); err != nil {
+			if opts.TSServer == nil {
+				tsServer.Close()
+			}
+			return nil, tserrors.WrapResource(err, "failed to setup tracing")
+		}
+	}
+
+	// Open the dedicated access log if configured
+	if cfg.Global.AccessLogOutput != "" {
+		sink, err := accesslog.New(cfg.Global.AccessLogOptions())
+		if err != nil {
+			if opts.TSServer == nil {
+				tsServer.Close()
+			}
+			return nil, tserrors.WrapResource(err, "failed to open access log")
+		}
+		app.accessLog = sink
+		registry.SetAccessLogSink(sink)
+	}
+
+	// Create the admin API server if configured (but don't start it yet)
+	if cfg.Global.AdminAddr != "" {
+		app.adminServer = admin.NewServer(admin.Options{
+			Addr:           cfg.Global.AdminAddr,
+			Allowed:        cfg.Global.AdminAllowed,
+			Tags:           cfg.Tailscale.DefaultTags,
+			StartupTimeout: cfg.Global.StartupTimeout,
+			Version:        opts.Version,
+			Events:         app.events,
+		}, app, tsServer)
+	}
+
+	// Create the dashboard if configured (but don't start it yet)
+	if cfg.Global.DashboardHostname != "" {
+		app.dashboard = admin.NewDashboard(admin.DashboardOptions{
+			Hostname:       cfg.Global.DashboardHostname,
+			Allowed:        cfg.Global.DashboardAllowed,
+			Tags:           cfg.Tailscale.DefaultTags,
+			StartupTimeout: cfg.Global.StartupTimeout,
+			Version:        opts.Version,
+			Metrics:        app.gatherer,
+		}, app, tsServer)
+	}
+
 	return app, nil
 }
 
@@ -127,8 +254,16 @@ func (a *App) setupMetrics() error {
 		return tserrors.WrapResource(err, "failed to register metrics")
 	}
 
-	// Set metrics collector on registry
+	// Set metrics collector on registry and for OAuth auth key generation
 	a.registry.SetMetricsCollector(collector)
+	if a.tsServer != nil {
+		a.tsServer.SetMetricsCollector(collector)
+	}
+	a.gatherer = reg
+
+	if a.cfg.Global.MetricsAddr == "" {
+		return nil
+	}
 
 	// Create metrics server (but don't start it yet)
 	var metricsTimeout time.Duration
@@ -136,10 +271,30 @@ func (a *App) setupMetrics() error {
 		metricsTimeout = *a.cfg.Global.MetricsReadHeaderTimeout
 	}
 	a.metricsServer = metrics.NewServer(a.cfg.Global.MetricsAddr, reg, metricsTimeout)
+	a.metricsServer.SetReadinessCheck(a.readinessCheck)
 
 	return nil
 }
 
+// setupTracing creates the tracer provider that exports request spans to the
+// OTLP collector
+func (a *App) setupTracing(version string) error {
+	slog.Debug("creating tracer provider", "endpoint", a.cfg.Global.TracingEndpoint)
+	tp, err := tracing.NewProvider(context.Background(), tracing.Options{
+		Endpoint:    a.cfg.Global.TracingEndpoint,
+		Protocol:    a.cfg.Global.TracingProtocol,
+		SampleRatio: a.cfg.Global.TracingSampleRatio,
+		Version:     version,
+	})
+	if err != nil {
+		return err
+	}
+
+	a.tracing = tp
+	a.registry.SetTracerProvider(tp)
+	return nil
+}
+
 // Start starts the application and all its services
 func (a *App) Start(ctx context.Context) error {
 	var startErr error
@@ -167,9 +322,35 @@ func (a *App) Start(ctx context.Context) error {
 			slog.Info("metrics server listening", "address", a.metricsServer.Addr())
 		}
 
+		// Start admin API server if configured
+		if a.adminServer != nil {
+			slog.Debug("starting admin server", "address", a.cfg.Global.AdminAddr)
+			if err := a.adminServer.Start(ctx); err != nil {
+				startErr = tserrors.WrapResource(err, "failed to start admin server")
+				return
+			}
+			slog.Info("admin API listening", "address", a.adminServer.Addr())
+		}
+
+		// Start dashboard if configured
+		if a.dashboard != nil {
+			slog.Debug("starting dashboard", "hostname", a.cfg.Global.DashboardHostname)
+			if err := a.dashboard.Start(ctx); err != nil {
+				startErr = tserrors.WrapResource(err, "failed to start dashboard")
+				return
+			}
+			slog.Info("dashboard listening", "hostname", a.cfg.Global.DashboardHostname, "address", a.dashboard.Addr())
+		}
+
 		// Start services
 		slog.Info("starting services")
-		if err := a.registry.StartServices(); err != nil {
+		err := a.registry.StartServices()
+		a.events.Publish(events.Event{
+			Type:    events.ConfigLoaded,
+			Trigger: &events.Trigger{Source: events.SourceStartup, Actor: a.providerName()},
+			Data:    map[string]any{"services": len(a.cfg.Services), "running": a.registry.Count()},
+		})
+		if err != nil {
 			// Check if this is a partial failure
 			if startupErr, ok := tserrors.AsServiceStartupError(err); ok && !startupErr.AllFailed() {
 				// Some services started successfully, log the failures but continue
@@ -182,15 +363,37 @@ func (a *App) Start(ctx context.Context) error {
 			} else {
 				// All services failed or other error type
 				startErr = err
-				// If all services fail, shut down metrics server
+				// If all services fail, stop retrying them and shut down
+				// metrics, admin and dashboard servers
+				if shutdownErr := a.registry.Shutdown(context.Background()); shutdownErr != nil {
+					slog.Error("failed to shutdown services", "error", shutdownErr)
+				}
 				if a.metricsServer != nil {
 					if shutdownErr := a.metricsServer.Shutdown(context.Background()); shutdownErr != nil {
 						slog.Error("failed to shutdown metrics server", "error", shutdownErr)
 					}
 				}
+				if a.adminServer != nil {
+					if shutdownErr := a.adminServer.Shutdown(context.Background()); shutdownErr != nil {
+						slog.Error("failed to shutdown admin server", "error", shutdownErr)
+					}
+				}
+				if a.dashboard != nil {
+					if shutdownErr := a.dashboard.Shutdown(context.Background()); shutdownErr != nil {
+						slog.Error("failed to shutdown dashboard", "error", shutdownErr)
+					}
+				}
 				return
 			}
 		}
+		a.ready.Store(true)
+
+		watchdogCtx, cancel := context.WithCancel(context.Background())
+		a.mu.Lock()
+		a.notify(systemd.StateReady, systemd.Status(a.statusLine()))
+		a.stopWatchdog = cancel
+		a.mu.Unlock()
+		go a.notifier.RunWatchdog(watchdogCtx, a.alive)
 	})
 
 	return startErr
@@ -205,15 +408,52 @@ func (a *App) Shutdown(ctx context.Context) error {
 	return shutdownErr
 }
 
+// ShutdownTimeout returns the shutdown_timeout of the running configuration,
+// the time Shutdown should be given to stop everything
+func (a *App) ShutdownTimeout() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.cfg == nil || a.cfg.Global.ShutdownTimeout == nil {
+		return constants.DefaultShutdownTimeout
+	}
+	return *a.cfg.Global.ShutdownTimeout
+}
+
 // performShutdown performs the actual shutdown sequence
 func (a *App) performShutdown(ctx context.Context) error {
 	var errs []error
 
+	// Report not ready so load balancers stop sending traffic
+	a.ready.Store(false)
+	a.notify(systemd.StateStopping, systemd.Status("Shutting down"))
+	a.mu.RLock()
+	stopWatchdog := a.stopWatchdog
+	a.mu.RUnlock()
+	if stopWatchdog != nil {
+		stopWatchdog()
+	}
+
 	// Stop config watcher if running
 	if a.configWatcher != nil {
 		a.configWatcher()
 	}
 
+	// Stop accepting admin and dashboard requests before services go away
+	if a.adminServer != nil {
+		if err := a.adminServer.Shutdown(ctx); err != nil {
+			wrappedErr := tserrors.WrapInternal(err, "failed to shutdown admin server")
+			slog.Error("failed to shutdown admin server", "error", err)
+			errs = append(errs, wrappedErr)
+		}
+	}
+	if a.dashboard != nil {
+		if err := a.dashboard.Shutdown(ctx); err != nil {
+			wrappedErr := tserrors.WrapInternal(err, "failed to shutdown dashboard")
+			slog.Error("failed to shutdown dashboard", "error", err)
+			errs = append(errs, wrappedErr)
+		}
+	}
+
 	// Shutdown services
 	if err := a.registry.Shutdown(ctx); err != nil {
 		// The error from Shutdown is already typed
@@ -230,6 +470,40 @@ func (a *App) performShutdown(ctx context.Context) error {
 		}
 	}
 
+	// Flush spans of the last requests. An unreachable collector should not
+	// fail shutdown, so only log.
+	if a.tracing != nil {
+		if err := a.tracing.Shutdown(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}
+
+	// Close the access log once no more requests are served
+	if a.accessLog != nil {
+		if err := a.accessLog.Close(); err != nil {
+			slog.Warn("failed to close access log", "error", err)
+		}
+	}
+
+	// Deliver the events of stopping services still queued for webhooks.
+	// Webhooks failing should not fail shutdown, so only log.
+	a.mu.RLock()
+	webhooks := a.webhooks
+	a.mu.RUnlock()
+	if webhooks != nil {
+		if err := webhooks.Close(ctx); err != nil {
+			slog.Warn("abandoned pending webhook deliveries", "error", err)
+		}
+	}
+	a.retiring.Wait()
+
+	// Close the audit log once services have stopped
+	if a.auditLog != nil {
+		if err := a.auditLog.Close(); err != nil {
+			slog.Warn("failed to close audit log", "error", err)
+		}
+	}
+
 	// Close tailscale server
 	if err := a.tsServer.Close(); err != nil {
 		// Check if it's a timeout error - log but don't fail shutdown
@@ -270,9 +544,16 @@ func (a *App) watchConfigChanges(ctx context.Context, configCh <-chan *config.Co
 	}
 }
 
-// ReloadConfig reloads the configuration and restarts affected services
+// ReloadConfig reloads the configuration and restarts affected services,
+// attributing the change to the configuration provider.
 // This method is exported for testing purposes
 func (a *App) ReloadConfig(newCfg *config.Config) error {
+	ctx := events.WithTrigger(context.Background(), events.Trigger{Source: events.SourceProvider, Actor: a.providerName()})
+	return a.reloadConfig(ctx, newCfg)
+}
+
+// reloadConfig applies newCfg and publishes the outcome with the trigger carried by ctx
+func (a *App) reloadConfig(ctx context.Context, newCfg *config.Config) error {
 	start := time.Now()
 
 	a.mu.Lock()
@@ -280,24 +561,206 @@ func (a *App) ReloadConfig(newCfg *config.Config) error {
 
 	oldCfg := a.cfg
 
-	// Use the extracted reload logic
-	err := reloadConfigWithRegistry(oldCfg, newCfg, a.registry)
+	a.notify(systemd.Reloading(), systemd.Status("Reloading configuration"))
+
+	// The new configuration's reload_mode applies to the reload that loads it
+	mode := newCfg.Global.ReloadMode
+	if mode == "" {
+		mode = constants.ReloadModeBestEffort
+	}
+	transactional := mode == constants.ReloadModeTransactional
+
+	// A transactional reload only changes logging once the services changed
+	if !transactional {
+		a.applyLogging(oldCfg, newCfg)
+		a.applyWebhooks(oldCfg, newCfg)
+	}
+
+	plan := PlanReload(oldCfg, newCfg)
+	var err error
+	if transactional {
+		err = reloadTransactional(oldCfg, newCfg, a.registry)
+	} else {
+		err = reloadConfigWithRegistry(oldCfg, newCfg, a.registry)
+	}
+	reloadErr, _ := errors.AsType[*tserrors.ReloadError](err)
+	rolledBack := reloadErr != nil && reloadErr.RolledBack
+	if transactional && err == nil {
+		a.applyLogging(oldCfg, newCfg)
+		a.applyWebhooks(oldCfg, newCfg)
+	}
+
+	status := admin.ReloadStatus{
+		Time:       start,
+		Mode:       mode,
+		Success:    err == nil,
+		RolledBack: rolledBack,
+	}
+	// A rolled back reload leaves the previous configuration in effect
+	effective := newCfg
+	if rolledBack {
+		effective = oldCfg
+	}
+	status.Desired, status.Applied, status.OutOfSync = a.configState(effective)
+
+	event := events.Event{Type: events.ConfigReloaded, Data: map[string]any{"plan": plan}}
+	if err != nil {
+		status.Error = err.Error()
+		event.Type = events.ConfigReloadFailed
+		event.Error = err.Error()
+		if rolledBack {
+			event.Data["rolled_back"] = true
+		}
+	}
+	if len(status.OutOfSync) > 0 {
+		event.Data["out_of_sync"] = status.OutOfSync
+	}
+	a.publish(ctx, event)
+	a.lastReload.Store(&status)
 
 	// Record reload metrics if collector is available
 	if a.registry != nil {
 		if collector := a.registry.GetMetricsCollector(); collector != nil {
 			success := err == nil
 			collector.RecordConfigReload(success, time.Since(start))
+			if rolledBack {
+				collector.RecordConfigRollback(len(reloadErr.RollbackErrors) == 0)
+			}
+			collector.SetConfigServices(status.Desired, status.Applied)
 		}
 	}
 
-	// Always update the config, even if some operations failed
-	// This ensures we're working with the latest intended configuration
-	a.cfg = newCfg
+	// A rolled back reload keeps the previous configuration, which the services
+	// match again. Otherwise the new configuration is kept even if some changes
+	// failed, and the services that differ from it are reported as out of sync.
+	if !rolledBack {
+		a.setConfig(newCfg)
+	}
+	a.notify(systemd.StateReady, systemd.Status(a.statusLine()))
 
 	return err
 }
 
+// setConfig replaces the running configuration. Callers hold a.mu.
+func (a *App) setConfig(cfg *config.Config) {
+	a.cfg = cfg
+	a.cfgSnapshot.Store(cfg)
+}
+
+// applyLogging reconfigures logging when its settings differ between oldCfg and newCfg
+func (a *App) applyLogging(oldCfg, newCfg *config.Config) {
+	if a.configureLogs == nil || reflect.DeepEqual(oldCfg.Global.LoggingOptions(), newCfg.Global.LoggingOptions()) {
+		return
+	}
+	// Keep logging as it was rather than fail the service changes
+	if err := a.configureLogs(newCfg.Global); err != nil {
+		slog.Error("failed to apply logging configuration", "error", err)
+	} else {
+		slog.Info("applied logging configuration")
+	}
+}
+
+// applyWebhooks replaces the webhook notifier when the webhooks differ between
+// oldCfg and newCfg. The replaced notifier still delivers the events it has
+// queued, up to the shutdown timeout. Callers hold a.mu.
+func (a *App) applyWebhooks(oldCfg, newCfg *config.Config) {
+	if reflect.DeepEqual(oldCfg.Webhooks, newCfg.Webhooks) {
+		return
+	}
+
+	var old, replacement events.Sink
+	retired := a.webhooks
+	if retired != nil {
+		old = retired
+	}
+	a.webhooks = nil
+	if len(newCfg.Webhooks) > 0 {
+		a.webhooks = webhook.New(newCfg.Webhooks)
+		replacement = a.webhooks
+	}
+	a.events.ReplaceSink(old, replacement)
+	slog.Info("applied webhook configuration", "webhooks", len(newCfg.Webhooks))
+
+	if retired != nil {
+		timeout := constants.DefaultShutdownTimeout
+		if newCfg.Global.ShutdownTimeout != nil {
+			timeout = *newCfg.Global.ShutdownTimeout
+		}
+		a.retiring.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := retired.Close(ctx); err != nil {
+				slog.Warn("abandoned pending deliveries of replaced webhooks", "error", err)
+			}
+		})
+	}
+}
+
+// configState compares the running services with those cfg configures. It
+// returns the number of configured services, how many of them run with their
+// configured settings, and the names of the others followed by any services
+// running that cfg does not configure.
+func (a *App) configState(cfg *config.Config) (desired, applied int, outOfSync []string) {
+	running := a.registry.Configs()
+	for _, want := range cfg.Services {
+		if got, ok := running[want.Name]; ok && config.ServiceConfigEqual(got, want) {
+			applied++
+		} else {
+			outOfSync = append(outOfSync, want.Name)
+		}
+		delete(running, want.Name)
+	}
+	outOfSync = append(outOfSync, slices.Sorted(maps.Keys(running))...)
+	return len(cfg.Services), applied, outOfSync
+}
+
+// eventPublisher is implemented by configuration providers that publish
+// events of their own, such as Docker container events
+type eventPublisher interface {
+	SetEventBus(bus *events.Bus)
+}
+
+// providerName returns the name of the configuration provider, if any
+func (a *App) providerName() string {
+	if a.provider == nil {
+		return ""
+	}
+	return a.provider.Name()
+}
+
+// publish publishes e with the trigger carried by ctx, if any
+func (a *App) publish(ctx context.Context, e events.Event) {
+	if t, ok := events.TriggerFromContext(ctx); ok {
+		e.Trigger = &t
+	}
+	a.events.Publish(e)
+}
+
+// notify sends a notification to systemd, logging failures since they never
+// affect serving
+func (a *App) notify(states ...string) {
+	if err := a.notifier.Notify(states...); err != nil {
+		slog.Warn("failed to notify systemd", "error", err)
+	}
+}
+
+// alive is the watchdog's liveness check. Every service change goes through
+// the registry, so systemd restarts tsbridge if it deadlocks. Neither it nor
+// a.mu is waited on, as a reload holds a.mu while services stop and start.
+func (a *App) alive(context.Context) error {
+	return a.registry.Responsive()
+}
+
+// statusLine describes the running services for systemd's STATUS field. The
+// caller must hold a.mu.
+func (a *App) statusLine() string {
+	running, configured := a.registry.Count(), len(a.cfg.Services)
+	if running == configured {
+		return fmt.Sprintf("Serving %d services", running)
+	}
+	return fmt.Sprintf("Serving %d of %d services", running, configured)
+}
+
 // findServicesToRemove returns names of services in old config not present in new config.
 func findServicesToRemove(old, new *config.Config) []string {
 	newServices := make(map[string]bool)
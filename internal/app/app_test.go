@@ -3,19 +3,26 @@ package app
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
 	"github.com/jtdowney/tsbridge/internal/errors"
 	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/jtdowney/tsbridge/internal/systemd"
 	"github.com/jtdowney/tsbridge/internal/tailscale"
 	"github.com/jtdowney/tsbridge/internal/testhelpers"
 	"github.com/jtdowney/tsbridge/internal/testutil"
 	"github.com/jtdowney/tsbridge/internal/tsnet"
 	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -641,6 +648,22 @@ func TestAppShutdownCanBeCalledIndependently(t *testing.T) {
 	}
 }
 
+func TestAppShutdownTimeout(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.Global.ShutdownTimeout = testhelpers.DurationPtr(45 * time.Second)
+	tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+	app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Second, app.ShutdownTimeout())
+
+	// A reload changes the timeout given to the next shutdown
+	newCfg := createTestConfig(t)
+	newCfg.Services = cfg.Services
+	newCfg.Global.ShutdownTimeout = testhelpers.DurationPtr(5 * time.Second)
+	require.NoError(t, app.ReloadConfig(newCfg))
+	assert.Equal(t, 5*time.Second, app.ShutdownTimeout())
+}
+
 func TestAppShutdownErrorTypes(t *testing.T) {
 	t.Run("shutdown errors are collected properly", func(t *testing.T) {
 		// Create a valid minimal config
@@ -913,6 +936,111 @@ func TestAppPartialFailureLogging(t *testing.T) {
 	t.Skip("Log capture test not implemented")
 }
 
+func TestAppSystemdNotifications(t *testing.T) {
+	// Stand in for systemd's notification socket, keeping the path short
+	// enough for the unix socket limit
+	dir, err := os.MkdirTemp("", "tsbridge")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	receive := func(t *testing.T) string {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	cfg := createTestConfig(t)
+	tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+	app, err := NewAppWithOptions(cfg, Options{
+		TSServer: tsServer,
+		Notifier: systemd.NewNotifier(socket, 0),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, app.Start(t.Context()))
+	assert.Equal(t, "READY=1\nSTATUS=Serving 1 services", receive(t))
+
+	newCfg := createTestConfig(t)
+	newCfg.Services = append(newCfg.Services, config.Service{Name: "web", BackendAddr: newCfg.Services[0].BackendAddr})
+	newCfg.SetDefaults()
+	require.NoError(t, app.ReloadConfig(newCfg))
+	assert.Regexp(t, `^RELOADING=1\nMONOTONIC_USEC=[1-9][0-9]*\nSTATUS=Reloading configuration$`, receive(t))
+	assert.Equal(t, "READY=1\nSTATUS=Serving 2 services", receive(t))
+
+	require.NoError(t, app.Shutdown(context.Background()))
+	assert.Equal(t, "STOPPING=1\nSTATUS=Shutting down", receive(t))
+}
+
+func TestAppTracing(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		cfg := createTestConfig(t)
+		app, err := NewAppWithOptions(cfg, Options{TSServer: testutil.CreateMockTailscaleServer(t, cfg.Tailscale)})
+		require.NoError(t, err)
+		assert.Nil(t, app.tracing)
+	})
+
+	t.Run("enabled with an endpoint", func(t *testing.T) {
+		cfg := createTestConfig(t)
+		cfg.Global.TracingEndpoint = "http://127.0.0.1:4317"
+		app, err := NewAppWithOptions(cfg, Options{TSServer: testutil.CreateMockTailscaleServer(t, cfg.Tailscale)})
+		require.NoError(t, err)
+		require.NotNil(t, app.tracing)
+
+		require.NoError(t, app.Start(t.Context()))
+		// An unreachable collector must not fail shutdown
+		assert.NoError(t, app.Shutdown(context.Background()))
+	})
+}
+
+func TestAppConfigureLogging(t *testing.T) {
+	t.Run("applied on load and on changed reloads", func(t *testing.T) {
+		var applied []config.Global
+		cfg := createTestConfig(t)
+		cfg.Global.LogFormat = "json"
+		app, err := NewAppWithOptions(cfg, Options{
+			TSServer: testutil.CreateMockTailscaleServer(t, cfg.Tailscale),
+			ConfigureLogging: func(g config.Global) error {
+				applied = append(applied, g)
+				return nil
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+		assert.Equal(t, "json", applied[0].LogFormat)
+
+		unchanged := createTestConfig(t)
+		unchanged.Global.LogFormat = "json"
+		require.NoError(t, app.ReloadConfig(unchanged))
+		assert.Len(t, applied, 1, "unchanged logging settings are not reapplied")
+
+		changed := createTestConfig(t)
+		changed.Global.LogFormat = "json"
+		changed.Global.LogLevels = map[string]string{"tsnet": "error"}
+		require.NoError(t, app.ReloadConfig(changed))
+		require.Len(t, applied, 2)
+		assert.Equal(t, map[string]string{"tsnet": "error"}, applied[1].LogLevels)
+	})
+
+	t.Run("failure on load", func(t *testing.T) {
+		cfg := createTestConfig(t)
+		_, err := NewAppWithOptions(cfg, Options{
+			TSServer: testutil.CreateMockTailscaleServer(t, cfg.Tailscale),
+			ConfigureLogging: func(config.Global) error {
+				return fmt.Errorf("permission denied")
+			},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to configure logging")
+	})
+}
+
 func TestWatchConfigChanges(t *testing.T) {
 	t.Run("handles config updates", func(t *testing.T) {
 		// Create initial config
@@ -1125,6 +1253,75 @@ func TestReloadConfig(t *testing.T) {
 		assert.Equal(t, "new-service", app.cfg.Services[1].Name)
 	})
 
+	t.Run("transactional reload keeps the configuration on failure", func(t *testing.T) {
+		cfg := &config.Config{
+			Global: config.Global{MetricsAddr: "127.0.0.1:0"},
+			Tailscale: config.Tailscale{
+				StateDir: t.TempDir(),
+				AuthKey:  "test-auth-key",
+			},
+			Services: []config.Service{
+				{
+					Name:        "test-service",
+					BackendAddr: "localhost:8080",
+					Tags:        []string{"tag:test"},
+				},
+			},
+		}
+		cfg.SetDefaults()
+
+		tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+		app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.NoError(t, app.Start(ctx))
+		defer app.Shutdown(ctx)
+		assert.Nil(t, app.LastReload())
+
+		newCfg := &config.Config{
+			Global:    config.Global{MetricsAddr: "127.0.0.1:0", ReloadMode: constants.ReloadModeTransactional},
+			Tailscale: cfg.Tailscale,
+			Services: []config.Service{
+				{
+					Name:        "test-service",
+					BackendAddr: "localhost:8081",
+					Tags:        []string{"tag:test"},
+				},
+				{
+					Name:        "new-service",
+					BackendAddr: "localhost:8082",
+					Tags:        []string{"tag:test"},
+				},
+			},
+		}
+		newCfg.SetDefaults()
+		newCfg.Services[0].TLSMode = "invalid"
+
+		err = app.ReloadConfig(newCfg)
+		require.Error(t, err)
+
+		// Nothing was applied and the previous configuration is kept
+		assert.Same(t, cfg, app.cfg)
+		assert.Equal(t, []string{"test-service"}, slices.Sorted(maps.Keys(app.registry.Configs())))
+
+		status := app.LastReload()
+		require.NotNil(t, status)
+		assert.Equal(t, constants.ReloadModeTransactional, status.Mode)
+		assert.False(t, status.Success)
+		assert.True(t, status.RolledBack)
+		assert.Contains(t, status.Error, "rolled back")
+		// The state reported is that of the configuration still in effect
+		assert.Equal(t, 1, status.Desired)
+		assert.Equal(t, 1, status.Applied)
+		assert.Empty(t, status.OutOfSync)
+
+		collector := app.registry.GetMetricsCollector()
+		require.NotNil(t, collector)
+		assert.Equal(t, 1.0, promtestutil.ToFloat64(collector.ConfigServices.WithLabelValues("desired")))
+		assert.Equal(t, 1.0, promtestutil.ToFloat64(collector.ConfigServices.WithLabelValues("applied")))
+	})
+
 	t.Run("adds new services", func(t *testing.T) {
 		// Test that reloadConfig identifies and processes new services
 		// We'll use the helper functions directly to verify the logic
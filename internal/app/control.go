@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jtdowney/tsbridge/internal/admin"
+	"github.com/jtdowney/tsbridge/internal/config"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/middleware"
+	"github.com/jtdowney/tsbridge/internal/service"
+)
+
+// The methods in this file implement admin.Controller for the admin API and
+// admin.DashboardSource for the dashboard.
+
+// Services returns the status of every running service
+func (a *App) Services(ctx context.Context) []service.Status {
+	return a.registry.Statuses(ctx)
+}
+
+// Service returns the status of a single running service
+func (a *App) Service(ctx context.Context, name string) (service.Status, error) {
+	return a.registry.Status(ctx, name)
+}
+
+// RecentRequests returns the latest access log entries of a service
+func (a *App) RecentRequests(name string) ([]middleware.AccessEntry, error) {
+	return a.registry.RecentRequests(name)
+}
+
+// Settings returns the effective configuration with secrets redacted. It does
+// not wait for a reload in progress, which holds a.mu while services change.
+func (a *App) Settings() map[string]any {
+	return a.cfgSnapshot.Load().Redacted().Settings()
+}
+
+// Reload loads the configuration from the provider and applies it
+func (a *App) Reload(ctx context.Context) error {
+	if a.provider == nil {
+		return tserrors.NewValidationError("no configuration provider to reload from")
+	}
+
+	newCfg, err := a.provider.Load(ctx)
+	if err != nil {
+		return tserrors.WrapConfig(err, "failed to load config from provider")
+	}
+	return a.reloadConfig(ctx, newCfg)
+}
+
+// LastReload returns the outcome of the last configuration reload, or nil if
+// the configuration has not been reloaded
+func (a *App) LastReload() *admin.ReloadStatus {
+	last := a.lastReload.Load()
+	if last == nil {
+		return nil
+	}
+	status := *last
+	return &status
+}
+
+// RestartService stops a service and starts it again with its current configuration
+func (a *App) RestartService(ctx context.Context, name string) error {
+	return a.publishAction(ctx, events.AdminRestart, name, a.registry.RestartService(name), nil)
+}
+
+// DrainService starts or stops draining a service
+func (a *App) DrainService(ctx context.Context, name string, drain bool) error {
+	eventType := events.AdminDrain
+	if !drain {
+		eventType = events.AdminResume
+	}
+	return a.publishAction(ctx, eventType, name, a.registry.DrainService(name, drain), nil)
+}
+
+// SetAccessLog turns access logging for a service on or off
+func (a *App) SetAccessLog(ctx context.Context, name string, enabled bool) error {
+	err := a.registry.SetAccessLog(name, enabled)
+	return a.publishAction(ctx, events.AdminAccessLog, name, err, map[string]any{"enabled": enabled})
+}
+
+// RemoveService stops a service and drops it from the running configuration.
+// A service that is still configured is started again by the next reload.
+func (a *App) RemoveService(ctx context.Context, name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.registry.RemoveService(name); err != nil {
+		return a.publishAction(ctx, events.AdminRemove, name, err, nil)
+	}
+	a.publishAction(ctx, events.AdminRemove, name, nil, nil)
+
+	cfg := *a.cfg
+	cfg.Services = slices.DeleteFunc(slices.Clone(cfg.Services), func(svc config.Service) bool {
+		return svc.Name == name
+	})
+	a.setConfig(&cfg)
+	return nil
+}
+
+// publishAction publishes the outcome of a change to a service made on behalf
+// of ctx's caller and returns err
+func (a *App) publishAction(ctx context.Context, eventType, name string, err error, data map[string]any) error {
+	e := events.Event{Type: eventType, Service: name, Data: data}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	a.publish(ctx, e)
+	return err
+}
+
+// AdminAddr returns the address the admin API is listening on.
+// Returns empty string if the admin API is not running.
+func (a *App) AdminAddr() string {
+	if a.adminServer == nil {
+		return ""
+	}
+	return a.adminServer.Addr()
+}
+
+// DashboardAddr returns the address the dashboard is listening on.
+// Returns empty string if the dashboard is not running.
+func (a *App) DashboardAddr() string {
+	if a.dashboard == nil {
+		return ""
+	}
+	return a.dashboard.Addr()
+}
@@ -0,0 +1,339 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/admin"
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/service"
+	"github.com/jtdowney/tsbridge/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freeLoopbackAddr returns a loopback address with a port nothing is listening on
+func freeLoopbackAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	return addr
+}
+
+// adminChange sends a request making a change to the admin API as the CLI
+// does, returning the status code of the response
+func adminChange(t *testing.T, method, url string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	req.Header.Set(constants.AdminClientHeader, "cli")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAppAdminAPI(t *testing.T) {
+	adminAddr := freeLoopbackAddr(t)
+	stateDir := t.TempDir()
+
+	// The provider serves two services until the reload, then only one
+	var reloaded atomic.Bool
+	provider := &mockConfigProvider{
+		name: "mock",
+		loadFunc: func(ctx context.Context) (*config.Config, error) {
+			cfg := &config.Config{
+				Tailscale: config.Tailscale{StateDir: stateDir, AuthKey: "test-auth-key"},
+				Global:    config.Global{AdminAddr: adminAddr},
+				Services: []config.Service{
+					{Name: "api", BackendAddr: "localhost:8080"},
+					{Name: "web", BackendAddr: "localhost:8081"},
+				},
+			}
+			if reloaded.Load() {
+				cfg.Services = cfg.Services[:1]
+			}
+			cfg.SetDefaults()
+			return cfg, nil
+		},
+	}
+
+	tsServer := testutil.CreateMockTailscaleServer(t, config.Tailscale{AuthKey: "test-auth-key"})
+	app, err := NewAppWithOptions(nil, Options{Provider: provider, TSServer: tsServer})
+	require.NoError(t, err)
+	require.NoError(t, app.Start(t.Context()))
+	defer func() {
+		http.DefaultClient.CloseIdleConnections()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, app.Shutdown(ctx))
+	}()
+	require.Equal(t, adminAddr, app.AdminAddr())
+
+	listServices := func() []string {
+		resp, err := http.Get("http://" + adminAddr + "/v1/services")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var statuses []service.Status
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&statuses))
+		var names []string
+		for _, status := range statuses {
+			names = append(names, status.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"api", "web"}, listServices())
+
+	reloaded.Store(true)
+	require.Equal(t, http.StatusOK, adminChange(t, http.MethodPost, "http://"+adminAddr+"/v1/reload"))
+	assert.Equal(t, []string{"api"}, listServices())
+
+	require.Equal(t, http.StatusNoContent, adminChange(t, http.MethodDelete, "http://"+adminAddr+"/v1/services/api"))
+	assert.Empty(t, listServices())
+
+	// The removed service is dropped from the running configuration, so the
+	// next reload starts it again
+	app.mu.RLock()
+	assert.Empty(t, app.cfg.Services)
+	app.mu.RUnlock()
+	require.NoError(t, app.Reload(t.Context()))
+	assert.Equal(t, []string{"api"}, listServices())
+}
+
+func TestAppAuditLog(t *testing.T) {
+	adminAddr := freeLoopbackAddr(t)
+	stateDir := t.TempDir()
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+
+	loadConfig := func(names ...string) *config.Config {
+		cfg := &config.Config{
+			Tailscale: config.Tailscale{StateDir: stateDir, AuthKey: "test-auth-key"},
+			Global:    config.Global{AdminAddr: adminAddr, AuditLog: auditPath},
+		}
+		for _, name := range names {
+			cfg.Services = append(cfg.Services, config.Service{Name: name, BackendAddr: "localhost:8080"})
+		}
+		cfg.SetDefaults()
+		return cfg
+	}
+	provider := &mockConfigProvider{
+		name: "mock",
+		loadFunc: func(ctx context.Context) (*config.Config, error) {
+			return loadConfig("api", "web"), nil
+		},
+	}
+
+	tsServer := testutil.CreateMockTailscaleServer(t, config.Tailscale{AuthKey: "test-auth-key"})
+	app, err := NewAppWithOptions(nil, Options{Provider: provider, TSServer: tsServer})
+	require.NoError(t, err)
+	require.NoError(t, app.Start(t.Context()))
+
+	// A change detected by the provider
+	require.NoError(t, app.ReloadConfig(loadConfig("api")))
+
+	// Changes made through the admin API
+	require.Equal(t, http.StatusNoContent, adminChange(t, http.MethodDelete, "http://"+adminAddr+"/v1/services/api"))
+	require.Equal(t, http.StatusNotFound, adminChange(t, http.MethodPost, "http://"+adminAddr+"/v1/services/missing/restart"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, app.Shutdown(ctx))
+
+	// Every event is recorded, and those for requested changes say who made them
+	f, err := os.Open(auditPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	type triggered struct {
+		Type, Service, Trigger, Error string
+	}
+	var changes []triggered
+	var stopped []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e events.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		if e.Type == events.ServiceStopped {
+			stopped = append(stopped, e.Service)
+		}
+		if e.Trigger != nil {
+			changes = append(changes, triggered{e.Type, e.Service, e.Trigger.Source + ":" + e.Trigger.Actor, e.Error})
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	assert.Equal(t, []triggered{
+		{Type: events.ConfigLoaded, Trigger: "startup:mock"},
+		{Type: events.ConfigReloaded, Trigger: "provider:mock"},
+		{Type: events.AdminRemove, Service: "api", Trigger: "admin_api:127.0.0.1"},
+		{Type: events.AdminRestart, Service: "missing", Trigger: "admin_api:127.0.0.1", Error: "service missing not found"},
+	}, changes)
+	assert.Equal(t, []string{"web", "api"}, stopped)
+}
+
+func TestAppWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get("X-Tsbridge-Event"))
+	}))
+	defer receiver.Close()
+
+	cfg := createTestConfig(t)
+	cfg.Webhooks = map[string]config.Webhook{
+		"ops": {URL: config.RedactedString(receiver.URL), Events: []string{"service.stopped", "config"}},
+	}
+	cfg.SetDefaults()
+	tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+	app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+	require.NoError(t, err)
+	require.NoError(t, app.Start(t.Context()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, app.Shutdown(ctx))
+
+	// Shutdown waits for the events of stopping services to be delivered
+	mu.Lock()
+	defer mu.Unlock()
+	stopped := make([]string, len(cfg.Services))
+	for i := range stopped {
+		stopped[i] = events.ServiceStopped
+	}
+	assert.Equal(t, append([]string{events.ConfigLoaded}, stopped...), received)
+}
+
+func TestAppReloadWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]string{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.URL.Path] = append(received[r.URL.Path], r.Header.Get("X-Tsbridge-Event"))
+	}))
+	defer receiver.Close()
+
+	withWebhook := func(path string) *config.Config {
+		cfg := createTestConfig(t)
+		cfg.Webhooks = map[string]config.Webhook{
+			"ops": {URL: config.RedactedString(receiver.URL + path), Events: []string{"config"}},
+		}
+		return cfg
+	}
+
+	cfg := withWebhook("/old")
+	app, err := NewAppWithOptions(cfg, Options{TSServer: testutil.CreateMockTailscaleServer(t, cfg.Tailscale)})
+	require.NoError(t, err)
+	old := app.webhooks
+
+	// Unchanged webhooks keep their notifier
+	require.NoError(t, app.ReloadConfig(withWebhook("/old")))
+	assert.Same(t, old, app.webhooks)
+
+	require.NoError(t, app.ReloadConfig(withWebhook("/new")))
+	assert.NotSame(t, old, app.webhooks)
+
+	require.NoError(t, app.ReloadConfig(createTestConfig(t)))
+	assert.Nil(t, app.webhooks)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, app.Shutdown(ctx))
+
+	// Each reload is posted by the webhooks it loaded
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string][]string{
+		"/old": {events.ConfigReloaded},
+		"/new": {events.ConfigReloaded},
+	}, received)
+}
+
+func TestAppReloadWithoutProvider(t *testing.T) {
+	cfg := createTestConfig(t)
+	tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+	app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+	require.NoError(t, err)
+
+	err = app.Reload(t.Context())
+	require.Error(t, err)
+	assert.True(t, tserrors.IsValidation(err))
+	assert.Empty(t, app.AdminAddr())
+}
+
+func TestAppSettings(t *testing.T) {
+	cfg := createTestConfig(t)
+	tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+	app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+	require.NoError(t, err)
+
+	settings := app.Settings()
+	tailscale := settings["tailscale"].(map[string]any)
+	assert.Equal(t, "[REDACTED]", tailscale["auth_key"])
+
+	t.Run("does not wait for a reload", func(t *testing.T) {
+		app.lastReload.Store(&admin.ReloadStatus{Mode: "best_effort", Success: true})
+
+		// A reload holds the lock for as long as it takes to apply
+		app.mu.Lock()
+		defer app.mu.Unlock()
+		done := make(chan *admin.ReloadStatus)
+		go func() {
+			app.Settings()
+			done <- app.LastReload()
+		}()
+		select {
+		case status := <-done:
+			require.NotNil(t, status)
+			assert.True(t, status.Success)
+		case <-time.After(5 * time.Second):
+			t.Fatal("settings waited for the reload")
+		}
+	})
+}
+
+func TestAppDashboard(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.Global.DashboardHostname = "tsbridge-dashboard"
+	cfg.Global.DashboardAllowed = []string{"tag:ops"}
+
+	tsServer := testutil.CreateMockTailscaleServer(t, cfg.Tailscale)
+	app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+	require.NoError(t, err)
+
+	// The dashboard needs the metrics collector even without a metrics server
+	assert.NotNil(t, app.gatherer)
+	assert.Nil(t, app.metricsServer)
+
+	require.NoError(t, app.Start(t.Context()))
+	assert.NotEmpty(t, app.DashboardAddr())
+	assert.NotNil(t, tsServer.GetServiceServer("tsbridge-dashboard"))
+
+	recent, err := app.RecentRequests("test-service")
+	require.NoError(t, err)
+	assert.Empty(t, recent)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, app.Shutdown(ctx))
+	assert.Nil(t, tsServer.GetServiceServer("tsbridge-dashboard"))
+}
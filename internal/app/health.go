@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"sync"
+)
+
+// nodeStateRunning is the Tailscale backend state of a node connected to the tailnet
+const nodeStateRunning = "Running"
+
+// Readiness is the /readyz response
+type Readiness struct {
+	Ready    bool               `json:"ready"`
+	Reason   string             `json:"reason,omitempty"` // Why tsbridge is not ready
+	Up       int                `json:"up"`               // Services whose node is running
+	Required int                `json:"required"`         // Services that must be up to be ready
+	Services []ServiceReadiness `json:"services"`
+}
+
+// ServiceReadiness is the readiness of a single configured service
+type ServiceReadiness struct {
+	Name      string `json:"name"`
+	Up        bool   `json:"up"`
+	NodeState string `json:"node_state,omitempty"` // Tailscale backend state of the service's node
+	Error     string `json:"error,omitempty"`
+}
+
+// Readiness reports whether startup has finished and enough configured services
+// have a running tailnet node. Every service must be up unless
+// ready_min_services lowers the requirement. It does not wait for a reload
+// in progress, checking the configuration from before it.
+func (a *App) Readiness(ctx context.Context) Readiness {
+	cfg := a.cfgSnapshot.Load()
+	services := cfg.Services
+	required := len(services)
+	if minServices := cfg.Global.ReadyMinServices; minServices != nil && *minServices < required {
+		required = *minServices
+	}
+
+	readiness := Readiness{
+		Required: required,
+		Services: make([]ServiceReadiness, len(services)),
+	}
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		wg.Go(func() {
+			readiness.Services[i] = a.serviceReadiness(ctx, svc.Name)
+		})
+	}
+	wg.Wait()
+
+	for _, svc := range readiness.Services {
+		if svc.Up {
+			readiness.Up++
+		}
+	}
+
+	switch {
+	case !a.ready.Load():
+		readiness.Reason = "not started"
+	case readiness.Up < required:
+		readiness.Reason = "not enough services up"
+	default:
+		readiness.Ready = true
+	}
+	return readiness
+}
+
+// serviceReadiness checks whether a service is running with its node connected
+func (a *App) serviceReadiness(ctx context.Context, name string) ServiceReadiness {
+	readiness := ServiceReadiness{Name: name}
+	state, err := a.registry.NodeState(ctx, name)
+	if err != nil {
+		readiness.Error = err.Error()
+		return readiness
+	}
+	readiness.NodeState = state
+	readiness.Up = state == nodeStateRunning
+	return readiness
+}
+
+// readinessCheck adapts Readiness to metrics.ReadinessCheck
+func (a *App) readinessCheck(ctx context.Context) (bool, any) {
+	readiness := a.Readiness(ctx)
+	return readiness.Ready, readiness
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/jtdowney/tsbridge/internal/tsnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/ipn/ipnstate"
+)
+
+// newNodeStateServer returns a tailscale server whose service nodes report the
+// given backend states
+func newNodeStateServer(t *testing.T, states map[string]string) *tailscale.Server {
+	t.Helper()
+	server, err := tailscale.NewServerWithFactory(config.Tailscale{AuthKey: "test-auth-key", StateDir: t.TempDir()}, func(name string) tsnet.TSNetServer {
+		mock := tsnet.NewMockTSNetServer()
+		mock.LocalClientFunc = func() (tsnet.LocalClient, error) {
+			return &tsnet.MockLocalClient{
+				StatusWithoutPeersFunc: func(ctx context.Context) (*ipnstate.Status, error) {
+					return &ipnstate.Status{
+						BackendState: states[name],
+						Self:         &ipnstate.PeerStatus{DNSName: name + ".example.ts.net."},
+					}, nil
+				},
+			}, nil
+		}
+		return mock
+	})
+	require.NoError(t, err)
+	return server
+}
+
+func TestAppReadiness(t *testing.T) {
+	newApp := func(t *testing.T, minServices *int) *App {
+		cfg := createTestConfig(t)
+		cfg.Global.ReadyMinServices = minServices
+		cfg.Services = append(cfg.Services, config.Service{Name: "web", BackendAddr: cfg.Services[0].BackendAddr})
+		cfg.SetDefaults()
+
+		tsServer := newNodeStateServer(t, map[string]string{"test-service": "Running", "web": "NeedsLogin"})
+		app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+		require.NoError(t, err)
+		return app
+	}
+
+	t.Run("every service required", func(t *testing.T) {
+		app := newApp(t, nil)
+		readiness := app.Readiness(t.Context())
+		assert.False(t, readiness.Ready)
+		assert.Equal(t, "not started", readiness.Reason)
+
+		require.NoError(t, app.Start(t.Context()))
+		defer app.Shutdown(context.Background())
+
+		readiness = app.Readiness(t.Context())
+		assert.False(t, readiness.Ready)
+		assert.Equal(t, "not enough services up", readiness.Reason)
+		assert.Equal(t, 1, readiness.Up)
+		assert.Equal(t, 2, readiness.Required)
+		assert.Equal(t, []ServiceReadiness{
+			{Name: "test-service", Up: true, NodeState: "Running"},
+			{Name: "web", NodeState: "NeedsLogin"},
+		}, readiness.Services)
+	})
+
+	t.Run("minimum services", func(t *testing.T) {
+		app := newApp(t, new(1))
+		require.NoError(t, app.Start(t.Context()))
+
+		readiness := app.Readiness(t.Context())
+		assert.True(t, readiness.Ready)
+		assert.Empty(t, readiness.Reason)
+		assert.Equal(t, 1, readiness.Required)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, app.Shutdown(ctx))
+		assert.False(t, app.Readiness(t.Context()).Ready)
+	})
+
+	t.Run("service not running", func(t *testing.T) {
+		app := newApp(t, nil)
+		require.NoError(t, app.Start(t.Context()))
+		defer app.Shutdown(context.Background())
+		require.NoError(t, app.registry.RemoveService("web"))
+
+		readiness := app.Readiness(t.Context())
+		assert.False(t, readiness.Ready)
+		assert.Contains(t, readiness.Services[1].Error, "not found")
+	})
+
+	t.Run("does not wait for a reload", func(t *testing.T) {
+		app := newApp(t, new(1))
+		require.NoError(t, app.Start(t.Context()))
+		defer app.Shutdown(context.Background())
+
+		// A reload holds the lock for as long as it takes to apply
+		app.mu.Lock()
+		defer app.mu.Unlock()
+		done := make(chan Readiness)
+		go func() { done <- app.Readiness(t.Context()) }()
+		select {
+		case readiness := <-done:
+			assert.True(t, readiness.Ready)
+		case <-time.After(5 * time.Second):
+			t.Fatal("readiness waited for the reload")
+		}
+	})
+}
+
+func TestAppReadyzEndpoint(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.Global.MetricsAddr = "127.0.0.1:0"
+	tsServer := newNodeStateServer(t, map[string]string{"test-service": "Running"})
+	app, err := NewAppWithOptions(cfg, Options{TSServer: tsServer})
+	require.NoError(t, err)
+	require.NoError(t, app.Start(t.Context()))
+	defer app.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + app.MetricsAddr() + "/readyz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var readiness Readiness
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&readiness))
+	assert.True(t, readiness.Ready)
+	require.Len(t, readiness.Services, 1)
+	assert.Equal(t, "Running", readiness.Services[0].NodeState)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"github.com/jtdowney/tsbridge/internal/config"
+)
+
+// ReloadPlan describes the service changes a reload from one configuration to
+// another would make, in the order they are applied
+type ReloadPlan struct {
+	Remove []string        `json:"remove,omitempty"` // Services that will be stopped
+	Add    []string        `json:"add,omitempty"`    // Services that will be started
+	Update []ServiceUpdate `json:"update,omitempty"` // Services whose settings changed
+}
+
+// ServiceUpdate describes the changes to a single service in a ReloadPlan
+type ServiceUpdate struct {
+	Name         string               `json:"name"`
+	Changes      []config.FieldChange `json:"changes"`
+	RestartsNode bool                 `json:"restarts_node"` // The service's tsnet node is recreated
+}
+
+// PlanReload returns the changes ReloadConfig would make to move from oldCfg to newCfg
+// without applying them
+func PlanReload(oldCfg, newCfg *config.Config) ReloadPlan {
+	plan := ReloadPlan{
+		Remove: findServicesToRemove(oldCfg, newCfg),
+	}
+
+	for _, svc := range findServicesToAdd(oldCfg, newCfg) {
+		plan.Add = append(plan.Add, svc.Name)
+	}
+
+	oldServices := make(map[string]config.Service, len(oldCfg.Services))
+	for _, svc := range oldCfg.Services {
+		oldServices[svc.Name] = svc
+	}
+	for _, svc := range findServicesToUpdate(oldCfg, newCfg) {
+		oldSvc := oldServices[svc.Name]
+		plan.Update = append(plan.Update, ServiceUpdate{
+			Name:    svc.Name,
+			Changes: config.DiffService(oldSvc, svc),
+			// Handler-level changes are swapped in behind the running node
+			RestartsNode: !config.ServiceNodeEqual(oldSvc, svc),
+		})
+	}
+
+	return plan
+}
+
+// Empty reports whether the plan makes no changes
+func (p ReloadPlan) Empty() bool {
+	return len(p.Remove) == 0 && len(p.Add) == 0 && len(p.Update) == 0
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanReload(t *testing.T) {
+	oldCfg := &config.Config{
+		Services: []config.Service{
+			{Name: "api", BackendAddr: "localhost:8080", WriteTimeout: testhelpers.DurationPtr(30 * time.Second)},
+			{Name: "web", BackendAddr: "localhost:8081"},
+			{Name: "legacy", BackendAddr: "localhost:8082"},
+		},
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		plan := PlanReload(oldCfg, oldCfg)
+		assert.True(t, plan.Empty())
+	})
+
+	t.Run("add, remove and update", func(t *testing.T) {
+		newCfg := &config.Config{
+			Services: []config.Service{
+				{Name: "api", BackendAddr: "localhost:9090", WriteTimeout: testhelpers.DurationPtr(30 * time.Second)},
+				{Name: "web", BackendAddr: "localhost:8081", Tags: []string{"tag:web"}},
+				{Name: "grafana", BackendAddr: "localhost:3000"},
+			},
+		}
+
+		plan := PlanReload(oldCfg, newCfg)
+		assert.False(t, plan.Empty())
+		assert.Equal(t, []string{"legacy"}, plan.Remove)
+		assert.Equal(t, []string{"grafana"}, plan.Add)
+
+		require.Len(t, plan.Update, 2)
+		update := plan.Update[0]
+		assert.Equal(t, "api", update.Name)
+		assert.False(t, update.RestartsNode, "backend changes are applied in place")
+		assert.Equal(t, []config.FieldChange{
+			{Field: "backend_addr", Old: "localhost:8080", New: "localhost:9090"},
+		}, update.Changes)
+
+		update = plan.Update[1]
+		assert.Equal(t, "web", update.Name)
+		assert.True(t, update.RestartsNode, "tag changes recreate the node")
+		assert.Equal(t, []config.FieldChange{
+			{Field: "tags", New: []string{"tag:web"}},
+		}, update.Changes)
+	})
+}
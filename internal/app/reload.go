@@ -1,16 +1,21 @@
 package app
 
 import (
+	"errors"
 	"log/slog"
+	"slices"
 
 	"github.com/jtdowney/tsbridge/internal/config"
-	"github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/service"
 )
 
 // serviceRegistryOps is the minimal interface for dynamic config reloads.
 // It supports adding, removing, and updating services at runtime.
 type serviceRegistryOps interface {
 	AddService(svcCfg config.Service) error
+	AddServices(svcs []config.Service) []error
 	RemoveService(name string) error
 	UpdateService(name string, newCfg config.Service) error
 }
@@ -40,7 +45,7 @@ func reloadConfigWithRegistry(oldCfg, newCfg *config.Config, registry serviceReg
 	}
 
 	// Track all errors during reload
-	reloadErr := errors.NewReloadError()
+	reloadErr := tserrors.NewReloadError()
 
 	// Process removals
 	for _, name := range toRemove {
@@ -58,9 +63,10 @@ func reloadConfigWithRegistry(oldCfg, newCfg *config.Config, registry serviceReg
 		}
 	}
 
-	// Process additions
-	for _, svc := range toAdd {
-		if err := registry.AddService(svc); err != nil {
+	// Process additions, starting the new services together
+	addErrs := registry.AddServices(toAdd)
+	for i, svc := range toAdd {
+		if err := addErrs[i]; err != nil {
 			slog.Error("failed to add service",
 				"service", svc.Name,
 				"error", err,
@@ -109,3 +115,160 @@ func reloadConfigWithRegistry(oldCfg, newCfg *config.Config, registry serviceReg
 
 	return reloadErr.ToError()
 }
+
+// transactionalRegistry is the registry interface transactional reloads need
+// to check changes up front and restore services that failed to stop
+type transactionalRegistry interface {
+	serviceRegistryOps
+	ValidateService(svcCfg config.Service) error
+	RestartService(name string) error
+}
+
+// reloadStep is a change a transactional reload made, with how to undo it
+type reloadStep struct {
+	service string
+	undo    func() error
+}
+
+// reloadTransactional reloads services in the registry to match newCfg, or
+// leaves them as they were. Every change is validated before any is made, and
+// new services are started before removed ones are stopped. If a change fails,
+// those already made are undone in reverse order and a ReloadError marked
+// RolledBack is returned.
+func reloadTransactional(oldCfg, newCfg *config.Config, registry transactionalRegistry) error {
+	toRemove := findServicesToRemove(oldCfg, newCfg)
+	toAdd := findServicesToAdd(oldCfg, newCfg)
+	toUpdate := findServicesToUpdate(oldCfg, newCfg)
+
+	if len(toRemove) == 0 && len(toAdd) == 0 && len(toUpdate) == 0 {
+		slog.Info("no service configuration changes detected")
+		return nil
+	}
+	slog.Info("configuration changes detected",
+		"services_to_remove", len(toRemove),
+		"services_to_add", len(toAdd),
+		"services_to_update", len(toUpdate),
+		"reload_mode", constants.ReloadModeTransactional)
+
+	reloadErr := tserrors.NewReloadError()
+
+	// Reject the reload before touching any service
+	for _, svc := range toAdd {
+		if err := registry.ValidateService(svc); err != nil {
+			reloadErr.RecordAddError(svc.Name, err)
+		}
+	}
+	for _, svc := range toUpdate {
+		if err := registry.ValidateService(svc); err != nil {
+			reloadErr.RecordUpdateError(svc.Name, err)
+		}
+	}
+	if reloadErr.HasErrors() {
+		reloadErr.RolledBack = true
+		slog.Warn("configuration reload rejected, no services were changed",
+			"failed_operations", reloadErr.Failed)
+		return reloadErr
+	}
+
+	oldServices := make(map[string]config.Service, len(oldCfg.Services))
+	for _, svc := range oldCfg.Services {
+		oldServices[svc.Name] = svc
+	}
+
+	var steps []reloadStep
+	rollback := func() error {
+		reloadErr.RolledBack = true
+		for _, step := range slices.Backward(steps) {
+			if err := step.undo(); err != nil {
+				slog.Error("failed to restore service during rollback",
+					"service", step.service,
+					"error", err,
+					"operation", "reload_rollback")
+				reloadErr.RecordRollbackError(step.service, err)
+			}
+		}
+		slog.Warn("configuration reload failed and was rolled back",
+			"failed_operations", reloadErr.Failed,
+			"undone_operations", len(steps),
+			"restore_errors", len(reloadErr.RollbackErrors))
+		return reloadErr
+	}
+
+	// Start new services first, so removed ones keep serving until they are up
+	addErrs := registry.AddServices(toAdd)
+	addFailed := false
+	for i, svc := range toAdd {
+		// The registry keeps retrying a service that failed to start, so
+		// every added service is removed on rollback
+		steps = append(steps, reloadStep{svc.Name, func() error {
+			return registry.RemoveService(svc.Name)
+		}})
+		if err := addErrs[i]; err != nil {
+			slog.Error("failed to add service",
+				"service", svc.Name,
+				"error", err,
+				"operation", "reload_add",
+				"backend", svc.BackendAddr)
+			reloadErr.RecordAddError(svc.Name, err)
+			addFailed = true
+			continue
+		}
+		reloadErr.RecordSuccess()
+	}
+	if addFailed {
+		return rollback()
+	}
+
+	for _, svc := range toUpdate {
+		restore := reloadStep{svc.Name, func() error {
+			return restoreService(registry, oldServices[svc.Name])
+		}}
+		if err := registry.UpdateService(svc.Name, svc); err != nil {
+			slog.Error("failed to update service",
+				"service", svc.Name,
+				"error", err,
+				"operation", "reload_update",
+				"backend", svc.BackendAddr)
+			reloadErr.RecordUpdateError(svc.Name, err)
+			// A failed update may have stopped the service
+			steps = append(steps, restore)
+			return rollback()
+		}
+		reloadErr.RecordSuccess()
+		steps = append(steps, restore)
+	}
+
+	for _, name := range toRemove {
+		if err := registry.RemoveService(name); err != nil {
+			slog.Error("failed to remove service",
+				"service", name,
+				"error", err,
+				"operation", "reload_remove")
+			reloadErr.RecordRemoveError(name, err)
+			// The service may be left half stopped, so restart it
+			steps = append(steps, reloadStep{name, func() error {
+				return registry.RestartService(name)
+			}})
+			return rollback()
+		}
+		reloadErr.RecordSuccess()
+		steps = append(steps, reloadStep{name, func() error {
+			return registry.AddService(oldServices[name])
+		}})
+	}
+
+	slog.Info("configuration reload completed successfully",
+		"operations", reloadErr.Successful,
+		"reload_mode", constants.ReloadModeTransactional)
+	return nil
+}
+
+// restoreService puts a service back to cfg, starting it again if a failed
+// update stopped it
+func restoreService(registry serviceRegistryOps, cfg config.Service) error {
+	err := registry.UpdateService(cfg.Name, cfg)
+	if errors.Is(err, service.ErrNotFound) {
+		return registry.AddService(cfg)
+	}
+	return err
+}
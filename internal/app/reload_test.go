@@ -3,9 +3,14 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"testing"
 
 	"github.com/jtdowney/tsbridge/internal/config"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -44,6 +49,20 @@ func (m *mockServiceRegistry) AddService(svcCfg config.Service) error {
 	return nil
 }
 
+func (m *mockServiceRegistry) AddServices(svcs []config.Service) []error {
+	return addEach(m.AddService, svcs)
+}
+
+// addEach adds svcs one at a time with add, like a registry starting one
+// service at a time
+func addEach(add func(config.Service) error, svcs []config.Service) []error {
+	errs := make([]error, len(svcs))
+	for i, svc := range svcs {
+		errs[i] = add(svc)
+	}
+	return errs
+}
+
 func (m *mockServiceRegistry) RemoveService(name string) error {
 	m.removeServiceCalls = append(m.removeServiceCalls, name)
 	if m.removeServiceError != nil {
@@ -274,3 +293,199 @@ func (m *mockServiceRegistryWithConditions) AddService(svcCfg config.Service) er
 	m.services[svcCfg.Name] = &svcCfg
 	return nil
 }
+
+func (m *mockServiceRegistryWithConditions) AddServices(svcs []config.Service) []error {
+	return addEach(m.AddService, svcs)
+}
+
+// fakeTransactionalRegistry records the operations a transactional reload makes
+// and fails those listed in failures, keyed by "operation:service"
+type fakeTransactionalRegistry struct {
+	services map[string]config.Service
+	failures map[string]error
+	calls    []string
+}
+
+func newFakeTransactionalRegistry(services []config.Service, failures map[string]error) *fakeTransactionalRegistry {
+	r := &fakeTransactionalRegistry{
+		services: make(map[string]config.Service),
+		failures: failures,
+	}
+	for _, svc := range services {
+		r.services[svc.Name] = svc
+	}
+	return r
+}
+
+func (r *fakeTransactionalRegistry) call(op, name string) error {
+	r.calls = append(r.calls, op+":"+name)
+	return r.failures[op+":"+name]
+}
+
+func (r *fakeTransactionalRegistry) ValidateService(svcCfg config.Service) error {
+	return r.call("validate", svcCfg.Name)
+}
+
+func (r *fakeTransactionalRegistry) AddService(svcCfg config.Service) error {
+	if err := r.call("add", svcCfg.Name); err != nil {
+		return err
+	}
+	r.services[svcCfg.Name] = svcCfg
+	return nil
+}
+
+func (r *fakeTransactionalRegistry) AddServices(svcs []config.Service) []error {
+	return addEach(r.AddService, svcs)
+}
+
+func (r *fakeTransactionalRegistry) UpdateService(name string, newCfg config.Service) error {
+	if _, ok := r.services[name]; !ok {
+		r.calls = append(r.calls, "update:"+name)
+		return fmt.Errorf("service %s %w", name, service.ErrNotFound)
+	}
+	if err := r.call("update", name); err != nil {
+		// Like the registry, a failed update leaves the service stopped
+		delete(r.services, name)
+		return err
+	}
+	r.services[name] = newCfg
+	return nil
+}
+
+func (r *fakeTransactionalRegistry) RemoveService(name string) error {
+	if err := r.call("remove", name); err != nil {
+		return err
+	}
+	delete(r.services, name)
+	return nil
+}
+
+func (r *fakeTransactionalRegistry) RestartService(name string) error {
+	return r.call("restart", name)
+}
+
+func (r *fakeTransactionalRegistry) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestReloadTransactional(t *testing.T) {
+	oldServices := []config.Service{
+		{Name: "keep", BackendAddr: "http://localhost:8001"},
+		{Name: "change", BackendAddr: "http://localhost:8002"},
+		{Name: "drop", BackendAddr: "http://localhost:8003"},
+	}
+	newServices := []config.Service{
+		{Name: "keep", BackendAddr: "http://localhost:8001"},
+		{Name: "change", BackendAddr: "http://localhost:8022"},
+		{Name: "new", BackendAddr: "http://localhost:8004"},
+	}
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name           string
+		failures       map[string]error
+		wantCalls      []string
+		wantRolledBack bool
+		wantRestoreErr []string
+		wantServices   []config.Service
+	}{
+		{
+			name: "success",
+			wantCalls: []string{
+				"validate:new", "validate:change",
+				"add:new", "update:change", "remove:drop",
+			},
+			wantServices: newServices,
+		},
+		{
+			name:     "validation rejects the reload",
+			failures: map[string]error{"validate:change": failed},
+			wantCalls: []string{
+				"validate:new", "validate:change",
+			},
+			wantRolledBack: true,
+			wantServices:   oldServices,
+		},
+		{
+			name:     "add failure",
+			failures: map[string]error{"add:new": failed},
+			wantCalls: []string{
+				"validate:new", "validate:change",
+				"add:new",
+				"remove:new",
+			},
+			wantRolledBack: true,
+			wantServices:   oldServices,
+		},
+		{
+			name:     "update failure restores the old service",
+			failures: map[string]error{"update:change": failed},
+			wantCalls: []string{
+				"validate:new", "validate:change",
+				"add:new", "update:change",
+				"update:change", "add:change", "remove:new",
+			},
+			wantRolledBack: true,
+			wantServices:   oldServices,
+		},
+		{
+			name:     "remove failure restarts the service",
+			failures: map[string]error{"remove:drop": failed},
+			wantCalls: []string{
+				"validate:new", "validate:change",
+				"add:new", "update:change", "remove:drop",
+				"restart:drop", "update:change", "remove:new",
+			},
+			wantRolledBack: true,
+			wantServices:   oldServices,
+		},
+		{
+			name: "rollback failure",
+			failures: map[string]error{
+				"remove:drop": failed,
+				"remove:new":  failed,
+			},
+			wantCalls: []string{
+				"validate:new", "validate:change",
+				"add:new", "update:change", "remove:drop",
+				"restart:drop", "update:change", "remove:new",
+			},
+			wantRolledBack: true,
+			wantRestoreErr: []string{"new"},
+			wantServices:   append(slices.Clone(oldServices), newServices[2]),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newFakeTransactionalRegistry(oldServices, tt.failures)
+			err := reloadTransactional(
+				&config.Config{Services: oldServices},
+				&config.Config{Services: newServices},
+				registry,
+			)
+
+			assert.Equal(t, tt.wantCalls, registry.calls)
+			assert.ElementsMatch(t, tt.wantServices, slices.Collect(maps.Values(registry.services)))
+			if !tt.wantRolledBack {
+				require.NoError(t, err)
+				return
+			}
+
+			reloadErr, ok := errors.AsType[*tserrors.ReloadError](err)
+			require.True(t, ok, "expected a ReloadError, got %v", err)
+			assert.True(t, reloadErr.RolledBack)
+			assert.Equal(t, 1, reloadErr.Failed)
+			assert.ElementsMatch(t, tt.wantRestoreErr, slices.Collect(maps.Keys(reloadErr.RollbackErrors)))
+		})
+	}
+}
+
+func TestReloadTransactional_NoChanges(t *testing.T) {
+	services := []config.Service{{Name: "keep", BackendAddr: "http://localhost:8001"}}
+	registry := newFakeTransactionalRegistry(services, nil)
+
+	err := reloadTransactional(&config.Config{Services: services}, &config.Config{Services: services}, registry)
+	require.NoError(t, err)
+	assert.Empty(t, registry.calls)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/jtdowney/tsbridge/internal/accesslog"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+)
+
+// validateAccessLog validates the dedicated access log settings
+func (c *Config) validateAccessLog() error {
+	g := c.Global
+	if g.AccessLogOutput == "" {
+		if g.AccessLogFormat != "" || g.AccessLogTemplate != "" || g.AccessLogMaxSize != nil || g.AccessLogMaxFiles != nil ||
+			len(g.AccessLogStatus) > 0 || g.AccessLogSampleRatio != nil {
+			return errors.NewValidationError("access_log_format, access_log_template, access_log_max_size, access_log_max_files, access_log_status and access_log_sample_ratio require access_log_output")
+		}
+		return nil
+	}
+
+	switch g.AccessLogFormat {
+	case "", constants.AccessLogFormatJSON, constants.AccessLogFormatCombined:
+		if g.AccessLogTemplate != "" {
+			return errors.NewValidationError(fmt.Sprintf("access_log_template requires access_log_format = %q", constants.AccessLogFormatTemplate))
+		}
+	case constants.AccessLogFormatTemplate:
+		if _, err := accesslog.ParseTemplate(g.AccessLogTemplate); err != nil {
+			return errors.WrapValidation(err, "invalid access_log_template")
+		}
+	default:
+		return errors.NewValidationError(fmt.Sprintf("access_log_format must be %q, %q or %q, got %q", constants.AccessLogFormatJSON, constants.AccessLogFormatCombined, constants.AccessLogFormatTemplate, g.AccessLogFormat))
+	}
+
+	isFile := g.AccessLogOutput != constants.AccessLogOutputStdout && g.AccessLogOutput != constants.AccessLogOutputStderr &&
+		!accesslog.IsSyslogOutput(g.AccessLogOutput)
+	if accesslog.IsSyslogOutput(g.AccessLogOutput) && g.AccessLogOutput != constants.AccessLogOutputSyslog {
+		if _, err := accesslog.ParseSyslogURL(g.AccessLogOutput); err != nil {
+			return errors.WrapValidation(err, "invalid access_log_output")
+		}
+	}
+	if !isFile && (g.AccessLogMaxSize != nil || g.AccessLogMaxFiles != nil) {
+		return errors.NewValidationError("access_log_max_size and access_log_max_files require a file access_log_output")
+	}
+	if g.AccessLogMaxSize != nil && *g.AccessLogMaxSize < 0 {
+		return errors.NewValidationError(fmt.Sprintf("access_log_max_size must not be negative, got %d", *g.AccessLogMaxSize))
+	}
+	if g.AccessLogMaxFiles != nil && *g.AccessLogMaxFiles < 0 {
+		return errors.NewValidationError(fmt.Sprintf("access_log_max_files must not be negative, got %d", *g.AccessLogMaxFiles))
+	}
+
+	if _, err := accesslog.ParseStatusFilter(g.AccessLogStatus); err != nil {
+		return errors.WrapValidation(err, "invalid access_log_status")
+	}
+	if ratio := g.AccessLogSampleRatio; ratio != nil && !(*ratio >= 0 && *ratio <= 1) {
+		return errors.NewValidationError(fmt.Sprintf("access_log_sample_ratio must be between 0 and 1, got %v", *ratio))
+	}
+	return nil
+}
+
+// AccessLogOptions returns the dedicated access log settings of g, with
+// defaults applied to the file rotation
+func (g Global) AccessLogOptions() accesslog.Options {
+	opts := accesslog.Options{
+		Format:      g.AccessLogFormat,
+		Template:    g.AccessLogTemplate,
+		Output:      g.AccessLogOutput,
+		MaxSize:     constants.DefaultLogMaxSize,
+		MaxFiles:    constants.DefaultLogMaxFiles,
+		Statuses:    g.AccessLogStatus,
+		SampleRatio: g.AccessLogSampleRatio,
+	}
+	if g.AccessLogMaxSize != nil {
+		opts.MaxSize = *g.AccessLogMaxSize
+	}
+	if g.AccessLogMaxFiles != nil {
+		opts.MaxFiles = *g.AccessLogMaxFiles
+	}
+	return opts
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/jtdowney/tsbridge/internal/accesslog"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAccessLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  Global
+		wantErr string
+	}{
+		{name: "main log by default", global: Global{}},
+		{name: "json to stdout", global: Global{AccessLogOutput: "stdout"}},
+		{name: "combined to file", global: Global{AccessLogOutput: "/var/log/access.log", AccessLogFormat: "combined", AccessLogMaxSize: new(int64(1024)), AccessLogMaxFiles: new(2)}},
+		{name: "template", global: Global{AccessLogOutput: "stderr", AccessLogFormat: "template", AccessLogTemplate: "{{.Method}} {{.Path}} {{.Status}}"}},
+		{name: "local syslog", global: Global{AccessLogOutput: "syslog"}},
+		{name: "remote syslog", global: Global{AccessLogOutput: "syslog+tcp://logs.example.com:514"}},
+		{name: "errors sampled", global: Global{AccessLogOutput: "stdout", AccessLogStatus: []string{"4xx", "5xx", "302"}, AccessLogSampleRatio: new(0.5)}},
+		{name: "settings without output", global: Global{AccessLogFormat: "combined"}, wantErr: "require access_log_output"},
+		{name: "unknown format", global: Global{AccessLogOutput: "stdout", AccessLogFormat: "xml"}, wantErr: "access_log_format must be"},
+		{name: "template without template format", global: Global{AccessLogOutput: "stdout", AccessLogTemplate: "{{.Path}}"}, wantErr: `access_log_template requires access_log_format = "template"`},
+		{name: "missing template", global: Global{AccessLogOutput: "stdout", AccessLogFormat: "template"}, wantErr: "template is empty"},
+		{name: "unknown template field", global: Global{AccessLogOutput: "stdout", AccessLogFormat: "template", AccessLogTemplate: "{{.Bogus}}"}, wantErr: "invalid access_log_template"},
+		{name: "syslog without port", global: Global{AccessLogOutput: "syslog://logs.example.com"}, wantErr: "invalid access_log_output"},
+		{name: "rotation of stdout", global: Global{AccessLogOutput: "stdout", AccessLogMaxFiles: new(2)}, wantErr: "require a file access_log_output"},
+		{name: "negative size", global: Global{AccessLogOutput: "access.log", AccessLogMaxSize: new(int64(-1))}, wantErr: "access_log_max_size must not be negative"},
+		{name: "unknown status", global: Global{AccessLogOutput: "stdout", AccessLogStatus: []string{"6xx"}}, wantErr: "invalid access_log_status"},
+		{name: "ratio above one", global: Global{AccessLogOutput: "stdout", AccessLogSampleRatio: new(2.0)}, wantErr: "between 0 and 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateGlobal(t, tt.global, tt.wantErr)
+		})
+	}
+}
+
+func TestAccessLogOptions(t *testing.T) {
+	assert.Equal(t, accesslog.Options{
+		Output:   "access.log",
+		MaxSize:  constants.DefaultLogMaxSize,
+		MaxFiles: constants.DefaultLogMaxFiles,
+	}, Global{AccessLogOutput: "access.log"}.AccessLogOptions())
+
+	assert.Equal(t, accesslog.Options{
+		Format:      "combined",
+		Output:      "access.log",
+		MaxSize:     0,
+		MaxFiles:    1,
+		Statuses:    []string{"5xx"},
+		SampleRatio: new(0.1),
+	}, Global{
+		AccessLogOutput:      "access.log",
+		AccessLogFormat:      "combined",
+		AccessLogMaxSize:     new(int64(0)),
+		AccessLogMaxFiles:    new(1),
+		AccessLogStatus:      []string{"5xx"},
+		AccessLogSampleRatio: new(0.1),
+	}.AccessLogOptions())
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+)
+
+// Admin API listener networks returned by ParseAdminAddr
+const (
+	AdminNetworkUnix    = "unix"
+	AdminNetworkTCP     = "tcp"
+	AdminNetworkTailnet = "tailnet"
+)
+
+// ParseAdminAddr splits an admin_addr value into the network to listen on and
+// the address within it:
+//   - unix:///run/tsbridge.sock listens on a unix socket
+//   - 127.0.0.1:9000 or localhost:9000 listens on a loopback TCP address
+//   - tailnet://tsbridge-admin[:port] listens on a dedicated tailnet node, with
+//     the address returned as hostname:port
+func ParseAdminAddr(addr string) (network, address string, err error) {
+	if path, ok := strings.CutPrefix(addr, constants.AdminUnixScheme); ok {
+		if !filepath.IsAbs(path) {
+			return "", "", errors.NewValidationError(fmt.Sprintf("admin socket path must be absolute, got %q", path))
+		}
+		return AdminNetworkUnix, path, nil
+	}
+
+	if node, ok := strings.CutPrefix(addr, constants.AdminTailnetScheme); ok {
+		hostname, port := node, constants.DefaultAdminTailnetPort
+		if strings.Contains(node, ":") {
+			hostname, port, err = net.SplitHostPort(node)
+			if err != nil {
+				return "", "", errors.WrapValidation(err, fmt.Sprintf("invalid admin address %q", addr))
+			}
+		}
+		if !isValidHostname(hostname) || strings.Contains(hostname, ".") {
+			return "", "", errors.NewValidationError(fmt.Sprintf("invalid admin node hostname %q", hostname))
+		}
+		address = net.JoinHostPort(hostname, port)
+		if err := validateAddr(address, "admin address"); err != nil {
+			return "", "", err
+		}
+		return AdminNetworkTailnet, address, nil
+	}
+
+	if err := validateAddr(addr, "admin address"); err != nil {
+		return "", "", err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", "", errors.NewValidationError(fmt.Sprintf("admin address %q must be a loopback address, a unix socket or a tailnet node", addr))
+	}
+	return AdminNetworkTCP, addr, nil
+}
+
+// validateAdmin validates the admin API settings
+func (c *Config) validateAdmin() error {
+	if c.Global.AdminAddr == "" {
+		return nil
+	}
+
+	network, address, err := ParseAdminAddr(c.Global.AdminAddr)
+	if err != nil {
+		return err
+	}
+	if network != AdminNetworkTailnet {
+		return nil
+	}
+
+	// Anyone on the tailnet can reach the node, so require an explicit allow-list
+	if len(c.Global.AdminAllowed) == 0 {
+		return errors.NewValidationError("admin_allowed must list at least one login name or tag when admin_addr is a tailnet node")
+	}
+	hostname, _, _ := net.SplitHostPort(address)
+	for _, svc := range c.Services {
+		if svc.Name == hostname {
+			return errors.NewValidationError(fmt.Sprintf("admin node hostname %q conflicts with service %q", hostname, svc.Name))
+		}
+	}
+	return nil
+}
+
+// validateDashboard validates the web dashboard settings
+func (c *Config) validateDashboard() error {
+	hostname := c.Global.DashboardHostname
+	if hostname == "" {
+		return nil
+	}
+
+	if !isValidHostname(hostname) || strings.Contains(hostname, ".") {
+		return errors.NewValidationError(fmt.Sprintf("invalid dashboard hostname %q", hostname))
+	}
+	if len(c.Global.DashboardAllowed) == 0 {
+		return errors.NewValidationError("dashboard_allowed must list at least one login name or tag when dashboard_hostname is set")
+	}
+	for _, svc := range c.Services {
+		if svc.Name == hostname {
+			return errors.NewValidationError(fmt.Sprintf("dashboard hostname %q conflicts with service %q", hostname, svc.Name))
+		}
+	}
+	if network, address, err := ParseAdminAddr(c.Global.AdminAddr); err == nil && network == AdminNetworkTailnet {
+		if adminHost, _, _ := net.SplitHostPort(address); adminHost == hostname {
+			return errors.NewValidationError(fmt.Sprintf("dashboard hostname %q conflicts with admin_addr", hostname))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAdminAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr        string
+		wantNetwork string
+		wantAddress string
+		wantErr     string
+	}{
+		{name: "unix socket", addr: "unix:///run/tsbridge/admin.sock", wantNetwork: AdminNetworkUnix, wantAddress: "/run/tsbridge/admin.sock"},
+		{name: "relative unix socket", addr: "unix://admin.sock", wantErr: "must be absolute"},
+		{name: "loopback ipv4", addr: "127.0.0.1:9000", wantNetwork: AdminNetworkTCP, wantAddress: "127.0.0.1:9000"},
+		{name: "loopback ipv6", addr: "[::1]:9000", wantNetwork: AdminNetworkTCP, wantAddress: "[::1]:9000"},
+		{name: "localhost", addr: "localhost:9000", wantNetwork: AdminNetworkTCP, wantAddress: "localhost:9000"},
+		{name: "all interfaces", addr: ":9000", wantErr: "must be a loopback address"},
+		{name: "public address", addr: "192.0.2.10:9000", wantErr: "must be a loopback address"},
+		{name: "missing port", addr: "127.0.0.1", wantErr: "invalid admin address"},
+		{name: "tailnet default port", addr: "tailnet://tsbridge-admin", wantNetwork: AdminNetworkTailnet, wantAddress: "tsbridge-admin:80"},
+		{name: "tailnet custom port", addr: "tailnet://tsbridge-admin:8443", wantNetwork: AdminNetworkTailnet, wantAddress: "tsbridge-admin:8443"},
+		{name: "tailnet fqdn", addr: "tailnet://admin.example.ts.net", wantErr: "invalid admin node hostname"},
+		{name: "tailnet bad port", addr: "tailnet://tsbridge-admin:0", wantErr: "between 1 and 65535"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network, address, err := ParseAdminAddr(tt.addr)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNetwork, network)
+			assert.Equal(t, tt.wantAddress, address)
+		})
+	}
+}
+
+func TestValidateAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  Global
+		wantErr string
+	}{
+		{name: "disabled", global: Global{}},
+		{name: "loopback without allow-list", global: Global{AdminAddr: "127.0.0.1:9000"}},
+		{name: "tailnet with allow-list", global: Global{AdminAddr: "tailnet://tsbridge-admin", AdminAllowed: []string{"alice@example.com", "tag:ops"}}},
+		{name: "tailnet without allow-list", global: Global{AdminAddr: "tailnet://tsbridge-admin"}, wantErr: "admin_allowed must list"},
+		{name: "tailnet hostname matches service", global: Global{AdminAddr: "tailnet://api", AdminAllowed: []string{"tag:ops"}}, wantErr: `conflicts with service "api"`},
+		{name: "invalid address", global: Global{AdminAddr: "0.0.0.0:9000"}, wantErr: "must be a loopback address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateGlobal(t, tt.global, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateDashboard(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  Global
+		wantErr string
+	}{
+		{name: "disabled", global: Global{}},
+		{name: "enabled", global: Global{DashboardHostname: "tsbridge-dashboard", DashboardAllowed: []string{"alice@example.com"}}},
+		{name: "without allow-list", global: Global{DashboardHostname: "tsbridge-dashboard"}, wantErr: "dashboard_allowed must list"},
+		{name: "fqdn", global: Global{DashboardHostname: "dash.example.ts.net", DashboardAllowed: []string{"tag:ops"}}, wantErr: "invalid dashboard hostname"},
+		{name: "hostname matches service", global: Global{DashboardHostname: "api", DashboardAllowed: []string{"tag:ops"}}, wantErr: `conflicts with service "api"`},
+		{
+			name: "hostname matches admin node",
+			global: Global{
+				AdminAddr:         "tailnet://tsbridge-admin",
+				AdminAllowed:      []string{"tag:ops"},
+				DashboardHostname: "tsbridge-admin",
+				DashboardAllowed:  []string{"tag:ops"},
+			},
+			wantErr: "conflicts with admin_addr",
+		},
+		{
+			name: "admin on unix socket",
+			global: Global{
+				AdminAddr:         "unix:///run/tsbridge/admin.sock",
+				DashboardHostname: "tsbridge-admin",
+				DashboardAllowed:  []string{"tag:ops"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateGlobal(t, tt.global, tt.wantErr)
+		})
+	}
+}
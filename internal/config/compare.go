@@ -7,11 +7,12 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 // ServiceConfigEqual compares two service configurations and returns true if they are equal.
-// This function is used to determine if a service needs to be restarted when configuration changes.
-// It compares all fields that would require a service restart if changed.
+// This function is used to determine if a service needs to be updated when configuration changes.
+// ServiceNodeEqual and ServiceHandlerEqual compare the two halves of the settings separately.
 func ServiceConfigEqual(a, b Service) bool {
 	// Custom comparer for string slices that:
 	// - Treats nil and empty slices as equal
@@ -61,9 +62,12 @@ func ServiceConfigEqual(a, b Service) bool {
 
 	// Define comparison options
 	opts := []cmp.Option{
-		// Use custom comparer for Tags field (order doesn't matter)
+		// Interpolation templates only affect how the config is displayed
+		cmpopts.IgnoreUnexported(Service{}),
+		// Use custom comparer for Tags and DependsOn fields (order doesn't matter)
 		cmp.FilterPath(func(p cmp.Path) bool {
-			return p.String() == "Tags"
+			field := p.String()
+			return field == "Tags" || field == "DependsOn"
 		}, tagsComparer),
 		// Use custom comparer for other string slice fields (order matters)
 		cmp.FilterPath(func(p cmp.Path) bool {
@@ -81,6 +85,57 @@ func ServiceConfigEqual(a, b Service) bool {
 	return cmp.Equal(a, b, opts...)
 }
 
+// nodeSettings are the service settings used to create its tsnet node,
+// listener and HTTP server. Changing any of them recreates the node; every
+// other setting only affects the request handler and is applied in place.
+var nodeSettings = []string{
+	"name",
+	"listen_addr",
+	"tls_mode",
+	"tags",
+	"startup_timeout",
+	"funnel_enabled",
+	"ephemeral",
+	"oauth_preauthorized",
+	"read_header_timeout",
+	"write_timeout",
+	"idle_timeout",
+	"on_demand",
+	"on_demand_idle_timeout",
+}
+
+// IsNodeSetting reports whether changing the service setting with the given
+// TOML key recreates the service's tsnet node
+func IsNodeSetting(field string) bool {
+	return slices.Contains(nodeSettings, field)
+}
+
+// ServiceNodeEqual reports whether a and b have the same node-level settings,
+// so moving from one to the other does not recreate the service's tsnet node
+func ServiceNodeEqual(a, b Service) bool {
+	return ServiceConfigEqual(onlySettings(a, true), onlySettings(b, true))
+}
+
+// ServiceHandlerEqual reports whether a and b have the same handler-level
+// settings, such as the backend address, headers and proxy timeouts
+func ServiceHandlerEqual(a, b Service) bool {
+	return ServiceConfigEqual(onlySettings(a, false), onlySettings(b, false))
+}
+
+// onlySettings returns svc with either only its node-level or only its
+// handler-level settings kept and the others cleared
+func onlySettings(svc Service, node bool) Service {
+	v := reflect.ValueOf(&svc).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		tag := t.Field(i).Tag.Get("mapstructure")
+		if tag != "" && IsNodeSetting(tag) != node {
+			v.Field(i).SetZero()
+		}
+	}
+	return svc
+}
+
 // durationPtrEqual compares two time.Duration pointers
 func durationPtrEqual(a, b *time.Duration) bool {
 	if a == nil && b == nil {
@@ -91,3 +146,41 @@ func durationPtrEqual(a, b *time.Duration) bool {
 	}
 	return *a == *b
 }
+
+// FieldChange describes a single service setting that differs between two configurations
+type FieldChange struct {
+	Field string `json:"field"`         // TOML key of the setting, e.g. "write_timeout"
+	Old   any    `json:"old,omitempty"` // Previous value, redacted like Config.Redacted
+	New   any    `json:"new,omitempty"` // New value, redacted like Config.Redacted
+}
+
+// DiffService returns the settings that differ between a and b, using the same
+// rules as ServiceConfigEqual. Values are shown as written in the configuration,
+// so secrets pulled in through interpolation are not exposed.
+func DiffService(a, b Service) []FieldChange {
+	av := reflect.ValueOf(a)
+	bv := reflect.ValueOf(b)
+	displayA := reflect.ValueOf(a.withTemplates())
+	displayB := reflect.ValueOf(b.withTemplates())
+
+	var changes []FieldChange
+	t := av.Type()
+	for i := range t.NumField() {
+		tag := t.Field(i).Tag.Get("mapstructure")
+		if tag == "" {
+			continue
+		}
+
+		// Compare a against a copy that only has this field taken from b
+		probe := a
+		reflect.ValueOf(&probe).Elem().Field(i).Set(bv.Field(i))
+		if ServiceConfigEqual(a, probe) {
+			continue
+		}
+
+		oldValue, _ := settingValue(displayA.Field(i))
+		newValue, _ := settingValue(displayB.Field(i))
+		changes = append(changes, FieldChange{Field: tag, Old: oldValue, New: newValue})
+	}
+	return changes
+}
@@ -1,12 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/jtdowney/tsbridge/internal/testhelpers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServiceConfigEqual(t *testing.T) {
@@ -468,11 +470,19 @@ func TestServiceConfigEqualCoversAllFields(t *testing.T) {
 		"RemoveDownstream":      true,
 		"MaxRequestBodySize":    true,
 		"OAuthPreauthorized":    true,
+		"Profile":               true,
+		"WaitForBackend":        true,
+		"DependsOn":             true,
+		"OnDemand":              true,
+		"OnDemandIdleTimeout":   true,
 	}
 
 	// Check that all struct fields are in our comparison
 	for field := range serviceType.Fields() {
 		field := field
+		if !field.IsExported() {
+			continue // Unexported bookkeeping (e.g. interpolation templates) is ignored
+		}
 		if !comparedFields[field.Name] {
 			t.Errorf("Field %s is not compared in ServiceConfigEqual", field.Name)
 		}
@@ -609,3 +619,108 @@ func TestServiceConfigEqualWithGoCmp(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceNodeEqual(t *testing.T) {
+	base := Service{
+		Name:         "api",
+		BackendAddr:  "localhost:8080",
+		Tags:         []string{"tag:a", "tag:b"},
+		WriteTimeout: testhelpers.DurationPtr(30 * time.Second),
+	}
+
+	tests := []struct {
+		name         string
+		modify       func(*Service)
+		nodeEqual    bool
+		handlerEqual bool
+	}{
+		{name: "no changes", modify: func(s *Service) {}, nodeEqual: true, handlerEqual: true},
+		{name: "tag order", modify: func(s *Service) { s.Tags = []string{"tag:b", "tag:a"} }, nodeEqual: true, handlerEqual: true},
+		{name: "backend address", modify: func(s *Service) { s.BackendAddr = "localhost:9090" }, nodeEqual: true},
+		{name: "upstream headers", modify: func(s *Service) { s.UpstreamHeaders = map[string]string{"X-Env": "prod"} }, nodeEqual: true},
+		{name: "response header timeout", modify: func(s *Service) { s.ResponseHeaderTimeout = testhelpers.DurationPtr(time.Minute) }, nodeEqual: true},
+		{name: "whois", modify: func(s *Service) { s.WhoisEnabled = testhelpers.BoolPtr(true) }, nodeEqual: true},
+		{name: "profile name", modify: func(s *Service) { s.Profile = "internal" }, nodeEqual: true},
+		{name: "tags", modify: func(s *Service) { s.Tags = []string{"tag:c"} }, handlerEqual: true},
+		{name: "funnel", modify: func(s *Service) { s.FunnelEnabled = testhelpers.BoolPtr(true) }, handlerEqual: true},
+		{name: "write timeout", modify: func(s *Service) { s.WriteTimeout = nil }, handlerEqual: true},
+		{name: "listen address", modify: func(s *Service) { s.ListenAddr = ":8443" }, handlerEqual: true},
+		{
+			name: "both",
+			modify: func(s *Service) {
+				s.TLSMode = "off"
+				s.BackendAddr = "localhost:9090"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := base
+			tt.modify(&other)
+			assert.Equal(t, tt.nodeEqual, ServiceNodeEqual(base, other), "ServiceNodeEqual")
+			assert.Equal(t, tt.handlerEqual, ServiceHandlerEqual(base, other), "ServiceHandlerEqual")
+			assert.Equal(t, tt.nodeEqual && tt.handlerEqual, ServiceConfigEqual(base, other), "ServiceConfigEqual")
+		})
+	}
+}
+
+func TestNodeSettingsExist(t *testing.T) {
+	tags := make(map[string]bool)
+	for field := range reflect.TypeFor[Service]().Fields() {
+		tags[field.Tag.Get("mapstructure")] = true
+	}
+	for _, setting := range nodeSettings {
+		assert.True(t, tags[setting], "node setting %q is not a service setting", setting)
+	}
+}
+
+func TestDiffService(t *testing.T) {
+	t.Setenv("TSBRIDGE_TEST_TOKEN", "secret-token")
+
+	base := Service{
+		Name:            "api",
+		BackendAddr:     "localhost:8080",
+		Tags:            []string{"tag:a", "tag:b"},
+		WriteTimeout:    testhelpers.DurationPtr(30 * time.Second),
+		UpstreamHeaders: map[string]string{"Authorization": "Bearer ${ENV:TSBRIDGE_TEST_TOKEN}"},
+	}
+	resolved := func(svc Service) Service {
+		cfg := &Config{Services: []Service{svc}}
+		if err := interpolateConfig(cfg, true); err != nil {
+			t.Fatal(err)
+		}
+		return cfg.Services[0]
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		other := base
+		other.Tags = []string{"tag:b", "tag:a"} // Tag order is ignored
+		assert.Empty(t, DiffService(resolved(base), resolved(other)))
+	})
+
+	t.Run("changed fields", func(t *testing.T) {
+		other := base
+		other.BackendAddr = "localhost:9090"
+		other.WriteTimeout = nil
+		other.FunnelEnabled = testhelpers.BoolPtr(true)
+
+		changes := DiffService(resolved(base), resolved(other))
+		assert.Equal(t, []FieldChange{
+			{Field: "backend_addr", Old: "localhost:8080", New: "localhost:9090"},
+			{Field: "write_timeout", Old: "30s"},
+			{Field: "funnel_enabled", New: true},
+		}, changes)
+	})
+
+	t.Run("interpolated values are not exposed", func(t *testing.T) {
+		other := base
+		other.UpstreamHeaders = map[string]string{"Authorization": "Bearer ${ENV:TSBRIDGE_TEST_TOKEN}", "X-Extra": "1"}
+
+		changes := DiffService(resolved(base), resolved(other))
+		require.Len(t, changes, 1)
+		assert.Equal(t, "upstream_headers", changes[0].Field)
+		assert.Equal(t, map[string]string{"Authorization": "Bearer ${ENV:TSBRIDGE_TEST_TOKEN}"}, changes[0].Old)
+		assert.NotContains(t, fmt.Sprint(changes), "secret-token")
+	})
+}
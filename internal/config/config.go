@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -24,9 +25,11 @@ import (
 
 // Config represents the complete tsbridge configuration
 type Config struct {
-	Tailscale Tailscale `mapstructure:"tailscale"` // Tailscale authentication config
-	Global    Global    `mapstructure:"global"`    // Default settings for all services
-	Services  []Service `mapstructure:"services"`  // List of services to expose
+	Tailscale Tailscale          `mapstructure:"tailscale"` // Tailscale authentication config
+	Global    Global             `mapstructure:"global"`    // Default settings for all services
+	Services  []Service          `mapstructure:"services"`  // List of services to expose
+	Profiles  map[string]Service `mapstructure:"profiles"`  // Named service settings shared via Service.Profile
+	Webhooks  map[string]Webhook `mapstructure:"webhooks"`  // Named outbound webhooks notified of failures and state changes
 }
 
 // Tailscale contains Tailscale-specific configuration
@@ -45,6 +48,8 @@ type Tailscale struct {
 	DefaultTags           []string       `mapstructure:"default_tags"`             // Default tags for services
 	ControlURL            string         `mapstructure:"control_url"`              // Control server URL (e.g., for Headscale)
 	OAuthPreauthorized    *bool          `mapstructure:"oauth_preauthorized"`      // Preauthorize OAuth-generated auth keys (default: true)
+
+	templates templates // Original values of interpolated fields, used for redaction
 }
 
 // Global contains global default settings
@@ -53,9 +58,13 @@ type Global struct {
 	AccessLog             *bool          `mapstructure:"access_log"`              // Enable access logging (default: true)
 	TrustedProxies        []string       `mapstructure:"trusted_proxies"`         // List of trusted proxy IPs or CIDR ranges
 	MetricsAddr           string         `mapstructure:"metrics_addr"`            // Address for Prometheus metrics
+	ReadyMinServices      *int           `mapstructure:"ready_min_services"`      // Services that must be up for /readyz to report ready (default: all)
 	ResponseHeaderTimeout *time.Duration `mapstructure:"response_header_timeout"` // Timeout for backend response headers
 	ShutdownTimeout       *time.Duration `mapstructure:"shutdown_timeout"`        // Max duration for graceful shutdown
+	DrainTimeout          *time.Duration `mapstructure:"drain_timeout"`           // Time WebSocket and streaming connections get to close when a service stops (default: 10s)
 	StartupTimeout        *time.Duration `mapstructure:"startup_timeout"`         // Max duration for Tailscale server startup
+	MaxStartAttempts      *int           `mapstructure:"max_start_attempts"`      // Attempts to start a service, including the first, before giving up on it (default: 0, retry forever)
+	StartupConcurrency    *int           `mapstructure:"startup_concurrency"`     // Services started at once at startup and by a reload (default: 4)
 	WriteTimeout          *time.Duration `mapstructure:"write_timeout"`           // Max duration for writing response
 	IdleTimeout           *time.Duration `mapstructure:"idle_timeout"`            // Max time to wait for next request
 	ReadHeaderTimeout     *time.Duration `mapstructure:"read_header_timeout"`     // Time allowed to read request headers
@@ -67,16 +76,48 @@ type Global struct {
 	TLSHandshakeTimeout      *time.Duration `mapstructure:"tls_handshake_timeout"`       // Max time for TLS handshake
 	ExpectContinueTimeout    *time.Duration `mapstructure:"expect_continue_timeout"`     // Timeout for 100-continue response
 	MetricsReadHeaderTimeout *time.Duration `mapstructure:"metrics_read_header_timeout"` // Read header timeout for metrics server
+	// Admin API
+	AdminAddr    string   `mapstructure:"admin_addr"`    // Admin API address: unix:///path, loopback host:port, or tailnet://hostname[:port]
+	AdminAllowed []string `mapstructure:"admin_allowed"` // Tailnet login names or tags allowed to use a tailnet admin API
+	// Audit log
+	AuditLog string `mapstructure:"audit_log"` // File lifecycle events and who triggered each configuration change are appended to as JSON lines (disabled if empty)
+	// Reloads
+	ReloadMode string `mapstructure:"reload_mode"` // How reloads apply service changes: "best_effort" (default) keeps the changes that succeed, "transactional" rolls back all of them if any fails
+	// Dashboard
+	DashboardHostname string   `mapstructure:"dashboard_hostname"` // Tailnet hostname of the web dashboard node (disabled if empty)
+	DashboardAllowed  []string `mapstructure:"dashboard_allowed"`  // Tailnet login names or tags allowed to view the dashboard
+	// Tracing
+	TracingEndpoint    string   `mapstructure:"tracing_endpoint"`     // OTLP collector URL traces are exported to, e.g. http://collector:4317 (disabled if empty)
+	TracingProtocol    string   `mapstructure:"tracing_protocol"`     // OTLP protocol: "grpc" (default) or "http"
+	TracingSampleRatio *float64 `mapstructure:"tracing_sample_ratio"` // Fraction of new traces to sample, from 0 to 1 (default: 1)
+	// Logging
+	LogFormat   string            `mapstructure:"log_format"`    // Log format: "text" (default), "json" or "logfmt"
+	LogLevel    string            `mapstructure:"log_level"`     // Minimum log level: "debug", "info" (default), "warn" or "error"
+	LogLevels   map[string]string `mapstructure:"log_levels"`    // Minimum log level of each component (tailscale, tsnet, proxy, docker, whois)
+	LogFile     string            `mapstructure:"log_file"`      // File logs are written to instead of stdout
+	LogMaxSize  *int64            `mapstructure:"log_max_size"`  // Size the log file is rotated at (default: 100MB, 0 to never rotate)
+	LogMaxFiles *int              `mapstructure:"log_max_files"` // Rotated log files to keep (default: 5)
+	// Access log
+	AccessLogOutput      string   `mapstructure:"access_log_output"`       // Dedicated access log: "stdout", "stderr", "syslog", "syslog://host:port", "syslog+tcp://host:port" or a file path (default: the main log)
+	AccessLogFormat      string   `mapstructure:"access_log_format"`       // Dedicated access log format: "json" (default), "combined" or "template"
+	AccessLogTemplate    string   `mapstructure:"access_log_template"`     // Go template of each line for the template format, e.g. "{{.Method}} {{.Path}} {{.Status}}"
+	AccessLogMaxSize     *int64   `mapstructure:"access_log_max_size"`     // Size the access log file is rotated at (default: 100MB, 0 to never rotate)
+	AccessLogMaxFiles    *int     `mapstructure:"access_log_max_files"`    // Rotated access log files to keep (default: 5)
+	AccessLogStatus      []string `mapstructure:"access_log_status"`       // Statuses to log, as codes like "404" or classes like "5xx" (default: all)
+	AccessLogSampleRatio *float64 `mapstructure:"access_log_sample_ratio"` // Fraction of requests to log, from 0 to 1 (default: 1)
+
+	templates templates // Original values of interpolated fields, used for redaction
 }
 
 // Service represents a single service configuration
 type Service struct {
 	Name         string         `mapstructure:"name"`          // Service name (Tailscale hostname)
+	Profile      string         `mapstructure:"profile"`       // Name of a [profiles.<name>] block to inherit settings from
 	BackendAddr  string         `mapstructure:"backend_addr"`  // Backend server address
 	ListenAddr   string         `mapstructure:"listen_addr"`   // Address to listen on (default: ":443" for TLS, ":80" for non-TLS)
 	WhoisEnabled *bool          `mapstructure:"whois_enabled"` // Enable whois lookups (default: true)
 	WhoisTimeout *time.Duration `mapstructure:"whois_timeout"` // Max time for whois lookup
-	TLSMode      string         `mapstructure:"tls_mode"`      // "auto" (default), "off"
+	TLSMode      string         `mapstructure:"tls_mode"`      // TLS mode: "auto" (default) or "off"
 	Tags         []string       `mapstructure:"tags"`          // Service-specific tags
 	// Optional overrides
 	StartupTimeout        *time.Duration `mapstructure:"startup_timeout"`         // Override global Tailscale server startup timeout
@@ -91,11 +132,27 @@ type Service struct {
 	OAuthPreauthorized    *bool          `mapstructure:"oauth_preauthorized"`     // Override global OAuth preauthorized setting
 	FlushInterval         *time.Duration `mapstructure:"flush_interval"`          // Time between flushes (-1ms for immediate)
 	InsecureSkipVerify    *bool          `mapstructure:"insecure_skip_verify"`    // Skip TLS certificate verification for HTTPS backends
+	WaitForBackend        string         `mapstructure:"wait_for_backend"`        // Until the backend accepts connections: "off" (default), "delay" the node, or serve an "unavailable" page
+	DependsOn             []string       `mapstructure:"depends_on"`              // Services that must be running before this one is started
+	OnDemand              *bool          `mapstructure:"on_demand"`               // Docker only: start the stopped container on the first request and stop it again when idle
+	OnDemandIdleTimeout   *time.Duration `mapstructure:"on_demand_idle_timeout"`  // Time without requests before an on-demand container is stopped (default: 15m)
 	// Header manipulation
 	UpstreamHeaders   map[string]string `mapstructure:"upstream_headers"`   // Headers to add to upstream requests
 	DownstreamHeaders map[string]string `mapstructure:"downstream_headers"` // Headers to add to downstream responses
 	RemoveUpstream    []string          `mapstructure:"remove_upstream"`    // Headers to remove from upstream requests
 	RemoveDownstream  []string          `mapstructure:"remove_downstream"`  // Headers to remove from downstream responses
+
+	templates templates // Original values of interpolated fields, used for redaction
+}
+
+// Webhook is an outbound webhook that lifecycle events are posted to
+type Webhook struct {
+	URL        RedactedString `mapstructure:"url"`         // URL events are posted to, kept secret since Slack and ntfy URLs grant access
+	Format     string         `mapstructure:"format"`      // Payload format: "json" (default), "slack" or "ntfy"
+	Events     []string       `mapstructure:"events"`      // Event types or categories to post (default: service.start_failed, backend.unhealthy, config.reload_failed, node.cert_expiring)
+	Secret     RedactedString `mapstructure:"secret"`      // Key the timestamp and body are signed with as an HMAC-SHA256 in the X-Tsbridge-Signature header
+	Timeout    *time.Duration `mapstructure:"timeout"`     // Max duration of each delivery attempt (default: 10s)
+	MaxRetries *int           `mapstructure:"max_retries"` // Retries after a failed delivery, with exponential backoff (default: 3)
 }
 
 // Load reads and parses the configuration from the specified file path.
@@ -107,7 +164,7 @@ type Service struct {
 // LoadWithProvider reads and parses the configuration with provider context.
 // It includes:
 // - Loading the base config from a TOML file
-// - Environment variable overrides
+// - Environment variable overrides, including per-service TSBRIDGE_SERVICES__ variables
 // - Secret resolution from env vars and files
 // - Validation, defaults and normalization
 func LoadWithProvider(path string, provider string) (*Config, error) {
@@ -124,40 +181,26 @@ func LoadWithProvider(path string, provider string) (*Config, error) {
 
 	// Load environment variables with TSBRIDGE_ prefix
 	// This allows overriding any config value via environment
-	if err := k.Load(env.Provider("TSBRIDGE_", ".", func(s string) string {
-		// Transform TSBRIDGE_TAILSCALE_OAUTH_CLIENT_ID to tailscale.oauth_client_id
-		s = strings.TrimPrefix(s, "TSBRIDGE_")
-		s = strings.ToLower(s)
-		// Replace only the first underscore to separate section from field
-		idx := strings.Index(s, "_")
-		if idx > 0 {
-			return s[:idx] + "." + s[idx+1:]
-		}
-		return s
-	}), nil); err != nil {
+	// (TSBRIDGE_TAILSCALE_OAUTH_CLIENT_ID becomes tailscale.oauth_client_id)
+	if err := k.Load(env.Provider(envPrefix, ".", envKeyTransform), nil); err != nil {
 		return nil, errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "loading environment variables")
 	}
 
-	// Unmarshal into our config struct with proper decoding
-	var cfg Config
-	decoderConfig := &mapstructure.DecoderConfig{
-		DecodeHook: mapstructure.ComposeDecodeHookFunc(
-			byteSizeDecodeHook(),
-			mapstructure.StringToTimeDurationHookFunc(),
-			redactedStringDecodeHook(),
-		),
-		Result:           &cfg,
-		WeaklyTypedInput: true,
-		TagName:          "mapstructure",
+	// Apply per-service overrides (TSBRIDGE_SERVICES__<NAME>__<FIELD>) on top
+	raw := k.Raw()
+	if err := applyServiceEnvOverrides(raw, os.Environ()); err != nil {
+		return nil, errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "applying service environment overrides")
 	}
 
-	decoder, err := mapstructure.NewDecoder(decoderConfig)
+	// Unmarshal into our config struct with proper decoding
+	var cfg Config
+	decoder, err := newDecoder(&cfg)
 	if err != nil {
 		return nil, errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "creating decoder")
 	}
 
 	// Use koanf's Raw() to get the data in the right format for mapstructure
-	if err := decoder.Decode(k.Raw()); err != nil {
+	if err := decoder.Decode(raw); err != nil {
 		return nil, errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "unmarshaling config")
 	}
 
@@ -174,6 +217,21 @@ func Load(path string) (*Config, error) {
 	return LoadWithProvider(path, "file")
 }
 
+// newDecoder returns a decoder of TOML-shaped maps into result, parsing
+// durations, byte sizes and secrets as they are written in the configuration
+func newDecoder(result any) (*mapstructure.Decoder, error) {
+	return mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			byteSizeDecodeHook(),
+			mapstructure.StringToTimeDurationHookFunc(),
+			redactedStringDecodeHook(),
+		),
+		Result:           result,
+		WeaklyTypedInput: true,
+		TagName:          "mapstructure",
+	})
+}
+
 // ParseByteSizeString parses a string like "10MB" into bytes
 func ParseByteSizeString(s string) (int64, error) {
 	s = strings.TrimSpace(s)
@@ -426,7 +484,7 @@ func resolveSecrets(cfg *Config) error {
 }
 
 // ProcessLoadedConfig applies the standard configuration processing pipeline:
-// resolves secrets, sets defaults, normalizes, and validates the configuration.
+// expands profiles and references, resolves secrets, sets defaults, normalizes, and validates the configuration.
 // This function encapsulates the common pattern used by different configuration providers.
 func ProcessLoadedConfig(cfg *Config) error {
 	return ProcessLoadedConfigWithProvider(cfg, "unknown")
@@ -435,6 +493,24 @@ func ProcessLoadedConfig(cfg *Config) error {
 // ProcessLoadedConfigWithProvider applies the standard configuration processing pipeline
 // with provider context for better error messages.
 func ProcessLoadedConfigWithProvider(cfg *Config, provider string) error {
+	// Profiles are interpolated whatever the provider, before they fill
+	// services, so that Docker services using them get resolved values
+	if err := cfg.interpolateProfiles(); err != nil {
+		return errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "interpolating values")
+	}
+
+	// Fill services from their profiles before anything else so that
+	// profile values are defaulted and validated like any other
+	if err := cfg.expandProfiles(); err != nil {
+		return errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "expanding profiles")
+	}
+
+	// Expand ${ENV:...} and ${FILE:...} references. Docker service labels can be
+	// set by anyone able to start a container, so they are never expanded.
+	if err := interpolateConfig(cfg, provider != "docker"); err != nil {
+		return errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "interpolating values")
+	}
+
 	// Resolve secrets
 	if err := resolveSecrets(cfg); err != nil {
 		return errors.WrapProviderError(err, provider, errors.ErrTypeConfig, "resolving secrets")
@@ -516,6 +592,22 @@ func (c *Config) SetDefaults() {
 		c.Global.MetricsReadHeaderTimeout = &defaultTimeout
 	}
 
+	// Set webhook defaults
+	for name, hook := range c.Webhooks {
+		if hook.Format == "" {
+			hook.Format = constants.WebhookFormatJSON
+		}
+		if hook.Timeout == nil {
+			defaultTimeout := constants.DefaultWebhookTimeout
+			hook.Timeout = &defaultTimeout
+		}
+		if hook.MaxRetries == nil {
+			defaultRetries := constants.DefaultWebhookMaxRetries
+			hook.MaxRetries = &defaultRetries
+		}
+		c.Webhooks[name] = hook
+	}
+
 	// Set Tailscale defaults
 	if c.Tailscale.OAuthPreauthorized == nil {
 		defaultPreauth := true
@@ -614,6 +706,10 @@ func (c *Config) Validate(provider string) error {
 		return err
 	}
 
+	if err := c.validateWebhooks(); err != nil {
+		return err
+	}
+
 	// Validate services - Docker provider allows zero services at startup
 	if len(c.Services) == 0 && provider != "docker" {
 		return errors.NewValidationError("at least one service must be defined in the [[services]] array")
@@ -633,8 +729,64 @@ func (c *Config) Validate(provider string) error {
 		if err := c.validateService(&c.Services[i]); err != nil {
 			return errors.WrapValidation(err, fmt.Sprintf("service %q", svc.Name))
 		}
+		if svc.OnDemand != nil && *svc.OnDemand && provider != "docker" {
+			return errors.NewValidationError(fmt.Sprintf("service %q: on_demand is only supported with the docker provider", svc.Name))
+		}
 	}
 
+	// Docker services may depend on containers that have not started yet
+	return c.validateDependencies(provider != "docker")
+}
+
+// validateDependencies checks that depends_on does not form a cycle and, when
+// requireKnown is set, only names services in the configuration
+func (c *Config) validateDependencies(requireKnown bool) error {
+	dependsOn := make(map[string][]string, len(c.Services))
+	for _, svc := range c.Services {
+		dependsOn[svc.Name] = svc.DependsOn
+	}
+
+	for _, svc := range c.Services {
+		for _, dep := range svc.DependsOn {
+			if dep == svc.Name {
+				return errors.NewValidationError(fmt.Sprintf("service %q: depends_on cannot include the service itself", svc.Name))
+			}
+			if _, ok := dependsOn[dep]; requireKnown && !ok {
+				return errors.NewValidationError(fmt.Sprintf("service %q: depends_on names unknown service %q", svc.Name, dep))
+			}
+		}
+	}
+
+	// Walk the dependencies depth first, looking for a service reached again
+	// while its own dependencies are being visited
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(c.Services))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visiting:
+			cycle := append(path[slices.Index(path, name):], name)
+			return errors.NewValidationError(fmt.Sprintf("depends_on cycle: %s", strings.Join(cycle, " -> ")))
+		case visited:
+			return nil
+		}
+		marks[name] = visiting
+		for _, dep := range dependsOn[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+	for _, svc := range c.Services {
+		if err := visit(svc.Name, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -871,6 +1023,9 @@ func (c *Config) validateGlobal() error {
 	if err := validateTimeoutPositive("shutdown_timeout", c.Global.ShutdownTimeout); err != nil {
 		return err
 	}
+	if err := validateTimeout("drain_timeout", c.Global.DrainTimeout, false); err != nil {
+		return err
+	}
 	if err := validateTimeoutPositive("startup_timeout", c.Global.StartupTimeout); err != nil {
 		return err
 	}
@@ -914,6 +1069,39 @@ func (c *Config) validateGlobal() error {
 		}
 	}
 
+	if c.Global.ReadyMinServices != nil && *c.Global.ReadyMinServices < 0 {
+		return errors.NewValidationError(fmt.Sprintf("ready_min_services must not be negative, got %d", *c.Global.ReadyMinServices))
+	}
+	if c.Global.MaxStartAttempts != nil && *c.Global.MaxStartAttempts < 0 {
+		return errors.NewValidationError(fmt.Sprintf("max_start_attempts must not be negative, got %d", *c.Global.MaxStartAttempts))
+	}
+	if c.Global.StartupConcurrency != nil && *c.Global.StartupConcurrency < 1 {
+		return errors.NewValidationError(fmt.Sprintf("startup_concurrency must be at least 1, got %d", *c.Global.StartupConcurrency))
+	}
+
+	switch c.Global.ReloadMode {
+	case "", constants.ReloadModeBestEffort, constants.ReloadModeTransactional:
+	default:
+		return errors.NewValidationError(fmt.Sprintf("reload_mode must be %q or %q, got %q",
+			constants.ReloadModeBestEffort, constants.ReloadModeTransactional, c.Global.ReloadMode))
+	}
+
+	if err := c.validateAdmin(); err != nil {
+		return err
+	}
+	if err := c.validateDashboard(); err != nil {
+		return err
+	}
+	if err := c.validateTracing(); err != nil {
+		return err
+	}
+	if err := c.validateLogging(); err != nil {
+		return err
+	}
+	if err := c.validateAccessLog(); err != nil {
+		return err
+	}
+
 	// Validate trusted proxies
 	for _, proxy := range c.Global.TrustedProxies {
 		if strings.Contains(proxy, "/") {
@@ -964,6 +1152,23 @@ func (c *Config) validateService(svc *Service) error {
 		}
 	}
 
+	switch svc.WaitForBackend {
+	case "", constants.WaitForBackendOff, constants.WaitForBackendDelay, constants.WaitForBackendUnavailable:
+	default:
+		return errors.NewValidationError(fmt.Sprintf("invalid wait_for_backend %q: must be '%s', '%s' or '%s'",
+			svc.WaitForBackend, constants.WaitForBackendOff, constants.WaitForBackendDelay, constants.WaitForBackendUnavailable))
+	}
+
+	// On-demand services wait for their backend themselves
+	if svc.OnDemand != nil && *svc.OnDemand {
+		if svc.WaitForBackend != "" && svc.WaitForBackend != constants.WaitForBackendOff {
+			return errors.NewValidationError("on_demand cannot be combined with wait_for_backend")
+		}
+		if err := validateTimeoutPositive("on_demand_idle_timeout", svc.OnDemandIdleTimeout); err != nil {
+			return err
+		}
+	}
+
 	// Validate service-level timeout overrides
 	if err := validateTimeoutPositive("startup_timeout", svc.StartupTimeout); err != nil {
 		return err
@@ -1005,6 +1210,9 @@ func (c *Config) validateService(svc *Service) error {
 
 // String returns a string representation of the Tailscale config with secrets redacted
 func (t Tailscale) String() string {
+	// Show interpolated fields as written, e.g. ${ENV:OAUTH_ID}
+	t = t.withTemplates()
+
 	var b strings.Builder
 	b.WriteString("Tailscale:\n")
 
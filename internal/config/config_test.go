@@ -836,6 +836,33 @@ func TestValidateAuthMethodSelection(t *testing.T) {
 	}
 }
 
+// validateMinimal validates a single-service configuration after applying
+// modify, checking it fails with wantErr or, when wantErr is empty, succeeds
+func validateMinimal(t *testing.T, modify func(*Config), wantErr string) {
+	t.Helper()
+	cfg := &Config{
+		Tailscale: Tailscale{AuthKey: "tskey-auth-test"},
+		Services:  []Service{{Name: "api", BackendAddr: "localhost:8080"}},
+	}
+	modify(cfg)
+	cfg.SetDefaults()
+
+	err := cfg.Validate("file")
+	if wantErr != "" {
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), wantErr)
+		return
+	}
+	require.NoError(t, err)
+}
+
+// validateGlobal validates a single-service configuration with the given
+// global settings
+func validateGlobal(t *testing.T, global Global, wantErr string) {
+	t.Helper()
+	validateMinimal(t, func(cfg *Config) { cfg.Global = global }, wantErr)
+}
+
 func TestValidate(t *testing.T) {
 	trueVal := true
 	falseVal := false
@@ -1167,6 +1194,70 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: "invalid metrics address",
 		},
+		{
+			name: "negative ready min services",
+			config: &Config{
+				Tailscale: Tailscale{
+					OAuthClientID:     "test-id",
+					OAuthClientSecret: "test-secret",
+				},
+				Global: Global{
+					ReadHeaderTimeout: testhelpers.DurationPtr(5 * time.Second),
+					WriteTimeout:      testhelpers.DurationPtr(10 * time.Second),
+					IdleTimeout:       testhelpers.DurationPtr(120 * time.Second),
+					ShutdownTimeout:   testhelpers.DurationPtr(15 * time.Second),
+					ReadyMinServices:  new(-1),
+				},
+				Services: []Service{
+					{
+						Name:        "api",
+						BackendAddr: "127.0.0.1:8080",
+						Tags:        []string{"tag:test"},
+					},
+				},
+			},
+			wantErr: "ready_min_services must not be negative",
+		},
+		{
+			name: "negative max start attempts",
+			config: &Config{
+				Tailscale: Tailscale{
+					OAuthClientID:     "test-id",
+					OAuthClientSecret: "test-secret",
+				},
+				Global: Global{
+					MaxStartAttempts: new(-1),
+				},
+				Services: []Service{
+					{
+						Name:        "api",
+						BackendAddr: "127.0.0.1:8080",
+						Tags:        []string{"tag:test"},
+					},
+				},
+			},
+			wantErr: "max_start_attempts must not be negative",
+		},
+		{
+			name: "startup concurrency below one",
+			config: &Config{
+				Tailscale: Tailscale{
+					OAuthClientID:     "test-id",
+					OAuthClientSecret: "test-secret",
+				},
+				Global: Global{
+					StartupConcurrency: new(0),
+				},
+				Services: []Service{
+					{
+						Name:        "api",
+						BackendAddr: "127.0.0.1:8080",
+						Tags:        []string{"tag:test"},
+					},
+				},
+			},
+			wantErr: "startup_concurrency must be at least 1",
+		},
 		{
 			name: "invalid trusted proxy IP",
 			config: &Config{
@@ -1669,6 +1760,30 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: "startup_timeout must be positive",
 		},
+		{
+			name: "negative drain_timeout",
+			config: &Config{
+				Tailscale: Tailscale{
+					OAuthClientID:     "test-id",
+					OAuthClientSecret: "test-secret",
+				},
+				Global: Global{
+					ReadHeaderTimeout: testhelpers.DurationPtr(5 * time.Second),
+					WriteTimeout:      testhelpers.DurationPtr(10 * time.Second),
+					IdleTimeout:       testhelpers.DurationPtr(120 * time.Second),
+					ShutdownTimeout:   testhelpers.DurationPtr(15 * time.Second),
+					DrainTimeout:      testhelpers.DurationPtr(-time.Second),
+				},
+				Services: []Service{
+					{
+						Name:        "api",
+						BackendAddr: "127.0.0.1:8080",
+						Tags:        []string{"tag:test"},
+					},
+				},
+			},
+			wantErr: "drain_timeout cannot be negative",
+		},
 		{
 			name: "nil timeout values are valid",
 			config: &Config{
@@ -1785,6 +1900,46 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: "",
 		},
+		{
+			name: "transactional reload mode is valid",
+			config: &Config{
+				Tailscale: Tailscale{
+					OAuthClientID:     "test-id",
+					OAuthClientSecret: "test-secret",
+				},
+				Global: Global{
+					ReloadMode: "transactional",
+				},
+				Services: []Service{
+					{
+						Name:        "api",
+						BackendAddr: "127.0.0.1:8080",
+						Tags:        []string{"tag:test"},
+					},
+				},
+			},
+			wantErr: "",
+		},
+		{
+			name: "invalid reload mode",
+			config: &Config{
+				Tailscale: Tailscale{
+					OAuthClientID:     "test-id",
+					OAuthClientSecret: "test-secret",
+				},
+				Global: Global{
+					ReloadMode: "atomic",
+				},
+				Services: []Service{
+					{
+						Name:        "api",
+						BackendAddr: "127.0.0.1:8080",
+						Tags:        []string{"tag:test"},
+					},
+				},
+			},
+			wantErr: "reload_mode must be",
+		},
 	}
 
 	for _, tt := range tests {
@@ -3312,6 +3467,68 @@ func TestValidateWithProvider(t *testing.T) {
 	})
 }
 
+func TestValidateServiceStartupGating(t *testing.T) {
+	service := func(name string, dependsOn ...string) Service {
+		return Service{Name: name, BackendAddr: "localhost:8080", DependsOn: dependsOn}
+	}
+
+	tests := []struct {
+		name     string
+		services []Service
+		provider string
+		wantErr  string
+	}{
+		{
+			name:     "dependencies",
+			services: []Service{service("db"), service("api", "db"), service("web", "api", "db")},
+		},
+		{
+			name:     "wait for backend modes",
+			services: []Service{{Name: "a", BackendAddr: "localhost:8080", WaitForBackend: "delay"}, {Name: "b", BackendAddr: "localhost:8080", WaitForBackend: "unavailable"}},
+		},
+		{
+			name:     "invalid wait for backend",
+			services: []Service{{Name: "api", BackendAddr: "localhost:8080", WaitForBackend: "forever"}},
+			wantErr:  `invalid wait_for_backend "forever"`,
+		},
+		{
+			name:     "depends on itself",
+			services: []Service{service("api", "api")},
+			wantErr:  `service "api": depends_on cannot include the service itself`,
+		},
+		{
+			name:     "unknown dependency",
+			services: []Service{service("api", "db")},
+			wantErr:  `service "api": depends_on names unknown service "db"`,
+		},
+		{
+			name:     "unknown dependency from docker",
+			services: []Service{service("api", "db")},
+			provider: "docker",
+		},
+		{
+			name:     "cycle",
+			services: []Service{service("a", "b"), service("b", "c"), service("c", "a")},
+			wantErr:  "depends_on cycle: a -> b -> c -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Tailscale: Tailscale{AuthKey: "test-key"},
+				Services:  tt.services,
+			}
+			err := cfg.Validate(tt.provider)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
 func TestConfigByteSizeParsing(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -3682,3 +3899,54 @@ func TestValidateBackendAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateServiceOnDemand(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  Service
+		provider string
+		wantErr  string
+	}{
+		{
+			name:     "docker",
+			service:  Service{Name: "app", BackendAddr: "app:8080", OnDemand: new(true), OnDemandIdleTimeout: new(time.Hour)},
+			provider: "docker",
+		},
+		{
+			name:    "off outside docker",
+			service: Service{Name: "app", BackendAddr: "app:8080", OnDemand: new(false)},
+		},
+		{
+			name:    "file provider",
+			service: Service{Name: "app", BackendAddr: "app:8080", OnDemand: new(true)},
+			wantErr: `service "app": on_demand is only supported with the docker provider`,
+		},
+		{
+			name:     "with wait for backend",
+			service:  Service{Name: "app", BackendAddr: "app:8080", OnDemand: new(true), WaitForBackend: "delay"},
+			provider: "docker",
+			wantErr:  "on_demand cannot be combined with wait_for_backend",
+		},
+		{
+			name:     "zero idle timeout",
+			service:  Service{Name: "app", BackendAddr: "app:8080", OnDemand: new(true), OnDemandIdleTimeout: new(time.Duration(0))},
+			provider: "docker",
+			wantErr:  "on_demand_idle_timeout must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Tailscale: Tailscale{AuthKey: "test-key"},
+				Services:  []Service{tt.service},
+			}
+			err := cfg.Validate(tt.provider)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/errors"
+)
+
+const (
+	// envPrefix is the prefix for all environment variable overrides
+	envPrefix = "TSBRIDGE_"
+
+	// serviceEnvPrefix is the prefix for per-service environment variable overrides.
+	// Segments are separated by a double underscore so that service and field names
+	// can themselves contain single underscores, e.g.
+	// TSBRIDGE_SERVICES__API__BACKEND_ADDR or
+	// TSBRIDGE_SERVICES__API__UPSTREAM_HEADERS__X_API_KEY.
+	serviceEnvPrefix = envPrefix + "SERVICES__"
+
+	// serviceEnvSeparator separates the service name, field and map key segments
+	serviceEnvSeparator = "__"
+)
+
+// envKeyTransform converts a TSBRIDGE_ environment variable name into a koanf key.
+// TSBRIDGE_TAILSCALE_OAUTH_CLIENT_ID becomes tailscale.oauth_client_id.
+// Per-service variables are skipped here and handled by applyServiceEnvOverrides.
+func envKeyTransform(s string) string {
+	if strings.HasPrefix(s, serviceEnvPrefix) {
+		return ""
+	}
+
+	s = strings.TrimPrefix(s, envPrefix)
+	s = strings.ToLower(s)
+	// Replace only the first underscore to separate section from field
+	idx := strings.Index(s, "_")
+	if idx > 0 {
+		return s[:idx] + "." + s[idx+1:]
+	}
+	return s
+}
+
+// serviceEnvOverride is a single parsed TSBRIDGE_SERVICES__ environment variable
+type serviceEnvOverride struct {
+	envName string
+	service string // Service segment as written in the variable (upper case)
+	field   string // mapstructure field name (lower case)
+	key     string // Map key for map fields such as upstream_headers
+	value   string
+}
+
+// parseServiceEnvOverrides extracts per-service overrides from environ (KEY=VALUE pairs).
+// Results are sorted by variable name so that services added from the
+// environment appear in a deterministic order.
+func parseServiceEnvOverrides(environ []string) ([]serviceEnvOverride, error) {
+	fields := serviceFieldKinds()
+
+	var overrides []serviceEnvOverride
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, serviceEnvPrefix) {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimPrefix(name, serviceEnvPrefix), serviceEnvSeparator)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.NewValidationError(fmt.Sprintf("environment variable %s must have the form %s<SERVICE>%s<FIELD>", name, serviceEnvPrefix, serviceEnvSeparator))
+		}
+
+		field := strings.ToLower(parts[1])
+		kind, known := fields[field]
+		if !known {
+			return nil, errors.NewValidationError(fmt.Sprintf("environment variable %s: unknown service field %q", name, field))
+		}
+
+		override := serviceEnvOverride{
+			envName: name,
+			service: parts[0],
+			field:   field,
+			value:   value,
+		}
+
+		switch {
+		case kind == reflect.Map:
+			if len(parts) != 3 || parts[2] == "" {
+				return nil, errors.NewValidationError(fmt.Sprintf("environment variable %s: field %q requires a key segment, e.g. %s%s%s%s%sX_CUSTOM_HEADER", name, field, serviceEnvPrefix, parts[0], serviceEnvSeparator, parts[1], serviceEnvSeparator))
+			}
+			// Environment variable names cannot portably contain hyphens, so
+			// underscores in header names are translated back to hyphens.
+			override.key = strings.ReplaceAll(parts[2], "_", "-")
+		case len(parts) != 2:
+			return nil, errors.NewValidationError(fmt.Sprintf("environment variable %s: field %q does not take a key segment", name, field))
+		}
+
+		overrides = append(overrides, override)
+	}
+
+	slices.SortFunc(overrides, func(a, b serviceEnvOverride) int {
+		return strings.Compare(a.envName, b.envName)
+	})
+
+	return overrides, nil
+}
+
+// serviceFieldKinds returns the mapstructure field names of Service with their reflect kind.
+// Pointer fields report the kind of the element they point to.
+func serviceFieldKinds() map[string]reflect.Kind {
+	t := reflect.TypeFor[Service]()
+	fields := make(map[string]reflect.Kind, t.NumField())
+	for f := range t.Fields() {
+		tag := f.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fields[tag] = ft.Kind()
+	}
+	return fields
+}
+
+// envServiceName converts a service segment into the name used when the
+// environment adds a brand new service: lower case with underscores turned into
+// hyphens so the result is a valid Tailscale hostname.
+func envServiceName(segment string) string {
+	return strings.ReplaceAll(strings.ToLower(segment), "_", "-")
+}
+
+// matchesServiceSegment reports whether a service name is addressed by an
+// environment variable segment. Matching is case-insensitive and treats
+// hyphens and dots in the service name as underscores.
+func matchesServiceSegment(name, segment string) bool {
+	normalized := strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	return strings.EqualFold(normalized, segment)
+}
+
+// applyServiceEnvOverrides applies TSBRIDGE_SERVICES__ overrides to the raw
+// configuration map produced by koanf, before it is decoded into a Config.
+// Overrides for a service that is not present in the file add a new service.
+func applyServiceEnvOverrides(raw map[string]any, environ []string) error {
+	overrides, err := parseServiceEnvOverrides(environ)
+	if err != nil {
+		return err
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	var services []any
+	if existing, ok := raw["services"]; ok && existing != nil {
+		services, ok = existing.([]any)
+		if !ok {
+			return errors.NewValidationError(fmt.Sprintf("services must be an array of tables, got %T", existing))
+		}
+	}
+
+	fields := serviceFieldKinds()
+	for _, o := range overrides {
+		svc, err := findOrAddEnvService(&services, o.service)
+		if err != nil {
+			return err
+		}
+
+		switch fields[o.field] {
+		case reflect.Map:
+			headers, _ := svc[o.field].(map[string]any)
+			if headers == nil {
+				headers = make(map[string]any)
+			}
+			headers[o.key] = o.value
+			svc[o.field] = headers
+		case reflect.Slice:
+			// Slices use the same comma-separated form as Docker labels
+			var items []any
+			for item := range strings.SplitSeq(o.value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			svc[o.field] = items
+		default:
+			svc[o.field] = o.value
+		}
+	}
+
+	raw["services"] = services
+	return nil
+}
+
+// findOrAddEnvService returns the raw service table addressed by segment,
+// appending a new one named after the segment if none matches.
+func findOrAddEnvService(services *[]any, segment string) (map[string]any, error) {
+	for i, entry := range *services {
+		svc, ok := entry.(map[string]any)
+		if !ok {
+			return nil, errors.NewValidationError(fmt.Sprintf("services[%d] must be a table, got %T", i, entry))
+		}
+		if name, _ := svc["name"].(string); matchesServiceSegment(name, segment) {
+			return svc, nil
+		}
+	}
+
+	svc := map[string]any{"name": envServiceName(segment)}
+	*services = append(*services, svc)
+	return svc, nil
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvKeyTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"tailscale field", "TSBRIDGE_TAILSCALE_OAUTH_CLIENT_ID", "tailscale.oauth_client_id"},
+		{"global field", "TSBRIDGE_GLOBAL_METRICS_ADDR", "global.metrics_addr"},
+		{"no field", "TSBRIDGE_DEBUG", "debug"},
+		{"service override skipped", "TSBRIDGE_SERVICES__API__BACKEND_ADDR", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, envKeyTransform(tt.in))
+		})
+	}
+}
+
+func TestParseServiceEnvOverrides(t *testing.T) {
+	t.Run("ignores unrelated variables", func(t *testing.T) {
+		overrides, err := parseServiceEnvOverrides([]string{
+			"PATH=/usr/bin",
+			"TSBRIDGE_GLOBAL_METRICS_ADDR=:9090",
+		})
+		require.NoError(t, err)
+		assert.Empty(t, overrides)
+	})
+
+	t.Run("parses scalar and map fields sorted by name", func(t *testing.T) {
+		overrides, err := parseServiceEnvOverrides([]string{
+			"TSBRIDGE_SERVICES__WEB__UPSTREAM_HEADERS__X_API_KEY=secret",
+			"TSBRIDGE_SERVICES__API__BACKEND_ADDR=localhost:9000",
+		})
+		require.NoError(t, err)
+		require.Len(t, overrides, 2)
+
+		assert.Equal(t, "API", overrides[0].service)
+		assert.Equal(t, "backend_addr", overrides[0].field)
+		assert.Equal(t, "localhost:9000", overrides[0].value)
+
+		assert.Equal(t, "WEB", overrides[1].service)
+		assert.Equal(t, "upstream_headers", overrides[1].field)
+		assert.Equal(t, "X-API-KEY", overrides[1].key)
+		assert.Equal(t, "secret", overrides[1].value)
+	})
+
+	errorTests := []struct {
+		name    string
+		env     string
+		wantErr string
+	}{
+		{"missing field", "TSBRIDGE_SERVICES__API=x", "must have the form"},
+		{"unknown field", "TSBRIDGE_SERVICES__API__NOPE=x", `unknown service field "nope"`},
+		{"map without key", "TSBRIDGE_SERVICES__API__UPSTREAM_HEADERS=x", "requires a key segment"},
+		{"scalar with key", "TSBRIDGE_SERVICES__API__BACKEND_ADDR__EXTRA=x", "does not take a key segment"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseServiceEnvOverrides([]string{tt.env})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestApplyServiceEnvOverrides(t *testing.T) {
+	t.Run("matches existing service case-insensitively with hyphens", func(t *testing.T) {
+		raw := map[string]any{
+			"services": []any{
+				map[string]any{"name": "my-api", "backend_addr": "localhost:8080"},
+			},
+		}
+
+		err := applyServiceEnvOverrides(raw, []string{"TSBRIDGE_SERVICES__MY_API__BACKEND_ADDR=localhost:9000"})
+		require.NoError(t, err)
+
+		services := raw["services"].([]any)
+		require.Len(t, services, 1)
+		assert.Equal(t, "localhost:9000", services[0].(map[string]any)["backend_addr"])
+	})
+
+	t.Run("adds a new service when no name matches", func(t *testing.T) {
+		raw := map[string]any{}
+
+		err := applyServiceEnvOverrides(raw, []string{
+			"TSBRIDGE_SERVICES__NEW_APP__BACKEND_ADDR=localhost:3000",
+			"TSBRIDGE_SERVICES__NEW_APP__TAGS=tag:a, tag:b",
+		})
+		require.NoError(t, err)
+
+		services := raw["services"].([]any)
+		require.Len(t, services, 1)
+		svc := services[0].(map[string]any)
+		assert.Equal(t, "new-app", svc["name"])
+		assert.Equal(t, "localhost:3000", svc["backend_addr"])
+		assert.Equal(t, []any{"tag:a", "tag:b"}, svc["tags"])
+	})
+
+	t.Run("merges map fields with existing entries", func(t *testing.T) {
+		raw := map[string]any{
+			"services": []any{
+				map[string]any{
+					"name":             "api",
+					"upstream_headers": map[string]any{"X-Existing": "1"},
+				},
+			},
+		}
+
+		err := applyServiceEnvOverrides(raw, []string{"TSBRIDGE_SERVICES__API__UPSTREAM_HEADERS__X_TOKEN=abc"})
+		require.NoError(t, err)
+
+		headers := raw["services"].([]any)[0].(map[string]any)["upstream_headers"].(map[string]any)
+		assert.Equal(t, map[string]any{"X-Existing": "1", "X-TOKEN": "abc"}, headers)
+	})
+}
+
+func TestLoadWithServiceEnvOverrides(t *testing.T) {
+	configContent := `
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[global]
+write_timeout = "30s"
+
+[[services]]
+name = "api"
+backend_addr = "localhost:8080"
+
+[[services]]
+name = "web-ui"
+backend_addr = "localhost:8081"
+`
+	tmpFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(configContent), 0644))
+
+	t.Setenv("TSBRIDGE_SERVICES__API__BACKEND_ADDR", "localhost:9090")
+	t.Setenv("TSBRIDGE_SERVICES__API__WRITE_TIMEOUT", "5s")
+	t.Setenv("TSBRIDGE_SERVICES__WEB_UI__WHOIS_ENABLED", "true")
+	t.Setenv("TSBRIDGE_SERVICES__WEB_UI__UPSTREAM_HEADERS__X_API_KEY", "key")
+	t.Setenv("TSBRIDGE_SERVICES__EXTRA__BACKEND_ADDR", "localhost:7000")
+	t.Setenv("TSBRIDGE_SERVICES__EXTRA__MAX_REQUEST_BODY_SIZE", "1MB")
+
+	cfg, err := Load(tmpFile)
+	require.NoError(t, err)
+	require.Len(t, cfg.Services, 3)
+
+	api := cfg.Services[0]
+	assert.Equal(t, "api", api.Name)
+	assert.Equal(t, "localhost:9090", api.BackendAddr)
+	require.NotNil(t, api.WriteTimeout)
+	assert.Equal(t, 5*time.Second, *api.WriteTimeout)
+
+	web := cfg.Services[1]
+	assert.Equal(t, "web-ui", web.Name)
+	require.NotNil(t, web.WhoisEnabled)
+	assert.True(t, *web.WhoisEnabled)
+	assert.Equal(t, map[string]string{"X-API-KEY": "key"}, web.UpstreamHeaders)
+	// Global defaults still apply to services that were not overridden
+	require.NotNil(t, web.WriteTimeout)
+	assert.Equal(t, 30*time.Second, *web.WriteTimeout)
+
+	extra := cfg.Services[2]
+	assert.Equal(t, "extra", extra.Name)
+	assert.Equal(t, "localhost:7000", extra.BackendAddr)
+	require.NotNil(t, extra.MaxRequestBodySize)
+	assert.Equal(t, int64(1024*1024), *extra.MaxRequestBodySize)
+}
+
+func TestLoadWithInvalidServiceEnvOverride(t *testing.T) {
+	configContent := `
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[[services]]
+name = "api"
+backend_addr = "localhost:8080"
+`
+	tmpFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(configContent), 0644))
+
+	t.Setenv("TSBRIDGE_SERVICES__API__BOGUS", "1")
+
+	_, err := Load(tmpFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "applying service environment overrides")
+}
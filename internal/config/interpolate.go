@@ -0,0 +1,272 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/errors"
+)
+
+const (
+	// interpolationStart opens a reference such as ${ENV:NAME} or ${FILE:/path}
+	interpolationStart = "${"
+
+	// interpolationEscape is written in place of a literal "${"
+	interpolationEscape = "$${"
+
+	// interpolationDefault separates a reference from its default value, e.g. ${ENV:PORT:-8080}
+	interpolationDefault = ":-"
+)
+
+// templates maps a field path (using mapstructure names, e.g. "upstream_headers.X-Token")
+// to the original, unresolved value written in the configuration. Values are
+// restored from their templates whenever the configuration is displayed so that
+// secrets pulled in through interpolation are never printed.
+type templates map[string]string
+
+// interpolate expands ${ENV:NAME}, ${FILE:/path} and their ${...:-default} forms in s.
+// A literal "${" is written as "$${".
+func interpolate(s string) (string, error) {
+	if !strings.Contains(s, interpolationStart) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		idx := strings.Index(s, interpolationStart)
+		if idx < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		// "$${" is an escaped literal "${"
+		if idx > 0 && s[idx-1] == '$' {
+			b.WriteString(s[:idx-1])
+			b.WriteString(interpolationStart)
+			s = s[idx+len(interpolationStart):]
+			continue
+		}
+
+		b.WriteString(s[:idx])
+		rest := s[idx+len(interpolationStart):]
+		end := strings.Index(rest, "}")
+		if end < 0 {
+			return "", errors.NewValidationError(fmt.Sprintf("unterminated reference in %q", s))
+		}
+
+		resolved, err := resolveReference(rest[:end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(resolved)
+		s = rest[end+1:]
+	}
+}
+
+// resolveReference resolves the body of a single ${...} reference.
+func resolveReference(ref string) (string, error) {
+	scheme, target, ok := strings.Cut(ref, ":")
+	if !ok || target == "" {
+		return "", errors.NewValidationError(fmt.Sprintf("invalid reference ${%s}: expected ${ENV:NAME} or ${FILE:/path}", ref))
+	}
+
+	target, fallback, hasDefault := strings.Cut(target, interpolationDefault)
+
+	var value string
+	switch strings.ToUpper(scheme) {
+	case "ENV":
+		var set bool
+		value, set = os.LookupEnv(target)
+		if !set && !hasDefault {
+			return "", errors.NewValidationError(fmt.Sprintf("environment variable %s referenced by ${%s} is not set", target, ref))
+		}
+	case "FILE":
+		if err := validateFilePath(target); err != nil {
+			return "", errors.WrapValidation(err, fmt.Sprintf("invalid file in ${%s}", ref))
+		}
+		data, err := os.ReadFile(target)
+		switch {
+		case err == nil:
+			value = strings.TrimSpace(string(data))
+		case os.IsNotExist(err) && hasDefault:
+			// Fall through to the default value
+		default:
+			return "", fmt.Errorf("reading file referenced by ${%s}: %w", ref, err)
+		}
+	default:
+		return "", errors.NewValidationError(fmt.Sprintf("unknown reference type %q in ${%s}: must be ENV or FILE", scheme, ref))
+	}
+
+	if value == "" && hasDefault {
+		return fallback, nil
+	}
+	return value, nil
+}
+
+// walkStrings calls fn for every string reachable from v through mapstructure-tagged
+// struct fields, string slices and string maps, replacing the value with fn's result.
+// Slices and maps are rebuilt rather than modified so values shared with other
+// copies of the struct are left untouched.
+func walkStrings(v reflect.Value, path string, fn func(path, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		updated, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		if updated != v.String() {
+			v.SetString(updated)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			tag := t.Field(i).Tag.Get("mapstructure")
+			if tag == "" || tag == "-" || !v.Field(i).CanSet() {
+				continue
+			}
+			if err := walkStrings(v.Field(i), joinFieldPath(path, tag), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(clone, v)
+		for i := range clone.Len() {
+			if err := walkStrings(clone.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+		v.Set(clone)
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := walkStrings(elem, joinFieldPath(path, iter.Key().String()), fn); err != nil {
+				return err
+			}
+			clone.SetMapIndex(iter.Key(), elem)
+		}
+		v.Set(clone)
+	}
+	return nil
+}
+
+// joinFieldPath joins a parent path and a field name with a dot
+func joinFieldPath(parent, field string) string {
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
+
+// interpolateFields expands references in every string field of the struct pointed
+// to by ptr, recording the original value of each field that changed in existing.
+// Fields existing already records are left as they are.
+func interpolateFields(ptr any, existing templates) (templates, error) {
+	found := existing
+	err := walkStrings(reflect.ValueOf(ptr).Elem(), "", func(path, value string) (string, error) {
+		// Values inherited from a profile are resolved already
+		if _, ok := existing[path]; ok {
+			return value, nil
+		}
+		resolved, err := interpolate(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		if resolved != value {
+			if found == nil {
+				found = make(templates)
+			}
+			found[path] = value
+		}
+		return resolved, nil
+	})
+	return found, err
+}
+
+// restoreTemplates replaces interpolated fields of the struct pointed to by ptr
+// with the templates they were resolved from.
+func restoreTemplates(ptr any, tmpl templates) {
+	if len(tmpl) == 0 {
+		return
+	}
+	// The callback never fails, so neither does the walk
+	_ = walkStrings(reflect.ValueOf(ptr).Elem(), "", func(path, value string) (string, error) {
+		if original, ok := tmpl[path]; ok {
+			return original, nil
+		}
+		return value, nil
+	})
+}
+
+// interpolateConfig expands ${ENV:...} and ${FILE:...} references in every string
+// field of the configuration, remembering the original values for redaction.
+// Services are left as written unless withServices is set, as their settings
+// may come from sources that must not read tsbridge's environment or files.
+func interpolateConfig(cfg *Config, withServices bool) error {
+	var err error
+	if cfg.Tailscale.templates, err = interpolateFields(&cfg.Tailscale, cfg.Tailscale.templates); err != nil {
+		return errors.WrapValidation(err, "tailscale")
+	}
+	if cfg.Global.templates, err = interpolateFields(&cfg.Global, cfg.Global.templates); err != nil {
+		return errors.WrapValidation(err, "global")
+	}
+	if withServices {
+		for i := range cfg.Services {
+			svc := &cfg.Services[i]
+			if svc.templates, err = interpolateFields(svc, svc.templates); err != nil {
+				return errors.WrapValidation(err, fmt.Sprintf("service[%d] %q", i, svc.Name))
+			}
+		}
+	}
+	return cfg.interpolateWebhooks()
+}
+
+// interpolateProfiles expands references in every profile. Profiles are
+// written by the operator whichever provider the services come from, so they
+// are expanded even when services are not, and fill services with their
+// values resolved.
+func (c *Config) interpolateProfiles() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Profiles)) {
+		profile := c.Profiles[name]
+		var err error
+		if profile.templates, err = interpolateFields(&profile, profile.templates); err != nil {
+			return errors.WrapValidation(err, fmt.Sprintf("profile %q", name))
+		}
+		c.Profiles[name] = profile
+	}
+	return nil
+}
+
+// withTemplates returns a copy of the Tailscale config with interpolated values
+// replaced by their original templates
+func (t Tailscale) withTemplates() Tailscale {
+	restoreTemplates(&t, t.templates)
+	return t
+}
+
+// withTemplates returns a copy of the Global config with interpolated values
+// replaced by their original templates
+func (g Global) withTemplates() Global {
+	restoreTemplates(&g, g.templates)
+	return g
+}
+
+// withTemplates returns a copy of the Service with interpolated values
+// replaced by their original templates
+func (s Service) withTemplates() Service {
+	restoreTemplates(&s, s.templates)
+	return s
+}
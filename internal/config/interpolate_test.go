@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(secretFile, []byte("file-token\n"), 0600))
+	missingFile := filepath.Join(t.TempDir(), "missing")
+
+	t.Setenv("TSBRIDGE_TEST_HOST", "backend.internal")
+	t.Setenv("TSBRIDGE_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{name: "plain string", in: "localhost:8080", want: "localhost:8080"},
+		{name: "env reference", in: "${ENV:TSBRIDGE_TEST_HOST}:8080", want: "backend.internal:8080"},
+		{name: "lower case scheme", in: "${env:TSBRIDGE_TEST_HOST}", want: "backend.internal"},
+		{name: "env default used when unset", in: "${ENV:TSBRIDGE_TEST_UNSET:-fallback}", want: "fallback"},
+		{name: "env default used when empty", in: "${ENV:TSBRIDGE_TEST_EMPTY:-fallback}", want: "fallback"},
+		{name: "env default ignored when set", in: "${ENV:TSBRIDGE_TEST_HOST:-fallback}", want: "backend.internal"},
+		{name: "env set to empty without default", in: "a${ENV:TSBRIDGE_TEST_EMPTY}b", want: "ab"},
+		{name: "empty default", in: "a${ENV:TSBRIDGE_TEST_UNSET:-}b", want: "ab"},
+		{name: "file reference trims whitespace", in: "Bearer ${FILE:" + secretFile + "}", want: "Bearer file-token"},
+		{name: "missing file with default", in: "${FILE:" + missingFile + ":-none}", want: "none"},
+		{name: "multiple references", in: "${ENV:TSBRIDGE_TEST_HOST}/${FILE:" + secretFile + "}", want: "backend.internal/file-token"},
+		{name: "escaped literal", in: "$${ENV:TSBRIDGE_TEST_HOST}", want: "${ENV:TSBRIDGE_TEST_HOST}"},
+		{name: "unset env without default", in: "${ENV:TSBRIDGE_TEST_UNSET}", wantErr: "is not set"},
+		{name: "missing file without default", in: "${FILE:" + missingFile + "}", wantErr: "reading file"},
+		{name: "relative file path", in: "${FILE:relative/path}", wantErr: "invalid file"},
+		{name: "unknown scheme", in: "${VAULT:secret}", wantErr: "unknown reference type"},
+		{name: "missing scheme", in: "${NAME}", wantErr: "invalid reference"},
+		{name: "unterminated", in: "${ENV:TSBRIDGE_TEST_HOST", wantErr: "unterminated reference"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpolate(tt.in)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInterpolateConfig(t *testing.T) {
+	t.Setenv("TSBRIDGE_TEST_TOKEN", "super-secret-token")
+	t.Setenv("TSBRIDGE_TEST_CONTROL", "https://headscale.example.com")
+
+	original := map[string]string{"Authorization": "Bearer ${ENV:TSBRIDGE_TEST_TOKEN}"}
+	cfg := &Config{
+		Tailscale: Tailscale{ControlURL: "${ENV:TSBRIDGE_TEST_CONTROL}"},
+		Services: []Service{{
+			Name:            "api",
+			BackendAddr:     "localhost:8080",
+			UpstreamHeaders: original,
+			Tags:            []string{"tag:${ENV:TSBRIDGE_TEST_UNSET:-default}"},
+		}},
+	}
+
+	require.NoError(t, interpolateConfig(cfg, true))
+
+	assert.Equal(t, "https://headscale.example.com", cfg.Tailscale.ControlURL)
+	assert.Equal(t, "Bearer super-secret-token", cfg.Services[0].UpstreamHeaders["Authorization"])
+	assert.Equal(t, []string{"tag:default"}, cfg.Services[0].Tags)
+	// The map from the decoder is replaced rather than modified in place
+	assert.Equal(t, "Bearer ${ENV:TSBRIDGE_TEST_TOKEN}", original["Authorization"])
+
+	t.Run("redacted output shows templates", func(t *testing.T) {
+		redacted := cfg.Redacted()
+		assert.Equal(t, "Bearer ${ENV:TSBRIDGE_TEST_TOKEN}", redacted.Services[0].UpstreamHeaders["Authorization"])
+		assert.Equal(t, "localhost:8080", redacted.Services[0].BackendAddr)
+
+		out := cfg.String()
+		assert.NotContains(t, out, "super-secret-token")
+		assert.Contains(t, out, "${ENV:TSBRIDGE_TEST_TOKEN}")
+
+		// Redaction must not modify the live config
+		assert.Equal(t, "Bearer super-secret-token", cfg.Services[0].UpstreamHeaders["Authorization"])
+	})
+
+	t.Run("tailscale string shows templates", func(t *testing.T) {
+		out := cfg.Tailscale.String()
+		assert.Contains(t, out, "ControlURL: ${ENV:TSBRIDGE_TEST_CONTROL}")
+		assert.NotContains(t, out, "headscale.example.com")
+	})
+
+	t.Run("templates do not affect service equality", func(t *testing.T) {
+		plain := cfg.Services[0]
+		plain.templates = nil
+		assert.True(t, ServiceConfigEqual(plain, cfg.Services[0]))
+	})
+}
+
+func TestLoadWithInterpolation(t *testing.T) {
+	tmpDir := t.TempDir()
+	tokenFile := filepath.Join(tmpDir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-secret"), 0600))
+	t.Setenv("TSBRIDGE_TEST_BACKEND", "api.internal:9000")
+
+	configContent := `
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[[services]]
+name = "api"
+backend_addr = "${ENV:TSBRIDGE_TEST_BACKEND}"
+upstream_headers = { "X-Api-Token" = "${FILE:` + tokenFile + `}" }
+`
+	tmpFile := filepath.Join(tmpDir, "config.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(configContent), 0644))
+
+	cfg, err := Load(tmpFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "api.internal:9000", cfg.Services[0].BackendAddr)
+	assert.Equal(t, "file-secret", cfg.Services[0].UpstreamHeaders["X-Api-Token"])
+	assert.NotContains(t, cfg.String(), "file-secret")
+
+	t.Run("unresolvable reference fails loading", func(t *testing.T) {
+		bad := filepath.Join(tmpDir, "bad.toml")
+		require.NoError(t, os.WriteFile(bad, []byte(`
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[[services]]
+name = "api"
+backend_addr = "${ENV:TSBRIDGE_TEST_DOES_NOT_EXIST}"
+`), 0644))
+
+		_, err := Load(bad)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "interpolating values")
+		assert.Contains(t, err.Error(), "backend_addr")
+	})
+}
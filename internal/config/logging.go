@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/logging"
+)
+
+// validateLogging validates the log format, levels and log file settings
+func (c *Config) validateLogging() error {
+	g := c.Global
+
+	switch g.LogFormat {
+	case "", constants.LogFormatText, constants.LogFormatJSON, constants.LogFormatLogfmt:
+	default:
+		return errors.NewValidationError(fmt.Sprintf("log_format must be %q, %q or %q, got %q", constants.LogFormatText, constants.LogFormatJSON, constants.LogFormatLogfmt, g.LogFormat))
+	}
+
+	if g.LogLevel != "" {
+		if _, err := logging.ParseLevel(g.LogLevel); err != nil {
+			return errors.WrapValidation(err, "invalid log_level")
+		}
+	}
+	for component, level := range g.LogLevels {
+		if !slices.Contains(logging.Components, component) {
+			return errors.NewValidationError(fmt.Sprintf("log_levels has unknown component %q, must be one of %s", component, strings.Join(logging.Components, ", ")))
+		}
+		if _, err := logging.ParseLevel(level); err != nil {
+			return errors.WrapValidation(err, fmt.Sprintf("invalid log_levels.%s", component))
+		}
+	}
+
+	if g.LogFile == "" && (g.LogMaxSize != nil || g.LogMaxFiles != nil) {
+		return errors.NewValidationError("log_max_size and log_max_files require log_file")
+	}
+	if g.LogMaxSize != nil && *g.LogMaxSize < 0 {
+		return errors.NewValidationError(fmt.Sprintf("log_max_size must not be negative, got %d", *g.LogMaxSize))
+	}
+	if g.LogMaxFiles != nil && *g.LogMaxFiles < 0 {
+		return errors.NewValidationError(fmt.Sprintf("log_max_files must not be negative, got %d", *g.LogMaxFiles))
+	}
+	return nil
+}
+
+// LoggingOptions returns the logging settings of g, with defaults applied to
+// the log file rotation
+func (g Global) LoggingOptions() logging.Options {
+	opts := logging.Options{
+		Format:   g.LogFormat,
+		File:     g.LogFile,
+		MaxSize:  constants.DefaultLogMaxSize,
+		MaxFiles: constants.DefaultLogMaxFiles,
+	}
+	if g.LogLevel != "" {
+		opts.Level, _ = logging.ParseLevel(g.LogLevel)
+	}
+	if len(g.LogLevels) > 0 {
+		opts.Levels = make(map[string]slog.Level, len(g.LogLevels))
+		for component, name := range g.LogLevels {
+			opts.Levels[component], _ = logging.ParseLevel(name)
+		}
+	}
+	if g.LogMaxSize != nil {
+		opts.MaxSize = *g.LogMaxSize
+	}
+	if g.LogMaxFiles != nil {
+		opts.MaxFiles = *g.LogMaxFiles
+	}
+	return opts
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  Global
+		wantErr string
+	}{
+		{name: "defaults", global: Global{}},
+		{name: "json", global: Global{LogFormat: "json", LogLevel: "debug"}},
+		{name: "component levels", global: Global{LogFormat: "logfmt", LogLevels: map[string]string{"tsnet": "error", "docker": "DEBUG"}}},
+		{name: "log file", global: Global{LogFile: "/var/log/tsbridge.log", LogMaxSize: new(int64(0)), LogMaxFiles: new(3)}},
+		{name: "unknown format", global: Global{LogFormat: "xml"}, wantErr: "log_format must be"},
+		{name: "unknown level", global: Global{LogLevel: "verbose"}, wantErr: "invalid log_level"},
+		{name: "unknown component", global: Global{LogLevels: map[string]string{"api": "debug"}}, wantErr: `unknown component "api"`},
+		{name: "unknown component level", global: Global{LogLevels: map[string]string{"proxy": "loud"}}, wantErr: "invalid log_levels.proxy"},
+		{name: "rotation without file", global: Global{LogMaxFiles: new(3)}, wantErr: "require log_file"},
+		{name: "negative size", global: Global{LogFile: "tsbridge.log", LogMaxSize: new(int64(-1))}, wantErr: "log_max_size must not be negative"},
+		{name: "negative files", global: Global{LogFile: "tsbridge.log", LogMaxFiles: new(-1)}, wantErr: "log_max_files must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateGlobal(t, tt.global, tt.wantErr)
+		})
+	}
+}
+
+func TestLoggingOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		opts := Global{}.LoggingOptions()
+		assert.Equal(t, slog.LevelInfo, opts.Level)
+		assert.Nil(t, opts.Levels)
+		assert.Equal(t, int64(constants.DefaultLogMaxSize), opts.MaxSize)
+		assert.Equal(t, constants.DefaultLogMaxFiles, opts.MaxFiles)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		opts := Global{
+			LogFormat:   "json",
+			LogLevel:    "warn",
+			LogLevels:   map[string]string{"tsnet": "error"},
+			LogFile:     "tsbridge.log",
+			LogMaxSize:  new(int64(1024)),
+			LogMaxFiles: new(0),
+		}.LoggingOptions()
+		assert.Equal(t, logging.Options{
+			Format:   "json",
+			Level:    slog.LevelWarn,
+			Levels:   map[string]slog.Level{"tsnet": slog.LevelError},
+			File:     "tsbridge.log",
+			MaxSize:  1024,
+			MaxFiles: 0,
+		}, opts)
+	})
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jtdowney/tsbridge/internal/errors"
+)
+
+// profileIgnoredFields are Service fields that only make sense on a service
+// itself and are never inherited from a profile.
+var profileIgnoredFields = map[string]bool{
+	"name":    true,
+	"profile": true,
+}
+
+// expandProfiles fills each service that references a profile with the profile's
+// settings. Values set on the service always win; map fields such as
+// upstream_headers are merged key by key with the service's entries taking
+// precedence. Global defaults are applied afterwards by SetDefaults and Normalize,
+// so the overall order is: service, profile, [global], built-in defaults.
+func (c *Config) expandProfiles() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Profiles)) {
+		profile := c.Profiles[name]
+		if profile.Name != "" {
+			return errors.NewValidationError(fmt.Sprintf("profile %q: name cannot be set in a profile", name))
+		}
+		if profile.Profile != "" {
+			return errors.NewValidationError(fmt.Sprintf("profile %q: profiles cannot reference other profiles", name))
+		}
+	}
+
+	for i := range c.Services {
+		svc := &c.Services[i]
+		if svc.Profile == "" {
+			continue
+		}
+
+		profile, ok := c.Profiles[svc.Profile]
+		if !ok {
+			return errors.NewValidationError(fmt.Sprintf("service %q references unknown profile %q", svc.Name, svc.Profile))
+		}
+		applyProfile(svc, profile)
+	}
+
+	return nil
+}
+
+// applyProfile copies every field of profile that svc leaves unset into svc.
+// Note that for plain booleans such as ephemeral, false is indistinguishable
+// from unset, so a profile can turn them on but a service cannot turn them off.
+func applyProfile(svc *Service, profile Service) {
+	dst := reflect.ValueOf(svc).Elem()
+	src := reflect.ValueOf(profile)
+	t := dst.Type()
+
+	for i := range t.NumField() {
+		tag := t.Field(i).Tag.Get("mapstructure")
+		if tag == "" || profileIgnoredFields[tag] || !dst.Field(i).CanSet() {
+			continue
+		}
+
+		field, value := dst.Field(i), src.Field(i)
+		if value.IsZero() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Map:
+			merged := reflect.MakeMapWithSize(field.Type(), value.Len()+field.Len())
+			iter := value.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), iter.Value())
+				if !field.MapIndex(iter.Key()).IsValid() {
+					inheritTemplates(svc, profile, joinFieldPath(tag, iter.Key().String()))
+				}
+			}
+			iter = field.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), iter.Value())
+			}
+			field.Set(merged)
+		default:
+			if field.IsZero() {
+				field.Set(cloneValue(value))
+				inheritTemplates(svc, profile, tag)
+			}
+		}
+	}
+}
+
+// inheritTemplates copies the templates of profile's field at path, and of
+// the values within it, to svc, so that values the profile resolved are
+// shown as written like the service's own
+func inheritTemplates(svc *Service, profile Service, path string) {
+	for p, original := range profile.templates {
+		if p != path && !strings.HasPrefix(p, path+".") && !strings.HasPrefix(p, path+"[") {
+			continue
+		}
+		if svc.templates == nil {
+			svc.templates = make(templates)
+		}
+		svc.templates[p] = original
+	}
+}
+
+// cloneValue returns a copy of v that shares no pointer or slice storage with it
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		clone := reflect.New(v.Type().Elem())
+		clone.Elem().Set(v.Elem())
+		return clone
+	case reflect.Slice:
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(clone, v)
+		return clone
+	default:
+		return v
+	}
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandProfiles(t *testing.T) {
+	t.Run("service values win over profile values", func(t *testing.T) {
+		cfg := &Config{
+			Profiles: map[string]Service{
+				"internal-web": {
+					WhoisEnabled:    testhelpers.BoolPtr(true),
+					Tags:            []string{"tag:web"},
+					WriteTimeout:    testhelpers.DurationPtr(45 * time.Second),
+					TLSMode:         "off",
+					Ephemeral:       true,
+					UpstreamHeaders: map[string]string{"X-Env": "prod", "X-Team": "platform"},
+				},
+			},
+			Services: []Service{
+				{
+					Name:            "grafana",
+					BackendAddr:     "localhost:3000",
+					Profile:         "internal-web",
+					TLSMode:         "auto",
+					UpstreamHeaders: map[string]string{"X-Team": "observability"},
+				},
+				{
+					Name:        "plain",
+					BackendAddr: "localhost:4000",
+				},
+			},
+		}
+
+		require.NoError(t, cfg.expandProfiles())
+
+		grafana := cfg.Services[0]
+		assert.Equal(t, "grafana", grafana.Name)
+		assert.Equal(t, "internal-web", grafana.Profile)
+		assert.Equal(t, "auto", grafana.TLSMode)
+		require.NotNil(t, grafana.WhoisEnabled)
+		assert.True(t, *grafana.WhoisEnabled)
+		assert.Equal(t, []string{"tag:web"}, grafana.Tags)
+		require.NotNil(t, grafana.WriteTimeout)
+		assert.Equal(t, 45*time.Second, *grafana.WriteTimeout)
+		assert.True(t, grafana.Ephemeral)
+		assert.Equal(t, map[string]string{"X-Env": "prod", "X-Team": "observability"}, grafana.UpstreamHeaders)
+
+		plain := cfg.Services[1]
+		assert.Nil(t, plain.WhoisEnabled)
+		assert.Empty(t, plain.Tags)
+		assert.False(t, plain.Ephemeral)
+	})
+
+	t.Run("services do not share profile storage", func(t *testing.T) {
+		cfg := &Config{
+			Profiles: map[string]Service{
+				"shared": {
+					Tags:         []string{"tag:a"},
+					WhoisEnabled: testhelpers.BoolPtr(true),
+				},
+			},
+			Services: []Service{
+				{Name: "one", BackendAddr: "localhost:1", Profile: "shared"},
+				{Name: "two", BackendAddr: "localhost:2", Profile: "shared"},
+			},
+		}
+
+		require.NoError(t, cfg.expandProfiles())
+
+		cfg.Services[0].Tags[0] = "tag:changed"
+		*cfg.Services[0].WhoisEnabled = false
+
+		assert.Equal(t, "tag:a", cfg.Services[1].Tags[0])
+		assert.True(t, *cfg.Services[1].WhoisEnabled)
+		assert.Equal(t, "tag:a", cfg.Profiles["shared"].Tags[0])
+	})
+
+	errorTests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name: "unknown profile",
+			cfg: Config{
+				Services: []Service{{Name: "api", BackendAddr: "localhost:1", Profile: "missing"}},
+			},
+			wantErr: `service "api" references unknown profile "missing"`,
+		},
+		{
+			name: "profile sets name",
+			cfg: Config{
+				Profiles: map[string]Service{"bad": {Name: "api"}},
+			},
+			wantErr: "name cannot be set in a profile",
+		},
+		{
+			name: "profile references another profile",
+			cfg: Config{
+				Profiles: map[string]Service{"bad": {Profile: "other"}},
+			},
+			wantErr: "profiles cannot reference other profiles",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.expandProfiles()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoadWithProfiles(t *testing.T) {
+	configContent := `
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[global]
+write_timeout = "30s"
+idle_timeout = "90s"
+
+[profiles.internal-web]
+whois_enabled = true
+write_timeout = "45s"
+tags = ["tag:web"]
+upstream_headers = { "X-Env" = "prod" }
+
+[[services]]
+name = "grafana"
+backend_addr = "localhost:3000"
+profile = "internal-web"
+
+[[services]]
+name = "prometheus"
+backend_addr = "localhost:9090"
+profile = "internal-web"
+write_timeout = "10s"
+`
+	tmpFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(configContent), 0644))
+
+	cfg, err := Load(tmpFile)
+	require.NoError(t, err)
+	require.Len(t, cfg.Services, 2)
+
+	grafana := cfg.Services[0]
+	require.NotNil(t, grafana.WhoisEnabled)
+	assert.True(t, *grafana.WhoisEnabled)
+	assert.Equal(t, []string{"tag:web"}, grafana.Tags)
+	assert.Equal(t, map[string]string{"X-Env": "prod"}, grafana.UpstreamHeaders)
+	require.NotNil(t, grafana.WriteTimeout)
+	assert.Equal(t, 45*time.Second, *grafana.WriteTimeout)
+	// Global values still fill fields that neither the service nor the profile set
+	require.NotNil(t, grafana.IdleTimeout)
+	assert.Equal(t, 90*time.Second, *grafana.IdleTimeout)
+
+	prometheus := cfg.Services[1]
+	require.NotNil(t, prometheus.WriteTimeout)
+	assert.Equal(t, 10*time.Second, *prometheus.WriteTimeout)
+	assert.Equal(t, []string{"tag:web"}, prometheus.Tags)
+
+	t.Run("unknown profile fails loading", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.toml")
+		require.NoError(t, os.WriteFile(bad, []byte(`
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[[services]]
+name = "api"
+backend_addr = "localhost:8080"
+profile = "nope"
+`), 0644))
+
+		_, err := Load(bad)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expanding profiles")
+	})
+}
+
+func TestProfilesInterpolatedForDocker(t *testing.T) {
+	t.Setenv("TEST_PROFILE_TOKEN", "s3cret")
+	cfg := &Config{
+		Tailscale: Tailscale{AuthKey: "tskey-auth-test"},
+		Profiles: map[string]Service{
+			"internal-web": {
+				UpstreamHeaders: map[string]string{"Authorization": "Bearer ${ENV:TEST_PROFILE_TOKEN}"},
+				WhoisEnabled:    new(true),
+			},
+		},
+		Services: []Service{{
+			Name:            "api",
+			BackendAddr:     "localhost:8080",
+			Profile:         "internal-web",
+			UpstreamHeaders: map[string]string{"X-Label": "${ENV:TEST_PROFILE_TOKEN}"},
+		}},
+	}
+
+	require.NoError(t, ProcessLoadedConfigWithProvider(cfg, "docker"))
+
+	headers := cfg.Services[0].UpstreamHeaders
+	assert.Equal(t, "Bearer s3cret", headers["Authorization"], "profile values are resolved")
+	assert.Equal(t, "${ENV:TEST_PROFILE_TOKEN}", headers["X-Label"], "container label values are not")
+
+	redacted := cfg.Redacted().Services[0].UpstreamHeaders
+	assert.Equal(t, "Bearer ${ENV:TEST_PROFILE_TOKEN}", redacted["Authorization"], "resolved profile values are shown as written")
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 )
 
 // RedactedString is a string type that masks its value in logs and string representations
@@ -40,14 +43,18 @@ type RedactedTailscale struct {
 	AuthKeyEnv            string   `json:"auth_key_env,omitempty"`
 	AuthKeyFile           string   `json:"auth_key_file,omitempty"`
 	StateDir              string   `json:"state_dir,omitempty"`
+	StateDirEnv           string   `json:"state_dir_env,omitempty"`
 	DefaultTags           []string `json:"default_tags,omitempty"`
+	ControlURL            string   `json:"control_url,omitempty"`
+	OAuthPreauthorized    *bool    `json:"oauth_preauthorized,omitempty"`
 }
 
 // RedactedConfig is a version of Config with sensitive fields redacted for safe logging
 type RedactedConfig struct {
-	Tailscale RedactedTailscale `json:"tailscale"`
-	Global    Global            `json:"global"`
-	Services  []Service         `json:"services"`
+	Tailscale RedactedTailscale          `json:"tailscale"`
+	Global    Global                     `json:"global"`
+	Services  []Service                  `json:"services"`
+	Webhooks  map[string]RedactedWebhook `json:"webhooks,omitempty"`
 }
 
 // Redacted returns a copy of the Config with sensitive fields masked
@@ -56,19 +63,26 @@ func (c *Config) Redacted() *RedactedConfig {
 		return nil
 	}
 
+	// Interpolated fields are shown as written, e.g. ${FILE:/run/secrets/token},
+	// so values pulled in from the environment or files are never exposed
+	ts := c.Tailscale.withTemplates()
+
 	redacted := &RedactedConfig{
 		Tailscale: RedactedTailscale{
-			OAuthClientID:         c.Tailscale.OAuthClientID,
-			OAuthClientIDEnv:      c.Tailscale.OAuthClientIDEnv,
-			OAuthClientIDFile:     c.Tailscale.OAuthClientIDFile,
-			OAuthClientSecretEnv:  c.Tailscale.OAuthClientSecretEnv,
-			OAuthClientSecretFile: c.Tailscale.OAuthClientSecretFile,
-			AuthKeyEnv:            c.Tailscale.AuthKeyEnv,
-			AuthKeyFile:           c.Tailscale.AuthKeyFile,
-			StateDir:              c.Tailscale.StateDir,
-			DefaultTags:           c.Tailscale.DefaultTags,
+			OAuthClientID:         ts.OAuthClientID,
+			OAuthClientIDEnv:      ts.OAuthClientIDEnv,
+			OAuthClientIDFile:     ts.OAuthClientIDFile,
+			OAuthClientSecretEnv:  ts.OAuthClientSecretEnv,
+			OAuthClientSecretFile: ts.OAuthClientSecretFile,
+			AuthKeyEnv:            ts.AuthKeyEnv,
+			AuthKeyFile:           ts.AuthKeyFile,
+			StateDir:              ts.StateDir,
+			StateDirEnv:           ts.StateDirEnv,
+			DefaultTags:           ts.DefaultTags,
+			ControlURL:            ts.ControlURL,
+			OAuthPreauthorized:    ts.OAuthPreauthorized,
 		},
-		Global:   c.Global,
+		Global:   c.Global.withTemplates(),
 		Services: make([]Service, len(c.Services)),
 	}
 
@@ -80,8 +94,16 @@ func (c *Config) Redacted() *RedactedConfig {
 		redacted.Tailscale.AuthKey = "[REDACTED]"
 	}
 
-	// Copy services (they don't contain sensitive data currently)
-	copy(redacted.Services, c.Services)
+	for i, svc := range c.Services {
+		redacted.Services[i] = svc.withTemplates()
+	}
+
+	for name, hook := range c.Webhooks {
+		if redacted.Webhooks == nil {
+			redacted.Webhooks = make(map[string]RedactedWebhook, len(c.Webhooks))
+		}
+		redacted.Webhooks[name] = hook.redacted()
+	}
 
 	return redacted
 }
@@ -95,3 +117,88 @@ func (r *RedactedConfig) String() string {
 	}
 	return string(data)
 }
+
+// Settings returns the redacted configuration as nested maps keyed by the same
+// names used in the TOML file, suitable for printing as TOML or JSON. Durations
+// are rendered as strings and unset optional values are left out.
+func (r *RedactedConfig) Settings() map[string]any {
+	services := make([]map[string]any, len(r.Services))
+	for i, svc := range r.Services {
+		services[i] = settingsMap(reflect.ValueOf(svc))
+	}
+
+	settings := map[string]any{
+		"tailscale": settingsMap(reflect.ValueOf(r.Tailscale)),
+		"global":    settingsMap(reflect.ValueOf(r.Global)),
+		"services":  services,
+	}
+	if len(r.Webhooks) > 0 {
+		webhooks := make(map[string]any, len(r.Webhooks))
+		for name, hook := range r.Webhooks {
+			webhooks[name] = settingsMap(reflect.ValueOf(hook))
+		}
+		settings["webhooks"] = webhooks
+	}
+	return settings
+}
+
+// ServicesFromSettings returns the services of settings produced by
+// RedactedConfig.Settings, such as those served by the admin API. Interpolated
+// values stay as written, so the services compare equal to those of
+// Config.Redacted for the same configuration.
+func ServicesFromSettings(settings map[string]any) ([]Service, error) {
+	var services []Service
+	decoder, err := newDecoder(&services)
+	if err != nil {
+		return nil, fmt.Errorf("creating decoder: %w", err)
+	}
+	if err := decoder.Decode(settings["services"]); err != nil {
+		return nil, fmt.Errorf("decoding services: %w", err)
+	}
+	return services, nil
+}
+
+// settingsMap converts a config struct to a map keyed by its mapstructure (or JSON) names
+func settingsMap(v reflect.Value) map[string]any {
+	settings := make(map[string]any)
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+		if name == "" {
+			continue
+		}
+		if value, ok := settingValue(v.Field(i)); ok {
+			settings[name] = value
+		}
+	}
+	return settings
+}
+
+// settingValue returns the printable form of a single field, or false if it is unset
+func settingValue(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String(), true
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRedactedString(t *testing.T) {
@@ -233,3 +237,44 @@ func TestTailscaleWithRedactedString(t *testing.T) {
 		t.Errorf("Formatted output contains actual auth key: %s", formatted)
 	}
 }
+
+func TestRedactedConfig_Settings(t *testing.T) {
+	writeTimeout := 30 * time.Second
+	maxBody := int64(1024)
+	cfg := &Config{
+		Tailscale: Tailscale{
+			AuthKey:    RedactedString("tskey-auth-secret"),
+			ControlURL: "https://headscale.example.com",
+		},
+		Global: Global{
+			WriteTimeout:       &writeTimeout,
+			MaxRequestBodySize: &maxBody,
+		},
+		Services: []Service{{
+			Name:            "api",
+			BackendAddr:     "localhost:8080",
+			Tags:            []string{"tag:api"},
+			UpstreamHeaders: map[string]string{"X-Env": "prod"},
+		}},
+	}
+
+	settings := cfg.Redacted().Settings()
+
+	tailscale := settings["tailscale"].(map[string]any)
+	assert.Equal(t, "[REDACTED]", tailscale["auth_key"])
+	assert.Equal(t, "https://headscale.example.com", tailscale["control_url"])
+	assert.NotContains(t, tailscale, "oauth_client_id", "unset values are left out")
+
+	global := settings["global"].(map[string]any)
+	assert.Equal(t, "30s", global["write_timeout"])
+	assert.Equal(t, int64(1024), global["max_request_body_size"])
+	assert.NotContains(t, global, "idle_timeout")
+
+	services := settings["services"].([]map[string]any)
+	require.Len(t, services, 1)
+	assert.Equal(t, "api", services[0]["name"])
+	assert.Equal(t, []string{"tag:api"}, services[0]["tags"])
+	assert.Equal(t, map[string]string{"X-Env": "prod"}, services[0]["upstream_headers"])
+	assert.Equal(t, false, services[0]["ephemeral"])
+	assert.NotContains(t, services[0], "whois_enabled")
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	_ "embed"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// configSource is the source of this package's config structs. The trailing
+// comment on each field doubles as its description in the generated schema so
+// the documentation lives in exactly one place.
+//
+//go:embed config.go
+var configSource string
+
+const (
+	// schemaDraft is the JSON Schema dialect used by Schema
+	schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+	// durationPattern matches Go duration strings such as "30s", "1m30s" or "-1ms"
+	durationPattern = `^(0|-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+
+	// byteSizePattern matches byte sizes such as "1048576", "10MB", "1.5GiB" or "-1"
+	byteSizePattern = `^(-1|[0-9]+(\.[0-9]+)? ?([bB]([yY][tT][eE][sS]?)?|[kKmMgGtT]([iI]?[bB])?)?)$`
+)
+
+// schemaSections maps config struct names to the section names used in field paths
+var schemaSections = map[string]string{
+	"Tailscale": "tailscale",
+	"Global":    "global",
+	"Service":   "service",
+	"Webhook":   "webhook",
+}
+
+// schemaEnums lists the accepted values of fields restricted to a fixed set
+var schemaEnums = map[string][]string{
+	"service.tls_mode":         {constants.TLSModeAuto, constants.TLSModeOff},
+	"service.wait_for_backend": {constants.WaitForBackendOff, constants.WaitForBackendDelay, constants.WaitForBackendUnavailable},
+	"global.tracing_protocol":  {constants.TracingProtocolGRPC, constants.TracingProtocolHTTP},
+	"global.reload_mode":       {constants.ReloadModeBestEffort, constants.ReloadModeTransactional},
+	"global.log_format":        {constants.LogFormatText, constants.LogFormatJSON, constants.LogFormatLogfmt},
+	"global.access_log_format": {constants.AccessLogFormatJSON, constants.AccessLogFormatCombined, constants.AccessLogFormatTemplate},
+	"webhook.format":           {constants.WebhookFormatJSON, constants.WebhookFormatSlack, constants.WebhookFormatNtfy},
+}
+
+// fieldDescriptions parses configSource once and returns the description of each
+// field keyed by "<section>.<mapstructure name>", e.g. "service.tls_mode".
+var fieldDescriptions = sync.OnceValue(func() map[string]string {
+	descriptions := make(map[string]string)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "config.go", configSource, parser.ParseComments)
+	if err != nil {
+		// configSource is this package's own source, so it always parses
+		panic(err)
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		section, ok := schemaSections[spec.Name.Name]
+		if !ok {
+			return false
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag == nil || field.Comment == nil {
+				continue
+			}
+			tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("mapstructure")
+			if tag == "" {
+				continue
+			}
+			descriptions[section+"."+tag] = strings.TrimSpace(field.Comment.Text())
+		}
+		return false
+	})
+
+	return descriptions
+})
+
+// FieldDescription returns the documentation for a configuration field. Section is
+// one of "tailscale", "global", "service" or "webhook" and field is the TOML key.
+func FieldDescription(section, field string) string {
+	return fieldDescriptions()[section+"."+field]
+}
+
+// Schema returns a JSON Schema describing the TOML configuration file. It is
+// generated from the Config structs and their mapstructure tags, so it always
+// matches what Load accepts.
+func Schema() map[string]any {
+	service := structSchema(reflect.TypeFor[Service](), "service", nil)
+	service["required"] = []string{"name", "backend_addr"}
+
+	profile := structSchema(reflect.TypeFor[Service](), "service", profileIgnoredFields)
+	profile["description"] = "Service settings shared by every service that names this profile"
+
+	webhook := structSchema(reflect.TypeFor[Webhook](), "webhook", nil)
+	webhook["required"] = []string{"url"}
+
+	return map[string]any{
+		"$schema":              schemaDraft,
+		"title":                "tsbridge configuration",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"tailscale": map[string]any{
+				"$ref":        "#/$defs/tailscale",
+				"description": "Tailscale authentication config",
+			},
+			"global": map[string]any{
+				"$ref":        "#/$defs/global",
+				"description": "Default settings for all services",
+			},
+			"services": map[string]any{
+				"type":        "array",
+				"description": "List of services to expose",
+				"items":       map[string]any{"$ref": "#/$defs/service"},
+			},
+			"profiles": map[string]any{
+				"type":                 "object",
+				"description":          "Named service settings shared via the service profile key",
+				"additionalProperties": map[string]any{"$ref": "#/$defs/profile"},
+			},
+			"webhooks": map[string]any{
+				"type":                 "object",
+				"description":          "Named outbound webhooks notified of failures and state changes",
+				"additionalProperties": map[string]any{"$ref": "#/$defs/webhook"},
+			},
+		},
+		"$defs": map[string]any{
+			"tailscale": structSchema(reflect.TypeFor[Tailscale](), "tailscale", nil),
+			"global":    structSchema(reflect.TypeFor[Global](), "global", nil),
+			"service":   service,
+			"profile":   profile,
+			"webhook":   webhook,
+		},
+	}
+}
+
+// structSchema builds an object schema from the mapstructure-tagged fields of t,
+// leaving out any field named in skip.
+func structSchema(t reflect.Type, section string, skip map[string]bool) map[string]any {
+	properties := make(map[string]any)
+	for field := range t.Fields() {
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" || skip[tag] {
+			continue
+		}
+
+		prop := fieldSchema(field.Type, tag)
+		if desc := FieldDescription(section, tag); desc != "" {
+			prop["description"] = desc
+		}
+		if enum, ok := schemaEnums[section+"."+tag]; ok {
+			prop["enum"] = enum
+		}
+		properties[tag] = prop
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+// byteSizeSchema returns the schema for a size given either in bytes, at
+// least minimum, or as a string with a unit such as "10MB"
+func byteSizeSchema(minimum int) map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "integer", "minimum": minimum},
+			map[string]any{"type": "string", "format": "byte-size", "pattern": byteSizePattern},
+		},
+	}
+}
+
+// fieldSchema returns the schema for a single field of type t
+func fieldSchema(t reflect.Type, name string) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeFor[time.Duration]():
+		return map[string]any{"type": "string", "format": "duration", "pattern": durationPattern}
+	case t == reflect.TypeFor[RedactedString]():
+		return map[string]any{"type": "string", "writeOnly": true}
+	case name == "max_request_body_size":
+		return byteSizeSchema(-1)
+	case name == "log_max_size", name == "access_log_max_size":
+		return byteSizeSchema(0)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": fieldSchema(t.Elem(), "")}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": fieldSchema(t.Elem(), "")}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldDescriptionCoversAllFields(t *testing.T) {
+	for name, section := range schemaSections {
+		var typ reflect.Type
+		switch name {
+		case "Tailscale":
+			typ = reflect.TypeFor[Tailscale]()
+		case "Global":
+			typ = reflect.TypeFor[Global]()
+		case "Service":
+			typ = reflect.TypeFor[Service]()
+		case "Webhook":
+			typ = reflect.TypeFor[Webhook]()
+		}
+
+		for field := range typ.Fields() {
+			tag := field.Tag.Get("mapstructure")
+			if tag == "" {
+				continue
+			}
+			assert.NotEmpty(t, FieldDescription(section, tag),
+				"Field %s.%s has no trailing comment to use as its description", name, field.Name)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+
+	// The schema must serialize cleanly for editors and CI tools
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+
+	defs := schema["$defs"].(map[string]any)
+	props := func(def string) map[string]any {
+		return defs[def].(map[string]any)["properties"].(map[string]any)
+	}
+
+	t.Run("every field is present", func(t *testing.T) {
+		for def, typ := range map[string]reflect.Type{
+			"tailscale": reflect.TypeFor[Tailscale](),
+			"global":    reflect.TypeFor[Global](),
+			"service":   reflect.TypeFor[Service](),
+			"webhook":   reflect.TypeFor[Webhook](),
+		} {
+			for field := range typ.Fields() {
+				tag := field.Tag.Get("mapstructure")
+				if tag == "" {
+					continue
+				}
+				assert.Contains(t, props(def), tag, "%s schema is missing %s", def, tag)
+			}
+		}
+	})
+
+	t.Run("tls_mode enum", func(t *testing.T) {
+		tlsMode := props("service")["tls_mode"].(map[string]any)
+		assert.Equal(t, []string{"auto", "off"}, tlsMode["enum"])
+		assert.Equal(t, `TLS mode: "auto" (default) or "off"`, tlsMode["description"])
+	})
+
+	t.Run("formats", func(t *testing.T) {
+		writeTimeout := props("global")["write_timeout"].(map[string]any)
+		assert.Equal(t, "duration", writeTimeout["format"])
+
+		authKey := props("tailscale")["auth_key"].(map[string]any)
+		assert.Equal(t, true, authKey["writeOnly"])
+
+		tags := props("service")["tags"].(map[string]any)
+		assert.Equal(t, "array", tags["type"])
+
+		headers := props("service")["upstream_headers"].(map[string]any)
+		assert.Equal(t, map[string]any{"type": "string"}, headers["additionalProperties"])
+	})
+
+	t.Run("profiles omit service-only fields", func(t *testing.T) {
+		assert.NotContains(t, props("profile"), "name")
+		assert.NotContains(t, props("profile"), "profile")
+		assert.Contains(t, props("profile"), "whois_enabled")
+		assert.Contains(t, props("service"), "profile")
+	})
+}
+
+func TestSchemaPatterns(t *testing.T) {
+	duration := regexp.MustCompile(durationPattern)
+	for _, valid := range []string{"0", "30s", "1m30s", "-1ms", "1.5h", "100us"} {
+		assert.True(t, duration.MatchString(valid), "duration %q should match", valid)
+	}
+	for _, invalid := range []string{"", "30", "soon", "1d"} {
+		assert.False(t, duration.MatchString(invalid), "duration %q should not match", invalid)
+	}
+
+	byteSize := regexp.MustCompile(byteSizePattern)
+	for _, valid := range []string{"-1", "1048576", "10MB", "10mb", "1.5GiB", "512 KB", "1B", "2bytes"} {
+		assert.True(t, byteSize.MatchString(valid), "byte size %q should match", valid)
+		_, err := ParseByteSizeString(valid)
+		assert.NoError(t, err, "byte size %q should also be accepted by the parser", valid)
+	}
+	for _, invalid := range []string{"", "ten", "10XB", "-5MB"} {
+		assert.False(t, byteSize.MatchString(invalid), "byte size %q should not match", invalid)
+	}
+}
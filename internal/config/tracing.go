@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+)
+
+// validateTracing validates the OpenTelemetry tracing settings
+func (c *Config) validateTracing() error {
+	g := c.Global
+	if g.TracingEndpoint == "" {
+		if g.TracingProtocol != "" || g.TracingSampleRatio != nil {
+			return errors.NewValidationError("tracing_protocol and tracing_sample_ratio require tracing_endpoint")
+		}
+		return nil
+	}
+
+	endpoint, err := url.Parse(g.TracingEndpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return errors.NewValidationError(fmt.Sprintf("tracing_endpoint must be an http:// or https:// URL, got %q", g.TracingEndpoint))
+	}
+
+	switch g.TracingProtocol {
+	case "", constants.TracingProtocolGRPC, constants.TracingProtocolHTTP:
+	default:
+		return errors.NewValidationError(fmt.Sprintf("tracing_protocol must be %q or %q, got %q", constants.TracingProtocolGRPC, constants.TracingProtocolHTTP, g.TracingProtocol))
+	}
+
+	if ratio := g.TracingSampleRatio; ratio != nil && !(*ratio >= 0 && *ratio <= 1) {
+		return errors.NewValidationError(fmt.Sprintf("tracing_sample_ratio must be between 0 and 1, got %v", *ratio))
+	}
+	return nil
+}
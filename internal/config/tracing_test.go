@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestValidateTracing(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  Global
+		wantErr string
+	}{
+		{name: "disabled", global: Global{}},
+		{name: "grpc by default", global: Global{TracingEndpoint: "http://collector:4317"}},
+		{name: "http", global: Global{TracingEndpoint: "https://collector.example.com/v1/traces", TracingProtocol: "http", TracingSampleRatio: new(0.25)}},
+		{name: "sample nothing", global: Global{TracingEndpoint: "http://collector:4317", TracingSampleRatio: new(0.0)}},
+		{name: "missing scheme", global: Global{TracingEndpoint: "collector:4317"}, wantErr: "must be an http:// or https:// URL"},
+		{name: "unsupported scheme", global: Global{TracingEndpoint: "udp://collector:4317"}, wantErr: "must be an http:// or https:// URL"},
+		{name: "unknown protocol", global: Global{TracingEndpoint: "http://collector:4317", TracingProtocol: "thrift"}, wantErr: "tracing_protocol must be"},
+		{name: "ratio above one", global: Global{TracingEndpoint: "http://collector:4317", TracingSampleRatio: new(1.5)}, wantErr: "between 0 and 1"},
+		{name: "negative ratio", global: Global{TracingEndpoint: "http://collector:4317", TracingSampleRatio: new(-0.1)}, wantErr: "between 0 and 1"},
+		{name: "settings without endpoint", global: Global{TracingProtocol: "http"}, wantErr: "require tracing_endpoint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateGlobal(t, tt.global, tt.wantErr)
+		})
+	}
+}
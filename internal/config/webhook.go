@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
+)
+
+// RedactedWebhook is a version of Webhook with its URL path and secret redacted
+type RedactedWebhook struct {
+	URL        string         `json:"url"`
+	Format     string         `json:"format,omitempty"`
+	Events     []string       `json:"events,omitempty"`
+	Secret     string         `json:"secret,omitempty"`
+	Timeout    *time.Duration `json:"timeout,omitempty"`
+	MaxRetries *int           `json:"max_retries,omitempty"`
+}
+
+// redacted returns the webhook with everything after the URL's host and the
+// secret masked
+func (w Webhook) redacted() RedactedWebhook {
+	redacted := RedactedWebhook{
+		URL:        "[REDACTED]",
+		Format:     w.Format,
+		Events:     w.Events,
+		Timeout:    w.Timeout,
+		MaxRetries: w.MaxRetries,
+	}
+	if u, err := url.Parse(w.URL.Value()); err == nil && u.Host != "" {
+		redacted.URL = u.Scheme + "://" + u.Host
+		if u.Path != "" || u.RawQuery != "" {
+			redacted.URL += "/[REDACTED]"
+		}
+	}
+	if w.Secret.Value() != "" {
+		redacted.Secret = "[REDACTED]"
+	}
+	return redacted
+}
+
+// interpolateWebhooks expands ${ENV:...} and ${FILE:...} references in every
+// webhook. Webhook URLs and secrets are always redacted, so no templates are kept.
+func (c *Config) interpolateWebhooks() error {
+	for name, hook := range c.Webhooks {
+		if _, err := interpolateFields(&hook, nil); err != nil {
+			return errors.WrapValidation(err, fmt.Sprintf("webhook %q", name))
+		}
+		c.Webhooks[name] = hook
+	}
+	return nil
+}
+
+// validateWebhooks validates the outbound webhook settings
+func (c *Config) validateWebhooks() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Webhooks)) {
+		if err := c.Webhooks[name].validate(); err != nil {
+			return errors.WrapValidation(err, fmt.Sprintf("webhook %q", name))
+		}
+	}
+	return nil
+}
+
+// validate validates a single webhook
+func (w Webhook) validate() error {
+	u, err := url.Parse(w.URL.Value())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		// The URL itself may be secret, so it is left out of the error
+		return errors.NewValidationError("url must be an http:// or https:// URL")
+	}
+
+	switch w.Format {
+	case "", constants.WebhookFormatJSON, constants.WebhookFormatSlack, constants.WebhookFormatNtfy:
+	default:
+		return errors.NewValidationError(fmt.Sprintf("format must be %q, %q or %q, got %q",
+			constants.WebhookFormatJSON, constants.WebhookFormatSlack, constants.WebhookFormatNtfy, w.Format))
+	}
+
+	for _, eventType := range w.Events {
+		if !events.IsType(eventType) {
+			return errors.NewValidationError(fmt.Sprintf("unknown event type %q", eventType))
+		}
+	}
+
+	if err := validateTimeoutPositive("timeout", w.Timeout); err != nil {
+		return err
+	}
+	if w.MaxRetries != nil && *w.MaxRetries < 0 {
+		return errors.NewValidationError(fmt.Sprintf("max_retries must not be negative, got %d", *w.MaxRetries))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebhooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook Webhook
+		wantErr string
+	}{
+		{name: "json by default", webhook: Webhook{URL: "https://hooks.example.com/tsbridge"}},
+		{name: "slack", webhook: Webhook{URL: "https://hooks.slack.com/services/T0/B0/x", Format: "slack", Secret: "s3cret"}},
+		{name: "event types and categories", webhook: Webhook{URL: "http://ntfy.local/tsbridge", Format: "ntfy", Events: []string{"backend", "config.reload_failed"}}},
+		{name: "no retries", webhook: Webhook{URL: "https://hooks.example.com", MaxRetries: new(0)}},
+		{name: "missing url", webhook: Webhook{}, wantErr: "url must be an http:// or https:// URL"},
+		{name: "error names the webhook", webhook: Webhook{}, wantErr: `webhook "alerts"`},
+		{name: "unsupported scheme", webhook: Webhook{URL: "ftp://hooks.example.com"}, wantErr: "url must be an http:// or https:// URL"},
+		{name: "unknown format", webhook: Webhook{URL: "https://hooks.example.com", Format: "teams"}, wantErr: `format must be "json", "slack" or "ntfy"`},
+		{name: "unknown event", webhook: Webhook{URL: "https://hooks.example.com", Events: []string{"service.exploded"}}, wantErr: `unknown event type "service.exploded"`},
+		{name: "zero timeout", webhook: Webhook{URL: "https://hooks.example.com", Timeout: new(time.Duration(0))}, wantErr: "timeout must be positive"},
+		{name: "negative retries", webhook: Webhook{URL: "https://hooks.example.com", MaxRetries: new(-1)}, wantErr: "max_retries must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateMinimal(t, func(cfg *Config) {
+				cfg.Webhooks = map[string]Webhook{"alerts": tt.webhook}
+			}, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	configContent := `
+[tailscale]
+auth_key = "tskey-auth-test"
+
+[[services]]
+name = "api"
+backend_addr = "localhost:8080"
+
+[webhooks.slack]
+url = "https://hooks.slack.com/services/T0/B0/token"
+format = "slack"
+secret = "${ENV:TEST_WEBHOOK_SECRET}"
+
+[webhooks.ntfy]
+url = "https://ntfy.sh/tsbridge-alerts"
+format = "ntfy"
+events = ["backend"]
+max_retries = 0
+`
+	tmpFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(configContent), 0644))
+
+	cfg, err := Load(tmpFile)
+	require.NoError(t, err)
+	require.Len(t, cfg.Webhooks, 2)
+
+	slack := cfg.Webhooks["slack"]
+	assert.Equal(t, "https://hooks.slack.com/services/T0/B0/token", slack.URL.Value())
+	assert.Equal(t, "s3cret", slack.Secret.Value())
+	require.NotNil(t, slack.Timeout)
+	assert.Equal(t, 10*time.Second, *slack.Timeout)
+	require.NotNil(t, slack.MaxRetries)
+	assert.Equal(t, 3, *slack.MaxRetries)
+
+	ntfy := cfg.Webhooks["ntfy"]
+	assert.Equal(t, []string{"backend"}, ntfy.Events)
+	require.NotNil(t, ntfy.MaxRetries)
+	assert.Equal(t, 0, *ntfy.MaxRetries)
+
+	// URL paths and secrets never appear in displayed configuration
+	settings := cfg.Redacted().Settings()
+	webhooks := settings["webhooks"].(map[string]any)
+	assert.Equal(t, map[string]any{
+		"url":         "https://hooks.slack.com/[REDACTED]",
+		"format":      "slack",
+		"secret":      "[REDACTED]",
+		"timeout":     "10s",
+		"max_retries": 3,
+	}, webhooks["slack"])
+	assert.NotContains(t, cfg.Redacted().String(), "token")
+	assert.NotContains(t, cfg.Redacted().String(), "s3cret")
+}
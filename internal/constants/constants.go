@@ -20,6 +20,10 @@ const (
 	// DefaultShutdownTimeout is the default timeout for graceful shutdown of services.
 	DefaultShutdownTimeout = 30 * time.Second
 
+	// DefaultDrainTimeout is the default time WebSocket and streaming
+	// connections get to close when a service stops.
+	DefaultDrainTimeout = 10 * time.Second
+
 	// DefaultStartupTimeout is the default timeout for Tailscale server startup.
 	DefaultStartupTimeout = 30 * time.Second
 
@@ -31,6 +35,9 @@ const (
 	// This should be relatively short to avoid blocking service startup.
 	BackendHealthCheckTimeout = 5 * time.Second
 
+	// ReadinessCheckTimeout bounds the node status lookups made for a /readyz request.
+	ReadinessCheckTimeout = 2 * time.Second
+
 	// DefaultDialTimeout is the default timeout for dialing backend connections.
 	DefaultDialTimeout = 30 * time.Second
 
@@ -81,6 +88,248 @@ const (
 	TLSModeOff = "off"
 )
 
+// wait_for_backend values for services.
+const (
+	// WaitForBackendOff starts a service without checking its backend.
+	WaitForBackendOff = "off"
+	// WaitForBackendDelay holds back a service's node until its backend
+	// accepts connections.
+	WaitForBackendDelay = "delay"
+	// WaitForBackendUnavailable starts a service's node straight away but
+	// answers with a 503 page until its backend accepts connections.
+	WaitForBackendUnavailable = "unavailable"
+)
+
+// Admin API address schemes and defaults.
+const (
+	// AdminUnixScheme prefixes an admin_addr that names a unix socket path.
+	AdminUnixScheme = "unix://"
+
+	// AdminTailnetScheme prefixes an admin_addr that serves the admin API from its own tailnet node.
+	AdminTailnetScheme = "tailnet://"
+
+	// DefaultAdminTailnetPort is the port the admin API listens on when served on the tailnet.
+	DefaultAdminTailnetPort = "80"
+
+	// DefaultAdminLogLines is the number of recent log lines the admin API keeps for `tsbridge logs`.
+	DefaultAdminLogLines = 1000
+
+	// DefaultAdminLogFollowBuffer is the number of log lines queued for each follower
+	// before new lines are dropped for that follower.
+	DefaultAdminLogFollowBuffer = 256
+
+	// AdminClientTimeout is the timeout for admin API requests made by the CLI.
+	// Following logs and events is not limited by it.
+	AdminClientTimeout = 60 * time.Second
+
+	// AdminClientHeader is sent by the CLI with every admin API request. Changes
+	// made through a loopback address must carry it, which browsers only allow
+	// after a CORS preflight the admin API never answers.
+	AdminClientHeader = "X-Tsbridge-Client"
+
+	// DefaultEventHistory is the number of recent lifecycle events kept for
+	// clients of the admin API's event stream.
+	DefaultEventHistory = 500
+
+	// AuditLogQueueSize is the number of events queued for the audit log's
+	// writer before recording another waits for it to catch up.
+	AuditLogQueueSize = 256
+
+	// DefaultEventFollowBuffer is the number of events queued for each subscriber
+	// before new events are dropped for that subscriber.
+	DefaultEventFollowBuffer = 256
+
+	// EventStreamKeepAlive is how often an idle event stream sends a comment so
+	// proxies and clients do not time it out.
+	EventStreamKeepAlive = 30 * time.Second
+)
+
+// Dashboard defaults.
+const (
+	// DashboardRecentRequests is the number of recent access log entries kept per service for the dashboard.
+	DashboardRecentRequests = 20
+
+	// DashboardSampleInterval is how often the dashboard samples request counters to compute rates.
+	DashboardSampleInterval = 10 * time.Second
+
+	// DashboardRateWindow is the period request and error rates are averaged over.
+	DashboardRateWindow = time.Minute
+
+	// DashboardRefreshInterval is how often the dashboard page reloads itself.
+	DashboardRefreshInterval = 10 * time.Second
+)
+
+// Reload modes.
+const (
+	// ReloadModeBestEffort applies every configuration change it can and keeps
+	// the ones that succeeded when others fail.
+	ReloadModeBestEffort = "best_effort"
+
+	// ReloadModeTransactional applies all configuration changes or none,
+	// restoring the previous services when any change fails.
+	ReloadModeTransactional = "transactional"
+)
+
+// Service supervisor states and retries.
+const (
+	// ServiceStatePending is a configured service that has not been started yet.
+	ServiceStatePending = "pending"
+
+	// ServiceStateWaiting is a service that is not started until the services
+	// it depends on are running and, with wait_for_backend, its backend
+	// accepts connections.
+	ServiceStateWaiting = "waiting"
+
+	// ServiceStateStarting is a service whose node and listener are being created.
+	ServiceStateStarting = "starting"
+
+	// ServiceStateRunning is a service that is serving requests.
+	ServiceStateRunning = "running"
+
+	// ServiceStateBackoff is a service that failed to start or stopped
+	// unexpectedly and is waiting to be started again.
+	ServiceStateBackoff = "backoff"
+
+	// ServiceStateFailed is a service that used up max_start_attempts and is no
+	// longer retried until it is restarted or changed by a reload.
+	ServiceStateFailed = "failed"
+
+	// ServiceRetryInitialInterval is the wait before a service is first started again.
+	ServiceRetryInitialInterval = time.Second
+
+	// ServiceRetryMaxInterval caps the wait between attempts to start a service.
+	ServiceRetryMaxInterval = 5 * time.Minute
+
+	// ServiceRetryRandomizationFactor spreads out retries of services that
+	// failed together, such as when the control server was unreachable.
+	ServiceRetryRandomizationFactor = 0.5
+
+	// ServiceWaitInterval is how often a waiting service checks whether it
+	// can be started, and a service waiting for its backend probes it.
+	ServiceWaitInterval = 2 * time.Second
+
+	// RegistryStallTimeout is how long the service registry may stay locked
+	// without a service changing before it counts as deadlocked. Services are
+	// stopped and started without holding the lock, so it is only held for
+	// bookkeeping and for swapping a handler in place.
+	RegistryStallTimeout = 30 * time.Second
+
+	// DefaultStartupConcurrency is how many services are started at once. It
+	// stays low enough not to run into Tailscale API rate limits when auth
+	// keys are generated with OAuth.
+	DefaultStartupConcurrency = 4
+)
+
+// On-demand services, whose containers are started by the first request and
+// stopped again when idle.
+const (
+	// DefaultOnDemandIdleTimeout is how long an on-demand service goes
+	// without requests before its container is stopped.
+	DefaultOnDemandIdleTimeout = 15 * time.Minute
+
+	// OnDemandStartTimeout bounds starting a container and waiting for its
+	// backend to accept connections.
+	OnDemandStartTimeout = 2 * time.Minute
+
+	// OnDemandStopTimeout bounds stopping an idle container.
+	OnDemandStopTimeout = 30 * time.Second
+)
+
+// Tracing protocols and defaults.
+const (
+	// TracingProtocolGRPC exports spans with OTLP over gRPC.
+	TracingProtocolGRPC = "grpc"
+
+	// TracingProtocolHTTP exports spans with OTLP over HTTP/protobuf.
+	TracingProtocolHTTP = "http"
+
+	// DefaultTracingSampleRatio is the fraction of new traces sampled when tracing_sample_ratio is not set.
+	DefaultTracingSampleRatio = 1.0
+
+	// TracerName identifies the spans tsbridge creates.
+	TracerName = "github.com/jtdowney/tsbridge"
+)
+
+// Log formats and defaults.
+const (
+	// LogFormatText writes logs as key=value pairs, the slog text format.
+	LogFormatText = "text"
+
+	// LogFormatJSON writes one JSON object per log record.
+	LogFormatJSON = "json"
+
+	// LogFormatLogfmt writes logfmt with a UTC ts key and lowercase levels, as expected by log shippers.
+	LogFormatLogfmt = "logfmt"
+
+	// DefaultLogMaxSize is the size a log file is rotated at when log_max_size is not set.
+	DefaultLogMaxSize = 100 * BytesPerMB
+
+	// DefaultLogMaxFiles is the number of rotated log files kept when log_max_files is not set.
+	DefaultLogMaxFiles = 5
+)
+
+// Access log formats and outputs.
+const (
+	// AccessLogFormatJSON writes one JSON object per request.
+	AccessLogFormatJSON = "json"
+
+	// AccessLogFormatCombined writes the Apache combined log format.
+	AccessLogFormatCombined = "combined"
+
+	// AccessLogFormatTemplate writes each request with the access_log_template text/template.
+	AccessLogFormatTemplate = "template"
+
+	// AccessLogOutputStdout writes the access log to standard output.
+	AccessLogOutputStdout = "stdout"
+
+	// AccessLogOutputStderr writes the access log to standard error.
+	AccessLogOutputStderr = "stderr"
+
+	// AccessLogOutputSyslog writes the access log to the local syslog daemon.
+	AccessLogOutputSyslog = "syslog"
+
+	// AccessLogSyslogTag is the tag access log messages are sent to syslog with.
+	AccessLogSyslogTag = "tsbridge"
+)
+
+// Webhook formats and delivery.
+const (
+	// WebhookFormatJSON posts each event as a JSON object.
+	WebhookFormatJSON = "json"
+
+	// WebhookFormatSlack posts a Slack-compatible {"text": ...} message.
+	WebhookFormatSlack = "slack"
+
+	// WebhookFormatNtfy posts a plain text message with ntfy title and tag headers.
+	WebhookFormatNtfy = "ntfy"
+
+	// WebhookSignatureHeader carries the HMAC-SHA256 of the timestamp and request body when a webhook secret is set.
+	WebhookSignatureHeader = "X-Tsbridge-Signature"
+
+	// WebhookTimestampHeader carries the Unix time in seconds a signed request was sent at.
+	WebhookTimestampHeader = "X-Tsbridge-Timestamp"
+
+	// DefaultWebhookTimeout bounds each delivery attempt when timeout is not set.
+	DefaultWebhookTimeout = 10 * time.Second
+
+	// DefaultWebhookMaxRetries is the number of retries after a failed delivery when max_retries is not set.
+	DefaultWebhookMaxRetries = 3
+
+	// WebhookInitialBackoff is the wait before the first retry; it doubles on each retry.
+	WebhookInitialBackoff = time.Second
+
+	// WebhookMaxBackoff caps the wait between retries.
+	WebhookMaxBackoff = 30 * time.Second
+
+	// WebhookQueueSize is the number of events queued for each webhook before new ones are dropped.
+	WebhookQueueSize = 64
+
+	// CertExpiryWarning is how long before a node's TLS certificate expires that
+	// a node.cert_expiring event is published. Certificates are normally renewed
+	// well before this, so reaching it means renewal is failing.
+	CertExpiryWarning = 14 * 24 * time.Hour
+)
+
 // Default size limits used in configuration.
 const (
 	// DefaultMaxRequestBodySize is the default maximum request body size (50 MB).
@@ -109,6 +358,13 @@ const (
 
 	// DefaultMetricsCollectionInterval is the default interval for collecting metrics.
 	DefaultMetricsCollectionInterval = 10 * time.Second
+
+	// NodeStatusInterval is how often the status of each service's tailnet node
+	// is checked for state changes and node metrics.
+	NodeStatusInterval = 30 * time.Second
+
+	// NodeStatusTimeout bounds each check of a node's status.
+	NodeStatusTimeout = 10 * time.Second
 )
 
 // Docker provider constants define timeouts and delays for Docker operations.
@@ -165,6 +421,18 @@ const (
 	// ServiceStopTimeout is the timeout for stopping a service gracefully.
 	ServiceStopTimeout = 5 * time.Second
 
+	// RetiredHandlerTimeout is how long a handler replaced by an in-place
+	// service update waits for its in-flight requests before it is closed.
+	RetiredHandlerTimeout = 5 * time.Minute
+
+	// RetiredHandlerPollInterval is how often a replaced handler checks for
+	// in-flight requests.
+	RetiredHandlerPollInterval = 100 * time.Millisecond
+
+	// DrainPollInterval is how often a stopping service checks whether its
+	// connections have closed.
+	DrainPollInterval = 50 * time.Millisecond
+
 	// TsnetServerStartTimeout is the delay before certificate priming.
 	TsnetServerStartTimeout = 5 * time.Second
 
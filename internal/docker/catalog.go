@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/jtdowney/tsbridge/internal/config"
+)
+
+// Label value types reported in the label catalogue
+const (
+	LabelTypeString   = "string"
+	LabelTypeBool     = "bool"
+	LabelTypeInt      = "int"
+	LabelTypeFloat    = "float"
+	LabelTypeDuration = "duration"
+	LabelTypeByteSize = "byte-size"
+	LabelTypeList     = "list"   // Comma-separated values
+	LabelTypeHeader   = "header" // One label per header, named after the header
+	LabelTypeMap      = "map"    // One label per map key, named after the key
+)
+
+// Containers a label can be set on
+const (
+	LabelContainerTsbridge = "tsbridge"
+	LabelContainerService  = "service"
+)
+
+// Label describes a single Docker label understood by the provider
+type Label struct {
+	Key         string `json:"key"`                   // Full label name, e.g. tsbridge.service.port
+	Container   string `json:"container"`             // Container the label belongs on: "tsbridge" or "service"
+	Type        string `json:"type"`                  // Value type, one of the LabelType constants
+	Description string `json:"description,omitempty"` // What the label configures
+}
+
+// labelDescriptions documents labels that have no matching config field
+var labelDescriptions = map[string]string{
+	"enabled":      "Set to true to expose the container through tsbridge",
+	"service.port": "Container port to proxy to when backend_addr is not set (default: the single exposed port)",
+}
+
+// LabelCatalog returns every label the provider understands for the given prefix,
+// sorted by key. It is built by running the label parsers against an empty label
+// set and recording the keys they read, so it cannot drift from the parsers.
+func LabelCatalog(prefix string) []Label {
+	if prefix == "" {
+		prefix = DefaultLabelPrefix
+	}
+
+	var labels []Label
+	add := func(containerName string, observed map[string]string) {
+		for key, labelType := range observed {
+			labels = append(labels, Label{
+				Key:         fmt.Sprintf("%s.%s", prefix, key),
+				Container:   containerName,
+				Type:        labelType,
+				Description: labelDescription(key),
+			})
+		}
+	}
+
+	// Labels on the tsbridge container, including one example profile and webhook
+	global := &labelParser{prefix: prefix, observed: make(map[string]string)}
+	parseGlobalLabels(global, &config.Config{})
+	parseServiceOptions(global, "profiles.<name>.", &config.Service{})
+	parseWebhookOptions(global, "webhooks.<name>.")
+	add(LabelContainerTsbridge, global.observed)
+
+	// Labels on service containers. The enabled label is matched by the
+	// container list filter rather than the parser.
+	service := &labelParser{
+		labels:   map[string]string{prefix + ".service.port": "80"},
+		prefix:   prefix,
+		observed: map[string]string{"enabled": LabelTypeBool},
+	}
+	p := &Provider{labelPrefix: prefix}
+	// The parse cannot fail with a port label and a container name present
+	_, _ = p.parseServiceLabels(service, container.Summary{Names: []string{"/catalog"}})
+	add(LabelContainerService, service.observed)
+
+	slices.SortFunc(labels, func(a, b Label) int {
+		return cmp.Or(cmp.Compare(a.Container, b.Container), cmp.Compare(a.Key, b.Key))
+	})
+	return labels
+}
+
+// labelDescription returns the description of a label key (without the prefix),
+// taken from the matching config field where there is one
+func labelDescription(key string) string {
+	if desc, ok := labelDescriptions[key]; ok {
+		return desc
+	}
+
+	key = strings.TrimSuffix(key, ".<header>")
+	key = strings.TrimSuffix(key, ".<key>")
+	section, field, _ := strings.Cut(key, ".")
+	switch section {
+	case "profiles":
+		// profiles.<name>.<field> takes the same values as service.<field>
+		_, field, _ = strings.Cut(field, ".")
+		section = "service"
+	case "webhooks":
+		_, field, _ = strings.Cut(field, ".")
+		section = "webhook"
+	}
+	return config.FieldDescription(section, field)
+}
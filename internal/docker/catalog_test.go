@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelCatalog(t *testing.T) {
+	catalog := LabelCatalog("")
+	require.NotEmpty(t, catalog)
+
+	byKey := make(map[string]Label, len(catalog))
+	for _, label := range catalog {
+		byKey[label.Key] = label
+		assert.NotEmpty(t, label.Description, "label %s has no description", label.Key)
+	}
+
+	t.Run("covers every config field", func(t *testing.T) {
+		sections := map[string]reflect.Type{
+			"tailscale":       reflect.TypeFor[config.Tailscale](),
+			"global":          reflect.TypeFor[config.Global](),
+			"service":         reflect.TypeFor[config.Service](),
+			"webhooks.<name>": reflect.TypeFor[config.Webhook](),
+		}
+		for section, typ := range sections {
+			for field := range typ.Fields() {
+				tag := field.Tag.Get("mapstructure")
+				if tag == "" {
+					continue
+				}
+				key := "tsbridge." + section + "." + tag
+				if strings.HasSuffix(tag, "_headers") {
+					key += ".<header>"
+				}
+				if tag == "log_levels" {
+					key += ".<key>"
+				}
+				_, ok := byKey[key]
+				assert.True(t, ok, "label catalogue is missing %s", key)
+			}
+		}
+	})
+
+	t.Run("types and containers", func(t *testing.T) {
+		tests := []struct {
+			key       string
+			container string
+			labelType string
+		}{
+			{"tsbridge.enabled", LabelContainerService, LabelTypeBool},
+			{"tsbridge.service.port", LabelContainerService, LabelTypeString},
+			{"tsbridge.service.write_timeout", LabelContainerService, LabelTypeDuration},
+			{"tsbridge.service.tags", LabelContainerService, LabelTypeList},
+			{"tsbridge.service.upstream_headers.<header>", LabelContainerService, LabelTypeHeader},
+			{"tsbridge.global.max_request_body_size", LabelContainerTsbridge, LabelTypeByteSize},
+			{"tsbridge.global.log_levels.<key>", LabelContainerTsbridge, LabelTypeMap},
+			{"tsbridge.tailscale.oauth_client_id", LabelContainerTsbridge, LabelTypeString},
+			{"tsbridge.profiles.<name>.whois_enabled", LabelContainerTsbridge, LabelTypeBool},
+			{"tsbridge.webhooks.<name>.url", LabelContainerTsbridge, LabelTypeString},
+			{"tsbridge.webhooks.<name>.events", LabelContainerTsbridge, LabelTypeList},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.key, func(t *testing.T) {
+				label, ok := byKey[tt.key]
+				require.True(t, ok)
+				assert.Equal(t, tt.container, label.Container)
+				assert.Equal(t, tt.labelType, label.Type)
+			})
+		}
+	})
+
+	t.Run("profiles cannot set name or profile", func(t *testing.T) {
+		assert.NotContains(t, byKey, "tsbridge.profiles.<name>.name")
+		assert.NotContains(t, byKey, "tsbridge.profiles.<name>.profile")
+	})
+
+	t.Run("custom prefix", func(t *testing.T) {
+		for _, label := range LabelCatalog("myapp") {
+			assert.True(t, strings.HasPrefix(label.Key, "myapp."), "label %s", label.Key)
+		}
+	})
+}
@@ -18,11 +18,20 @@ import (
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	"github.com/jtdowney/tsbridge/internal/errors"
+	tsevents "github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/logging"
 )
 
+// componentLogger returns the logger for the docker component
+func componentLogger() *slog.Logger {
+	return logging.Logger(logging.ComponentDocker)
+}
+
 // DockerClient defines the methods required from a Docker client to be used by the provider
 type DockerClient interface {
 	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
 	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
 	Ping(ctx context.Context) (types.Ping, error)
 	Close() error
@@ -44,10 +53,13 @@ type Provider struct {
 	pollInterval    time.Duration
 	mu              sync.RWMutex
 	lastConfig      *config.Config
+	containerIDs    map[string]string         // ID of the container of each service in lastConfig
+	profiles        map[string]config.Service // profiles of the last parsed tsbridge container
 	cachedTailscale *config.Tailscale
 	debounceTimer   *time.Timer
 	debounceMu      sync.Mutex
 	pollTicker      *time.Ticker
+	events          *tsevents.Bus
 }
 
 // Options contains configuration options for the Docker provider
@@ -123,7 +135,7 @@ func NewProvider(opts Options) (*Provider, error) {
 	}
 
 	// Validate Docker socket access before creating client
-	slog.Debug("validating Docker socket access", "endpoint", opts.DockerEndpoint)
+	componentLogger().Debug("validating Docker socket access", "endpoint", opts.DockerEndpoint)
 	if err := validateDockerAccess(opts.DockerEndpoint); err != nil {
 		return nil, err
 	}
@@ -145,11 +157,11 @@ func NewProvider(opts Options) (*Provider, error) {
 	if err != nil {
 		return nil, errors.WrapProviderError(err, "docker", errors.ErrTypeResource, "connecting to Docker")
 	}
-	slog.Debug("Docker connection verified",
+	componentLogger().Debug("Docker connection verified",
 		"api_version", pingInfo.APIVersion,
 		"os", pingInfo.OSType)
 
-	slog.Info("Docker provider initialized successfully",
+	componentLogger().Info("Docker provider initialized successfully",
 		"endpoint", opts.DockerEndpoint,
 		"label_prefix", opts.LabelPrefix,
 		"poll_interval", opts.PollInterval)
@@ -180,6 +192,7 @@ func (p *Provider) Load(ctx context.Context) (*config.Config, error) {
 	if err := p.parseGlobalConfig(selfContainer, cfg); err != nil {
 		return nil, errors.WrapProviderError(err, "docker", errors.ErrTypeConfig, "parsing global configuration")
 	}
+	p.profiles = cfg.Profiles
 
 	// Reuse cached Tailscale config on subsequent loads. The initial
 	// config resolution clears auth key env vars (TS_AUTHKEY) to prevent
@@ -195,9 +208,10 @@ func (p *Provider) Load(ctx context.Context) (*config.Config, error) {
 	if err != nil {
 		return nil, errors.WrapProviderError(err, "docker", errors.ErrTypeResource, "finding service containers")
 	}
-	slog.Debug("found service containers", "count", len(serviceContainers))
+	componentLogger().Debug("found service containers", "count", len(serviceContainers))
 
 	// Parse service configurations
+	containerIDs := make(map[string]string, len(serviceContainers))
 	for _, container := range serviceContainers {
 		containerName := ""
 		if len(container.Names) > 0 {
@@ -206,12 +220,13 @@ func (p *Provider) Load(ctx context.Context) (*config.Config, error) {
 
 		svc, err := p.parseServiceConfig(container)
 		if err != nil {
-			slog.Warn("failed to parse service configuration",
+			componentLogger().Warn("failed to parse service configuration",
 				"container", containerName,
 				"error", err)
 			continue
 		}
 		cfg.Services = append(cfg.Services, *svc)
+		containerIDs[svc.Name] = container.ID
 	}
 
 	// Apply standard configuration processing
@@ -226,11 +241,12 @@ func (p *Provider) Load(ctx context.Context) (*config.Config, error) {
 		p.cachedTailscale = &cached
 	}
 
-	slog.Info("Docker configuration loaded successfully",
+	componentLogger().Info("Docker configuration loaded successfully",
 		"services", len(cfg.Services),
 		"label_prefix", p.labelPrefix)
 
 	p.lastConfig = cfg
+	p.containerIDs = containerIDs
 	return cfg, nil
 }
 
@@ -239,7 +255,7 @@ func (p *Provider) Watch(ctx context.Context) (<-chan *config.Config, error) {
 	configCh := make(chan *config.Config)
 	eventOptions := p.createEventOptions()
 
-	slog.Info("starting Docker event watcher",
+	componentLogger().Info("starting Docker event watcher",
 		"label_prefix", p.labelPrefix,
 		"socket_path", p.socketPath,
 		"poll_interval", p.pollInterval)
@@ -266,7 +282,7 @@ func (p *Provider) pollLoop(ctx context.Context, configCh chan<- *config.Config)
 		case <-ctx.Done():
 			return
 		case <-p.pollTicker.C:
-			slog.Debug("periodic poll triggered")
+			componentLogger().Debug("periodic poll triggered")
 			p.immediateReload(ctx, configCh)
 		}
 	}
@@ -312,7 +328,7 @@ func (p *Provider) watchLoop(ctx context.Context, configCh chan<- *config.Config
 			}
 
 			// Event stream closed, wait before reconnecting with backoff
-			slog.Debug("Docker event stream closed, reconnecting...", "backoff", backoff)
+			componentLogger().Debug("Docker event stream closed, reconnecting...", "backoff", backoff)
 
 			select {
 			case <-ctx.Done():
@@ -342,7 +358,7 @@ func (p *Provider) processEventStream(ctx context.Context, configCh chan<- *conf
 			return true, streamEstablished
 		case err := <-errs:
 			if err != nil {
-				slog.Error("Docker events stream error", "error", err)
+				componentLogger().Error("Docker events stream error", "error", err)
 				return false, streamEstablished // Return to restart event stream
 			}
 		case event := <-events:
@@ -383,16 +399,28 @@ func (p *Provider) handleContainerEvent(ctx context.Context, configCh chan<- *co
 		containerID = containerID[:12]
 	}
 
-	slog.Debug("Docker container event received",
+	componentLogger().Debug("Docker container event received",
 		"action", event.Action,
 		"container_name", event.Actor.Attributes["name"],
 		"container_id", containerID)
 
+	p.events.Publish(tsevents.Event{
+		Type: tsevents.DockerContainer,
+		Data: map[string]any{
+			"action":         string(event.Action),
+			"container_name": event.Actor.Attributes["name"],
+			"container_id":   containerID,
+		},
+	})
+
+	// On-demand containers keep their service while stopped
+	onDemand := p.isOnDemand(event.Actor.Attributes, p.getProfiles())
+
 	// For critical events like stop/die, handle immediately to avoid race conditions
 	// For other events, use debounced reload to batch rapid changes
-	if event.Action == "stop" || event.Action == "die" {
+	if (event.Action == "stop" || event.Action == "die") && !onDemand {
 		// Handle stop/die events immediately to avoid Docker API race conditions
-		slog.Debug("Handling stop/die event immediately (no debouncing)",
+		componentLogger().Debug("Handling stop/die event immediately (no debouncing)",
 			"action", event.Action,
 			"container_name", event.Actor.Attributes["name"])
 
@@ -402,7 +430,7 @@ func (p *Provider) handleContainerEvent(ctx context.Context, configCh chan<- *co
 		// Load new configuration when container event occurs
 		newConfig, err := p.Load(ctx)
 		if err != nil {
-			slog.Error("failed to reload configuration after Docker event", "error", err)
+			componentLogger().Error("failed to reload configuration after Docker event", "error", err)
 			return false
 		}
 
@@ -416,7 +444,7 @@ func (p *Provider) handleContainerEvent(ctx context.Context, configCh chan<- *co
 
 		// Check if configuration has changed
 		if !p.configEqual(oldConfig, newConfig) {
-			slog.Info("Docker configuration changed due to container event",
+			componentLogger().Info("Docker configuration changed due to container event",
 				"action", event.Action,
 				"container_name", event.Actor.Attributes["name"])
 
@@ -432,7 +460,7 @@ func (p *Provider) handleContainerEvent(ctx context.Context, configCh chan<- *co
 		}
 	} else {
 		// For start and other events, use debounced reload
-		slog.Debug("Triggering debounced configuration reload due to container event",
+		componentLogger().Debug("Triggering debounced configuration reload due to container event",
 			"action", event.Action,
 			"container_name", event.Actor.Attributes["name"])
 
@@ -450,6 +478,12 @@ func (p *Provider) isContainerEnabled(labels map[string]string) bool {
 	return labels[enabledLabel] == "true" || labels[enableLabel] == "true"
 }
 
+// SetEventBus makes the provider publish the events of tsbridge-enabled
+// containers to bus. It must be called before Watch.
+func (p *Provider) SetEventBus(bus *tsevents.Bus) {
+	p.events = bus
+}
+
 // Name returns the provider name
 func (p *Provider) Name() string {
 	return "docker"
@@ -470,13 +504,13 @@ func (p *Provider) immediateReload(ctx context.Context, configCh chan<- *config.
 	// Load new config
 	newConfig, err := p.Load(ctx)
 	if err != nil {
-		slog.Error("failed to reload configuration", "error", err)
+		componentLogger().Error("failed to reload configuration", "error", err)
 		return
 	}
 
 	// Skip if config unchanged
 	if oldConfig != nil && p.configEqual(oldConfig, newConfig) {
-		slog.Debug("config unchanged, skipping reload")
+		componentLogger().Debug("config unchanged, skipping reload")
 		return
 	}
 
@@ -490,7 +524,7 @@ func (p *Provider) immediateReload(ctx context.Context, configCh chan<- *config.
 		return
 	default:
 		// Channel might be closed, just log and return
-		slog.Debug("could not send config - channel closed or full")
+		componentLogger().Debug("could not send config - channel closed or full")
 	}
 }
 
@@ -530,6 +564,43 @@ func (p *Provider) Close() error {
 	return nil
 }
 
+// StartContainer starts the container of the on-demand service named service.
+// Starting a container that is already running does nothing.
+func (p *Provider) StartContainer(ctx context.Context, service string) error {
+	id, err := p.containerID(service)
+	if err != nil {
+		return err
+	}
+	if err := p.client.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		return errors.WrapProviderError(err, "docker", errors.ErrTypeResource, fmt.Sprintf("starting container of service %q", service))
+	}
+	return nil
+}
+
+// StopContainer stops the container of the on-demand service named service.
+// Stopping a container that is not running does nothing.
+func (p *Provider) StopContainer(ctx context.Context, service string) error {
+	id, err := p.containerID(service)
+	if err != nil {
+		return err
+	}
+	if err := p.client.ContainerStop(ctx, id, container.StopOptions{}); err != nil {
+		return errors.WrapProviderError(err, "docker", errors.ErrTypeResource, fmt.Sprintf("stopping container of service %q", service))
+	}
+	return nil
+}
+
+// containerID returns the ID of the container of service as of the last load
+func (p *Provider) containerID(service string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	id, ok := p.containerIDs[service]
+	if !ok {
+		return "", errors.NewProviderError("docker", errors.ErrTypeResource, fmt.Sprintf("no container found for service %q", service))
+	}
+	return id, nil
+}
+
 // getLastConfig returns the last configuration in a thread-safe manner
 func (p *Provider) getLastConfig() *config.Config {
 	p.mu.RLock()
@@ -537,21 +608,28 @@ func (p *Provider) getLastConfig() *config.Config {
 	return p.lastConfig
 }
 
+// getProfiles returns the profiles parsed by the last Load
+func (p *Provider) getProfiles() map[string]config.Service {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.profiles
+}
+
 // findSelfContainer finds the tsbridge container itself
 func (p *Provider) findSelfContainer(ctx context.Context) (*container.Summary, error) {
 	// First try to find by hostname (which is the container ID in Docker)
 	hostname, err := p.getHostname()
 	if err == nil {
-		slog.Debug("checking for self container by hostname", "hostname", hostname)
+		componentLogger().Debug("checking for self container by hostname", "hostname", hostname)
 		container, err := p.getContainerByID(ctx, hostname)
 		if err == nil {
-			slog.Debug("found self container by hostname", "container", container.ID)
+			componentLogger().Debug("found self container by hostname", "container", container.ID)
 			return container, nil
 		}
 
-		slog.Debug("failed to find self container by hostname", "error", err)
+		componentLogger().Debug("failed to find self container by hostname", "error", err)
 	} else {
-		slog.Debug("failed to get hostname", "error", err)
+		componentLogger().Debug("failed to get hostname", "error", err)
 	}
 
 	// Fallback: find container with tsbridge labels
@@ -574,7 +652,8 @@ func (p *Provider) findSelfContainer(ctx context.Context) (*container.Summary, e
 	return &containers[0], nil
 }
 
-// findServiceContainers finds all containers with tsbridge.enabled=true or tsbridge.enable=true
+// findServiceContainers finds all running containers with tsbridge.enabled=true
+// or tsbridge.enable=true, and stopped ones whose service is on demand
 func (p *Provider) findServiceContainers(ctx context.Context) ([]container.Summary, error) {
 	// Query for containers with enabled=true
 	enabledLabel := fmt.Sprintf("%s.enabled=true", p.labelPrefix)
@@ -604,6 +683,25 @@ func (p *Provider) findServiceContainers(ctx context.Context) ([]container.Summa
 		return nil, err
 	}
 
+	// Stopped on-demand containers keep their service, so they are started
+	// by the first request
+	var onDemandContainers []container.Summary
+	for _, label := range []string{enabledLabel, enableLabel} {
+		opts := container.ListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("label", label)),
+		}
+		containers, err := p.client.ContainerList(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if p.isOnDemand(c.Labels, p.profiles) {
+				onDemandContainers = append(onDemandContainers, c)
+			}
+		}
+	}
+
 	// Merge results and remove duplicates
 	containerMap := make(map[string]container.Summary)
 	for _, c := range enabledContainers {
@@ -612,6 +710,9 @@ func (p *Provider) findServiceContainers(ctx context.Context) ([]container.Summa
 	for _, c := range enableContainers {
 		containerMap[c.ID] = c
 	}
+	for _, c := range onDemandContainers {
+		containerMap[c.ID] = c
+	}
 
 	// Convert map back to slice
 	var serviceContainers []container.Summary
@@ -622,6 +723,18 @@ func (p *Provider) findServiceContainers(ctx context.Context) ([]container.Summa
 	return serviceContainers, nil
 }
 
+// isOnDemand returns whether a container with labels runs an on-demand
+// service, reading tsbridge.service.on_demand or, when it is not set, the
+// on_demand setting of the service's profile the same way as expandProfiles
+func (p *Provider) isOnDemand(labels map[string]string, profiles map[string]config.Service) bool {
+	onDemand, _ := parseBool(labels[fmt.Sprintf("%s.service.on_demand", p.labelPrefix)])
+	if onDemand == nil {
+		profile := profiles[labels[fmt.Sprintf("%s.service.profile", p.labelPrefix)]]
+		onDemand = profile.OnDemand
+	}
+	return onDemand != nil && *onDemand
+}
+
 // getContainerByID gets a container by ID
 func (p *Provider) getContainerByID(ctx context.Context, id string) (*container.Summary, error) {
 	// List all containers since Docker's ID filter might not work with partial IDs
@@ -634,7 +747,7 @@ func (p *Provider) getContainerByID(ctx context.Context, id string) (*container.
 		return nil, err
 	}
 
-	slog.Debug("searching for container by ID",
+	componentLogger().Debug("searching for container by ID",
 		"target_id", id,
 		"total_containers", len(containers))
 
@@ -645,7 +758,7 @@ func (p *Provider) getContainerByID(ctx context.Context, id string) (*container.
 		if len(shortID) > 12 {
 			shortID = shortID[:12]
 		}
-		slog.Debug("checking container",
+		componentLogger().Debug("checking container",
 			"container_id", shortID,
 			"container_names", c.Names,
 			"matches", strings.HasPrefix(c.ID, id))
@@ -660,7 +773,7 @@ func (p *Provider) getContainerByID(ctx context.Context, id string) (*container.
 		for _, name := range c.Names {
 			// Docker container names are prefixed with '/'
 			if strings.TrimPrefix(name, "/") == id {
-				slog.Debug("found container by name", "container", c.ID, "name", name)
+				componentLogger().Debug("found container by name", "container", c.ID, "name", name)
 				return &c, nil
 			}
 		}
@@ -691,6 +804,8 @@ func (p *Provider) removeServiceByContainerName(cfg *config.Config, containerNam
 	newCfg := &config.Config{
 		Tailscale: cfg.Tailscale,
 		Global:    cfg.Global,
+		Profiles:  cfg.Profiles,
+		Webhooks:  cfg.Webhooks,
 		Services:  make([]config.Service, 0, len(cfg.Services)),
 	}
 
@@ -712,7 +827,7 @@ func (p *Provider) removeServiceByContainerName(cfg *config.Config, containerNam
 		// Check for exact match only to prevent false positives
 		// Backend addresses should exactly match the container name (excluding port)
 		if hostname == containerName {
-			slog.Debug("removing service from stopped container",
+			componentLogger().Debug("removing service from stopped container",
 				"service", svc.Name,
 				"container", containerName,
 				"backend", svc.BackendAddr,
@@ -725,7 +840,7 @@ func (p *Provider) removeServiceByContainerName(cfg *config.Config, containerNam
 	}
 
 	if removed {
-		slog.Info("removed service associated with stopped container",
+		componentLogger().Info("removed service associated with stopped container",
 			"container", containerName,
 			"remaining_services", len(newCfg.Services))
 	}
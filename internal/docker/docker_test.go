@@ -16,6 +16,7 @@ import (
 	"github.com/docker/docker/api/types/events"
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/errors"
+	tsevents "github.com/jtdowney/tsbridge/internal/events"
 	"github.com/jtdowney/tsbridge/internal/testhelpers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -265,6 +266,60 @@ func TestParseGlobalConfig(t *testing.T) {
 		assert.Equal(t, "/var/lib/tsbridge", cfg.Tailscale.StateDir)
 		assert.Equal(t, ":9090", cfg.Global.MetricsAddr)
 	})
+
+	t.Run("logging", func(t *testing.T) {
+		container := &container.Summary{
+			Labels: map[string]string{
+				"tsbridge.global.log_format":       "json",
+				"tsbridge.global.log_level":        "warn",
+				"tsbridge.global.log_levels.tsnet": "error",
+				"tsbridge.global.log_levels.proxy": "debug",
+				"tsbridge.global.log_file":         "/var/log/tsbridge.log",
+				"tsbridge.global.log_max_size":     "10MB",
+				"tsbridge.global.log_max_files":    "3",
+			},
+		}
+
+		cfg := &config.Config{}
+		require.NoError(t, provider.parseGlobalConfig(container, cfg))
+
+		assert.Equal(t, "json", cfg.Global.LogFormat)
+		assert.Equal(t, "warn", cfg.Global.LogLevel)
+		assert.Equal(t, map[string]string{"tsnet": "error", "proxy": "debug"}, cfg.Global.LogLevels)
+		assert.Equal(t, "/var/log/tsbridge.log", cfg.Global.LogFile)
+		require.NotNil(t, cfg.Global.LogMaxSize)
+		assert.Equal(t, int64(10*1024*1024), *cfg.Global.LogMaxSize)
+		require.NotNil(t, cfg.Global.LogMaxFiles)
+		assert.Equal(t, 3, *cfg.Global.LogMaxFiles)
+	})
+
+	t.Run("access log", func(t *testing.T) {
+		container := &container.Summary{
+			Labels: map[string]string{
+				"tsbridge.global.access_log_output":       "/var/log/access.log",
+				"tsbridge.global.access_log_format":       "template",
+				"tsbridge.global.access_log_template":     "{{.Method}} {{.Path}} {{.Status}}",
+				"tsbridge.global.access_log_max_size":     "50MB",
+				"tsbridge.global.access_log_max_files":    "2",
+				"tsbridge.global.access_log_status":       "4xx, 5xx",
+				"tsbridge.global.access_log_sample_ratio": "0.25",
+			},
+		}
+
+		cfg := &config.Config{}
+		require.NoError(t, provider.parseGlobalConfig(container, cfg))
+
+		assert.Equal(t, "/var/log/access.log", cfg.Global.AccessLogOutput)
+		assert.Equal(t, "template", cfg.Global.AccessLogFormat)
+		assert.Equal(t, "{{.Method}} {{.Path}} {{.Status}}", cfg.Global.AccessLogTemplate)
+		require.NotNil(t, cfg.Global.AccessLogMaxSize)
+		assert.Equal(t, int64(50*1024*1024), *cfg.Global.AccessLogMaxSize)
+		require.NotNil(t, cfg.Global.AccessLogMaxFiles)
+		assert.Equal(t, 2, *cfg.Global.AccessLogMaxFiles)
+		assert.Equal(t, []string{"4xx", "5xx"}, cfg.Global.AccessLogStatus)
+		require.NotNil(t, cfg.Global.AccessLogSampleRatio)
+		assert.Equal(t, 0.25, *cfg.Global.AccessLogSampleRatio)
+	})
 }
 
 func TestConfigEqual(t *testing.T) {
@@ -953,6 +1008,14 @@ func (t *testEventStreamClient) ContainerList(ctx context.Context, options conta
 	}, nil
 }
 
+func (t *testEventStreamClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	return nil
+}
+
+func (t *testEventStreamClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	return nil
+}
+
 func (t *testEventStreamClient) Ping(ctx context.Context) (types.Ping, error) {
 	return types.Ping{}, nil
 }
@@ -986,6 +1049,9 @@ type mockDockerClient struct {
 	mu               sync.Mutex
 	eventsStarted    bool
 	eventsCloseCount int
+	started          []string // IDs of the containers started, in order
+	stopped          []string // IDs of the containers stopped, in order
+	startError       error
 }
 
 func newMockDockerClient() *mockDockerClient {
@@ -1078,6 +1144,23 @@ func (m *mockDockerClient) Events(ctx context.Context, options events.ListOption
 	return eventsChan, errsChan
 }
 
+func (m *mockDockerClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	if m.startError != nil {
+		return m.startError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = append(m.started, containerID)
+	return nil
+}
+
+func (m *mockDockerClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = append(m.stopped, containerID)
+	return nil
+}
+
 func (m *mockDockerClient) Ping(ctx context.Context) (types.Ping, error) {
 	if m.pingError != nil {
 		return types.Ping{}, m.pingError
@@ -2309,6 +2392,14 @@ func (m *MockFailingDockerClient) Events(ctx context.Context, options events.Lis
 	return eventCh, errCh
 }
 
+func (m *MockFailingDockerClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	return fmt.Errorf("simulated Docker connection failure")
+}
+
+func (m *MockFailingDockerClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	return fmt.Errorf("simulated Docker connection failure")
+}
+
 func (m *MockFailingDockerClient) Ping(ctx context.Context) (types.Ping, error) {
 	return types.Ping{}, fmt.Errorf("simulated Docker connection failure")
 }
@@ -2607,6 +2698,14 @@ func (m *MockPollDockerClient) Events(ctx context.Context, options events.ListOp
 	return eventCh, errCh
 }
 
+func (m *MockPollDockerClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	return nil
+}
+
+func (m *MockPollDockerClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	return nil
+}
+
 func (m *MockPollDockerClient) Ping(ctx context.Context) (types.Ping, error) {
 	return types.Ping{}, nil
 }
@@ -2733,3 +2832,226 @@ func TestCloseStopsPollTicker(t *testing.T) {
 	// (calling Stop on an already stopped ticker is safe)
 	p.pollTicker.Stop()
 }
+
+func TestHandleContainerEventPublishesEvents(t *testing.T) {
+	mockClient := newMockDockerClient()
+	mockClient.containers = []container.Summary{createTsbridgeContainer("tsbridge123")}
+
+	bus := tsevents.NewBus(10)
+	provider := &Provider{
+		client:      mockClient,
+		labelPrefix: "tsbridge",
+	}
+	provider.SetEventBus(bus)
+
+	configCh := make(chan *config.Config, 1)
+	for _, event := range []events.Message{
+		{
+			Type:   "container",
+			Action: "die",
+			Actor: events.Actor{
+				ID:         "0123456789abcdef",
+				Attributes: map[string]string{"name": "api", "tsbridge.enabled": "true"},
+			},
+		},
+		{
+			Type:   "container",
+			Action: "die",
+			Actor: events.Actor{
+				ID:         "fedcba9876543210",
+				Attributes: map[string]string{"name": "other"},
+			},
+		},
+	} {
+		provider.handleContainerEvent(t.Context(), configCh, event)
+	}
+
+	recorded := bus.Recent(-1)
+	require.Len(t, recorded, 1, "only tsbridge-enabled containers are published")
+	assert.Equal(t, tsevents.DockerContainer, recorded[0].Type)
+	assert.Equal(t, map[string]any{
+		"action":         "die",
+		"container_name": "api",
+		"container_id":   "0123456789ab",
+	}, recorded[0].Data)
+}
+
+func TestProvider_OnDemand(t *testing.T) {
+	onDemandContainer := func(id, name, onDemand, state string) container.Summary {
+		c := createTestContainer(id, name, map[string]string{
+			"tsbridge.enabled":           "true",
+			"tsbridge.service.port":      "8080",
+			"tsbridge.service.on_demand": onDemand,
+		})
+		c.State = state
+		return c
+	}
+	newOnDemandProvider := func(t *testing.T) (*Provider, *mockDockerClient) {
+		mockClient := newMockDockerClient()
+		mockClient.containers = []container.Summary{
+			createTsbridgeContainer("tsbridge123"),
+			onDemandContainer("wiki123", "wiki", "true", "exited"),
+			createServiceContainer("api123", "api", "api:8080"),
+			onDemandContainer("old123", "old", "false", "exited"),
+			// Any value strconv.ParseBool accepts turns on_demand on
+			onDemandContainer("docs123", "docs", "1", "exited"),
+		}
+		provider := &Provider{client: mockClient, labelPrefix: "tsbridge"}
+		t.Cleanup(func() { _ = provider.Close() })
+		_, err := provider.Load(t.Context())
+		require.NoError(t, err)
+		return provider, mockClient
+	}
+
+	t.Run("stopped containers keep their service", func(t *testing.T) {
+		provider, _ := newOnDemandProvider(t)
+		cfg := provider.getLastConfig()
+		names := make([]string, 0, len(cfg.Services))
+		for _, svc := range cfg.Services {
+			names = append(names, svc.Name)
+		}
+		assert.ElementsMatch(t, []string{"wiki", "api", "docs"}, names)
+	})
+
+	t.Run("starts and stops containers by service", func(t *testing.T) {
+		provider, mockClient := newOnDemandProvider(t)
+		require.NoError(t, provider.StartContainer(t.Context(), "wiki"))
+		require.NoError(t, provider.StopContainer(t.Context(), "wiki"))
+		assert.Equal(t, []string{"wiki123"}, mockClient.started)
+		assert.Equal(t, []string{"wiki123"}, mockClient.stopped)
+
+		err := provider.StartContainer(t.Context(), "missing")
+		assert.ErrorContains(t, err, `no container found for service "missing"`)
+	})
+
+	t.Run("start errors are wrapped", func(t *testing.T) {
+		provider, mockClient := newOnDemandProvider(t)
+		mockClient.startError = fmt.Errorf("no such container")
+		err := provider.StartContainer(t.Context(), "wiki")
+		assert.ErrorContains(t, err, `starting container of service "wiki"`)
+		assert.ErrorContains(t, err, "no such container")
+	})
+
+	t.Run("stop event keeps the service", func(t *testing.T) {
+		provider, _ := newOnDemandProvider(t)
+		configCh := make(chan *config.Config, 1)
+		provider.handleContainerEvent(t.Context(), configCh, events.Message{
+			Type:   "container",
+			Action: "die",
+			Actor: events.Actor{
+				ID: "wiki123",
+				Attributes: map[string]string{
+					"name":                       "wiki",
+					"tsbridge.enabled":           "true",
+					"tsbridge.service.on_demand": "True",
+				},
+			},
+		})
+		select {
+		case cfg := <-configCh:
+			t.Fatalf("configuration changed after an on-demand container stopped: %d services", len(cfg.Services))
+		default:
+		}
+		assert.Len(t, provider.getLastConfig().Services, 3)
+	})
+
+	t.Run("requires a port label", func(t *testing.T) {
+		p := &Provider{labelPrefix: "tsbridge"}
+		c := createTestContainer("wiki123", "wiki", map[string]string{
+			"tsbridge.enabled":           "true",
+			"tsbridge.service.on_demand": "true",
+		})
+		c.Ports = []container.Port{{PrivatePort: 8080}}
+		_, err := p.parseServiceConfig(c)
+		assert.ErrorContains(t, err, "on_demand requires tsbridge.service.port or tsbridge.service.backend_addr")
+	})
+
+	t.Run("profile turns on_demand on", func(t *testing.T) {
+		self := createTsbridgeContainer("tsbridge123")
+		self.Labels["tsbridge.profiles.lazy.on_demand"] = "true"
+		self.Labels["tsbridge.profiles.lazy.on_demand_idle_timeout"] = "1m"
+		lazy := createTestContainer("lazy123", "lazy", map[string]string{
+			"tsbridge.enabled":         "true",
+			"tsbridge.service.port":    "8080",
+			"tsbridge.service.profile": "lazy",
+		})
+		lazy.State = "exited"
+		optedOut := createTestContainer("eager123", "eager", map[string]string{
+			"tsbridge.enabled":           "true",
+			"tsbridge.service.port":      "8080",
+			"tsbridge.service.profile":   "lazy",
+			"tsbridge.service.on_demand": "false",
+		})
+		optedOut.State = "exited"
+		noPort := createTestContainer("noport123", "noport", map[string]string{
+			"tsbridge.enabled":         "true",
+			"tsbridge.service.profile": "lazy",
+		})
+		noPort.Ports = []container.Port{{PrivatePort: 8080}}
+
+		mockClient := newMockDockerClient()
+		mockClient.containers = []container.Summary{self, lazy, optedOut, noPort}
+		provider := &Provider{client: mockClient, labelPrefix: "tsbridge"}
+		t.Cleanup(func() { _ = provider.Close() })
+		cfg, err := provider.Load(t.Context())
+		require.NoError(t, err)
+		require.Len(t, cfg.Services, 1, "only the stopped container whose profile is on demand is served")
+		assert.Equal(t, "lazy", cfg.Services[0].Name)
+		require.NotNil(t, cfg.Services[0].OnDemand)
+		assert.True(t, *cfg.Services[0].OnDemand)
+
+		// The idle timeout stopping the container keeps its service
+		configCh := make(chan *config.Config, 1)
+		provider.handleContainerEvent(t.Context(), configCh, events.Message{
+			Type:   "container",
+			Action: "die",
+			Actor: events.Actor{
+				ID: "lazy123",
+				Attributes: map[string]string{
+					"name":                     "lazy",
+					"tsbridge.enabled":         "true",
+					"tsbridge.service.port":    "8080",
+					"tsbridge.service.profile": "lazy",
+				},
+			},
+		})
+		select {
+		case cfg := <-configCh:
+			t.Fatalf("configuration changed after an on-demand container stopped: %d services", len(cfg.Services))
+		default:
+		}
+		assert.Len(t, provider.getLastConfig().Services, 1)
+	})
+}
+
+func TestProvider_LabelInterpolation(t *testing.T) {
+	t.Setenv("TSBRIDGE_TEST_SECRET", "super-secret")
+	t.Setenv("TSBRIDGE_TEST_CONTROL", "https://headscale.example.com")
+
+	self := createTsbridgeContainer("tsbridge123")
+	self.Labels["tsbridge.tailscale.control_url"] = "${ENV:TSBRIDGE_TEST_CONTROL}"
+	mockClient := newMockDockerClient()
+	mockClient.containers = []container.Summary{
+		self,
+		createTestContainer("api123", "api", map[string]string{
+			"tsbridge.enabled":                             "true",
+			"tsbridge.service.port":                        "8080",
+			"tsbridge.service.upstream_headers.X-Leak":     "${ENV:TSBRIDGE_TEST_SECRET}",
+			"tsbridge.service.upstream_headers.X-Leak-Too": "${FILE:/etc/hostname}",
+		}),
+	}
+	provider := &Provider{client: mockClient, labelPrefix: "tsbridge"}
+	t.Cleanup(func() { _ = provider.Close() })
+
+	cfg, err := provider.Load(t.Context())
+	require.NoError(t, err)
+
+	// The tsbridge container's own labels are expanded
+	assert.Equal(t, "https://headscale.example.com", cfg.Tailscale.ControlURL)
+
+	// Labels of service containers are used as written, so they cannot read
+	// tsbridge's environment or files
+	require.Len(t, cfg.Services, 1)
+	assert.Equal(t, "${ENV:TSBRIDGE_TEST_SECRET}", cfg.Services[0].UpstreamHeaders["X-Leak"])
+	assert.Equal(t, "${FILE:/etc/hostname}", cfg.Services[0].UpstreamHeaders["X-Leak-Too"])
+}
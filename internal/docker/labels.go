@@ -2,7 +2,6 @@ package docker
 
 import (
 	"fmt"
-	"log/slog"
 	"regexp"
 	"strconv"
 	"strings"
@@ -41,6 +40,10 @@ func isValidHeaderValue(value string) bool {
 type labelParser struct {
 	labels map[string]string
 	prefix string
+
+	// observed, when set, records the type of every key the parser is asked for.
+	// LabelCatalog uses it to list the labels understood by the parse functions.
+	observed map[string]string
 }
 
 // newLabelParser creates a new label parser
@@ -51,42 +54,67 @@ func newLabelParser(labels map[string]string, prefix string) *labelParser {
 	}
 }
 
-// getString gets a string value from labels
-func (p *labelParser) getString(key string) string {
+// observe records that key was read as the given label type
+func (p *labelParser) observe(key, labelType string) {
+	if p.observed != nil {
+		p.observed[key] = labelType
+	}
+}
+
+// lookup returns the raw value of a label
+func (p *labelParser) lookup(key string) string {
 	fullKey := fmt.Sprintf("%s.%s", p.prefix, key)
 	return p.labels[fullKey]
 }
 
+// getString gets a string value from labels
+func (p *labelParser) getString(key string) string {
+	p.observe(key, LabelTypeString)
+	return p.lookup(key)
+}
+
 // getBool gets a boolean pointer from labels
 func (p *labelParser) getBool(key string) *bool {
-	value := p.getString(key)
+	p.observe(key, LabelTypeBool)
+	value := p.lookup(key)
 	result, _ := parseBool(value)
 	return result
 }
 
 // getInt gets an integer pointer from labels
 func (p *labelParser) getInt(key string) *int {
-	value := p.getString(key)
+	p.observe(key, LabelTypeInt)
+	value := p.lookup(key)
 	result, _ := parseInt(value)
 	return result
 }
 
+// getFloat gets a float pointer from labels
+func (p *labelParser) getFloat(key string) *float64 {
+	p.observe(key, LabelTypeFloat)
+	value := p.lookup(key)
+	result, _ := parseFloat(value)
+	return result
+}
+
 // getDuration gets a duration from labels
 func (p *labelParser) getDuration(key string) *time.Duration {
-	value := p.getString(key)
+	p.observe(key, LabelTypeDuration)
+	value := p.lookup(key)
 	result, _ := parseDuration(value)
 	return result
 }
 
 // getByteSize gets a ByteSize pointer from labels
 func (p *labelParser) getByteSize(key string) *int64 {
-	value := p.getString(key)
+	p.observe(key, LabelTypeByteSize)
+	value := p.lookup(key)
 	if value == "" {
 		return nil
 	}
 	result, err := parseByteSize(value)
 	if err != nil {
-		slog.Warn("failed to parse ByteSize from Docker label",
+		componentLogger().Warn("failed to parse ByteSize from Docker label",
 			"key", key,
 			"value", value,
 			"error", err)
@@ -97,12 +125,14 @@ func (p *labelParser) getByteSize(key string) *int64 {
 
 // getStringSlice gets a string slice from labels
 func (p *labelParser) getStringSlice(key, separator string) []string {
-	value := p.getString(key)
+	p.observe(key, LabelTypeList)
+	value := p.lookup(key)
 	return parseStringSlice(value, separator)
 }
 
 // getHeaders parses header configuration from labels with security validation
 func (p *labelParser) getHeaders(key string) map[string]string {
+	p.observe(key+".<header>", LabelTypeHeader)
 	headers := make(map[string]string)
 	fullPrefix := fmt.Sprintf("%s.%s.", p.prefix, key)
 
@@ -112,7 +142,7 @@ func (p *labelParser) getHeaders(key string) map[string]string {
 
 			// Validate header name to prevent injection attacks
 			if !isValidHeaderName(headerName) {
-				slog.Warn("rejecting invalid header name from Docker label",
+				componentLogger().Warn("rejecting invalid header name from Docker label",
 					"header", headerName,
 					"label", label,
 					"reason", "invalid characters in header name")
@@ -121,7 +151,7 @@ func (p *labelParser) getHeaders(key string) map[string]string {
 
 			// Validate header value to prevent CRLF injection
 			if !isValidHeaderValue(value) {
-				slog.Warn("rejecting header with invalid value from Docker label",
+				componentLogger().Warn("rejecting header with invalid value from Docker label",
 					"header", headerName,
 					"label", label,
 					"reason", "control characters in header value")
@@ -138,10 +168,33 @@ func (p *labelParser) getHeaders(key string) map[string]string {
 	return headers
 }
 
+// getStringMap gets the values of the labels under key, one label per map key,
+// e.g. global.log_levels.tsnet=error
+func (p *labelParser) getStringMap(key string) map[string]string {
+	p.observe(key+".<key>", LabelTypeMap)
+	values := make(map[string]string)
+	fullPrefix := fmt.Sprintf("%s.%s.", p.prefix, key)
+
+	for label, value := range p.labels {
+		if mapKey, ok := strings.CutPrefix(label, fullPrefix); ok && mapKey != "" {
+			values[mapKey] = value
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
 // parseGlobalConfig parses global configuration from container labels
 func (p *Provider) parseGlobalConfig(container *container.Summary, cfg *config.Config) error {
-	parser := newLabelParser(container.Labels, p.labelPrefix)
+	parseGlobalLabels(newLabelParser(container.Labels, p.labelPrefix), cfg)
+	return nil
+}
 
+// parseGlobalLabels fills the Tailscale, Global and Profiles sections of cfg from parser
+func parseGlobalLabels(parser *labelParser, cfg *config.Config) {
 	// Parse Tailscale configuration
 	cfg.Tailscale = config.Tailscale{
 		OAuthClientID:         parser.getString("tailscale.oauth_client_id"),
@@ -163,10 +216,14 @@ func (p *Provider) parseGlobalConfig(container *container.Summary, cfg *config.C
 	// Parse global configuration
 	cfg.Global = config.Global{
 		MetricsAddr:              parser.getString("global.metrics_addr"),
+		ReadyMinServices:         parser.getInt("global.ready_min_services"),
+		MaxStartAttempts:         parser.getInt("global.max_start_attempts"),
+		StartupConcurrency:       parser.getInt("global.startup_concurrency"),
 		ReadHeaderTimeout:        parser.getDuration("global.read_header_timeout"),
 		WriteTimeout:             parser.getDuration("global.write_timeout"),
 		IdleTimeout:              parser.getDuration("global.idle_timeout"),
 		ShutdownTimeout:          parser.getDuration("global.shutdown_timeout"),
+		DrainTimeout:             parser.getDuration("global.drain_timeout"),
 		StartupTimeout:           parser.getDuration("global.startup_timeout"),
 		ResponseHeaderTimeout:    parser.getDuration("global.response_header_timeout"),
 		AccessLog:                parser.getBool("global.access_log"),
@@ -178,6 +235,28 @@ func (p *Provider) parseGlobalConfig(container *container.Summary, cfg *config.C
 		ExpectContinueTimeout:    parser.getDuration("global.expect_continue_timeout"),
 		MetricsReadHeaderTimeout: parser.getDuration("global.metrics_read_header_timeout"),
 		FlushInterval:            parser.getDuration("global.flush_interval"),
+		AdminAddr:                parser.getString("global.admin_addr"),
+		AdminAllowed:             parser.getStringSlice("global.admin_allowed", ","),
+		AuditLog:                 parser.getString("global.audit_log"),
+		ReloadMode:               parser.getString("global.reload_mode"),
+		DashboardHostname:        parser.getString("global.dashboard_hostname"),
+		DashboardAllowed:         parser.getStringSlice("global.dashboard_allowed", ","),
+		TracingEndpoint:          parser.getString("global.tracing_endpoint"),
+		TracingProtocol:          parser.getString("global.tracing_protocol"),
+		TracingSampleRatio:       parser.getFloat("global.tracing_sample_ratio"),
+		LogFormat:                parser.getString("global.log_format"),
+		LogLevel:                 parser.getString("global.log_level"),
+		LogLevels:                parser.getStringMap("global.log_levels"),
+		LogFile:                  parser.getString("global.log_file"),
+		LogMaxSize:               parser.getByteSize("global.log_max_size"),
+		LogMaxFiles:              parser.getInt("global.log_max_files"),
+		AccessLogOutput:          parser.getString("global.access_log_output"),
+		AccessLogFormat:          parser.getString("global.access_log_format"),
+		AccessLogTemplate:        parser.getString("global.access_log_template"),
+		AccessLogMaxSize:         parser.getByteSize("global.access_log_max_size"),
+		AccessLogMaxFiles:        parser.getInt("global.access_log_max_files"),
+		AccessLogStatus:          parser.getStringSlice("global.access_log_status", ","),
+		AccessLogSampleRatio:     parser.getFloat("global.access_log_sample_ratio"),
 	}
 
 	// Handle MaxRequestBodySize separately since it's a ByteSize type
@@ -185,12 +264,21 @@ func (p *Provider) parseGlobalConfig(container *container.Summary, cfg *config.C
 		cfg.Global.MaxRequestBodySize = bs
 	}
 
-	return nil
+	// Parse shared service profiles
+	cfg.Profiles = parseProfiles(parser)
+
+	// Parse outbound webhooks
+	cfg.Webhooks = parseWebhooks(parser)
 }
 
 // parseServiceConfig parses service configuration from container labels
 func (p *Provider) parseServiceConfig(container container.Summary) (*config.Service, error) {
-	parser := newLabelParser(container.Labels, p.labelPrefix)
+	return p.parseServiceLabels(newLabelParser(container.Labels, p.labelPrefix), container)
+}
+
+// parseServiceLabels builds a service from parser, using container to fill in
+// the name and backend address when they are not set by labels
+func (p *Provider) parseServiceLabels(parser *labelParser, container container.Summary) (*config.Service, error) {
 	svc := &config.Service{}
 
 	// Service name (required)
@@ -206,6 +294,14 @@ func (p *Provider) parseServiceConfig(container container.Summary) (*config.Serv
 
 	// Backend address
 	backendAddr := parser.getString("service.backend_addr")
+	if backendAddr == "" && parser.getString("service.port") == "" {
+		// A stopped container has no exposed ports to pick from, so its
+		// address would change when it starts and stops
+		if p.isOnDemand(parser.labels, p.profiles) {
+			return nil, errors.NewProviderError("docker", errors.ErrTypeValidation,
+				"on_demand requires tsbridge.service.port or tsbridge.service.backend_addr")
+		}
+	}
 	if backendAddr == "" {
 		// Default to port from label or single exposed port
 		port := parser.getString("service.port")
@@ -248,38 +344,79 @@ func (p *Provider) parseServiceConfig(container container.Summary) (*config.Serv
 	svc.BackendAddr = backendAddr
 
 	// Parse configuration
-	svc.Tags = parser.getStringSlice("service.tags", ",")
-	svc.WhoisEnabled = parser.getBool("service.whois_enabled")
-	svc.AccessLog = parser.getBool("service.access_log")
-	svc.FunnelEnabled = parser.getBool("service.funnel_enabled")
-	svc.InsecureSkipVerify = parser.getBool("service.insecure_skip_verify")
+	svc.Profile = parser.getString("service.profile")
+	parseServiceOptions(parser, "service.", svc)
 	if svc.InsecureSkipVerify != nil && *svc.InsecureSkipVerify {
 		if !strings.HasPrefix(strings.ToLower(backendAddr), "https://") {
 			return nil, errors.NewProviderError("docker", errors.ErrTypeValidation, "insecure_skip_verify is only supported for HTTPS backends")
 		}
 	}
-	svc.TLSMode = parser.getString("service.tls_mode")
-	svc.ListenAddr = parser.getString("service.listen_addr")
-	svc.WhoisTimeout = parser.getDuration("service.whois_timeout")
-	svc.StartupTimeout = parser.getDuration("service.startup_timeout")
-	svc.ReadHeaderTimeout = parser.getDuration("service.read_header_timeout")
-	svc.WriteTimeout = parser.getDuration("service.write_timeout")
-	svc.IdleTimeout = parser.getDuration("service.idle_timeout")
-	svc.ResponseHeaderTimeout = parser.getDuration("service.response_header_timeout")
-	svc.FlushInterval = parser.getDuration("service.flush_interval")
-	svc.UpstreamHeaders = parser.getHeaders("service.upstream_headers")
-	svc.DownstreamHeaders = parser.getHeaders("service.downstream_headers")
-	svc.RemoveUpstream = parser.getStringSlice("service.remove_upstream", ",")
-	svc.RemoveDownstream = parser.getStringSlice("service.remove_downstream", ",")
-	svc.MaxRequestBodySize = parser.getByteSize("service.max_request_body_size")
-	svc.OAuthPreauthorized = parser.getBool("service.oauth_preauthorized")
+
+	return svc, nil
+}
+
+// parseServiceOptions parses the optional service settings found under keyPrefix
+// (e.g. "service." on service containers or "profiles.web." on the tsbridge container)
+func parseServiceOptions(parser *labelParser, keyPrefix string, svc *config.Service) {
+	svc.Tags = parser.getStringSlice(keyPrefix+"tags", ",")
+	svc.WhoisEnabled = parser.getBool(keyPrefix + "whois_enabled")
+	svc.AccessLog = parser.getBool(keyPrefix + "access_log")
+	svc.FunnelEnabled = parser.getBool(keyPrefix + "funnel_enabled")
+	svc.InsecureSkipVerify = parser.getBool(keyPrefix + "insecure_skip_verify")
+	svc.TLSMode = parser.getString(keyPrefix + "tls_mode")
+	svc.ListenAddr = parser.getString(keyPrefix + "listen_addr")
+	svc.WhoisTimeout = parser.getDuration(keyPrefix + "whois_timeout")
+	svc.StartupTimeout = parser.getDuration(keyPrefix + "startup_timeout")
+	svc.ReadHeaderTimeout = parser.getDuration(keyPrefix + "read_header_timeout")
+	svc.WriteTimeout = parser.getDuration(keyPrefix + "write_timeout")
+	svc.IdleTimeout = parser.getDuration(keyPrefix + "idle_timeout")
+	svc.ResponseHeaderTimeout = parser.getDuration(keyPrefix + "response_header_timeout")
+	svc.FlushInterval = parser.getDuration(keyPrefix + "flush_interval")
+	svc.UpstreamHeaders = parser.getHeaders(keyPrefix + "upstream_headers")
+	svc.DownstreamHeaders = parser.getHeaders(keyPrefix + "downstream_headers")
+	svc.RemoveUpstream = parser.getStringSlice(keyPrefix+"remove_upstream", ",")
+	svc.RemoveDownstream = parser.getStringSlice(keyPrefix+"remove_downstream", ",")
+	svc.MaxRequestBodySize = parser.getByteSize(keyPrefix + "max_request_body_size")
+	svc.OAuthPreauthorized = parser.getBool(keyPrefix + "oauth_preauthorized")
+	svc.WaitForBackend = parser.getString(keyPrefix + "wait_for_backend")
+	svc.DependsOn = parser.getStringSlice(keyPrefix+"depends_on", ",")
+	svc.OnDemand = parser.getBool(keyPrefix + "on_demand")
+	svc.OnDemandIdleTimeout = parser.getDuration(keyPrefix + "on_demand_idle_timeout")
 
 	// Handle ephemeral (non-pointer bool)
-	if ephemeral := parser.getBool("service.ephemeral"); ephemeral != nil {
+	if ephemeral := parser.getBool(keyPrefix + "ephemeral"); ephemeral != nil {
 		svc.Ephemeral = *ephemeral
 	}
+}
 
-	return svc, nil
+// parseProfiles parses named service profiles from labels of the form
+// <prefix>.profiles.<name>.<field>
+func parseProfiles(parser *labelParser) map[string]config.Service {
+	profilesPrefix := fmt.Sprintf("%s.profiles.", parser.prefix)
+
+	var profiles map[string]config.Service
+	for label := range parser.labels {
+		rest, ok := strings.CutPrefix(label, profilesPrefix)
+		if !ok {
+			continue
+		}
+		name, _, ok := strings.Cut(rest, ".")
+		if !ok || name == "" {
+			continue
+		}
+		if _, seen := profiles[name]; seen {
+			continue
+		}
+
+		var profile config.Service
+		parseServiceOptions(parser, "profiles."+name+".", &profile)
+		if profiles == nil {
+			profiles = make(map[string]config.Service)
+		}
+		profiles[name] = profile
+	}
+
+	return profiles
 }
 
 // getContainerAddress returns the address to reach the container
@@ -334,6 +471,18 @@ func parseInt(value string) (*int, error) {
 	return &i, nil
 }
 
+// parseFloat parses a decimal string and returns a pointer to float64
+func parseFloat(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
 // parseStringSlice parses a delimited string and returns a slice of strings
 func parseStringSlice(value, separator string) []string {
 	if value == "" {
@@ -353,3 +502,44 @@ func parseByteSize(value string) (int64, error) {
 	}
 	return config.ParseByteSizeString(value)
 }
+
+// parseWebhookOptions parses the settings of the webhook found under keyPrefix
+// (e.g. "webhooks.slack.")
+func parseWebhookOptions(parser *labelParser, keyPrefix string) config.Webhook {
+	return config.Webhook{
+		URL:        config.RedactedString(parser.getString(keyPrefix + "url")),
+		Format:     parser.getString(keyPrefix + "format"),
+		Events:     parser.getStringSlice(keyPrefix+"events", ","),
+		Secret:     config.RedactedString(parser.getString(keyPrefix + "secret")),
+		Timeout:    parser.getDuration(keyPrefix + "timeout"),
+		MaxRetries: parser.getInt(keyPrefix + "max_retries"),
+	}
+}
+
+// parseWebhooks parses named outbound webhooks from labels of the form
+// <prefix>.webhooks.<name>.<field>
+func parseWebhooks(parser *labelParser) map[string]config.Webhook {
+	webhooksPrefix := fmt.Sprintf("%s.webhooks.", parser.prefix)
+
+	var webhooks map[string]config.Webhook
+	for label := range parser.labels {
+		rest, ok := strings.CutPrefix(label, webhooksPrefix)
+		if !ok {
+			continue
+		}
+		name, _, ok := strings.Cut(rest, ".")
+		if !ok || name == "" {
+			continue
+		}
+		if _, seen := webhooks[name]; seen {
+			continue
+		}
+
+		if webhooks == nil {
+			webhooks = make(map[string]config.Webhook)
+		}
+		webhooks[name] = parseWebhookOptions(parser, "webhooks."+name+".")
+	}
+
+	return webhooks
+}
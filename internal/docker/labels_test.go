@@ -201,6 +201,54 @@ func TestParseInt(t *testing.T) {
 	}
 }
 
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected *float64
+		wantErr  bool
+	}{
+		{
+			name:     "fraction",
+			value:    "0.25",
+			expected: new(0.25),
+		},
+		{
+			name:     "whole number",
+			value:    "1",
+			expected: new(1.0),
+		},
+		{
+			name:     "empty string",
+			value:    "",
+			expected: nil,
+		},
+		{
+			name:     "invalid float",
+			value:    "half",
+			expected: nil,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseFloat(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				if tt.expected == nil {
+					assert.Nil(t, result)
+				} else {
+					require.NotNil(t, result)
+					assert.Equal(t, *tt.expected, *result)
+				}
+			}
+		})
+	}
+}
+
 func TestParseStringSlice(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -689,6 +737,7 @@ func TestDockerInsecureSkipVerifyParsing(t *testing.T) {
 func getDockerParsedGlobalFields() map[string]bool {
 	return map[string]bool{
 		"global.metrics_addr":                true,
+		"global.ready_min_services":          true,
 		"global.read_header_timeout":         true,
 		"global.write_timeout":               true,
 		"global.idle_timeout":                true,
@@ -706,6 +755,31 @@ func getDockerParsedGlobalFields() map[string]bool {
 		"global.flush_interval":              true,
 		"global.default_tags":                true,
 		"global.max_request_body_size":       true,
+		"global.admin_addr":                  true,
+		"global.admin_allowed":               true,
+		"global.audit_log":                   true,
+		"global.reload_mode":                 true,
+		"global.max_start_attempts":          true,
+		"global.startup_concurrency":         true,
+		"global.drain_timeout":               true,
+		"global.dashboard_hostname":          true,
+		"global.dashboard_allowed":           true,
+		"global.tracing_endpoint":            true,
+		"global.tracing_protocol":            true,
+		"global.tracing_sample_ratio":        true,
+		"global.log_format":                  true,
+		"global.log_level":                   true,
+		"global.log_levels":                  true,
+		"global.log_file":                    true,
+		"global.log_max_size":                true,
+		"global.log_max_files":               true,
+		"global.access_log_output":           true,
+		"global.access_log_format":           true,
+		"global.access_log_template":         true,
+		"global.access_log_max_size":         true,
+		"global.access_log_max_files":        true,
+		"global.access_log_status":           true,
+		"global.access_log_sample_ratio":     true,
 	}
 }
 
@@ -736,6 +810,11 @@ func getDockerParsedServiceFields() map[string]bool {
 		"service.max_request_body_size":   true,
 		"service.oauth_preauthorized":     true,
 		"service.listen_addr":             true,
+		"service.profile":                 true,
+		"service.wait_for_backend":        true,
+		"service.depends_on":              true,
+		"service.on_demand":               true,
+		"service.on_demand_idle_timeout":  true,
 	}
 }
 
@@ -976,3 +1055,109 @@ func TestDockerServiceOAuthPreauthorizedParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestDockerProfileParsing(t *testing.T) {
+	provider := &Provider{
+		labelPrefix: "tsbridge",
+	}
+
+	t.Run("profiles on tsbridge container", func(t *testing.T) {
+		container := &container.Summary{
+			Names: []string{"/tsbridge"},
+			Labels: map[string]string{
+				"tsbridge.tailscale.auth_key":                           "tskey-auth-test",
+				"tsbridge.profiles.internal-web.whois_enabled":          "true",
+				"tsbridge.profiles.internal-web.tags":                   "tag:web,tag:internal",
+				"tsbridge.profiles.internal-web.upstream_headers.X-Env": "prod",
+				"tsbridge.profiles.internal-web.write_timeout":          "45s",
+				"tsbridge.profiles.public.funnel_enabled":               "true",
+				"tsbridge.profiles.public.ephemeral":                    "true",
+				"tsbridge.profiles.public.response_header_timeout":      "5s",
+				"tsbridge.profilesextra.ignored.whois_enabled":          "true",
+				"tsbridge.profiles.missing-field":                       "true",
+				"tsbridge.profiles.internal-web.unknown_field_is_set":   "x",
+			},
+		}
+
+		cfg := &config.Config{}
+		require.NoError(t, provider.parseGlobalConfig(container, cfg))
+		require.Len(t, cfg.Profiles, 2)
+
+		web := cfg.Profiles["internal-web"]
+		require.NotNil(t, web.WhoisEnabled)
+		assert.True(t, *web.WhoisEnabled)
+		assert.Equal(t, []string{"tag:web", "tag:internal"}, web.Tags)
+		assert.Equal(t, map[string]string{"X-Env": "prod"}, web.UpstreamHeaders)
+		require.NotNil(t, web.WriteTimeout)
+		assert.Equal(t, 45*time.Second, *web.WriteTimeout)
+
+		public := cfg.Profiles["public"]
+		require.NotNil(t, public.FunnelEnabled)
+		assert.True(t, *public.FunnelEnabled)
+		assert.True(t, public.Ephemeral)
+		assert.Nil(t, public.WhoisEnabled)
+	})
+
+	t.Run("no profile labels", func(t *testing.T) {
+		container := &container.Summary{
+			Names:  []string{"/tsbridge"},
+			Labels: map[string]string{"tsbridge.tailscale.auth_key": "tskey-auth-test"},
+		}
+
+		cfg := &config.Config{}
+		require.NoError(t, provider.parseGlobalConfig(container, cfg))
+		assert.Nil(t, cfg.Profiles)
+	})
+
+	t.Run("service references profile", func(t *testing.T) {
+		container := container.Summary{
+			Names: []string{"/web"},
+			Labels: map[string]string{
+				"tsbridge.enabled":         "true",
+				"tsbridge.service.port":    "8080",
+				"tsbridge.service.profile": "internal-web",
+			},
+		}
+
+		svc, err := provider.parseServiceConfig(container)
+		require.NoError(t, err)
+		assert.Equal(t, "internal-web", svc.Profile)
+	})
+}
+
+func TestDockerWebhookParsing(t *testing.T) {
+	provider := &Provider{
+		labelPrefix: "tsbridge",
+	}
+
+	container := &container.Summary{
+		Names: []string{"/tsbridge"},
+		Labels: map[string]string{
+			"tsbridge.tailscale.auth_key":        "tskey-auth-test",
+			"tsbridge.webhooks.slack.url":        "https://hooks.slack.com/services/T0/B0/token",
+			"tsbridge.webhooks.slack.format":     "slack",
+			"tsbridge.webhooks.slack.secret":     "s3cret",
+			"tsbridge.webhooks.ntfy.url":         "https://ntfy.sh/tsbridge-alerts",
+			"tsbridge.webhooks.ntfy.events":      "backend,config.reload_failed",
+			"tsbridge.webhooks.ntfy.timeout":     "5s",
+			"tsbridge.webhooks.ntfy.max_retries": "1",
+			"tsbridge.webhooksextra.x.url":       "https://ignored.example.com",
+		},
+	}
+
+	cfg := &config.Config{}
+	require.NoError(t, provider.parseGlobalConfig(container, cfg))
+	require.Len(t, cfg.Webhooks, 2)
+
+	slack := cfg.Webhooks["slack"]
+	assert.Equal(t, "https://hooks.slack.com/services/T0/B0/token", slack.URL.Value())
+	assert.Equal(t, "slack", slack.Format)
+	assert.Equal(t, "s3cret", slack.Secret.Value())
+
+	ntfy := cfg.Webhooks["ntfy"]
+	assert.Equal(t, []string{"backend", "config.reload_failed"}, ntfy.Events)
+	require.NotNil(t, ntfy.Timeout)
+	assert.Equal(t, 5*time.Second, *ntfy.Timeout)
+	require.NotNil(t, ntfy.MaxRetries)
+	assert.Equal(t, 1, *ntfy.MaxRetries)
+}
@@ -823,6 +823,21 @@ func TestReloadError_RecordErrors(t *testing.T) {
 		assert.Len(t, err.RemoveErrors, 1)
 		assert.Len(t, err.UpdateErrors, 1)
 	})
+
+	t.Run("record rollback", func(t *testing.T) {
+		err := NewReloadError()
+		err.RecordSuccess()
+		err.RecordUpdateError("svc1", errors.New("update error"))
+		err.RolledBack = true
+		err.RecordRollbackError("svc2", errors.New("restart error"))
+
+		assert.Equal(t, 1, err.Failed, "rollback errors are not failed operations")
+		assert.Len(t, err.RollbackErrors, 1)
+		msg := err.Error()
+		assert.Contains(t, msg, "configuration reload failed and was rolled back (1 errors):")
+		assert.Contains(t, msg, "Failed to update services:  - svc1: update error")
+		assert.Contains(t, msg, "Failed to restore services:  - svc2: restart error")
+	})
 }
 
 func TestReloadError_RecordSuccess(t *testing.T) {
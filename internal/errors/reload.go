@@ -13,6 +13,10 @@ type ReloadError struct {
 	UpdateErrors map[string]error // Services that failed to update
 	Successful   int              // Number of successful operations
 	Failed       int              // Number of failed operations
+
+	// Set by transactional reloads, which undo every change when one fails
+	RolledBack     bool             // The changes already made were undone
+	RollbackErrors map[string]error // Services that could not be restored to their previous configuration
 }
 
 // Error implements the error interface
@@ -23,8 +27,12 @@ func (e *ReloadError) Error() string {
 		return "configuration reload completed successfully"
 	}
 
-	parts = append(parts, fmt.Sprintf("configuration reload partially failed (%d errors, %d successful):",
-		e.Failed, e.Successful))
+	if e.RolledBack {
+		parts = append(parts, fmt.Sprintf("configuration reload failed and was rolled back (%d errors):", e.Failed))
+	} else {
+		parts = append(parts, fmt.Sprintf("configuration reload partially failed (%d errors, %d successful):",
+			e.Failed, e.Successful))
+	}
 
 	// Report removal errors first (cleanup failures)
 	if len(e.RemoveErrors) > 0 {
@@ -50,6 +58,14 @@ func (e *ReloadError) Error() string {
 		}
 	}
 
+	// Services a rollback left out of their previous state
+	if len(e.RollbackErrors) > 0 {
+		parts = append(parts, "\nFailed to restore services:")
+		for name, err := range e.RollbackErrors {
+			parts = append(parts, fmt.Sprintf("  - %s: %v", name, err))
+		}
+	}
+
 	return strings.Join(parts, "")
 }
 
@@ -66,9 +82,10 @@ func (e *ReloadError) AllFailed() bool {
 // NewReloadError creates a new reload error if there were any failures
 func NewReloadError() *ReloadError {
 	return &ReloadError{
-		AddErrors:    make(map[string]error),
-		RemoveErrors: make(map[string]error),
-		UpdateErrors: make(map[string]error),
+		AddErrors:      make(map[string]error),
+		RemoveErrors:   make(map[string]error),
+		UpdateErrors:   make(map[string]error),
+		RollbackErrors: make(map[string]error),
 	}
 }
 
@@ -90,6 +107,12 @@ func (e *ReloadError) RecordUpdateError(serviceName string, err error) {
 	e.Failed++
 }
 
+// RecordRollbackError records a service that could not be restored while
+// rolling back. It does not count as a failed operation.
+func (e *ReloadError) RecordRollbackError(serviceName string, err error) {
+	e.RollbackErrors[serviceName] = err
+}
+
 // RecordSuccess increments the successful operation counter
 func (e *ReloadError) RecordSuccess() {
 	e.Successful++
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// AuditLog is a Sink that appends every event to a file as a line of JSON.
+// The file is only ever appended to, so earlier records survive restarts.
+// Events are written by a goroutine of its own so that publishers do not wait
+// on the disk.
+type AuditLog struct {
+	file   *os.File
+	queue  chan Event
+	done   chan struct{} // Closed once every queued event is written
+	closed bool
+	mu     sync.Mutex
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it readable
+// only by the current user if it does not exist
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	a := &AuditLog{
+		file:  f,
+		queue: make(chan Event, constants.AuditLogQueueSize),
+		done:  make(chan struct{}),
+	}
+	go a.write()
+	return a, nil
+}
+
+// Record queues e to be appended to the audit log. It only waits when the
+// writer has fallen a full queue behind, so that no event goes unrecorded.
+func (a *AuditLog) Record(e Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.queue <- e
+}
+
+// write appends queued events to the file until the queue is closed.
+// Failures are logged rather than returned so an unwritable audit log never
+// stops the change it records.
+func (a *AuditLog) write() {
+	defer close(a.done)
+	enc := json.NewEncoder(a.file)
+	for e := range a.queue {
+		if err := enc.Encode(e); err != nil {
+			slog.Warn("failed to write audit log", "event", e.Type, "error", err)
+		}
+	}
+}
+
+// Close writes the events still queued and closes the audit log file
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	return a.file.Close()
+}
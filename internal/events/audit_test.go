@@ -0,0 +1,86 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAuditLog returns the events recorded in the audit log at path
+func readAuditLog(t *testing.T, path string) []Event {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("appends events as JSON lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+
+		for _, typ := range []string{ConfigReloaded, AdminRestart} {
+			audit, err := OpenAuditLog(path)
+			require.NoError(t, err)
+
+			b := NewBus(0)
+			b.AddSink(audit)
+			b.Publish(Event{
+				Type:    typ,
+				Service: "api",
+				Trigger: &Trigger{Source: SourceAdminAPI, Actor: "alice@example.com"},
+			})
+			require.NoError(t, audit.Close())
+		}
+
+		recorded := readAuditLog(t, path)
+		require.Len(t, recorded, 2, "reopening appends rather than truncates")
+		assert.Equal(t, []string{ConfigReloaded, AdminRestart}, types(recorded))
+		assert.Equal(t, &Trigger{Source: SourceAdminAPI, Actor: "alice@example.com"}, recorded[1].Trigger)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("writes queued events in order before closing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		audit, err := OpenAuditLog(path)
+		require.NoError(t, err)
+
+		b := NewBus(0)
+		b.AddSink(audit)
+		const published = 1000
+		for range published {
+			b.Publish(Event{Type: ServiceStarted, Service: "api"})
+		}
+		require.NoError(t, audit.Close())
+		b.Publish(Event{Type: ServiceStopped, Service: "api"})
+
+		recorded := readAuditLog(t, path)
+		require.Len(t, recorded, published, "events published after closing are not recorded")
+		for i, e := range recorded {
+			assert.Equal(t, uint64(i+1), e.ID)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := OpenAuditLog(filepath.Join(t.TempDir(), "missing", "audit.log"))
+		assert.ErrorContains(t, err, "failed to open audit log")
+	})
+}
@@ -0,0 +1,250 @@
+// Package events publishes the lifecycle events of services, tailnet nodes,
+// configuration reloads and Docker containers to subscribers and the audit log.
+package events
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// Event types
+const (
+	ServiceStarted     = "service.started"      // A service is serving on its tailnet node
+	ServiceStartFailed = "service.start_failed" // A service could not be started
+	ServiceStopped     = "service.stopped"      // A service stopped serving
+
+	NodeStateChanged = "node.state_changed" // A service's tailnet node changed backend state
+	NodeCertExpiring = "node.cert_expiring" // A service's TLS certificate expires within constants.CertExpiryWarning
+
+	BackendUnhealthy = "backend.unhealthy" // A service's backend stopped accepting connections
+	BackendHealthy   = "backend.healthy"   // An unhealthy backend accepts connections again
+
+	ConfigLoaded       = "config.loaded"        // The configuration was loaded and its services started at startup
+	ConfigReloaded     = "config.reloaded"      // A configuration change was applied
+	ConfigReloadFailed = "config.reload_failed" // A configuration change could not be fully applied
+
+	AdminRestart   = "admin.restart"    // A service was restarted through the admin API
+	AdminDrain     = "admin.drain"      // A service started draining through the admin API
+	AdminResume    = "admin.resume"     // A draining service was resumed through the admin API
+	AdminRemove    = "admin.remove"     // A service was removed through the admin API
+	AdminAccessLog = "admin.access_log" // Access logging of a service was toggled through the admin API
+
+	DockerContainer = "docker.container" // A tsbridge-enabled container started, stopped, died, paused or unpaused
+)
+
+// allTypes lists every event type, for validating the types a consumer asks for
+var allTypes = []string{
+	ServiceStarted, ServiceStartFailed, ServiceStopped,
+	NodeStateChanged, NodeCertExpiring,
+	BackendUnhealthy, BackendHealthy,
+	ConfigLoaded, ConfigReloaded, ConfigReloadFailed,
+	AdminRestart, AdminDrain, AdminResume, AdminRemove, AdminAccessLog,
+	DockerContainer,
+}
+
+// IsType reports whether name is an event type or a category of them, such as
+// "service" for "service.started"
+func IsType(name string) bool {
+	for _, t := range allTypes {
+		if category, _, _ := strings.Cut(t, "."); name == t || name == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Trigger sources
+const (
+	SourceStartup  = "startup"   // Loading the configuration at startup
+	SourceAdminAPI = "admin_api" // A request to the admin API
+	SourceProvider = "provider"  // A change detected by the configuration provider
+)
+
+// Event is something that happened to the running instance
+type Event struct {
+	ID      uint64         `json:"id"` // Increases by one for every event published
+	Time    time.Time      `json:"time"`
+	Type    string         `json:"type"`
+	Service string         `json:"service,omitempty"`
+	Trigger *Trigger       `json:"trigger,omitempty"` // Who or what caused the event, for changes that were requested
+	Error   string         `json:"error,omitempty"`   // Why the change failed, if it did
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// Trigger describes who or what caused a change
+type Trigger struct {
+	Source string `json:"source"`          // SourceStartup, SourceAdminAPI or SourceProvider
+	Actor  string `json:"actor,omitempty"` // Tailnet identity or address of an admin API caller, or the provider name
+}
+
+// triggerKey is the context key of the Trigger of a request
+type triggerKey struct{}
+
+// WithTrigger returns ctx carrying the trigger of the changes made on its behalf
+func WithTrigger(ctx context.Context, t Trigger) context.Context {
+	return context.WithValue(ctx, triggerKey{}, t)
+}
+
+// TriggerFromContext returns the trigger carried by ctx, if any
+func TriggerFromContext(ctx context.Context) (Trigger, bool) {
+	t, ok := ctx.Value(triggerKey{}).(Trigger)
+	return t, ok
+}
+
+// Match reports whether the event has one of types, either exactly or by
+// category such as "service" for "service.started". Empty types match every event.
+func (e Event) Match(types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	category, _, _ := strings.Cut(e.Type, ".")
+	for _, t := range types {
+		if t == e.Type || t == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink receives every published event, in order, before subscribers do.
+// Record is called while the bus is locked, so it must not wait on IO.
+type Sink interface {
+	Record(Event)
+}
+
+// Bus fans published events out to sinks and subscribers and keeps the most
+// recent ones. A nil Bus discards events, so publishers need no checks.
+type Bus struct {
+	history     []Event
+	next        int // Index in history the next event is written to once full
+	size        int
+	lastID      uint64
+	sinks       []Sink
+	subscribers map[chan Event]struct{}
+	mu          sync.Mutex
+}
+
+// NewBus creates a Bus that keeps the last size events
+func NewBus(size int) *Bus {
+	return &Bus{
+		history:     make([]Event, 0, size),
+		size:        size,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// AddSink makes s record every event published from now on
+func (b *Bus) AddSink(s Sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks = append(b.sinks, s)
+}
+
+// ReplaceSink makes replacement record the events old did, in its place. A nil
+// old adds replacement and a nil replacement removes old.
+func (b *Bus) ReplaceSink(old, replacement Sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := slices.Index(b.sinks, old)
+	switch {
+	case old == nil || i < 0:
+		if replacement != nil {
+			b.sinks = append(b.sinks, replacement)
+		}
+	case replacement == nil:
+		b.sinks = slices.Delete(b.sinks, i, i+1)
+	default:
+		b.sinks[i] = replacement
+	}
+}
+
+// Publish assigns e an ID and time and delivers it, dropping it for
+// subscribers that are not keeping up
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if len(b.history) < b.size {
+		b.history = append(b.history, e)
+	} else if b.size > 0 {
+		b.history[b.next] = e
+		b.next = (b.next + 1) % b.size
+	}
+
+	for _, sink := range b.sinks {
+		sink.Record(e)
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Since returns the kept events with an ID greater than id, oldest first. An
+// id from before the process started, greater than any published, returns
+// every kept event.
+func (b *Bus) Since(id uint64) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if id > b.lastID {
+		id = 0
+	}
+	var events []Event
+	for _, e := range b.ordered() {
+		if e.ID > id {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Recent returns up to n of the most recent events, oldest first
+func (b *Bus) Recent(n int) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.ordered()
+	if n >= 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// ordered returns a copy of the kept events, oldest first. The caller must hold b.mu.
+func (b *Bus) ordered() []Event {
+	return append(append([]Event{}, b.history[b.next:]...), b.history[:b.next]...)
+}
+
+// Subscribe returns a channel that receives every event published from now on
+// and a function that stops the subscription
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, constants.DefaultEventFollowBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
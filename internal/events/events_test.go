@@ -0,0 +1,146 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// types returns the types of events, in order
+func types(events []Event) []string {
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+type recordingSink struct {
+	events []Event
+}
+
+func (s *recordingSink) Record(e Event) {
+	s.events = append(s.events, e)
+}
+
+func TestBus(t *testing.T) {
+	t.Run("keeps the most recent events", func(t *testing.T) {
+		b := NewBus(3)
+		for _, typ := range []string{"a.one", "a.two", "a.three", "a.four"} {
+			b.Publish(Event{Type: typ})
+		}
+
+		recent := b.Recent(-1)
+		assert.Equal(t, []string{"a.two", "a.three", "a.four"}, types(recent))
+		assert.Equal(t, []uint64{2, 3, 4}, []uint64{recent[0].ID, recent[1].ID, recent[2].ID})
+		assert.False(t, recent[0].Time.IsZero())
+		assert.Equal(t, []string{"a.four"}, types(b.Recent(1)))
+		assert.Empty(t, b.Recent(0))
+	})
+
+	t.Run("events since an id", func(t *testing.T) {
+		b := NewBus(3)
+		for _, typ := range []string{"a.one", "a.two", "a.three", "a.four"} {
+			b.Publish(Event{Type: typ})
+		}
+
+		assert.Equal(t, []string{"a.three", "a.four"}, types(b.Since(2)))
+		assert.Empty(t, b.Since(4))
+		assert.Equal(t, []string{"a.two", "a.three", "a.four"}, types(b.Since(0)))
+		// An id from a previous run replays everything kept
+		assert.Equal(t, []string{"a.two", "a.three", "a.four"}, types(b.Since(100)))
+	})
+
+	t.Run("sinks record every event", func(t *testing.T) {
+		b := NewBus(1)
+		sink := &recordingSink{}
+		b.AddSink(sink)
+		b.Publish(Event{Type: "a.one"})
+		b.Publish(Event{Type: "a.two"})
+
+		assert.Equal(t, []string{"a.one", "a.two"}, types(sink.events))
+	})
+
+	t.Run("replaced sinks", func(t *testing.T) {
+		b := NewBus(1)
+		first, second := &recordingSink{}, &recordingSink{}
+		b.ReplaceSink(nil, first)
+		b.Publish(Event{Type: "a.one"})
+		b.ReplaceSink(first, second)
+		b.Publish(Event{Type: "a.two"})
+		b.ReplaceSink(second, nil)
+		b.Publish(Event{Type: "a.three"})
+
+		assert.Equal(t, []string{"a.one"}, types(first.events))
+		assert.Equal(t, []string{"a.two"}, types(second.events))
+	})
+
+	t.Run("subscribers receive new events", func(t *testing.T) {
+		b := NewBus(10)
+		b.Publish(Event{Type: "a.before"})
+
+		updates, unsubscribe := b.Subscribe()
+		b.Publish(Event{Type: "a.after"})
+
+		select {
+		case e := <-updates:
+			assert.Equal(t, "a.after", e.Type)
+		case <-time.After(time.Second):
+			t.Fatal("no event received")
+		}
+
+		unsubscribe()
+		b.Publish(Event{Type: "a.unsubscribed"})
+		assert.Empty(t, updates)
+	})
+
+	t.Run("nil bus discards events", func(t *testing.T) {
+		var b *Bus
+		assert.NotPanics(t, func() { b.Publish(Event{Type: "a.one"}) })
+	})
+}
+
+func TestEventMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  bool
+	}{
+		{name: "no types", want: true},
+		{name: "exact type", types: []string{ServiceStarted}, want: true},
+		{name: "category", types: []string{"service"}, want: true},
+		{name: "one of several", types: []string{"config", "service"}, want: true},
+		{name: "other type", types: []string{ServiceStopped}, want: false},
+		{name: "other category", types: []string{"admin"}, want: false},
+		{name: "partial category", types: []string{"serv"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Event{Type: ServiceStarted}.Match(tt.types))
+		})
+	}
+}
+
+func TestIsType(t *testing.T) {
+	assert.True(t, IsType(ServiceStartFailed))
+	assert.True(t, IsType(BackendUnhealthy))
+	assert.True(t, IsType("backend"), "categories are types")
+	assert.True(t, IsType("admin"))
+	assert.False(t, IsType("service.exploded"))
+	assert.False(t, IsType("services"))
+	assert.False(t, IsType(""))
+}
+
+func TestTriggerFromContext(t *testing.T) {
+	_, ok := TriggerFromContext(context.Background())
+	assert.False(t, ok)
+
+	want := Trigger{Source: SourceAdminAPI, Actor: "alice@example.com"}
+	got, ok := TriggerFromContext(WithTrigger(context.Background(), want))
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+}
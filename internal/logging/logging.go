@@ -0,0 +1,270 @@
+// Package logging implements tsbridge's structured log pipeline: the log
+// format, where logs are written, and a log level for each component.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+)
+
+// ComponentKey is the attribute that tags a record with the component that logged it
+const ComponentKey = "component"
+
+// Components with their own log level
+const (
+	ComponentTailscale = "tailscale" // Tailscale nodes, auth keys and OAuth
+	ComponentTSNet     = "tsnet"     // Internal logs of the embedded Tailscale nodes
+	ComponentProxy     = "proxy"     // Access logs and backend errors of proxied requests
+	ComponentDocker    = "docker"    // Docker provider
+	ComponentWhois     = "whois"     // Whois lookups of callers
+)
+
+// Components lists every component that can be given its own level
+var Components = []string{ComponentTailscale, ComponentTSNet, ComponentProxy, ComponentDocker, ComponentWhois}
+
+// Logger returns the default logger with records tagged as coming from
+// component, so that component's level applies to them
+func Logger(component string) *slog.Logger {
+	return slog.With(ComponentKey, component)
+}
+
+// Options configures a Handler
+type Options struct {
+	Format   string                // "text" (default), "json" or "logfmt"
+	Level    slog.Level            // Minimum level of records from any component
+	Levels   map[string]slog.Level // Minimum level of records from each component, overriding Level
+	File     string                // Log file path; logs go to Output if empty
+	MaxSize  int64                 // Size the log file is rotated at, 0 to never rotate
+	MaxFiles int                   // Rotated log files kept next to the log file
+	Output   io.Writer             // Where logs go without a file (default: stdout)
+	Tee      io.Writer             // Also receives every log line, if not nil
+}
+
+// output is the configured formatting handler and levels
+type output struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level
+	file    io.Closer // Log file, closed when replaced
+
+	mu       sync.RWMutex // Held for reading while a record is written
+	replaced bool         // Configure replaced this output, so records go to the new one
+}
+
+// derived is a formatting handler with a Handler's attributes and groups applied
+type derived struct {
+	output  *output
+	handler slog.Handler
+}
+
+// Handler is the root slog handler. Configure replaces its format, output and
+// levels in place, so loggers created before the configuration was loaded
+// follow the new settings.
+type Handler struct {
+	output    *atomic.Pointer[output]
+	component string                            // Component whose level applies, from WithAttrs
+	grouped   bool                              // Attributes are added inside a group
+	ops       []func(slog.Handler) slog.Handler // WithAttrs and WithGroup calls to replay on the formatting handler
+	derived   atomic.Pointer[derived]
+}
+
+// NewHandler creates a handler configured with opts
+func NewHandler(opts Options) (*Handler, error) {
+	h := &Handler{output: new(atomic.Pointer[output])}
+	if err := h.Configure(opts); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Configure replaces the format, output and levels of h and every handler
+// derived from it. A previous log file is closed once the records being
+// written to it are done.
+func (h *Handler) Configure(opts Options) error {
+	w := opts.Output
+	if w == nil {
+		w = os.Stdout
+	}
+
+	var file io.Closer
+	if opts.File != "" {
+		rotating, err := openRotatingFile(opts.File, opts.MaxSize, opts.MaxFiles)
+		if err != nil {
+			return err
+		}
+		w, file = rotating, rotating
+	}
+	if opts.Tee != nil {
+		w = io.MultiWriter(w, opts.Tee)
+	}
+
+	handler, err := newFormatHandler(opts.Format, w)
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}
+
+	old := h.output.Swap(&output{
+		handler: handler,
+		level:   opts.Level,
+		levels:  opts.Levels,
+		file:    file,
+	})
+	if old == nil {
+		return nil
+	}
+	old.mu.Lock()
+	old.replaced = true
+	old.mu.Unlock()
+	if old.file != nil {
+		return old.file.Close()
+	}
+	return nil
+}
+
+// Close closes the log file, if any, once the records being written to it
+// are done
+func (h *Handler) Close() error {
+	out := h.output.Load()
+	if out.file == nil {
+		return nil
+	}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	return out.file.Close()
+}
+
+// Enabled implements slog.Handler
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	out := h.output.Load()
+	minLevel := out.level
+	if componentLevel, ok := out.levels[h.component]; ok {
+		minLevel = componentLevel
+	}
+	return level >= minLevel
+}
+
+// Handle implements slog.Handler
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	for {
+		out := h.output.Load()
+		out.mu.RLock()
+		if out.replaced {
+			// Configure swapped in a new output after this one was loaded
+			out.mu.RUnlock()
+			continue
+		}
+		err := h.formatter(out).Handle(ctx, r)
+		out.mu.RUnlock()
+		return err
+	}
+}
+
+// WithAttrs implements slog.Handler. A top-level component attribute selects
+// the level records are filtered by.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := h.clone(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+	if !h.grouped {
+		for _, attr := range attrs {
+			if attr.Key == ComponentKey {
+				clone.component = attr.Value.String()
+			}
+		}
+	}
+	return clone
+}
+
+// WithGroup implements slog.Handler
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := h.clone(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+	clone.grouped = true
+	return clone
+}
+
+// clone returns a copy of h that also applies op to the formatting handler
+func (h *Handler) clone(op func(slog.Handler) slog.Handler) *Handler {
+	return &Handler{
+		output:    h.output,
+		component: h.component,
+		grouped:   h.grouped,
+		ops:       append(slices.Clip(h.ops), op),
+	}
+}
+
+// formatter returns the formatting handler of out with h's attributes and
+// groups applied, reusing it until the configuration changes
+func (h *Handler) formatter(out *output) slog.Handler {
+	if len(h.ops) == 0 {
+		return out.handler
+	}
+	if d := h.derived.Load(); d != nil && d.output == out {
+		return d.handler
+	}
+
+	handler := out.handler
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	h.derived.Store(&derived{output: out, handler: handler})
+	return handler
+}
+
+// newFormatHandler creates the handler that writes records to w in format.
+// It does not filter by level; Handler does.
+func newFormatHandler(format string, w io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: slog.Level(-100)}
+	switch format {
+	case "", constants.LogFormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case constants.LogFormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	case constants.LogFormatLogfmt:
+		opts.ReplaceAttr = logfmtAttr
+		return slog.NewTextHandler(w, opts), nil
+	default:
+		return nil, tserrors.NewConfigError(fmt.Sprintf("unsupported log format %q", format))
+	}
+}
+
+// logfmtAttr rewrites the built-in attributes to the conventional logfmt keys
+// and values: a UTC ts and a lowercase level
+func logfmtAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+	switch attr.Key {
+	case slog.TimeKey:
+		return slog.String("ts", attr.Value.Time().UTC().Format(time.RFC3339Nano))
+	case slog.LevelKey:
+		return slog.String(slog.LevelKey, strings.ToLower(attr.Value.String()))
+	}
+	return attr
+}
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
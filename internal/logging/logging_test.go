@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		check  func(t *testing.T, line string)
+	}{
+		{
+			name: "text by default",
+			check: func(t *testing.T, line string) {
+				assert.Contains(t, line, "level=INFO")
+				assert.Contains(t, line, `msg="service started"`)
+				assert.Contains(t, line, "component=proxy")
+			},
+		},
+		{
+			name:   "json",
+			format: constants.LogFormatJSON,
+			check: func(t *testing.T, line string) {
+				var record map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &record))
+				assert.Equal(t, "INFO", record["level"])
+				assert.Equal(t, "service started", record["msg"])
+				assert.Equal(t, "proxy", record["component"])
+				assert.Equal(t, "api", record["service"])
+			},
+		},
+		{
+			name:   "logfmt",
+			format: constants.LogFormatLogfmt,
+			check: func(t *testing.T, line string) {
+				assert.True(t, strings.HasPrefix(line, "ts="), line)
+				assert.Contains(t, line, "Z level=info")
+				assert.Contains(t, line, "component=proxy")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, err := NewHandler(Options{Format: tt.format, Output: &buf})
+			require.NoError(t, err)
+
+			slog.New(h).With(ComponentKey, ComponentProxy).Info("service started", "service", "api")
+			tt.check(t, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+func TestHandlerUnsupportedFormat(t *testing.T) {
+	_, err := NewHandler(Options{Format: "xml"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported log format "xml"`)
+}
+
+func TestHandlerComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewHandler(Options{
+		Output: &buf,
+		Level:  slog.LevelInfo,
+		Levels: map[string]slog.Level{
+			ComponentTSNet:  slog.LevelError,
+			ComponentDocker: slog.LevelDebug,
+		},
+	})
+	require.NoError(t, err)
+	logger := slog.New(h)
+
+	logger.Debug("default debug")
+	logger.Info("default info")
+	logger.With(ComponentKey, ComponentTSNet).Warn("tsnet warn")
+	logger.With(ComponentKey, ComponentTSNet).Error("tsnet error")
+	logger.With(ComponentKey, ComponentDocker).Debug("docker debug")
+	logger.WithGroup("request").With(ComponentKey, ComponentDocker).Debug("grouped debug")
+
+	out := buf.String()
+	assert.NotContains(t, out, "default debug")
+	assert.Contains(t, out, "default info")
+	assert.NotContains(t, out, "tsnet warn")
+	assert.Contains(t, out, "tsnet error")
+	assert.Contains(t, out, "docker debug")
+	assert.NotContains(t, out, "grouped debug", "a component attribute inside a group does not select a level")
+}
+
+func TestHandlerConfigure(t *testing.T) {
+	var first, second bytes.Buffer
+	h, err := NewHandler(Options{Output: &first})
+	require.NoError(t, err)
+
+	// Loggers created before Configure follow the new settings
+	logger := slog.New(h).With(ComponentKey, ComponentWhois)
+	logger.Debug("before")
+
+	require.NoError(t, h.Configure(Options{
+		Format: constants.LogFormatJSON,
+		Output: &second,
+		Levels: map[string]slog.Level{ComponentWhois: slog.LevelDebug},
+	}))
+	logger.Debug("after")
+
+	assert.Empty(t, first.String())
+	assert.Contains(t, second.String(), `"msg":"after"`)
+	assert.Contains(t, second.String(), `"component":"whois"`)
+}
+
+func TestHandlerTee(t *testing.T) {
+	var out, tee bytes.Buffer
+	h, err := NewHandler(Options{Output: &out, Tee: &tee})
+	require.NoError(t, err)
+
+	slog.New(h).Info("hello")
+	assert.Contains(t, out.String(), "msg=hello")
+	assert.Equal(t, out.String(), tee.String())
+}
+
+func TestHandlerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tsbridge.log")
+	h, err := NewHandler(Options{File: path})
+	require.NoError(t, err)
+
+	slog.New(h).Info("to file")
+	require.NoError(t, h.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "msg=\"to file\"")
+}
+
+func TestHandlerConfigureWhileLogging(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewHandler(Options{File: filepath.Join(dir, "0.log")})
+	require.NoError(t, err)
+	defer h.Close()
+
+	// Records being written when the file is replaced are not lost
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for ctx.Err() == nil {
+				assert.NoError(t, h.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "busy", 0)))
+			}
+		})
+	}
+	for i := range 100 {
+		require.NoError(t, h.Configure(Options{File: filepath.Join(dir, fmt.Sprintf("%d.log", i+1))}))
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{name: "debug", want: slog.LevelDebug},
+		{name: "INFO", want: slog.LevelInfo},
+		{name: "warn", want: slog.LevelWarn},
+		{name: "error", want: slog.LevelError},
+		{name: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, err := ParseLevel(tt.name)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "invalid log level")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, level)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+)
+
+// rotatingFile is a log file that is rotated once it reaches maxSize. Rotated
+// files are named path.1 (newest) through path.<maxFiles> (oldest).
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	retryAt  int64     // Size the file is next rotated at after a failed rotation, 0 if the last one succeeded
+	closed   bool      // Closed by Close rather than by a failed rotation
+	warnings io.Writer // Where a failed rotation is reported, as it cannot be logged
+}
+
+// openRotatingFile opens path for appending, creating it if needed
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles, warnings: os.Stderr}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the log file and records its current size
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return tserrors.WrapResource(err, "failed to open log file")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return tserrors.WrapResource(err, "failed to stat log file")
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first if p would take it past
+// maxSize. If rotating fails, p is still written to the log file, which is
+// rotated again once it has grown by another maxSize.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > max(f.maxSize, f.retryAt) {
+		if err := f.rotate(); err != nil {
+			f.rotateFailed(err)
+		} else {
+			f.retryAt = 0
+		}
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotateFailed keeps writing to the log file after rotating it failed with
+// err, reporting the failure unless the previous rotation failed too. Caller
+// must hold f.mu.
+func (f *rotatingFile) rotateFailed(err error) {
+	// A file that was closed for rotating is opened again for appending
+	if f.file == nil {
+		_ = f.open()
+	}
+	if f.retryAt == 0 {
+		fmt.Fprintf(f.warnings, "failed to rotate log file %s, writing to it without rotating: %v\n", f.path, err)
+	}
+	f.retryAt = f.size + f.maxSize
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, moves the
+// log file to path.1 and opens a new one. Caller must hold f.mu.
+func (f *rotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return tserrors.WrapResource(err, "failed to close log file")
+	}
+
+	if f.maxFiles > 0 {
+		_ = os.Remove(f.backup(f.maxFiles))
+		for i := f.maxFiles - 1; i >= 1; i-- {
+			if err := os.Rename(f.backup(i), f.backup(i+1)); err != nil && !os.IsNotExist(err) {
+				return tserrors.WrapResource(err, "failed to rotate log file")
+			}
+		}
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			return tserrors.WrapResource(err, "failed to rotate log file")
+		}
+	} else if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return tserrors.WrapResource(err, "failed to rotate log file")
+	}
+
+	return f.open()
+}
+
+// backup returns the name of the nth rotated file
+func (f *rotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// Close closes the log file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// OpenFile opens path for appending like the log file, rotating it once it
+// reaches maxSize and keeping maxFiles rotated files
+func OpenFile(path string, maxSize int64, maxFiles int) (io.WriteCloser, error) {
+	return openRotatingFile(path, maxSize, maxFiles)
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxFiles int
+		want     map[string]string
+		missing  []string
+	}{
+		{
+			name:     "keeps max files",
+			maxFiles: 2,
+			want: map[string]string{
+				"app.log":   "dddd\n",
+				"app.log.1": "cccc\n",
+				"app.log.2": "bbbb\n",
+			},
+			missing: []string{"app.log.3"},
+		},
+		{
+			name:     "no rotated files",
+			maxFiles: 0,
+			want:     map[string]string{"app.log": "dddd\n"},
+			missing:  []string{"app.log.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := openRotatingFile(filepath.Join(dir, "app.log"), 8, tt.maxFiles)
+			require.NoError(t, err)
+			defer f.Close()
+
+			for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+				n, err := f.Write([]byte(line))
+				require.NoError(t, err)
+				assert.Equal(t, len(line), n)
+			}
+
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err, name)
+				assert.Equal(t, want, string(data), name)
+			}
+			for _, name := range tt.missing {
+				assert.NoFileExists(t, filepath.Join(dir, name))
+			}
+		})
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("aaaa\n"), 0o640))
+
+	f, err := openRotatingFile(path, 8, 1)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("bbbb\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa\n", string(data), "the size of the existing file counts towards rotation")
+
+	_, err = f.Write([]byte("cccc\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotatingFileRotationFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// A directory in the way of the rotated file makes renaming fail
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "taken"), 0o750))
+
+	f, err := openRotatingFile(path, 8, 1)
+	require.NoError(t, err)
+	defer f.Close()
+	var warnings bytes.Buffer
+	f.warnings = &warnings
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+		n, err := f.Write([]byte(line))
+		require.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa\nbbbb\ncccc\ndddd\n", string(data), "records are kept when rotation fails")
+	assert.Contains(t, warnings.String(), "failed to rotate log file")
+	assert.Equal(t, 1, strings.Count(warnings.String(), "\n"), "the failure is reported once")
+
+	// Rotation succeeds again once the way is clear
+	require.NoError(t, os.RemoveAll(path+".1"))
+	_, err = f.Write([]byte("eeee\n"))
+	require.NoError(t, err)
+	data, err = os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa\nbbbb\ncccc\ndddd\n", string(data))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "eeee\n", string(data))
+}
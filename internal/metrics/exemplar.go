@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxExemplarRunes is the limit OpenMetrics puts on the combined length of an
+// exemplar's label names and values
+const maxExemplarRunes = 128
+
+// requestExemplar returns exemplar labels linking a request's metrics to its
+// X-Request-ID and trace, or nil if it has neither. Request IDs that would
+// make the exemplar invalid are left out.
+func requestExemplar(r *http.Request) prometheus.Labels {
+	labels := prometheus.Labels{}
+	runes := 0
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		labels["trace_id"] = sc.TraceID().String()
+		runes += len("trace_id") + len(labels["trace_id"])
+	}
+	if id := r.Header.Get("X-Request-ID"); id != "" && utf8.ValidString(id) &&
+		runes+len("request_id")+utf8.RuneCountInString(id) <= maxExemplarRunes {
+		labels["request_id"] = id
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// observeWithExemplar observes value on o, attaching exemplar if it is not nil
+func observeWithExemplar(o prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	o.Observe(value)
+}
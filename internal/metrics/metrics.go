@@ -4,38 +4,82 @@ package metrics
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jtdowney/tsbridge/internal/constants"
 	"github.com/jtdowney/tsbridge/internal/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// upstreamPhaseBuckets are the histogram buckets of the backend connection
+// phases, which take milliseconds rather than seconds
+var upstreamPhaseBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// sizeBuckets are the histogram buckets of request and response sizes, from
+// 64 bytes to 16MB
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+
 // Collector holds all prometheus metrics for tsbridge
 type Collector struct {
-	RequestsTotal   *prometheus.CounterVec
-	RequestDuration *prometheus.HistogramVec
-	ErrorsTotal     *prometheus.CounterVec
+	RequestsTotal    *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	ErrorsTotal      *prometheus.CounterVec
+	ResponsesByClass *prometheus.CounterVec
+	RequestSize      *prometheus.HistogramVec
+	ResponseSize     *prometheus.HistogramVec
+	RequestsInFlight *prometheus.GaugeVec
+
+	// Hijacked (e.g. WebSocket) connection metrics
+	HijackedTotal    *prometheus.CounterVec
+	HijackedActive   *prometheus.GaugeVec
+	HijackedDuration *prometheus.HistogramVec
 
 	// Enhanced metrics
 	ConnectionCount      *prometheus.GaugeVec
 	WhoisDuration        *prometheus.HistogramVec
+	WhoisCacheHits       *prometheus.CounterVec
+	WhoisCacheMisses     *prometheus.CounterVec
+	WhoisCacheEvictions  *prometheus.CounterVec
 	OAuthRefreshTotal    *prometheus.CounterVec
+	OAuthAttempts        *prometheus.CounterVec
+	OAuthRetries         prometheus.Counter
 	BackendHealth        *prometheus.GaugeVec
 	ConnectionPoolActive *prometheus.GaugeVec
 
+	// Tailnet node metrics
+	NodeState      *prometheus.GaugeVec
+	NodeKeyExpiry  *prometheus.GaugeVec
+	NodeCertExpiry *prometheus.GaugeVec
+	NodePeers      *prometheus.GaugeVec
+
+	// Upstream timing metrics
+	UpstreamDNSDuration       *prometheus.HistogramVec
+	UpstreamConnectDuration   *prometheus.HistogramVec
+	UpstreamTLSDuration       *prometheus.HistogramVec
+	UpstreamFirstByteDuration *prometheus.HistogramVec
+	UpstreamDuration          *prometheus.HistogramVec
+	UpstreamConnections       *prometheus.CounterVec
+
 	// Service lifecycle metrics
 	ServiceOperations    *prometheus.CounterVec
 	ServiceOpDuration    *prometheus.HistogramVec
 	ServicesActive       prometheus.Gauge
+	ServiceState         *prometheus.GaugeVec
+	ServiceStartRetries  *prometheus.CounterVec
 	ConfigReloads        *prometheus.CounterVec
 	ConfigReloadDuration prometheus.Histogram
+	ConfigRollbacks      *prometheus.CounterVec
+	ConfigServices       *prometheus.GaugeVec
 }
 
 // NewCollector creates a new metrics collector with all required metrics
@@ -63,6 +107,58 @@ func NewCollector() *Collector {
 			},
 			[]string{"service", "type"},
 		),
+		ResponsesByClass: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_responses_total",
+				Help: "Total number of responses by status class",
+			},
+			[]string{"service", "class"},
+		),
+		RequestSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_request_size_bytes",
+				Help:    "Request body size in bytes",
+				Buckets: sizeBuckets,
+			},
+			[]string{"service"},
+		),
+		ResponseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_response_size_bytes",
+				Help:    "Response body size in bytes",
+				Buckets: sizeBuckets,
+			},
+			[]string{"service"},
+		),
+		RequestsInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_requests_in_flight",
+				Help: "Number of requests currently being served",
+			},
+			[]string{"service"},
+		),
+		HijackedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_hijacked_connections_total",
+				Help: "Total number of connections taken over from HTTP, such as WebSockets",
+			},
+			[]string{"service"},
+		),
+		HijackedActive: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_hijacked_connections_active",
+				Help: "Number of open connections taken over from HTTP",
+			},
+			[]string{"service"},
+		),
+		HijackedDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_hijacked_connection_duration_seconds",
+				Help:    "Lifetime of connections taken over from HTTP in seconds",
+				Buckets: []float64{1, 5, 15, 60, 300, 900, 1800, 3600, 14400, 86400},
+			},
+			[]string{"service"},
+		),
 		ConnectionCount: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "tsbridge_connections_active",
@@ -78,6 +174,27 @@ func NewCollector() *Collector {
 			},
 			[]string{"service"},
 		),
+		WhoisCacheHits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_whois_cache_hits_total",
+				Help: "Total number of whois lookups answered from the cache",
+			},
+			[]string{"service"},
+		),
+		WhoisCacheMisses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_whois_cache_misses_total",
+				Help: "Total number of whois lookups not found in the cache",
+			},
+			[]string{"service"},
+		),
+		WhoisCacheEvictions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_whois_cache_evictions_total",
+				Help: "Total number of whois cache entries evicted or expired",
+			},
+			[]string{"service"},
+		),
 		OAuthRefreshTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "tsbridge_oauth_refresh_total",
@@ -85,6 +202,19 @@ func NewCollector() *Collector {
 			},
 			[]string{"status"},
 		),
+		OAuthAttempts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_oauth_key_attempts_total",
+				Help: "Total number of attempts to generate an auth key with OAuth",
+			},
+			[]string{"result"},
+		),
+		OAuthRetries: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tsbridge_oauth_key_retries_total",
+				Help: "Total number of retried attempts to generate an auth key with OAuth",
+			},
+		),
 		BackendHealth: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "tsbridge_backend_health",
@@ -99,6 +229,81 @@ func NewCollector() *Collector {
 			},
 			[]string{"service"},
 		),
+		UpstreamDNSDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_upstream_dns_duration_seconds",
+				Help:    "Backend DNS lookup duration in seconds",
+				Buckets: upstreamPhaseBuckets,
+			},
+			[]string{"service"},
+		),
+		UpstreamConnectDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_upstream_connect_duration_seconds",
+				Help:    "Backend connection establishment duration in seconds",
+				Buckets: upstreamPhaseBuckets,
+			},
+			[]string{"service"},
+		),
+		UpstreamTLSDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_upstream_tls_duration_seconds",
+				Help:    "Backend TLS handshake duration in seconds",
+				Buckets: upstreamPhaseBuckets,
+			},
+			[]string{"service"},
+		),
+		UpstreamFirstByteDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_upstream_first_byte_duration_seconds",
+				Help:    "Time from sending a request to the backend until the first byte of its response in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"service"},
+		),
+		UpstreamDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tsbridge_upstream_duration_seconds",
+				Help:    "Time from sending a request to the backend until its response body was read in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"service"},
+		),
+		UpstreamConnections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_upstream_connections_total",
+				Help: "Total number of backend connections used by requests, by whether they were reused from the pool",
+			},
+			[]string{"service", "reused"},
+		),
+		NodeState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_node_state",
+				Help: "Tailscale backend state of a service's node (1 for the current state)",
+			},
+			[]string{"service", "state"},
+		),
+		NodeKeyExpiry: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_node_key_expiry_timestamp_seconds",
+				Help: "Unix time the node key of a service's node expires",
+			},
+			[]string{"service"},
+		),
+		NodeCertExpiry: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_node_tls_cert_expiry_timestamp_seconds",
+				Help: "Unix time the TLS certificate of a service's node expires",
+			},
+			[]string{"service"},
+		),
+		NodePeers: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_node_peers",
+				Help: "Number of active peers of a service's node by connection type",
+			},
+			[]string{"service", "connection"},
+		),
 		ServiceOperations: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "tsbridge_service_operations_total",
@@ -120,6 +325,20 @@ func NewCollector() *Collector {
 				Help: "Number of active services",
 			},
 		),
+		ServiceState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_service_state",
+				Help: "Supervisor state of a service: pending, starting, running, backoff or failed (1 for the current state)",
+			},
+			[]string{"service", "state"},
+		),
+		ServiceStartRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_service_start_retries_total",
+				Help: "Total number of attempts to start a service again after it failed to start or stopped unexpectedly",
+			},
+			[]string{"service"},
+		),
 		ConfigReloads: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "tsbridge_config_reloads_total",
@@ -134,6 +353,20 @@ func NewCollector() *Collector {
 				Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30},
 			},
 		),
+		ConfigRollbacks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tsbridge_config_reload_rollbacks_total",
+				Help: "Total number of transactional reloads rolled back, by whether every service was restored",
+			},
+			[]string{"status"},
+		),
+		ConfigServices: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tsbridge_config_services",
+				Help: "Number of services in the loaded configuration (desired) and running with their configured settings (applied)",
+			},
+			[]string{"state"},
+		),
 	}
 }
 
@@ -143,16 +376,42 @@ func (c *Collector) Register(reg prometheus.Registerer) error {
 		c.RequestsTotal,
 		c.RequestDuration,
 		c.ErrorsTotal,
+		c.ResponsesByClass,
+		c.RequestSize,
+		c.ResponseSize,
+		c.RequestsInFlight,
+		c.HijackedTotal,
+		c.HijackedActive,
+		c.HijackedDuration,
 		c.ConnectionCount,
 		c.WhoisDuration,
+		c.WhoisCacheHits,
+		c.WhoisCacheMisses,
+		c.WhoisCacheEvictions,
 		c.OAuthRefreshTotal,
+		c.OAuthAttempts,
+		c.OAuthRetries,
 		c.BackendHealth,
 		c.ConnectionPoolActive,
+		c.UpstreamDNSDuration,
+		c.UpstreamConnectDuration,
+		c.UpstreamTLSDuration,
+		c.UpstreamFirstByteDuration,
+		c.UpstreamDuration,
+		c.UpstreamConnections,
+		c.NodeState,
+		c.NodeKeyExpiry,
+		c.NodeCertExpiry,
+		c.NodePeers,
 		c.ServiceOperations,
 		c.ServiceOpDuration,
 		c.ServicesActive,
+		c.ServiceState,
+		c.ServiceStartRetries,
 		c.ConfigReloads,
 		c.ConfigReloadDuration,
+		c.ConfigRollbacks,
+		c.ConfigServices,
 	}
 
 	for _, collector := range collectors {
@@ -174,6 +433,79 @@ func (c *Collector) RecordWhoisDuration(service string, duration time.Duration)
 	c.WhoisDuration.WithLabelValues(service).Observe(duration.Seconds())
 }
 
+// RecordWhoisCache records a whois lookup that was answered from the cache
+// (hit) or not
+func (c *Collector) RecordWhoisCache(service string, hit bool) {
+	if hit {
+		c.WhoisCacheHits.WithLabelValues(service).Inc()
+		return
+	}
+	c.WhoisCacheMisses.WithLabelValues(service).Inc()
+}
+
+// RecordWhoisCacheEviction records a whois cache entry being evicted or expiring
+func (c *Collector) RecordWhoisCacheEviction(service string) {
+	c.WhoisCacheEvictions.WithLabelValues(service).Inc()
+}
+
+// RecordOAuthAttempt records one attempt to generate an auth key with OAuth,
+// with a result of "success", "retryable_error" or "error"
+func (c *Collector) RecordOAuthAttempt(result string) {
+	c.OAuthAttempts.WithLabelValues(result).Inc()
+}
+
+// RecordOAuthKeyGeneration records the outcome of generating an auth key with
+// OAuth and the attempts it took
+func (c *Collector) RecordOAuthKeyGeneration(success bool, attempts int) {
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	c.OAuthRefreshTotal.WithLabelValues(status).Inc()
+	if attempts > 1 {
+		c.OAuthRetries.Add(float64(attempts - 1))
+	}
+}
+
+// NodeStatus is the state of a service's tailnet node
+type NodeStatus struct {
+	State        string    // Tailscale backend state, such as "Running"
+	KeyExpiry    time.Time // When the node key expires, zero if it does not
+	CertExpiry   time.Time // When the node's TLS certificate expires, zero without one
+	DirectPeers  int       // Active peers reached directly
+	RelayedPeers int       // Active peers reached through DERP
+}
+
+// SetNodeStatus records the state of a service's tailnet node
+func (c *Collector) SetNodeStatus(service string, status NodeStatus) {
+	c.NodeState.DeletePartialMatch(prometheus.Labels{"service": service})
+	if status.State != "" {
+		c.NodeState.WithLabelValues(service, status.State).Set(1)
+	}
+	setTimestamp(c.NodeKeyExpiry, service, status.KeyExpiry)
+	setTimestamp(c.NodeCertExpiry, service, status.CertExpiry)
+	c.NodePeers.WithLabelValues(service, "direct").Set(float64(status.DirectPeers))
+	c.NodePeers.WithLabelValues(service, "derp").Set(float64(status.RelayedPeers))
+}
+
+// DeleteNodeStatus removes the node metrics of a service that has stopped
+func (c *Collector) DeleteNodeStatus(service string) {
+	labels := prometheus.Labels{"service": service}
+	c.NodeState.DeletePartialMatch(labels)
+	c.NodeKeyExpiry.DeletePartialMatch(labels)
+	c.NodeCertExpiry.DeletePartialMatch(labels)
+	c.NodePeers.DeletePartialMatch(labels)
+}
+
+// setTimestamp sets a service's gauge to t as Unix time, or removes it if t is zero
+func setTimestamp(g *prometheus.GaugeVec, service string, t time.Time) {
+	if t.IsZero() {
+		g.DeleteLabelValues(service)
+		return
+	}
+	g.WithLabelValues(service).Set(float64(t.Unix()))
+}
+
 // SetBackendHealth sets the health status of a backend
 func (c *Collector) SetBackendHealth(service string, healthy bool) {
 	value := 0.0
@@ -188,6 +520,36 @@ func (c *Collector) UpdateConnectionPoolMetrics(service string, active int) {
 	c.ConnectionPoolActive.WithLabelValues(service).Set(float64(active))
 }
 
+// RecordUpstreamDNS records the duration of a backend DNS lookup
+func (c *Collector) RecordUpstreamDNS(service string, duration time.Duration) {
+	c.UpstreamDNSDuration.WithLabelValues(service).Observe(duration.Seconds())
+}
+
+// RecordUpstreamConnect records the duration of connecting to a backend
+func (c *Collector) RecordUpstreamConnect(service string, duration time.Duration) {
+	c.UpstreamConnectDuration.WithLabelValues(service).Observe(duration.Seconds())
+}
+
+// RecordUpstreamTLS records the duration of a TLS handshake with a backend
+func (c *Collector) RecordUpstreamTLS(service string, duration time.Duration) {
+	c.UpstreamTLSDuration.WithLabelValues(service).Observe(duration.Seconds())
+}
+
+// RecordUpstreamFirstByte records the time until the first byte of a backend response
+func (c *Collector) RecordUpstreamFirstByte(service string, duration time.Duration) {
+	c.UpstreamFirstByteDuration.WithLabelValues(service).Observe(duration.Seconds())
+}
+
+// RecordUpstreamDuration records the total time of a backend round trip
+func (c *Collector) RecordUpstreamDuration(service string, duration time.Duration) {
+	c.UpstreamDuration.WithLabelValues(service).Observe(duration.Seconds())
+}
+
+// RecordUpstreamConnection records a request getting a backend connection
+func (c *Collector) RecordUpstreamConnection(service string, reused bool) {
+	c.UpstreamConnections.WithLabelValues(service, strconv.FormatBool(reused)).Inc()
+}
+
 // RecordServiceOperation records a service lifecycle operation
 func (c *Collector) RecordServiceOperation(operation string, success bool, duration time.Duration) {
 	status := "success"
@@ -198,6 +560,24 @@ func (c *Collector) RecordServiceOperation(operation string, success bool, durat
 	c.ServiceOpDuration.WithLabelValues(operation).Observe(duration.Seconds())
 }
 
+// SetServiceState records the supervisor state of a service
+func (c *Collector) SetServiceState(service, state string) {
+	c.ServiceState.DeletePartialMatch(prometheus.Labels{"service": service})
+	c.ServiceState.WithLabelValues(service, state).Set(1)
+}
+
+// DeleteServiceState removes the supervisor metrics of a service that is no
+// longer configured
+func (c *Collector) DeleteServiceState(service string) {
+	c.ServiceState.DeletePartialMatch(prometheus.Labels{"service": service})
+	c.ServiceStartRetries.DeleteLabelValues(service)
+}
+
+// RecordServiceStartRetry records an attempt to start a service again
+func (c *Collector) RecordServiceStartRetry(service string) {
+	c.ServiceStartRetries.WithLabelValues(service).Inc()
+}
+
 // SetActiveServices sets the number of active services
 func (c *Collector) SetActiveServices(count int) {
 	c.ServicesActive.Set(float64(count))
@@ -213,11 +593,31 @@ func (c *Collector) RecordConfigReload(success bool, duration time.Duration) {
 	c.ConfigReloadDuration.Observe(duration.Seconds())
 }
 
+// RecordConfigRollback records a transactional reload being rolled back and
+// whether every service was restored to its previous configuration
+func (c *Collector) RecordConfigRollback(success bool) {
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	c.ConfigRollbacks.WithLabelValues(status).Inc()
+}
+
+// SetConfigServices records how many services the loaded configuration has and
+// how many of them are running with their configured settings
+func (c *Collector) SetConfigServices(desired, applied int) {
+	c.ConfigServices.WithLabelValues("desired").Set(float64(desired))
+	c.ConfigServices.WithLabelValues("applied").Set(float64(applied))
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	written    bool
+	size       int64
+	hijacked   bool
+	onHijack   func(net.Conn) net.Conn // Wraps a hijacked connection, if not nil
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -232,15 +632,26 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	if !rw.written {
 		rw.WriteHeader(http.StatusOK)
 	}
-	return rw.ResponseWriter.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
 }
 
 // Hijack implements the http.Hijacker interface for WebSocket support
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	if hijacker, ok := rw.ResponseWriter.(http.Hijacker); ok {
-		return hijacker.Hijack()
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter does not support hijacking")
+	}
+	conn, bufrw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.hijacked = true
+	if rw.onHijack != nil {
+		conn = rw.onHijack(conn)
 	}
-	return nil, nil, fmt.Errorf("ResponseWriter does not support hijacking")
+	return conn, bufrw, nil
 }
 
 // Flush implements the http.Flusher interface for streaming support
@@ -250,19 +661,84 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// countingBody counts the bytes read from a request body
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+// Read implements io.Reader
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// hijackedConn records the lifetime of a hijacked connection when it is closed
+type hijackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+// Close closes the connection and records its lifetime, once
+func (c *hijackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.onClose)
+	return err
+}
+
+// trackHijacked counts a connection hijacked from a request to serviceName
+// and returns it wrapped so its lifetime is recorded when it is closed
+func (c *Collector) trackHijacked(serviceName string, conn net.Conn) net.Conn {
+	start := time.Now()
+	c.HijackedTotal.WithLabelValues(serviceName).Inc()
+	active := c.HijackedActive.WithLabelValues(serviceName)
+	active.Inc()
+	return &hijackedConn{
+		Conn: conn,
+		onClose: func() {
+			active.Dec()
+			c.HijackedDuration.WithLabelValues(serviceName).Observe(time.Since(start).Seconds())
+		},
+	}
+}
+
+// statusClass returns the class of a status code, e.g. "4xx"
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
 // Middleware returns HTTP middleware that records metrics for requests
 func (c *Collector) Middleware(serviceName string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		inFlight := c.RequestsInFlight.WithLabelValues(serviceName)
+		inFlight.Inc()
 
 		// Wrap response writer to capture status
 		wrapped := &responseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
+			onHijack: func(conn net.Conn) net.Conn {
+				return c.trackHijacked(serviceName, conn)
+			},
+		}
+
+		// Count the request body as it is read
+		var body *countingBody
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &countingBody{ReadCloser: r.Body}
+			r = r.WithContext(r.Context())
+			r.Body = body
 		}
 
 		// Recover from panics
 		defer func() {
+			inFlight.Dec()
 			if err := recover(); err != nil {
 				// Write error response if not already written
 				if !wrapped.written {
@@ -272,10 +748,23 @@ func (c *Collector) Middleware(serviceName string, next http.Handler) http.Handl
 				c.RecordError(serviceName, "panic")
 			}
 
-			// Record metrics
+			// Record metrics, linking them to the request and its trace
+			// when exemplars are scraped
+			exemplar := requestExemplar(r)
 			duration := time.Since(start)
-			c.RequestDuration.WithLabelValues(serviceName).Observe(duration.Seconds())
+			observeWithExemplar(c.RequestDuration.WithLabelValues(serviceName), duration.Seconds(), exemplar)
 			c.RequestsTotal.WithLabelValues(serviceName, strconv.Itoa(wrapped.statusCode)).Inc()
+			c.ResponsesByClass.WithLabelValues(serviceName, statusClass(wrapped.statusCode)).Inc()
+
+			// A hijacked connection has no HTTP body sizes of its own
+			if !wrapped.hijacked {
+				var requestSize int64
+				if body != nil {
+					requestSize = body.n.Load()
+				}
+				observeWithExemplar(c.RequestSize.WithLabelValues(serviceName), float64(requestSize), exemplar)
+				observeWithExemplar(c.ResponseSize.WithLabelValues(serviceName), float64(wrapped.size), exemplar)
+			}
 		}()
 
 		// Call next handler
@@ -283,6 +772,10 @@ func (c *Collector) Middleware(serviceName string, next http.Handler) http.Handl
 	})
 }
 
+// ReadinessCheck reports whether tsbridge is ready to serve traffic, along with
+// details that are returned as JSON by /readyz
+type ReadinessCheck func(ctx context.Context) (ready bool, details any)
+
 // Server represents a metrics HTTP server
 type Server struct {
 	addr              string
@@ -290,6 +783,7 @@ type Server struct {
 	listener          net.Listener
 	registry          *prometheus.Registry
 	readHeaderTimeout time.Duration
+	readiness         ReadinessCheck
 	mu                sync.RWMutex
 }
 
@@ -302,10 +796,21 @@ func NewServer(addr string, registry *prometheus.Registry, readHeaderTimeout tim
 	}
 }
 
+// SetReadinessCheck sets the check behind /readyz. Without one, /readyz reports
+// ready whenever the server is up.
+func (s *Server) SetReadinessCheck(check ReadinessCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readiness = check
+}
+
 // Start starts the metrics server
 func (s *Server) Start(ctx context.Context) error {
-	// Create prometheus handler
-	handler := promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+	// Serve metrics alongside liveness and readiness probes
+	handler := http.NewServeMux()
+	handler.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	handler.HandleFunc("GET /healthz", s.handleHealthz)
+	handler.HandleFunc("GET /readyz", s.handleReadyz)
 
 	// Create listener
 	listener, err := net.Listen("tcp", s.addr)
@@ -339,6 +844,43 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// handleHealthz reports that the process is alive and serving
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeProbeResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether tsbridge is ready, with 503 Service Unavailable
+// when it is not
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	check := s.readiness
+	s.mu.RUnlock()
+
+	if check == nil {
+		writeProbeResponse(w, http.StatusOK, map[string]bool{"ready": true})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), constants.ReadinessCheckTimeout)
+	defer cancel()
+	ready, details := check(ctx)
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeProbeResponse(w, status, details)
+}
+
+// writeProbeResponse writes v as a JSON probe response
+func writeProbeResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write probe response", "error", err)
+	}
+}
+
 // Addr returns the actual address the server is listening on
 func (s *Server) Addr() string {
 	s.mu.RLock()
@@ -2,11 +2,13 @@ package metrics
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNewCollector(t *testing.T) {
@@ -405,6 +408,39 @@ func TestRecordWhoisDuration(t *testing.T) {
 	assert.InDelta(t, 0.1, metric.GetHistogram().GetSampleSum(), 0.001)
 }
 
+func TestRecordUpstreamTiming(t *testing.T) {
+	collector := NewCollector()
+
+	collector.RecordUpstreamDNS("api", 2*time.Millisecond)
+	collector.RecordUpstreamConnect("api", 3*time.Millisecond)
+	collector.RecordUpstreamTLS("api", 5*time.Millisecond)
+	collector.RecordUpstreamFirstByte("api", 40*time.Millisecond)
+	collector.RecordUpstreamDuration("api", 60*time.Millisecond)
+	collector.RecordUpstreamConnection("api", false)
+	collector.RecordUpstreamConnection("api", true)
+	collector.RecordUpstreamConnection("api", true)
+
+	histograms := []struct {
+		vec  *prometheus.HistogramVec
+		want float64
+	}{
+		{collector.UpstreamDNSDuration, 0.002},
+		{collector.UpstreamConnectDuration, 0.003},
+		{collector.UpstreamTLSDuration, 0.005},
+		{collector.UpstreamFirstByteDuration, 0.04},
+		{collector.UpstreamDuration, 0.06},
+	}
+	for _, h := range histograms {
+		metric := &dto.Metric{}
+		require.NoError(t, h.vec.WithLabelValues("api").(prometheus.Histogram).Write(metric))
+		assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+		assert.InDelta(t, h.want, metric.GetHistogram().GetSampleSum(), 0.0001)
+	}
+
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.UpstreamConnections.WithLabelValues("api", "false")))
+	assert.Equal(t, float64(2), promtestutil.ToFloat64(collector.UpstreamConnections.WithLabelValues("api", "true")))
+}
+
 func TestSetBackendHealth(t *testing.T) {
 	collector := NewCollector()
 
@@ -812,6 +848,131 @@ func TestMiddlewareWebSocketSupport(t *testing.T) {
 	})
 }
 
+func TestMiddlewareSizes(t *testing.T) {
+	collector := NewCollector()
+	var inFlight float64
+	handler := collector.Middleware("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = promtestutil.ToFloat64(collector.RequestsInFlight.WithLabelValues("api"))
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.Repeat("x", 2000)))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("y", 100))))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, float64(1), inFlight, "a request is in flight while it is served")
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(collector.RequestsInFlight.WithLabelValues("api")))
+
+	requestSize := &dto.Metric{}
+	require.NoError(t, collector.RequestSize.WithLabelValues("api").(prometheus.Histogram).Write(requestSize))
+	assert.Equal(t, uint64(2), requestSize.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(100), requestSize.GetHistogram().GetSampleSum())
+
+	responseSize := &dto.Metric{}
+	require.NoError(t, collector.ResponseSize.WithLabelValues("api").(prometheus.Histogram).Write(responseSize))
+	assert.Equal(t, uint64(2), responseSize.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(2000+len("404 page not found\n")), responseSize.GetHistogram().GetSampleSum())
+
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.ResponsesByClass.WithLabelValues("api", "2xx")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.ResponsesByClass.WithLabelValues("api", "4xx")))
+}
+
+func TestMiddlewareHijackedConnections(t *testing.T) {
+	collector := NewCollector()
+	closed := make(chan struct{})
+	handler := collector.Middleware("ws", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, bufrw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.HijackedActive.WithLabelValues("ws")))
+
+		bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		bufrw.Flush()
+		conn.Close()
+		conn.Close()
+		close(closed)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	<-closed
+
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.HijackedTotal.WithLabelValues("ws")))
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(collector.HijackedActive.WithLabelValues("ws")))
+	duration := &dto.Metric{}
+	require.NoError(t, collector.HijackedDuration.WithLabelValues("ws").(prometheus.Histogram).Write(duration))
+	assert.Equal(t, uint64(1), duration.GetHistogram().GetSampleCount(), "closing twice records the lifetime once")
+
+	require.Eventually(t, func() bool {
+		return promtestutil.ToFloat64(collector.RequestsTotal.WithLabelValues("ws", "200")) == 1
+	}, time.Second, 10*time.Millisecond)
+	size := &dto.Metric{}
+	require.NoError(t, collector.ResponseSize.WithLabelValues("ws").(prometheus.Histogram).Write(size))
+	assert.Equal(t, uint64(0), size.GetHistogram().GetSampleCount(), "hijacked connections have no response size")
+}
+
+func TestMiddlewareExemplars(t *testing.T) {
+	collector := NewCollector()
+	reg := prometheus.NewRegistry()
+	require.NoError(t, collector.Register(reg))
+	handler := collector.Middleware("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("X-Request-ID", "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Request IDs too long for an exemplar are left out rather than panicking
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", strings.Repeat("a", 200))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	metricsServer := NewServer("127.0.0.1:0", reg, 5*time.Second)
+	require.NoError(t, metricsServer.Start(context.Background()))
+	defer metricsServer.Shutdown(context.Background())
+
+	for _, tt := range []struct {
+		accept        string
+		wantExemplars bool
+	}{
+		{accept: "application/openmetrics-text; version=1.0.0", wantExemplars: true},
+		{accept: "text/plain", wantExemplars: false},
+	} {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/metrics", metricsServer.Addr()), nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", tt.accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		for _, label := range []string{`request_id="req-123"`, `trace_id="4bf92f3577b34da6a3ce929d0e0e4736"`} {
+			if tt.wantExemplars {
+				assert.Contains(t, string(body), label, tt.accept)
+			} else {
+				assert.NotContains(t, string(body), label, tt.accept)
+			}
+		}
+		assert.NotContains(t, string(body), strings.Repeat("a", 200))
+	}
+}
+
 func TestMiddlewareFlushSupport(t *testing.T) {
 	// Create a test handler that uses http.Flusher for streaming
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -929,6 +1090,66 @@ func TestSetActiveServices(t *testing.T) {
 	}
 }
 
+func TestRecordWhoisCache(t *testing.T) {
+	collector := NewCollector()
+
+	collector.RecordWhoisCache("api", false)
+	collector.RecordWhoisCache("api", true)
+	collector.RecordWhoisCache("api", true)
+	collector.RecordWhoisCacheEviction("api")
+
+	assert.Equal(t, float64(2), promtestutil.ToFloat64(collector.WhoisCacheHits.WithLabelValues("api")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.WhoisCacheMisses.WithLabelValues("api")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.WhoisCacheEvictions.WithLabelValues("api")))
+}
+
+func TestRecordOAuth(t *testing.T) {
+	collector := NewCollector()
+
+	collector.RecordOAuthAttempt("retryable_error")
+	collector.RecordOAuthAttempt("retryable_error")
+	collector.RecordOAuthAttempt("success")
+	collector.RecordOAuthKeyGeneration(true, 3)
+	collector.RecordOAuthAttempt("error")
+	collector.RecordOAuthKeyGeneration(false, 1)
+
+	assert.Equal(t, float64(2), promtestutil.ToFloat64(collector.OAuthAttempts.WithLabelValues("retryable_error")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.OAuthAttempts.WithLabelValues("success")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.OAuthAttempts.WithLabelValues("error")))
+	assert.Equal(t, float64(2), promtestutil.ToFloat64(collector.OAuthRetries))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.OAuthRefreshTotal.WithLabelValues("success")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.OAuthRefreshTotal.WithLabelValues("failure")))
+}
+
+func TestNodeStatus(t *testing.T) {
+	collector := NewCollector()
+	keyExpiry := time.Date(2027, 1, 2, 3, 4, 5, 0, time.UTC)
+	certExpiry := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	collector.SetNodeStatus("api", NodeStatus{
+		State:        "Starting",
+		KeyExpiry:    keyExpiry,
+		CertExpiry:   certExpiry,
+		DirectPeers:  3,
+		RelayedPeers: 1,
+	})
+	collector.SetNodeStatus("api", NodeStatus{State: "Running", KeyExpiry: keyExpiry, DirectPeers: 2})
+
+	assert.Equal(t, 1, promtestutil.CollectAndCount(collector.NodeState), "only the current state is reported")
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(collector.NodeState.WithLabelValues("api", "Running")))
+	assert.Equal(t, float64(keyExpiry.Unix()), promtestutil.ToFloat64(collector.NodeKeyExpiry.WithLabelValues("api")))
+	assert.Equal(t, 0, promtestutil.CollectAndCount(collector.NodeCertExpiry), "a zero expiry removes the series")
+	assert.Equal(t, float64(2), promtestutil.ToFloat64(collector.NodePeers.WithLabelValues("api", "direct")))
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(collector.NodePeers.WithLabelValues("api", "derp")))
+
+	collector.SetNodeStatus("web", NodeStatus{State: "Running"})
+	collector.DeleteNodeStatus("api")
+
+	assert.Equal(t, 1, promtestutil.CollectAndCount(collector.NodeState))
+	assert.Equal(t, 0, promtestutil.CollectAndCount(collector.NodeKeyExpiry))
+	assert.Equal(t, 2, promtestutil.CollectAndCount(collector.NodePeers), "only web's peer gauges remain")
+}
+
 func TestRecordConfigReload(t *testing.T) {
 	collector := NewCollector()
 	reg := prometheus.NewRegistry()
@@ -996,6 +1217,38 @@ func TestRecordConfigReload(t *testing.T) {
 	}
 }
 
+func TestConfigState(t *testing.T) {
+	collector := NewCollector()
+	require.NoError(t, collector.Register(prometheus.NewRegistry()))
+
+	collector.SetConfigServices(3, 2)
+	assert.Equal(t, 3.0, promtestutil.ToFloat64(collector.ConfigServices.WithLabelValues("desired")))
+	assert.Equal(t, 2.0, promtestutil.ToFloat64(collector.ConfigServices.WithLabelValues("applied")))
+
+	collector.RecordConfigRollback(true)
+	collector.RecordConfigRollback(true)
+	collector.RecordConfigRollback(false)
+	assert.Equal(t, 2.0, promtestutil.ToFloat64(collector.ConfigRollbacks.WithLabelValues("success")))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(collector.ConfigRollbacks.WithLabelValues("failure")))
+}
+
+func TestServiceState(t *testing.T) {
+	collector := NewCollector()
+	require.NoError(t, collector.Register(prometheus.NewRegistry()))
+
+	collector.SetServiceState("api", "starting")
+	collector.SetServiceState("api", "backoff")
+	collector.RecordServiceStartRetry("api")
+	collector.SetServiceState("web", "running")
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(collector.ServiceState.WithLabelValues("api", "backoff")))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(collector.ServiceStartRetries.WithLabelValues("api")))
+	assert.Equal(t, 2, promtestutil.CollectAndCount(collector.ServiceState), "only the current state of each service is reported")
+
+	collector.DeleteServiceState("api")
+	assert.Equal(t, 1, promtestutil.CollectAndCount(collector.ServiceState))
+	assert.Equal(t, 0, promtestutil.CollectAndCount(collector.ServiceStartRetries))
+}
+
 func TestResponseWriterMetrics(t *testing.T) {
 	t.Run("Write returns error when underlying writer fails", func(t *testing.T) {
 		// Create a failing writer
@@ -1069,3 +1322,50 @@ func (fw *failingWriter) Write(b []byte) (int, error) {
 }
 
 func (fw *failingWriter) WriteHeader(statusCode int) {}
+
+func TestServerProbes(t *testing.T) {
+	s := NewServer("127.0.0.1:0", prometheus.NewRegistry(), 5*time.Second)
+	var ready atomic.Bool
+	s.SetReadinessCheck(func(ctx context.Context) (bool, any) {
+		_, hasDeadline := ctx.Deadline()
+		return ready.Load(), map[string]any{"ready": ready.Load(), "deadline": hasDeadline}
+	})
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	get := func(path string) (int, map[string]any) {
+		resp, err := http.Get("http://" + s.Addr() + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	status, body := get("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body["status"])
+
+	status, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, false, body["ready"])
+	assert.Equal(t, true, body["deadline"])
+
+	ready.Store(true)
+	status, body = get("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, body["ready"])
+
+	t.Run("without readiness check", func(t *testing.T) {
+		s := NewServer("127.0.0.1:0", prometheus.NewRegistry(), 5*time.Second)
+		require.NoError(t, s.Start(context.Background()))
+		defer s.Shutdown(context.Background())
+
+		resp, err := http.Get("http://" + s.Addr() + "/readyz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
@@ -2,13 +2,75 @@ package middleware
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/jtdowney/tsbridge/internal/accesslog"
+	"github.com/jtdowney/tsbridge/internal/funnel"
+	"tailscale.com/client/tailscale/apitype"
 )
 
+// AccessEntry is a request recorded by an access log
+type AccessEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Size       int       `json:"size"`
+	DurationMS float64   `json:"duration_ms"`
+	RemoteAddr string    `json:"remote_addr"`
+}
+
+// RecentRequests keeps the most recent access log entries of a service
+type RecentRequests struct {
+	entries []AccessEntry
+	next    int // Index in entries the next entry is written to once full
+	size    int
+	mu      sync.Mutex
+}
+
+// NewRecentRequests creates a RecentRequests that keeps the last size entries
+func NewRecentRequests(size int) *RecentRequests {
+	return &RecentRequests{
+		entries: make([]AccessEntry, 0, size),
+		size:    size,
+	}
+}
+
+// add records an entry, replacing the oldest once full
+func (r *RecentRequests) add(entry AccessEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) < r.size {
+		r.entries = append(r.entries, entry)
+	} else if r.size > 0 {
+		r.entries[r.next] = entry
+		r.next = (r.next + 1) % r.size
+	}
+}
+
+// Entries returns the recorded entries, newest first
+func (r *RecentRequests) Entries() []AccessEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]AccessEntry, 0, len(r.entries))
+	for i := range len(r.entries) {
+		idx := (r.next - 1 - i + 2*len(r.entries)) % len(r.entries)
+		entries = append(entries, r.entries[idx])
+	}
+	return entries
+}
+
 // accessLogResponseWriter wraps http.ResponseWriter to capture response details
 type accessLogResponseWriter struct {
 	http.ResponseWriter
@@ -46,6 +108,25 @@ func (w *accessLogResponseWriter) Flush() {
 
 // AccessLog returns a middleware that logs HTTP requests
 func AccessLog(logger *slog.Logger, serviceName string) func(http.Handler) http.Handler {
+	return RecordingAccessLog(logger, serviceName, nil)
+}
+
+// RecordingAccessLog returns a middleware that logs HTTP requests like AccessLog
+// and also records them in recent, if not nil
+func RecordingAccessLog(logger *slog.Logger, serviceName string, recent *RecentRequests) func(http.Handler) http.Handler {
+	return NewAccessLog(serviceName, AccessLogOptions{Logger: logger, Recent: recent})
+}
+
+// AccessLogOptions configures the middleware returned by NewAccessLog
+type AccessLogOptions struct {
+	Logger *slog.Logger      // Receives each request as a log record when Sink is nil
+	Sink   *accesslog.Logger // Dedicated access log that replaces Logger, if not nil
+	Recent *RecentRequests   // Also records every request, before the sink's filters, if not nil
+}
+
+// NewAccessLog returns a middleware that writes each request to the dedicated
+// access log or, without one, logs it as a record on opts.Logger
+func NewAccessLog(serviceName string, opts AccessLogOptions) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
@@ -56,42 +137,159 @@ func AccessLog(logger *slog.Logger, serviceName string) func(http.Handler) http.
 				statusCode:     http.StatusOK, // Default to 200
 			}
 
+			// The dedicated access log also records the request size and
+			// details filled in further down the chain
+			var details *accessDetails
+			var body *countingReader
+			if opts.Sink != nil {
+				details = &accessDetails{}
+				r = r.WithContext(context.WithValue(r.Context(), accessDetailsKey{}, details))
+				if r.Body != nil && r.Body != http.NoBody {
+					body = &countingReader{ReadCloser: r.Body}
+					r.Body = body
+				}
+			}
+
 			// Process request
 			next.ServeHTTP(wrapped, r)
 
 			// Calculate duration
 			duration := time.Since(start)
 
-			// Build log attributes
-			attrs := []slog.Attr{
-				slog.String("service", serviceName),
-				slog.String("method", r.Method),
-				slog.String("path", r.URL.Path),
-				slog.Int("status", wrapped.statusCode),
-				slog.Int("size", wrapped.size),
-				slog.Float64("duration_ms", float64(duration.Microseconds())/1000.0),
-			}
-
 			// Add request ID if available (from context or header)
 			requestID := GetRequestID(r.Context())
 			if requestID == "" {
 				// Fallback to header if not in context
 				requestID = r.Header.Get("X-Request-ID")
 			}
-			if requestID != "" {
-				attrs = append(attrs, slog.String("request_id", requestID))
+
+			if opts.Sink != nil {
+				opts.Sink.Log(newAccessEntry(r, serviceName, start, duration, wrapped, requestID, details, body))
+			} else {
+				logRequest(opts.Logger, r, serviceName, duration, wrapped, requestID)
 			}
 
-			// Add user agent if present
-			if ua := r.Header.Get("User-Agent"); ua != "" {
-				attrs = append(attrs, slog.String("user_agent", ua))
+			if opts.Recent != nil {
+				opts.Recent.add(AccessEntry{
+					Time:       start,
+					Method:     r.Method,
+					Path:       r.URL.Path,
+					Status:     wrapped.statusCode,
+					Size:       wrapped.size,
+					DurationMS: float64(duration.Microseconds()) / 1000.0,
+					RemoteAddr: r.RemoteAddr,
+				})
 			}
+		})
+	}
+}
 
-			// Add remote address
-			attrs = append(attrs, slog.String("remote_addr", r.RemoteAddr))
+// logRequest logs a request as a record on logger
+func logRequest(logger *slog.Logger, r *http.Request, serviceName string, duration time.Duration, wrapped *accessLogResponseWriter, requestID string) {
+	// Build log attributes
+	attrs := []slog.Attr{
+		slog.String("service", serviceName),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", wrapped.statusCode),
+		slog.Int("size", wrapped.size),
+		slog.Float64("duration_ms", float64(duration.Microseconds())/1000.0),
+	}
 
-			// Log the request
-			logger.LogAttrs(r.Context(), slog.LevelInfo, "HTTP request", attrs...)
-		})
+	if requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+
+	// Add user agent if present
+	if ua := r.Header.Get("User-Agent"); ua != "" {
+		attrs = append(attrs, slog.String("user_agent", ua))
+	}
+
+	// Add remote address
+	attrs = append(attrs, slog.String("remote_addr", r.RemoteAddr))
+
+	// Log the request
+	logger.LogAttrs(r.Context(), slog.LevelInfo, "HTTP request", attrs...)
+}
+
+// newAccessEntry builds the dedicated access log entry of a request
+func newAccessEntry(r *http.Request, serviceName string, start time.Time, duration time.Duration, wrapped *accessLogResponseWriter, requestID string, details *accessDetails, body *countingReader) accesslog.Entry {
+	entry := accesslog.Entry{
+		Time:       start,
+		Service:    serviceName,
+		Method:     r.Method,
+		Host:       r.Host,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Proto:      r.Proto,
+		Status:     wrapped.statusCode,
+		BytesOut:   int64(wrapped.size),
+		Duration:   duration,
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  requestID,
+		UserAgent:  r.UserAgent(),
+		Referer:    r.Referer(),
+	}
+	if body != nil {
+		entry.BytesIn = body.n.Load()
+	}
+	if src, ok := funnel.SourceAddrFromContext(r.Context()); ok {
+		entry.FunnelSource = src.String()
+	}
+	if r.TLS != nil {
+		entry.TLSVersion = tls.VersionName(r.TLS.Version)
+	}
+
+	details.mu.Lock()
+	entry.UserLogin = details.userLogin
+	entry.UpstreamLatency = details.upstreamLatency
+	details.mu.Unlock()
+	return entry
+}
+
+// accessDetailsKey is the context key of a request's accessDetails
+type accessDetailsKey struct{}
+
+// accessDetails collects access log fields that are only known further down
+// the handler chain
+type accessDetails struct {
+	mu              sync.Mutex
+	userLogin       string
+	upstreamLatency time.Duration
+}
+
+// recordAccessUser records the whois login of the caller for the access log
+func recordAccessUser(r *http.Request, resp *apitype.WhoIsResponse) {
+	details, ok := r.Context().Value(accessDetailsKey{}).(*accessDetails)
+	if !ok || resp.UserProfile == nil {
+		return
+	}
+	details.mu.Lock()
+	details.userLogin = resp.UserProfile.LoginName
+	details.mu.Unlock()
+}
+
+// RecordUpstreamLatency records how long the backend took to send its response
+// headers for the access log of the request ctx belongs to
+func RecordUpstreamLatency(ctx context.Context, latency time.Duration) {
+	details, ok := ctx.Value(accessDetailsKey{}).(*accessDetails)
+	if !ok {
+		return
 	}
+	details.mu.Lock()
+	details.upstreamLatency = latency
+	details.mu.Unlock()
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
 }
@@ -2,14 +2,26 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/accesslog"
+	"github.com/jtdowney/tsbridge/internal/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
 )
 
 func TestAccessLog(t *testing.T) {
@@ -289,3 +301,77 @@ func TestAccessLogFlushSupport(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "chunk1chunk2", rec.Body.String())
 }
+
+func TestRecordingAccessLog(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	recent := NewRecentRequests(2)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	})
+	wrapped := RecordingAccessLog(logger, "test-service", recent)(handler)
+
+	assert.Empty(t, recent.Entries())
+	for _, path := range []string{"/first", "/missing", "/third"} {
+		wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := recent.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/third", entries[0].Path)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, 2, entries[0].Size)
+	assert.Equal(t, "/missing", entries[1].Path)
+	assert.Equal(t, http.StatusNotFound, entries[1].Status)
+	assert.Equal(t, http.MethodGet, entries[1].Method)
+}
+
+func TestAccessLogSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	sink, err := accesslog.New(accesslog.Options{Output: path})
+	require.NoError(t, err)
+
+	client := &MockWhoisClient{
+		WhoIsFunc: func(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error) {
+			return &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"}}, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordUpstreamLatency(r.Context(), 25*time.Millisecond)
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	recent := NewRecentRequests(1)
+	wrapped := NewAccessLog("api", AccessLogOptions{Sink: sink, Recent: recent})(Whois(client, true, time.Second, 0, 0)(handler))
+
+	req := httptest.NewRequest(http.MethodPost, "https://api.example.ts.net/users?page=2", strings.NewReader(`{"name":"bob"}`))
+	req.RemoteAddr = "100.64.0.2:51234"
+	req.Header.Set("User-Agent", "curl/8.0")
+	req = req.WithContext(funnel.WithSourceAddr(req.Context(), netip.MustParseAddrPort("203.0.113.7:41000")))
+	wrapped.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, "api", record["service"])
+	assert.Equal(t, "POST", record["method"])
+	assert.Equal(t, "/users", record["path"])
+	assert.Equal(t, "page=2", record["query"])
+	assert.Equal(t, 201.0, record["status"])
+	assert.Equal(t, 14.0, record["bytes_in"])
+	assert.Equal(t, 14.0, record["bytes_out"])
+	assert.Equal(t, 25.0, record["upstream_ms"])
+	assert.Equal(t, "alice@example.com", record["user_login"])
+	assert.Equal(t, "203.0.113.7:41000", record["funnel_source"])
+	assert.Equal(t, "TLS 1.2", record["tls_version"], "httptest marks https requests as TLS 1.2")
+	assert.Equal(t, "curl/8.0", record["user_agent"])
+
+	require.Len(t, recent.Entries(), 1)
+	assert.Equal(t, http.StatusCreated, recent.Entries()[0].Status)
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/funnel"
+	"github.com/jtdowney/tsbridge/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// Tracing returns a middleware that records a server span for each request,
+// continuing the trace of tailnet callers that send a W3C traceparent header.
+// Funnel requests always start a new trace, so the public internet can neither
+// choose trace IDs nor force requests to be sampled.
+func Tracing(tp trace.TracerProvider, serviceName string) func(http.Handler) http.Handler {
+	tracer := tp.Tracer(constants.TracerName)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			clientAddr, _, _ := net.SplitHostPort(r.RemoteAddr)
+			funnelSrc, isFunnel := funnel.SourceAddrFromContext(ctx)
+			if isFunnel {
+				clientAddr = funnelSrc.Addr().String()
+			} else {
+				ctx = tracing.Propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+			}
+
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+			attrs := []attribute.KeyValue{
+				tracing.AttrService.String(serviceName),
+				tracing.AttrFunnel.Bool(isFunnel),
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.URLScheme(scheme),
+				semconv.ServerAddress(r.Host),
+				semconv.ClientAddress(clientAddr),
+			}
+			if ua := r.UserAgent(); ua != "" {
+				attrs = append(attrs, semconv.UserAgentOriginal(ua))
+			}
+
+			// tsbridge has no routes of its own, so spans are named after the
+			// service and the path is recorded as an attribute
+			ctx, span := tracer.Start(ctx, r.Method+" "+serviceName,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			wrapped := &accessLogResponseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.statusCode))
+			if wrapped.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+			}
+		})
+	}
+}
+
+// addTraceAttributes records the caller's tailnet identity on the request's
+// span, if the request is being traced
+func addTraceAttributes(r *http.Request, resp *apitype.WhoIsResponse) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	if resp.UserProfile != nil && resp.UserProfile.LoginName != "" {
+		span.SetAttributes(tracing.AttrUserLogin.String(resp.UserProfile.LoginName))
+	}
+	if resp.Node != nil && resp.Node.ComputedName != "" {
+		span.SetAttributes(tracing.AttrNodeName.String(resp.Node.ComputedName))
+	}
+}
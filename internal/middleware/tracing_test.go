@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/funnel"
+	"github.com/jtdowney/tsbridge/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+// spanAttributes returns the attributes of a span keyed by name
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestTracing(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		traceparent string
+		funnelSrc   string
+		wantClient  string
+		wantError   bool
+	}{
+		{name: "new trace", status: http.StatusOK, wantClient: "100.64.0.2"},
+		{name: "server error", status: http.StatusBadGateway, wantClient: "100.64.0.2", wantError: true},
+		{name: "propagated trace", status: http.StatusOK, traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantClient: "100.64.0.2"},
+		{name: "funnel", status: http.StatusOK, funnelSrc: "203.0.113.7:41000", wantClient: "203.0.113.7"},
+		{name: "funnel ignores propagated trace", status: http.StatusOK, traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", funnelSrc: "203.0.113.7:41000", wantClient: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			var handlerSpan trace.SpanContext
+			handler := Tracing(tp, "api")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.RemoteAddr = "100.64.0.2:51234"
+			req.Header.Set("User-Agent", "curl/8.0")
+			if tt.traceparent != "" {
+				req.Header.Set("Traceparent", tt.traceparent)
+			}
+			if tt.funnelSrc != "" {
+				req = req.WithContext(funnel.WithSourceAddr(req.Context(), netip.MustParseAddrPort(tt.funnelSrc)))
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, "GET api", span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Equal(t, span.SpanContext(), handlerSpan, "the span should be in the request context")
+
+			attrs := spanAttributes(span)
+			assert.Equal(t, "api", attrs[tracing.AttrService].AsString())
+			assert.Equal(t, tt.funnelSrc != "", attrs[tracing.AttrFunnel].AsBool())
+			assert.Equal(t, "GET", attrs["http.request.method"].AsString())
+			assert.Equal(t, "/users/1", attrs["url.path"].AsString())
+			assert.Equal(t, tt.wantClient, attrs["client.address"].AsString())
+			assert.Equal(t, "curl/8.0", attrs["user_agent.original"].AsString())
+			assert.Equal(t, int64(tt.status), attrs["http.response.status_code"].AsInt64())
+
+			if tt.wantError {
+				assert.Equal(t, codes.Error, span.Status().Code)
+			} else {
+				assert.Equal(t, codes.Unset, span.Status().Code)
+			}
+
+			if tt.traceparent != "" && tt.funnelSrc == "" {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+				assert.True(t, span.Parent().IsRemote())
+			} else {
+				assert.False(t, span.Parent().IsValid())
+				assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+			}
+		})
+	}
+}
+
+func TestTracingWhoisIdentity(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client := &MockWhoisClient{
+		WhoIsFunc: func(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error) {
+			return &apitype.WhoIsResponse{
+				Node:        &tailcfg.Node{ComputedName: "laptop"},
+				UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"},
+			}, nil
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := Tracing(tp, "api")(Whois(client, true, time.Second, 0, 0)(next))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "100.64.0.2:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, "alice@example.com", attrs[tracing.AttrUserLogin].AsString())
+	assert.Equal(t, "laptop", attrs[tracing.AttrNodeName].AsString())
+}
@@ -10,12 +10,12 @@ import (
 	"syscall"
 	"time"
 
-	"log/slog"
-
 	"github.com/cenkalti/backoff/v4"
 	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/logging"
+	"github.com/jtdowney/tsbridge/internal/metrics"
 	"tailscale.com/client/tailscale/apitype"
 )
 
@@ -41,26 +41,69 @@ type WhoisClient interface {
 	WhoIs(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error)
 }
 
+// Whois returns a middleware that adds the caller's tailnet identity to
+// requests, caching up to cacheSize lookups for cacheTTL
 func Whois(client WhoisClient, enabled bool, timeout time.Duration, cacheSize int, cacheTTL time.Duration) func(http.Handler) http.Handler {
-	var cache *expirable.LRU[string, *apitype.WhoIsResponse]
-	if cacheSize > 0 {
-		cache = expirable.NewLRU[string, *apitype.WhoIsResponse](cacheSize, nil, cacheTTL)
+	return NewWhois(client, WhoisOptions{
+		Enabled:   enabled,
+		Timeout:   timeout,
+		CacheSize: cacheSize,
+		CacheTTL:  cacheTTL,
+	})
+}
+
+// WhoisOptions configures the middleware returned by NewWhois
+type WhoisOptions struct {
+	Enabled     bool
+	Timeout     time.Duration      // Timeout of each lookup attempt
+	CacheSize   int                // Lookups cached by client IP, 0 to disable the cache
+	CacheTTL    time.Duration      // How long a lookup is cached
+	Collector   *metrics.Collector // Records lookup durations, cache hits, misses and evictions, if not nil
+	ServiceName string             // Service the metrics are recorded for
+}
+
+// NewWhois returns a middleware that adds the caller's tailnet identity to
+// requests as Tailscale-User-* headers
+func NewWhois(client WhoisClient, opts WhoisOptions) func(http.Handler) http.Handler {
+	lookup := &whoisLookup{
+		client:      client,
+		timeout:     opts.Timeout,
+		collector:   opts.Collector,
+		serviceName: opts.ServiceName,
+	}
+	if opts.CacheSize > 0 {
+		var onEvict expirable.EvictCallback[string, *apitype.WhoIsResponse]
+		if opts.Collector != nil {
+			onEvict = func(string, *apitype.WhoIsResponse) {
+				opts.Collector.RecordWhoisCacheEviction(opts.ServiceName)
+			}
+		}
+		lookup.cache = expirable.NewLRU(opts.CacheSize, onEvict, opts.CacheTTL)
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !enabled {
+			if !opts.Enabled {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			performWhoisLookup(client, timeout, r, cache)
+			lookup.perform(r)
 
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// whoisLookup looks up callers for the whois middleware
+type whoisLookup struct {
+	client      WhoisClient
+	timeout     time.Duration
+	cache       *expirable.LRU[string, *apitype.WhoIsResponse] // Nil when caching is disabled
+	collector   *metrics.Collector
+	serviceName string
+}
+
 func performWhoisWithRetryLogic(client WhoisClient, timeout time.Duration, r *http.Request) (*apitype.WhoIsResponse, error) {
 	// Configure exponential backoff with attempt limit
 	b := backoff.NewExponentialBackOff()
@@ -154,29 +197,33 @@ func isWhoisRetryableError(err error) bool {
 		strings.Contains(errStr, "network is unreachable")
 }
 
-func performWhoisLookup(client WhoisClient, timeout time.Duration, r *http.Request, cache *expirable.LRU[string, *apitype.WhoIsResponse]) {
+// perform looks up the caller of r, from the cache if possible, and adds its
+// identity to the request
+func (l *whoisLookup) perform(r *http.Request) {
 	var resp *apitype.WhoIsResponse
 	var err error
 
-	if cache != nil {
+	if l.cache != nil {
 		cacheKey := extractHostFromRemoteAddr(r.RemoteAddr)
-		if cached, ok := cache.Get(cacheKey); ok {
+		cached, ok := l.cache.Get(cacheKey)
+		if l.collector != nil {
+			l.collector.RecordWhoisCache(l.serviceName, ok)
+		}
+		if ok {
 			resp = cached
 		} else {
-			resp, err = performWhoisWithRetryLogic(client, timeout, r)
+			resp, err = l.lookup(r)
 			if err != nil {
-				logWhoisError(err, r.RemoteAddr, timeout)
 				return
 			}
 
 			if resp != nil {
-				cache.Add(cacheKey, resp)
+				l.cache.Add(cacheKey, resp)
 			}
 		}
 	} else {
-		resp, err = performWhoisWithRetryLogic(client, timeout, r)
+		resp, err = l.lookup(r)
 		if err != nil {
-			logWhoisError(err, r.RemoteAddr, timeout)
 			return
 		}
 	}
@@ -184,15 +231,38 @@ func performWhoisLookup(client WhoisClient, timeout time.Duration, r *http.Reque
 	if resp != nil {
 		addUserHeaders(r, resp)
 		addAddressHeaders(r, resp)
+		addTraceAttributes(r, resp)
+		recordAccessUser(r, resp)
+	}
+}
+
+// lookup asks the whois client about the caller of r, recording the duration
+// and logging failures
+func (l *whoisLookup) lookup(r *http.Request) (*apitype.WhoIsResponse, error) {
+	start := time.Now()
+	resp, err := performWhoisWithRetryLogic(l.client, l.timeout, r)
+	if l.collector != nil {
+		l.collector.RecordWhoisDuration(l.serviceName, time.Since(start))
+	}
+	if err != nil {
+		logWhoisError(err, r.RemoteAddr, l.timeout)
+		if l.collector != nil {
+			errorType := "whois_error"
+			if errors.Is(err, context.DeadlineExceeded) {
+				errorType = "whois_timeout"
+			}
+			l.collector.RecordError(l.serviceName, errorType)
+		}
 	}
+	return resp, err
 }
 
 // logWhoisError logs the appropriate error message based on the error type
 func logWhoisError(err error, remoteAddr string, timeout time.Duration) {
 	if err == context.DeadlineExceeded {
-		slog.Warn("whois lookup timed out", "remote_addr", remoteAddr, "timeout", timeout)
+		logging.Logger(logging.ComponentWhois).Warn("whois lookup timed out", "remote_addr", remoteAddr, "timeout", timeout)
 	} else {
-		slog.Warn("whois lookup failed", "remote_addr", remoteAddr, "error", err)
+		logging.Logger(logging.ComponentWhois).Warn("whois lookup failed", "remote_addr", remoteAddr, "error", err)
 	}
 }
 
@@ -11,7 +11,12 @@ import (
 	"net/netip"
 
 	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"tailscale.com/client/tailscale/apitype"
 	"tailscale.com/tailcfg"
 )
@@ -219,6 +224,42 @@ func TestWhoisCaching(t *testing.T) {
 	}
 }
 
+func TestWhoisMetrics(t *testing.T) {
+	whoisClient := &MockWhoisClient{
+		WhoIsFunc: func(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error) {
+			if remoteAddr == "100.64.1.9:1000" {
+				return nil, errors.New("permission denied")
+			}
+			return &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "user@example.com"}}, nil
+		},
+	}
+
+	collector := metrics.NewCollector()
+	handler := NewWhois(whoisClient, WhoisOptions{
+		Enabled:     true,
+		Timeout:     100 * time.Millisecond,
+		CacheSize:   1,
+		CacheTTL:    time.Minute,
+		Collector:   collector,
+		ServiceName: "api",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, remoteAddr := range []string{"100.64.1.2:1000", "100.64.1.2:2000", "100.64.1.3:1000", "100.64.1.9:1000"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.WhoisCacheHits.WithLabelValues("api")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(collector.WhoisCacheMisses.WithLabelValues("api")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.WhoisCacheEvictions.WithLabelValues("api")), "the second caller evicts the first from a cache of one")
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.ErrorsTotal.WithLabelValues("api", "whois_error")))
+
+	var m dto.Metric
+	require.NoError(t, collector.WhoisDuration.WithLabelValues("api").(prometheus.Histogram).Write(&m))
+	assert.Equal(t, uint64(3), m.GetHistogram().GetSampleCount(), "cache hits are not timed")
+}
+
 func TestWhoisCaching_DifferentSourcePorts(t *testing.T) {
 	// This test verifies that the cache key uses only the IP address,
 	// not the full host:port. This ensures cache hits when the same
@@ -18,8 +18,10 @@ import (
 	"github.com/jtdowney/tsbridge/internal/constants"
 	"github.com/jtdowney/tsbridge/internal/errors"
 	"github.com/jtdowney/tsbridge/internal/funnel"
+	"github.com/jtdowney/tsbridge/internal/logging"
 	"github.com/jtdowney/tsbridge/internal/metrics"
 	"github.com/jtdowney/tsbridge/internal/middleware"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TransportConfig holds configuration for the HTTP transport
@@ -47,6 +49,9 @@ type HandlerConfig struct {
 	// If nil, defaults to 0 (standard buffering). Negative values cause immediate flushing.
 	FlushInterval      *time.Duration
 	InsecureSkipVerify bool
+	// TracerProvider records a client span for each backend request. Tracing is
+	// disabled if nil.
+	TracerProvider trace.TracerProvider
 }
 
 // Handler is the interface for all proxy handlers
@@ -118,6 +123,9 @@ func NewHandler(cfg *HandlerConfig) (Handler, error) {
 	}
 	h.transport = createProxyTransport(cfg.BackendAddr, transportConfig, cfg.InsecureSkipVerify)
 	h.proxy.Transport = h.transport
+	if cfg.TracerProvider != nil {
+		h.proxy.Transport = newTracingTransport(h.transport, cfg.TracerProvider, cfg.ServiceName)
+	}
 
 	// Configure ModifyResponse to handle downstream headers
 	h.proxy.ModifyResponse = createModifyResponse(h.removeDownstream, h.downstreamHeaders)
@@ -268,6 +276,9 @@ func createProxyRewrite(h *httpHandler, target *url.URL) func(*httputil.ProxyReq
 		for key, value := range h.upstreamHeaders {
 			pr.Out.Header.Set(key, value)
 		}
+
+		// Time the round trip for the access log and upstream metrics
+		pr.Out = pr.Out.WithContext(h.traceUpstream(pr.Out.Context(), pr.In.Context()))
 	}
 }
 
@@ -405,6 +416,8 @@ func (h *httpHandler) Close() error {
 // createModifyResponse creates a ModifyResponse function for handling downstream headers
 func createModifyResponse(removeDownstream []string, downstreamHeaders map[string]string) func(*http.Response) error {
 	return func(resp *http.Response) error {
+		timeUpstreamResponse(resp)
+
 		// Remove headers specified in removeDownstream
 		for _, header := range removeDownstream {
 			resp.Header.Del(header)
@@ -432,7 +445,7 @@ func createErrorHandler(backendAddr string) func(http.ResponseWriter, *http.Requ
 		networkErr := errors.WrapNetwork(err, "proxy request failed")
 
 		// Log with request ID from context
-		logger := middleware.LogWithRequestID(r.Context())
+		logger := middleware.LogWithRequestID(r.Context()).With(logging.ComponentKey, logging.ComponentProxy)
 		logger.Error("proxy error", "backend", backendAddr, "path", r.URL.Path, "error", networkErr)
 
 		// Determine status code and message
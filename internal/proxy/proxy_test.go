@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/netip"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,10 +23,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/jtdowney/tsbridge/internal/accesslog"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	"github.com/jtdowney/tsbridge/internal/errors"
 	"github.com/jtdowney/tsbridge/internal/funnel"
 	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/jtdowney/tsbridge/internal/middleware"
 )
 
 // simpleHandler wraps an http.HandlerFunc to implement the Handler interface
@@ -1673,3 +1677,31 @@ func BenchmarkActiveRequestsAccess(b *testing.B) {
 		})
 	})
 }
+
+func TestProxyRecordsUpstreamLatency(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	handler, err := newTestHandler(backend.URL, defaultTestTransportConfig(), nil)
+	require.NoError(t, err)
+	defer handler.Close()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	sink, err := accesslog.New(accesslog.Options{Format: constants.AccessLogFormatTemplate, Template: "{{.UpstreamLatency.Milliseconds}}", Output: path})
+	require.NoError(t, err)
+	wrapped := middleware.NewAccessLog("api", middleware.AccessLogOptions{Sink: sink})(handler)
+
+	w := httptest.NewRecorder()
+	wrapped.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	latency, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, latency, 20)
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/jtdowney/tsbridge/internal/middleware"
+)
+
+// upstreamTimerKey is the context key of the upstreamTimer of a backend request
+type upstreamTimerKey struct{}
+
+// upstreamTimer times the phases of a round trip to the backend with an
+// httptrace.ClientTrace: DNS lookup, connect and TLS handshake for new
+// connections, time to first byte and the total time until the response body
+// has been read. Hooks can run on other goroutines, so state is guarded by mu.
+type upstreamTimer struct {
+	collector   *metrics.Collector // Receives the phase durations, nil to only record the access log latency
+	serviceName string
+	inCtx       context.Context // Context of the incoming request, which carries its access log details
+	start       time.Time
+
+	mu           sync.Mutex
+	dnsBegin     time.Time
+	connectBegin map[string]time.Time // Keyed by address, as dials to several addresses can race
+	tlsBegin     time.Time
+	once         sync.Once
+}
+
+// traceUpstream returns ctx with a client trace that times the backend round
+// trip of the request arriving with inCtx
+func (h *httpHandler) traceUpstream(ctx, inCtx context.Context) context.Context {
+	t := &upstreamTimer{
+		collector:   h.metricsCollector,
+		serviceName: h.serviceName,
+		inCtx:       inCtx,
+		start:       time.Now(),
+	}
+
+	trace := &httptrace.ClientTrace{GotFirstResponseByte: t.gotFirstResponseByte}
+	if t.collector != nil {
+		trace.DNSStart = t.dnsStart
+		trace.DNSDone = t.dnsDone
+		trace.ConnectStart = t.connectStart
+		trace.ConnectDone = t.connectDone
+		trace.TLSHandshakeStart = t.tlsHandshakeStart
+		trace.TLSHandshakeDone = t.tlsHandshakeDone
+		trace.GotConn = t.gotConn
+	}
+	return context.WithValue(httptrace.WithClientTrace(ctx, trace), upstreamTimerKey{}, t)
+}
+
+func (t *upstreamTimer) dnsStart(httptrace.DNSStartInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dnsBegin = time.Now()
+}
+
+func (t *upstreamTimer) dnsDone(httptrace.DNSDoneInfo) {
+	t.mu.Lock()
+	start := t.dnsBegin
+	t.mu.Unlock()
+	if !start.IsZero() {
+		t.collector.RecordUpstreamDNS(t.serviceName, time.Since(start))
+	}
+}
+
+func (t *upstreamTimer) connectStart(network, addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.connectBegin == nil {
+		t.connectBegin = make(map[string]time.Time)
+	}
+	t.connectBegin[network+":"+addr] = time.Now()
+}
+
+func (t *upstreamTimer) connectDone(network, addr string, err error) {
+	t.mu.Lock()
+	start, ok := t.connectBegin[network+":"+addr]
+	t.mu.Unlock()
+	if ok && err == nil {
+		t.collector.RecordUpstreamConnect(t.serviceName, time.Since(start))
+	}
+}
+
+func (t *upstreamTimer) tlsHandshakeStart() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tlsBegin = time.Now()
+}
+
+func (t *upstreamTimer) tlsHandshakeDone(_ tls.ConnectionState, err error) {
+	t.mu.Lock()
+	start := t.tlsBegin
+	t.mu.Unlock()
+	if !start.IsZero() && err == nil {
+		t.collector.RecordUpstreamTLS(t.serviceName, time.Since(start))
+	}
+}
+
+func (t *upstreamTimer) gotConn(info httptrace.GotConnInfo) {
+	t.collector.RecordUpstreamConnection(t.serviceName, info.Reused)
+}
+
+func (t *upstreamTimer) gotFirstResponseByte() {
+	latency := time.Since(t.start)
+	middleware.RecordUpstreamLatency(t.inCtx, latency)
+	if t.collector != nil {
+		t.collector.RecordUpstreamFirstByte(t.serviceName, latency)
+	}
+}
+
+// done records the total upstream time, once
+func (t *upstreamTimer) done() {
+	t.once.Do(func() {
+		if t.collector != nil {
+			t.collector.RecordUpstreamDuration(t.serviceName, time.Since(t.start))
+		}
+	})
+}
+
+// timeUpstreamResponse arranges for the total upstream time of resp to be
+// recorded once its body has been read and closed
+func timeUpstreamResponse(resp *http.Response) {
+	if resp.Request == nil {
+		return
+	}
+	t, ok := resp.Request.Context().Value(upstreamTimerKey{}).(*upstreamTimer)
+	if !ok || t.collector == nil {
+		return
+	}
+
+	// The body of an upgraded connection stays open for the connection's
+	// lifetime, so its round trip ends when the backend switches protocols
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		t.done()
+		return
+	}
+	resp.Body = &timedBody{ReadCloser: resp.Body, timer: t}
+}
+
+// timedBody records the total upstream time when a response body is closed
+type timedBody struct {
+	io.ReadCloser
+	timer *upstreamTimer
+}
+
+// Close closes the body and records the total upstream time
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.timer.done()
+	return err
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtdowney/tsbridge/internal/metrics"
+)
+
+// histogramCount returns the number of observations of a service's histogram
+func histogramCount(t *testing.T, vec *prometheus.HistogramVec, service string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, vec.WithLabelValues(service).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestUpstreamTimingMetrics(t *testing.T) {
+	t.Run("plain http", func(t *testing.T) {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("OK"))
+		}))
+		defer backend.Close()
+
+		collector := metrics.NewCollector()
+		// localhost rather than 127.0.0.1 so the backend address is resolved
+		handler, err := NewHandler(&HandlerConfig{
+			BackendAddr:      strings.Replace(backend.URL, "127.0.0.1", "localhost", 1),
+			TransportConfig:  defaultTestTransportConfig(),
+			MetricsCollector: collector,
+			ServiceName:      "api",
+		})
+		require.NoError(t, err)
+		defer handler.Close()
+
+		for range 2 {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+
+		assert.Equal(t, uint64(1), histogramCount(t, collector.UpstreamDNSDuration, "api"))
+		assert.Equal(t, uint64(1), histogramCount(t, collector.UpstreamConnectDuration, "api"))
+		assert.Equal(t, uint64(0), histogramCount(t, collector.UpstreamTLSDuration, "api"))
+		assert.Equal(t, uint64(2), histogramCount(t, collector.UpstreamFirstByteDuration, "api"))
+		assert.Equal(t, uint64(2), histogramCount(t, collector.UpstreamDuration, "api"))
+		assert.Equal(t, 1.0, testutil.ToFloat64(collector.UpstreamConnections.WithLabelValues("api", "false")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(collector.UpstreamConnections.WithLabelValues("api", "true")))
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer backend.Close()
+
+		collector := metrics.NewCollector()
+		handler, err := NewHandler(&HandlerConfig{
+			BackendAddr:        backend.URL,
+			TransportConfig:    defaultTestTransportConfig(),
+			InsecureSkipVerify: true,
+			MetricsCollector:   collector,
+			ServiceName:        "secure",
+		})
+		require.NoError(t, err)
+		defer handler.Close()
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		assert.Equal(t, uint64(0), histogramCount(t, collector.UpstreamDNSDuration, "secure"), "IP backends need no lookup")
+		assert.Equal(t, uint64(1), histogramCount(t, collector.UpstreamTLSDuration, "secure"))
+		assert.Equal(t, uint64(1), histogramCount(t, collector.UpstreamDuration, "secure"))
+	})
+
+	t.Run("backend unreachable", func(t *testing.T) {
+		collector := metrics.NewCollector()
+		handler, err := NewHandler(&HandlerConfig{
+			BackendAddr:      "127.0.0.1:1",
+			TransportConfig:  defaultTestTransportConfig(),
+			MetricsCollector: collector,
+			ServiceName:      "down",
+		})
+		require.NoError(t, err)
+		defer handler.Close()
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusBadGateway, w.Code)
+
+		assert.Equal(t, uint64(0), histogramCount(t, collector.UpstreamConnectDuration, "down"))
+		assert.Equal(t, uint64(0), histogramCount(t, collector.UpstreamFirstByteDuration, "down"))
+		assert.Equal(t, uint64(0), histogramCount(t, collector.UpstreamDuration, "down"))
+	})
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingTransport records a client span for each round trip to the backend
+// and propagates its trace context in the W3C traceparent header
+type tracingTransport struct {
+	base        http.RoundTripper
+	tracer      trace.Tracer
+	serviceName string
+}
+
+// newTracingTransport wraps base so backend requests are traced with tp
+func newTracingTransport(base http.RoundTripper, tp trace.TracerProvider, serviceName string) *tracingTransport {
+	return &tracingTransport{
+		base:        base,
+		tracer:      tp.Tracer(constants.TracerName),
+		serviceName: serviceName,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			tracing.AttrService.String(t.serviceName),
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(spanURL(req.URL)),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(ctx)
+	tracing.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	// The reverse proxy needs the writable body of an upgraded connection, so
+	// those spans end once the backend agrees to switch protocols. Otherwise
+	// the span lasts until the response body has been copied to the client.
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		span.End()
+		return resp, nil
+	}
+	resp.Body = &spanEndingBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// spanURL returns u as recorded on spans, without the query string or user
+// info, which may carry tokens and other secrets
+func spanURL(u *url.URL) string {
+	recorded := *u
+	recorded.User = nil
+	recorded.RawQuery = ""
+	recorded.ForceQuery = false
+	recorded.Fragment = ""
+	recorded.RawFragment = ""
+	return recorded.String()
+}
+
+// spanEndingBody ends a span when the response body is closed
+type spanEndingBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+}
+
+// Close closes the body and ends the span
+func (b *spanEndingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.span.End() })
+	return err
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jtdowney/tsbridge/internal/tracing"
+)
+
+// newTracedHandler creates a proxy handler to backendAddr whose spans are recorded
+func newTracedHandler(t *testing.T, backendAddr string) (Handler, *tracetest.SpanRecorder, trace.Tracer) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	handler, err := NewHandler(&HandlerConfig{
+		BackendAddr:    backendAddr,
+		ServiceName:    "api",
+		TracerProvider: tp,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	return handler, recorder, tp.Tracer("test")
+}
+
+func TestTracingTransport(t *testing.T) {
+	t.Run("propagates trace context to the backend", func(t *testing.T) {
+		var traceparent string
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("Traceparent")
+			_, _ = io.WriteString(w, "hello")
+		}))
+		defer backend.Close()
+
+		handler, recorder, tracer := newTracedHandler(t, backend.URL)
+		ctx, parent := tracer.Start(t.Context(), "GET api", trace.WithSpanKind(trace.SpanKindServer))
+		req := httptest.NewRequest(http.MethodGet, "/users/1?token=secret", nil).WithContext(ctx)
+		// An inbound traceparent must be replaced, not forwarded
+		req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		parent.End()
+
+		assert.Equal(t, "hello", w.Body.String())
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		client := spans[0]
+		assert.Equal(t, "GET", client.Name())
+		assert.Equal(t, trace.SpanKindClient, client.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), client.Parent().SpanID())
+		assert.Equal(t, parent.SpanContext().TraceID(), client.SpanContext().TraceID())
+
+		attrs := make(map[string]string)
+		for _, attr := range client.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		assert.Equal(t, "api", attrs[string(tracing.AttrService)])
+		assert.Equal(t, backend.URL+"/users/1", attrs["url.full"], "the query string may carry secrets")
+		assert.Equal(t, "200", attrs["http.response.status_code"])
+
+		want := "00-" + client.SpanContext().TraceID().String() + "-" + client.SpanContext().SpanID().String() + "-01"
+		assert.Equal(t, want, traceparent)
+	})
+
+	t.Run("records backend errors", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		handler, recorder, _ := newTracedHandler(t, "http://"+addr)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		require.NotEmpty(t, spans[0].Events())
+		assert.Equal(t, "exception", spans[0].Events()[0].Name)
+	})
+
+	t.Run("keeps upgraded connections working", func(t *testing.T) {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, rw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			_ = rw.Flush()
+			line, _ := rw.ReadString('\n')
+			_, _ = rw.WriteString(line)
+			_ = rw.Flush()
+		}))
+		defer backend.Close()
+
+		handler, recorder, _ := newTracedHandler(t, backend.URL)
+		front := httptest.NewServer(handler)
+		defer front.Close()
+
+		conn, err := net.Dial("tcp", front.Listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: api\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		require.NoError(t, err)
+
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+		_, err = io.WriteString(conn, "ping\n")
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "ping\n", line)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	})
+}
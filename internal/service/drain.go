@@ -0,0 +1,383 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// goingAwayFrame is an unmasked WebSocket close frame with status 1001,
+// telling the client the server is going away
+var goingAwayFrame = []byte{0x88, 0x02, 0x03, 0xe9}
+
+// connTracker tracks the connections of a service's HTTP server that
+// http.Server.Shutdown neither waits for nor closes: hijacked connections, such
+// as WebSockets, and server-sent event streams. It is the server's outermost
+// handler and is shared with services that replace this one in place.
+type connTracker struct {
+	name    string
+	next    http.Handler
+	closing atomic.Bool // The service is stopping, so clients are asked to go away
+	mu      sync.Mutex
+	conns   map[*trackedConn]struct{}
+	streams map[*trackedWriter]struct{}
+}
+
+// newConnTracker returns a tracker for the service name that serves requests
+// with next
+func newConnTracker(name string, next http.Handler) *connTracker {
+	return &connTracker{
+		name:    name,
+		next:    next,
+		conns:   make(map[*trackedConn]struct{}),
+		streams: make(map[*trackedWriter]struct{}),
+	}
+}
+
+// ServeHTTP serves r with the tracker's handler, turning away protocol
+// upgrades once the service is stopping
+func (t *connTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t.closing.Load() && r.Header.Get("Upgrade") != "" {
+		w.Header().Set("Connection", "close")
+		http.Error(w, "Service is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	tw := &trackedWriter{
+		ResponseWriter: w,
+		tracker:        t,
+		cancel:         cancel,
+		websocket:      strings.EqualFold(r.Header.Get("Upgrade"), "websocket"),
+	}
+	defer t.untrackStream(tw)
+	t.next.ServeHTTP(tw, r.WithContext(ctx))
+}
+
+func (t *connTracker) trackStream(tw *trackedWriter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streams[tw] = struct{}{}
+}
+
+func (t *connTracker) untrackStream(tw *trackedWriter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.streams, tw)
+}
+
+func (t *connTracker) trackConn(c *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conns[c] = struct{}{}
+}
+
+func (t *connTracker) untrackConn(c *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, c)
+}
+
+// open returns the tracked connections and streams that are still open
+func (t *connTracker) open() ([]*trackedConn, []*trackedWriter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := make([]*trackedConn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	streams := make([]*trackedWriter, 0, len(t.streams))
+	for tw := range t.streams {
+		streams = append(streams, tw)
+	}
+	return conns, streams
+}
+
+// drain asks clients to go away: responses ask them to close the connection,
+// WebSocket clients are sent a close frame and event streams are closed
+// between events. It waits up to timeout, or until ctx is done, for hijacked
+// connections and event streams to close, then closes those that are left.
+func (t *connTracker) drain(ctx context.Context, timeout time.Duration) {
+	t.closing.Store(true)
+	conns, streams := t.open()
+	if len(conns) == 0 && len(streams) == 0 {
+		return
+	}
+	slog.Debug("draining connections",
+		"service", t.name,
+		"connections", len(conns),
+		"streams", len(streams),
+		"timeout", timeout,
+	)
+
+	// Either may wait for a write in progress to a slow client
+	for _, c := range conns {
+		go c.goAway()
+	}
+	for _, tw := range streams {
+		go tw.closeIfIdle()
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		if conns, streams = t.open(); len(conns) == 0 && len(streams) == 0 {
+			return
+		}
+		time.Sleep(constants.DrainPollInterval)
+	}
+
+	conns, streams = t.open()
+	if len(conns) == 0 && len(streams) == 0 {
+		return
+	}
+	slog.Warn("closing connections that did not drain",
+		"service", t.name,
+		"connections", len(conns),
+		"streams", len(streams),
+	)
+	for _, c := range conns {
+		_ = c.Close()
+	}
+	for _, tw := range streams {
+		tw.cancel()
+	}
+}
+
+// trackedWriter is the response writer of a request to a tracked service
+type trackedWriter struct {
+	http.ResponseWriter
+	tracker     *connTracker
+	cancel      context.CancelFunc // Ends the request
+	websocket   bool               // The request asks to upgrade to a WebSocket
+	wroteHeader bool
+
+	mu     sync.Mutex // Serializes writes to an event stream with closing it
+	stream bool       // The response is a server-sent event stream
+	tail   []byte     // Last bytes written to the event stream
+}
+
+// WriteHeader asks the client to close the connection once the service is
+// stopping, and starts tracking event streams
+func (w *trackedWriter) WriteHeader(code int) {
+	if code >= http.StatusOK && !w.wroteHeader {
+		w.wroteHeader = true
+		if w.tracker.closing.Load() {
+			w.Header().Set("Connection", "close")
+		}
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			w.stream = true
+			w.tracker.trackStream(w)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes p, closing an event stream at the end of an event once the
+// service is stopping
+func (w *trackedWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.stream {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.ResponseWriter.Write(p)
+	w.tail = append(w.tail, p[:n]...)
+	if len(w.tail) > 4 {
+		w.tail = w.tail[len(w.tail)-4:]
+	}
+	if w.tracker.closing.Load() {
+		w.closeIfIdleLocked()
+	}
+	return n, err
+}
+
+// closeIfIdle closes an event stream now if it is between events, or once
+// the event being written is complete
+func (w *trackedWriter) closeIfIdle() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closeIfIdleLocked()
+}
+
+func (w *trackedWriter) closeIfIdleLocked() {
+	// Events end with a blank line, and clients discard an incomplete event
+	// when the stream closes
+	idle := len(w.tail) == 0 ||
+		bytes.HasSuffix(w.tail, []byte("\n\n")) ||
+		bytes.HasSuffix(w.tail, []byte("\r\n\r\n")) ||
+		bytes.HasSuffix(w.tail, []byte("\r\r"))
+	if idle {
+		w.flush()
+		w.cancel()
+	}
+}
+
+func (w *trackedWriter) flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Flush implements the http.Flusher interface for streaming support
+func (w *trackedWriter) Flush() {
+	if w.stream {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+	}
+	w.flush()
+}
+
+// Hijack implements the http.Hijacker interface, tracking the connection
+// until it is closed
+func (w *trackedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, bufrw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &trackedConn{Conn: conn, tracker: w.tracker, websocket: w.websocket}
+	w.tracker.trackConn(c)
+	return c, bufrw, nil
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (w *trackedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// trackedConn is a hijacked connection to a tracked service
+type trackedConn struct {
+	net.Conn
+	tracker   *connTracker
+	websocket bool // The connection was upgraded to a WebSocket
+	closeOnce sync.Once
+
+	mu        sync.Mutex  // Serializes writes with sending a close frame
+	frames    frameCursor // Position in the WebSocket frames written to the client
+	closeSent bool        // A close frame was sent, so nothing more is written
+}
+
+// Write writes p to the client. Once the service is stopping, a WebSocket
+// client is sent a close frame as soon as the frame being written is complete.
+func (c *trackedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The client expects nothing after a close frame, but the backend does
+	// not know one was sent until the client answers it
+	if c.closeSent {
+		return len(p), nil
+	}
+	n, err := c.Conn.Write(p)
+	if c.websocket {
+		c.frames.advance(p[:n])
+		if c.tracker.closing.Load() {
+			c.goAwayLocked()
+		}
+	}
+	return n, err
+}
+
+// goAway sends a WebSocket client a close frame, now if it is between frames
+// or once the frame being written is complete
+func (c *trackedConn) goAway() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.websocket {
+		c.goAwayLocked()
+	}
+}
+
+func (c *trackedConn) goAwayLocked() {
+	if c.closeSent || !c.frames.atBoundary() {
+		return
+	}
+	c.closeSent = true
+	if _, err := c.Conn.Write(goingAwayFrame); err != nil {
+		slog.Debug("failed to send WebSocket close frame", "service", c.tracker.name, "error", err)
+	}
+}
+
+// Close closes the connection and stops tracking it
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { c.tracker.untrackConn(c) })
+	return err
+}
+
+// frameCursor follows the boundaries between the WebSocket frames written to
+// a client
+type frameCursor struct {
+	header    []byte // Bytes of the header of the next frame seen so far
+	remaining uint64 // Payload bytes of the current frame still to be written
+}
+
+// advance moves the cursor past p
+func (f *frameCursor) advance(p []byte) {
+	for len(p) > 0 {
+		if f.remaining > 0 {
+			n := min(uint64(len(p)), f.remaining)
+			f.remaining -= n
+			p = p[n:]
+			continue
+		}
+
+		f.header = append(f.header, p[0])
+		p = p[1:]
+		if size, payload, ok := parseFrameHeader(f.header); ok && len(f.header) == size {
+			f.remaining = payload
+			f.header = f.header[:0]
+		}
+	}
+}
+
+// atBoundary returns whether the last frame written is complete
+func (f *frameCursor) atBoundary() bool {
+	return len(f.header) == 0 && f.remaining == 0
+}
+
+// parseFrameHeader returns the size of the WebSocket frame header starting
+// with h and the length of the frame's payload. ok is false until h holds
+// enough of the header to tell.
+func parseFrameHeader(h []byte) (size int, payload uint64, ok bool) {
+	if len(h) < 2 {
+		return 0, 0, false
+	}
+
+	size = 2
+	length := uint64(h[1] & 0x7f)
+	switch length {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if h[1]&0x80 != 0 {
+		size += 4 // Masking key
+	}
+	if len(h) < size {
+		return size, 0, true
+	}
+
+	switch length {
+	case 126:
+		length = uint64(h[2])<<8 | uint64(h[3])
+	case 127:
+		length = 0
+		for _, b := range h[2:10] {
+			length = length<<8 | uint64(b)
+		}
+	}
+	return size, length, true
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// drainServer serves handler behind a connection tracker
+func drainServer(t *testing.T, handler http.HandlerFunc) (*connTracker, *httptest.Server) {
+	t.Helper()
+	tracker := newConnTracker("test", handler)
+	server := httptest.NewServer(tracker)
+	t.Cleanup(server.Close)
+	return tracker, server
+}
+
+// drainAsync drains tracker in the background, closing the returned channel
+// once it has finished
+func drainAsync(tracker *connTracker, timeout time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracker.drain(context.Background(), timeout)
+	}()
+	return done
+}
+
+// dialUpgrade opens a connection to server and upgrades it to a WebSocket
+func dialUpgrade(t *testing.T, server *httptest.Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	return conn, reader
+}
+
+// hijackUpgrade hijacks the connection of a request and accepts the upgrade
+func hijackUpgrade(t *testing.T, w http.ResponseWriter) net.Conn {
+	conn, bufrw, err := http.NewResponseController(w).Hijack()
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	_, _ = bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	_ = bufrw.Flush()
+	return conn
+}
+
+func TestFrameCursor(t *testing.T) {
+	payload := func(n int) []byte { return make([]byte, n) }
+	tests := []struct {
+		name       string
+		written    []byte
+		atBoundary bool
+	}{
+		{name: "nothing written", atBoundary: true},
+		{name: "complete frame", written: []byte{0x81, 0x02, 'h', 'i'}, atBoundary: true},
+		{name: "partial header", written: []byte{0x81}, atBoundary: false},
+		{name: "header only", written: []byte{0x81, 0x05}, atBoundary: false},
+		{name: "partial payload", written: []byte{0x81, 0x05, 'h', 'e'}, atBoundary: false},
+		{name: "empty frame", written: []byte{0x89, 0x00}, atBoundary: true},
+		{name: "16-bit length", written: append([]byte{0x82, 126, 0x01, 0x00}, payload(256)...), atBoundary: true},
+		{name: "16-bit length partial", written: append([]byte{0x82, 126, 0x01, 0x00}, payload(255)...), atBoundary: false},
+		{name: "64-bit length", written: append([]byte{0x82, 127, 0, 0, 0, 0, 0, 1, 0, 0}, payload(65536)...), atBoundary: true},
+		{name: "masked frame", written: []byte{0x81, 0x82, 1, 2, 3, 4, 'h', 'i'}, atBoundary: true},
+		{name: "two frames", written: []byte{0x81, 0x01, 'a', 0x81, 0x01, 'b'}, atBoundary: true},
+		{name: "second frame partial", written: []byte{0x81, 0x01, 'a', 0x81, 0x02, 'b'}, atBoundary: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var whole frameCursor
+			whole.advance(tt.written)
+			assert.Equal(t, tt.atBoundary, whole.atBoundary())
+
+			// Frames are split across writes arbitrarily
+			var split frameCursor
+			for _, b := range tt.written {
+				split.advance([]byte{b})
+			}
+			assert.Equal(t, tt.atBoundary, split.atBoundary())
+		})
+	}
+}
+
+func TestConnTracker_WebSocketGoingAway(t *testing.T) {
+	halfWritten := make(chan struct{})
+	writeRest := make(chan struct{})
+	tracker, server := drainServer(t, func(w http.ResponseWriter, r *http.Request) {
+		conn := hijackUpgrade(t, w)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte{0x81, 0x05, 'h', 'e'})
+		close(halfWritten)
+		<-writeRest
+		_, _ = conn.Write([]byte{'l', 'l', 'o'})
+		_, _ = io.Copy(io.Discard, conn) // Until the client closes
+	})
+
+	conn, reader := dialUpgrade(t, server)
+	<-halfWritten
+	start := time.Now()
+	done := drainAsync(tracker, 5*time.Second)
+
+	// The close frame is held back until the frame in progress is complete
+	assert.Eventually(t, tracker.closing.Load, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(writeRest)
+
+	received := make([]byte, 11)
+	_, err := io.ReadFull(reader, received)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o'}, received[:7])
+	assert.Equal(t, goingAwayFrame, received[7:])
+
+	// The client answering by closing lets the drain finish early
+	require.NoError(t, conn.Close())
+	select {
+	case <-done:
+		assert.Less(t, time.Since(start), 5*time.Second)
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not finish after the client went away")
+	}
+}
+
+func TestConnTracker_NothingWrittenAfterGoingAway(t *testing.T) {
+	sent := make(chan struct{})
+	tracker, server := drainServer(t, func(w http.ResponseWriter, r *http.Request) {
+		conn := hijackUpgrade(t, w)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		<-sent
+		// The backend keeps sending until it hears the client's close frame
+		_, _ = conn.Write([]byte{0x81, 0x01, 'x'})
+		_, _ = io.Copy(io.Discard, conn)
+	})
+
+	conn, reader := dialUpgrade(t, server)
+	done := drainAsync(tracker, 5*time.Second)
+
+	received := make([]byte, len(goingAwayFrame))
+	_, err := io.ReadFull(reader, received)
+	require.NoError(t, err)
+	assert.Equal(t, goingAwayFrame, received)
+	close(sent)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	n, _ := reader.Read(make([]byte, 1))
+	assert.Zero(t, n, "data written after the close frame")
+	require.NoError(t, conn.Close())
+	<-done
+}
+
+func TestConnTracker_EventStreamClosedBetweenEvents(t *testing.T) {
+	partial := make(chan struct{})
+	finishEvent := make(chan struct{})
+	canceled := make(chan struct{})
+	tracker, server := drainServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		rc := http.NewResponseController(w)
+		_, _ = io.WriteString(w, "data: one\n\n")
+		_, _ = io.WriteString(w, "data: tw")
+		_ = rc.Flush()
+		close(partial)
+		<-finishEvent
+		_, _ = io.WriteString(w, "o\n\n")
+		_ = rc.Flush()
+		<-r.Context().Done()
+		close(canceled)
+	})
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	<-partial
+
+	done := drainAsync(tracker, 5*time.Second)
+	assert.Eventually(t, tracker.closing.Load, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-canceled:
+		t.Fatal("stream closed in the middle of an event")
+	default:
+	}
+
+	close(finishEvent)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "data: one\n\ndata: two\n\n", string(body))
+	<-canceled
+	<-done
+}
+
+func TestConnTracker_ClosesConnectionsAtDeadline(t *testing.T) {
+	tracker, server := drainServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: tw")
+		_ = http.NewResponseController(w).Flush()
+		<-r.Context().Done()
+	})
+	rawTracker := newConnTracker("raw", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn := hijackUpgrade(t, w)
+		if conn != nil {
+			_, _ = io.Copy(io.Discard, conn) // Never closed by the client
+		}
+	}))
+	rawServer := httptest.NewServer(rawTracker)
+	defer rawServer.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	conn, err := net.Dial("tcp", rawServer.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: tcp\r\nConnection: Upgrade\r\n\r\n")
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	_, err = http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	tracker.drain(context.Background(), 50*time.Millisecond)
+	rawTracker.drain(context.Background(), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// The stream's request is ended even though its event is incomplete
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "data: tw", string(body))
+	_, streams := tracker.open()
+	assert.Empty(t, streams)
+
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+	conns, _ := rawTracker.open()
+	assert.Empty(t, conns)
+}
+
+func TestConnTracker_ResponsesWhileClosing(t *testing.T) {
+	tracker, server := drainServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})
+	tracker.drain(context.Background(), time.Second)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.Close, "response did not ask the client to close the connection")
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Contains(t, string(body), "shutting down")
+}
+
+func TestService_StopTimeout(t *testing.T) {
+	svc := &Service{}
+	assert.Equal(t, constants.DefaultDrainTimeout+constants.ServiceStopTimeout, svc.stopTimeout())
+
+	// Stopping leaves drain_timeout for connections to close before the server
+	// shuts down
+	drainTimeout := time.Minute
+	svc.globalConfig = &config.Config{Global: config.Global{DrainTimeout: &drainTimeout}}
+	assert.Equal(t, time.Minute+constants.ServiceStopTimeout, svc.stopTimeout())
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// startingPage is served in place of a service whose backend is not ready yet,
+// with wait_for_backend = "unavailable" or while an on-demand container starts
+const startingPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="%[2]d">
+<title>%[1]s is starting</title>
+</head>
+<body>
+<h1>%[1]s is starting</h1>
+<p>The service is not ready yet. This page will reload in a few seconds.</p>
+</body>
+</html>
+`
+
+// mustWait returns whether a service with cfg has to wait for its
+// dependencies or its backend before it is started. Callers hold r.mu.
+func (r *Registry) mustWait(cfg config.Service) bool {
+	return cfg.WaitForBackend == constants.WaitForBackendDelay || r.unmetDependency(cfg) != ""
+}
+
+// unmetDependency returns the first service cfg depends on that is not
+// running, or "" if they all are. Callers hold r.mu.
+func (r *Registry) unmetDependency(cfg config.Service) string {
+	for _, dep := range cfg.DependsOn {
+		if r.states[dep] != constants.ServiceStateRunning {
+			return dep
+		}
+	}
+	return ""
+}
+
+// wait holds a service with cfg back until the services it depends on are
+// running and, with wait_for_backend = "delay", its backend accepts
+// connections. Callers hold r.mu.
+func (r *Registry) wait(cfg config.Service) {
+	rt := newRetry(cfg, r.retryInterval)
+	rt.waiting = true
+	r.retries[cfg.Name] = rt
+	r.setState(cfg.Name, constants.ServiceStateWaiting)
+	slog.Info("waiting to start service",
+		"service", cfg.Name,
+		"depends_on", cfg.DependsOn,
+		"wait_for_backend", cfg.WaitForBackend,
+	)
+	rt.timer = time.AfterFunc(0, func() { r.checkWaiting(rt) })
+}
+
+// checkWaiting starts the waiting service of rt if it is ready, and checks
+// again after a while if not
+func (r *Registry) checkWaiting(rt *retry) {
+	name := rt.config.Name
+	if !r.stillWaiting(rt) {
+		return
+	}
+
+	// The backend is probed without holding the lock, as it may take as long
+	// as the health check timeout
+	if rt.config.WaitForBackend == constants.WaitForBackendDelay {
+		if err := dialBackend(context.Background(), rt.config.BackendAddr); err != nil {
+			slog.Debug("backend not ready", "service", name, "error", err)
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.retries[name] == rt && !r.stopped {
+				r.waitAgain(rt)
+			}
+			return
+		}
+	}
+
+	r.mu.Lock()
+	if r.stopped || r.retries[name] != rt {
+		r.mu.Unlock()
+		return
+	}
+	if dep := r.unmetDependency(rt.config); dep != "" {
+		r.waitAgain(rt)
+		r.mu.Unlock()
+		return
+	}
+	rt.busy = true
+	cfg := rt.config
+	r.setState(name, constants.ServiceStateStarting)
+	r.mu.Unlock()
+
+	// The lock is not held while the node starts, which can take as long as
+	// its startup timeout
+	svc, err := r.startService(cfg)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.reclaim(rt, cfg, svc) {
+		return
+	}
+	delete(r.retries, name)
+	if err != nil {
+		r.startFailed(cfg, err)
+		return
+	}
+	r.setRunning(name, svc)
+	r.setState(name, constants.ServiceStateRunning)
+	if r.metricsCollector != nil {
+		r.metricsCollector.SetActiveServices(len(r.services))
+	}
+	slog.Info("started service after waiting", "service", name)
+}
+
+// stillWaiting returns whether rt is still waiting, checking again later if a
+// service it depends on is not running
+func (r *Registry) stillWaiting(rt *retry) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped || r.retries[rt.config.Name] != rt {
+		return false
+	}
+	if dep := r.unmetDependency(rt.config); dep != "" {
+		slog.Debug("dependency not running", "service", rt.config.Name, "depends_on", dep)
+		r.waitAgain(rt)
+		return false
+	}
+	return true
+}
+
+// waitAgain checks whether the waiting service of rt is ready after the wait
+// interval. Callers hold r.mu.
+func (r *Registry) waitAgain(rt *retry) {
+	rt.timer = time.AfterFunc(r.waitInterval, func() { r.checkWaiting(rt) })
+}
+
+// wakeDependents checks straight away whether services waiting for name can
+// start. Callers hold r.mu.
+func (r *Registry) wakeDependents(name string) {
+	for _, rt := range r.retries {
+		if !rt.waiting || !slices.Contains(rt.config.DependsOn, name) {
+			continue
+		}
+		// A timer that already fired is checking the service anyway
+		if rt.timer != nil && rt.timer.Stop() {
+			rt.timer = time.AfterFunc(0, func() { r.checkWaiting(rt) })
+		}
+	}
+}
+
+// waitForBackend answers requests with a page saying the service is starting
+// until its backend accepts connections. Stop ends the wait.
+func (s *Service) waitForBackend() {
+	s.backendDown.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.stopBackendWait = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		if err := waitUntilReachable(ctx, s.Config.BackendAddr, s.waitInterval); err != nil {
+			return
+		}
+		s.backendDown.Store(false)
+		slog.Info("backend is ready, serving requests", "service", s.Name)
+	}()
+}
+
+// stopWaitingForBackend stops checking whether the backend is ready
+func (s *Service) stopWaitingForBackend() {
+	if s.stopBackendWait == nil {
+		return
+	}
+	s.stopBackendWait()
+	s.stopBackendWait = nil
+}
+
+// backendGateMiddleware serves the starting page while the backend is not
+// ready to accept connections
+func (s *Service) backendGateMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.backendDown.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		serveStartingPage(w, s.Name)
+	})
+}
+
+// serveStartingPage answers with a page saying the service name is starting,
+// which reloads itself until it is ready
+func serveStartingPage(w http.ResponseWriter, name string) {
+	retryAfter := int(constants.ServiceWaitInterval.Seconds())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = fmt.Fprintf(w, startingPage, html.EscapeString(name), retryAfter)
+}
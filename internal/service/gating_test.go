@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/jtdowney/tsbridge/internal/tsnet"
+)
+
+// gatedRegistry returns a registry of svcs that checks waiting services every
+// millisecond, calling listen before each service's node listens
+func gatedRegistry(t *testing.T, listen func(name string) error, svcs ...config.Service) *Registry {
+	t.Helper()
+	factory := func(serviceName string) tsnet.TSNetServer {
+		mock := tsnet.NewMockTSNetServer()
+		next := mock.ListenFunc
+		mock.ListenFunc = func(network, addr string) (net.Listener, error) {
+			if err := listen(serviceName); err != nil {
+				return nil, err
+			}
+			return next(network, addr)
+		}
+		return mock
+	}
+	tsServer, err := tailscale.NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, factory)
+	require.NoError(t, err)
+
+	registry := NewRegistry(&config.Config{Services: svcs}, tsServer)
+	registry.retryInterval = time.Hour
+	registry.waitInterval = time.Millisecond
+	t.Cleanup(func() {
+		_ = registry.Shutdown(context.Background())
+	})
+	return registry
+}
+
+// unusedAddr returns a local address nothing is listening on
+func unusedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+// serveBackend starts a backend answering "ok" on addr
+func serveBackend(t *testing.T, addr string) {
+	t.Helper()
+	l, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	backend := &httptest.Server{
+		Listener: l,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "ok")
+		})},
+	}
+	backend.Start()
+	t.Cleanup(backend.Close)
+}
+
+func TestRegistry_DependsOn(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	dbFails := true
+	registry := gatedRegistry(t, func(name string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if name == "db" && dbFails {
+			return assert.AnError
+		}
+		started = append(started, name)
+		return nil
+	},
+		config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off", DependsOn: []string{"api"}},
+		config.Service{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off", DependsOn: []string{"db"}},
+		config.Service{Name: "db", BackendAddr: "localhost:5432", TLSMode: "off"},
+	)
+
+	require.Error(t, registry.StartServices())
+	state, _ := registry.State("db")
+	assert.Equal(t, constants.ServiceStateBackoff, state)
+
+	// Dependents wait for as long as the service they depend on is not running
+	time.Sleep(20 * time.Millisecond)
+	for _, name := range []string{"web", "api"} {
+		state, _ := registry.State(name)
+		assert.Equal(t, constants.ServiceStateWaiting, state, name)
+	}
+
+	mu.Lock()
+	dbFails = false
+	mu.Unlock()
+	require.NoError(t, registry.RestartService("db"))
+	assertState(t, registry, "web", constants.ServiceStateRunning)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"db", "api", "web"}, started)
+}
+
+func TestRegistry_WaitForBackendDelay(t *testing.T) {
+	addr := unusedAddr(t)
+	registry := gatedRegistry(t, func(string) error { return nil },
+		config.Service{Name: "app", BackendAddr: addr, TLSMode: "off", WaitForBackend: constants.WaitForBackendDelay},
+	)
+
+	require.NoError(t, registry.StartServices())
+	time.Sleep(20 * time.Millisecond)
+	state, _ := registry.State("app")
+	assert.Equal(t, constants.ServiceStateWaiting, state)
+	_, ok := registry.GetService("app")
+	assert.False(t, ok, "service started before its backend was ready")
+
+	serveBackend(t, addr)
+	assertState(t, registry, "app", constants.ServiceStateRunning)
+	_, ok = registry.GetService("app")
+	assert.True(t, ok)
+}
+
+func TestRegistry_WaitForBackendUnavailable(t *testing.T) {
+	addr := unusedAddr(t)
+	registry := gatedRegistry(t, func(string) error { return nil },
+		config.Service{Name: "app", BackendAddr: addr, TLSMode: "off", WaitForBackend: constants.WaitForBackendUnavailable},
+	)
+	require.NoError(t, registry.StartServices())
+	state, _ := registry.State("app")
+	assert.Equal(t, constants.ServiceStateRunning, state)
+	svc, ok := registry.GetService("app")
+	require.True(t, ok)
+
+	rec := httptest.NewRecorder()
+	svc.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "app is starting")
+
+	serveBackend(t, addr)
+	assert.Eventually(t, func() bool { return !svc.backendDown.Load() }, 5*time.Second, time.Millisecond)
+	rec = httptest.NewRecorder()
+	svc.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+}
+
+func TestRegistry_ChangesToWaitingService(t *testing.T) {
+	waitingRegistry := func(t *testing.T) *Registry {
+		registry := gatedRegistry(t, func(string) error { return nil },
+			config.Service{Name: "app", BackendAddr: unusedAddr(t), TLSMode: "off", WaitForBackend: constants.WaitForBackendDelay},
+		)
+		require.NoError(t, registry.StartServices())
+		state, _ := registry.State("app")
+		require.Equal(t, constants.ServiceStateWaiting, state)
+		return registry
+	}
+
+	t.Run("remove stops waiting", func(t *testing.T) {
+		registry := waitingRegistry(t)
+		require.NoError(t, registry.RemoveService("app"))
+		_, ok := registry.State("app")
+		assert.False(t, ok)
+		registry.mu.RLock()
+		defer registry.mu.RUnlock()
+		assert.Empty(t, registry.retries)
+	})
+
+	t.Run("update that no longer waits starts it", func(t *testing.T) {
+		registry := waitingRegistry(t)
+		newCfg := config.Service{Name: "app", BackendAddr: "localhost:9090", TLSMode: "off"}
+		require.NoError(t, registry.UpdateService("app", newCfg))
+		state, _ := registry.State("app")
+		assert.Equal(t, constants.ServiceStateRunning, state)
+	})
+
+	t.Run("added services wait too", func(t *testing.T) {
+		registry := waitingRegistry(t)
+		errs := registry.AddServices([]config.Service{
+			{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off", DependsOn: []string{"app"}},
+		})
+		require.Len(t, errs, 1)
+		assert.NoError(t, errs[0])
+		state, _ := registry.State("web")
+		assert.Equal(t, constants.ServiceStateWaiting, state)
+	})
+}
+
+func TestRegistry_RemoveWaitingServiceWhileStarting(t *testing.T) {
+	release := make(chan struct{})
+	registry := gatedRegistry(t, func(name string) error {
+		if name == "web" {
+			<-release
+		}
+		return nil
+	},
+		config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off", DependsOn: []string{"api"}},
+		config.Service{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"},
+	)
+
+	require.NoError(t, registry.StartServices())
+	// The registry is not locked while the service starts
+	assertState(t, registry, "web", constants.ServiceStateStarting)
+	require.NoError(t, registry.RemoveService("web"))
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		return registry.tsServer.GetServiceServer("web") == nil
+	}, 5*time.Second, time.Millisecond, "node of removed service was not closed")
+	_, ok := registry.GetService("web")
+	assert.False(t, ok)
+}
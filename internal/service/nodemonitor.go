@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+)
+
+// startNodeMonitor periodically checks the status of the service's tailnet
+// node and, when events are published, its backend until stopNodeMonitoring
+// is called
+func (s *Service) startNodeMonitor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.stopNodeMonitor = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(constants.NodeStatusInterval)
+		defer ticker.Stop()
+
+		for {
+			s.checkNode(ctx)
+			if s.events != nil {
+				s.checkBackendHealth(ctx)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stopNodeMonitoring stops checking the node and removes the service's node
+// metrics, so a stopped service does not linger
+func (s *Service) stopNodeMonitoring() {
+	if s.stopNodeMonitor == nil {
+		return
+	}
+	s.stopNodeMonitor()
+	s.stopNodeMonitor = nil
+	if s.nodeMetrics {
+		s.metricsCollector.DeleteNodeStatus(s.Name)
+	}
+}
+
+// checkNode publishes changes to the backend state of the service's node and
+// a certificate nearing expiry and, when node metrics are exported, records its
+// state, key and certificate expiry and peer connectivity
+func (s *Service) checkNode(ctx context.Context) {
+	callCtx, cancel := context.WithTimeout(ctx, constants.NodeStatusTimeout)
+	defer cancel()
+
+	info, err := s.tsServer.ServiceNodeInfo(callCtx, s.Name)
+	if err != nil {
+		slog.Debug("failed to check node status", "service", s.Name, "error", err)
+		return
+	}
+	if info.BackendState != s.nodeState && ctx.Err() == nil {
+		s.events.Publish(events.Event{
+			Type:    events.NodeStateChanged,
+			Service: s.Name,
+			Data:    map[string]any{"from": s.nodeState, "to": info.BackendState},
+		})
+		s.nodeState = info.BackendState
+	}
+
+	status := metrics.NodeStatus{
+		State:     info.BackendState,
+		KeyExpiry: info.KeyExpiry,
+	}
+
+	// Only nodes that serve HTTPS hold a certificate, and asking others for
+	// one would request it from Let's Encrypt
+	if s.servesTLS() && info.FQDN != "" {
+		if expiry, err := s.tsServer.ServiceCertExpiry(callCtx, s.Name, info.FQDN); err != nil {
+			slog.Debug("failed to check node certificate expiry", "service", s.Name, "error", err)
+		} else {
+			status.CertExpiry = expiry
+			s.warnCertExpiry(ctx, expiry)
+		}
+	}
+	if !s.nodeMetrics {
+		return
+	}
+
+	if conn, err := s.tsServer.ServicePeerConnectivity(callCtx, s.Name); err != nil {
+		slog.Debug("failed to collect node peer connectivity", "service", s.Name, "error", err)
+	} else {
+		status.DirectPeers = conn.Direct
+		status.RelayedPeers = conn.Relayed
+	}
+
+	// Checking is cut short when the service stops, after which its metrics
+	// must stay removed
+	if ctx.Err() == nil {
+		s.metricsCollector.SetNodeStatus(s.Name, status)
+	}
+}
+
+// warnCertExpiry publishes node.cert_expiring once for each certificate that
+// comes within constants.CertExpiryWarning of expiring
+func (s *Service) warnCertExpiry(ctx context.Context, expiry time.Time) {
+	if expiry.IsZero() || time.Until(expiry) > constants.CertExpiryWarning || expiry.Equal(s.certWarned) || ctx.Err() != nil {
+		return
+	}
+	s.certWarned = expiry
+	s.events.Publish(events.Event{
+		Type:    events.NodeCertExpiring,
+		Service: s.Name,
+		Data:    map[string]any{"expires_at": expiry},
+	})
+}
+
+// checkBackendHealth checks that the backend accepts connections, publishing
+// when it becomes unhealthy and when it recovers. The backend of an on-demand
+// service is not checked while its container is stopped.
+func (s *Service) checkBackendHealth(ctx context.Context) {
+	if s.onDemand != nil && !s.onDemand.isRunning() {
+		return
+	}
+	err := s.CheckBackend(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	switch {
+	case err != nil && !s.backendUnhealthy:
+		s.backendUnhealthy = true
+		s.events.Publish(events.Event{
+			Type:    events.BackendUnhealthy,
+			Service: s.Name,
+			Error:   err.Error(),
+			Data:    map[string]any{"backend": s.Config.BackendAddr},
+		})
+	case err == nil && s.backendUnhealthy:
+		s.backendUnhealthy = false
+		s.events.Publish(events.Event{
+			Type:    events.BackendHealthy,
+			Service: s.Name,
+			Data:    map[string]any{"backend": s.Config.BackendAddr},
+		})
+	}
+}
+
+// servesTLS reports whether the service is served over HTTPS with a
+// certificate from its tailnet node
+func (s *Service) servesTLS() bool {
+	funnel := s.Config.FunnelEnabled != nil && *s.Config.FunnelEnabled
+	return funnel || s.Config.TLSMode != constants.TLSModeOff
+}
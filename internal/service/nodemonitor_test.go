@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/jtdowney/tsbridge/internal/tsnet"
+)
+
+// certPEM returns a self-signed certificate expiring at notAfter
+func certPEM(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    notAfter.AddDate(0, -3, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// nodeMetricsService returns a service whose tailnet node reports a running
+// state with one direct peer, recording the domains asked for a certificate.
+// The node holds a certificate expiring at certExpiry unless it is zero.
+func nodeMetricsService(t *testing.T, svcCfg config.Service, certDomains *[]string, certExpiry time.Time) *Service {
+	t.Helper()
+	var cert []byte
+	if !certExpiry.IsZero() {
+		cert = certPEM(t, certExpiry)
+	}
+	keyExpiry := time.Unix(1800000000, 0)
+	lc := &tsnet.MockLocalClient{
+		StatusWithoutPeersFunc: func(ctx context.Context) (*ipnstate.Status, error) {
+			return &ipnstate.Status{
+				BackendState: "Running",
+				Self:         &ipnstate.PeerStatus{DNSName: svcCfg.Name + ".tailnet.ts.net.", KeyExpiry: &keyExpiry},
+			}, nil
+		},
+		StatusFunc: func(ctx context.Context) (*ipnstate.Status, error) {
+			return &ipnstate.Status{Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+				key.NewNode().Public(): {Active: true, CurAddr: "203.0.113.5:41641"},
+			}}, nil
+		},
+		CertPairFunc: func(ctx context.Context, domain string) ([]byte, []byte, error) {
+			*certDomains = append(*certDomains, domain)
+			return cert, nil, nil
+		},
+	}
+
+	tsServer, err := tailscale.NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, func(string) tsnet.TSNetServer {
+		server := tsnet.NewMockTSNetServer()
+		server.LocalClientFunc = func() (tsnet.LocalClient, error) { return lc, nil }
+		return server
+	})
+	require.NoError(t, err)
+	_, err = tsServer.Listen(svcCfg, svcCfg.TLSMode, false)
+	require.NoError(t, err)
+
+	return &Service{
+		Name:             svcCfg.Name,
+		Config:           svcCfg,
+		tsServer:         tsServer,
+		metricsCollector: metrics.NewCollector(),
+		nodeMetrics:      true,
+	}
+}
+
+func TestCheckNode(t *testing.T) {
+	t.Run("records node status", func(t *testing.T) {
+		var certDomains []string
+		svc := nodeMetricsService(t, config.Service{Name: "api", TLSMode: "auto"}, &certDomains, time.Time{})
+
+		svc.checkNode(context.Background())
+
+		c := svc.metricsCollector
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.NodeState.WithLabelValues("api", "Running")))
+		assert.Equal(t, 1800000000.0, testutil.ToFloat64(c.NodeKeyExpiry.WithLabelValues("api")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.NodePeers.WithLabelValues("api", "direct")))
+		assert.Equal(t, 0.0, testutil.ToFloat64(c.NodePeers.WithLabelValues("api", "derp")))
+		assert.Equal(t, []string{"api.tailnet.ts.net"}, certDomains)
+	})
+
+	t.Run("plain http services are not asked for a certificate", func(t *testing.T) {
+		var certDomains []string
+		svc := nodeMetricsService(t, config.Service{Name: "web", TLSMode: "off"}, &certDomains, time.Time{})
+
+		svc.checkNode(context.Background())
+
+		assert.Empty(t, certDomains)
+		assert.Equal(t, 1.0, testutil.ToFloat64(svc.metricsCollector.NodeState.WithLabelValues("web", "Running")))
+	})
+
+	t.Run("stopping removes the metrics", func(t *testing.T) {
+		var certDomains []string
+		svc := nodeMetricsService(t, config.Service{Name: "api", TLSMode: "off"}, &certDomains, time.Time{})
+
+		svc.startNodeMonitor()
+		require.Eventually(t, func() bool {
+			return testutil.CollectAndCount(svc.metricsCollector.NodeState) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		svc.stopNodeMonitoring()
+		assert.Equal(t, 0, testutil.CollectAndCount(svc.metricsCollector.NodeState))
+		assert.Equal(t, 0, testutil.CollectAndCount(svc.metricsCollector.NodePeers))
+		assert.Nil(t, svc.stopNodeMonitor)
+	})
+
+	t.Run("publishes state changes", func(t *testing.T) {
+		var certDomains []string
+		svc := nodeMetricsService(t, config.Service{Name: "api", TLSMode: "off"}, &certDomains, time.Time{})
+		svc.nodeMetrics = false
+		svc.events = events.NewBus(10)
+
+		svc.checkNode(context.Background())
+		svc.checkNode(context.Background())
+
+		published := svc.events.Recent(-1)
+		require.Len(t, published, 1, "an unchanged state is not published again")
+		assert.Equal(t, events.NodeStateChanged, published[0].Type)
+		assert.Equal(t, "api", published[0].Service)
+		assert.Equal(t, map[string]any{"from": "", "to": "Running"}, published[0].Data)
+		assert.Equal(t, 0, testutil.CollectAndCount(svc.metricsCollector.NodeState), "node metrics are not exported")
+	})
+	t.Run("publishes a certificate nearing expiry once", func(t *testing.T) {
+		var certDomains []string
+		expiry := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+		svc := nodeMetricsService(t, config.Service{Name: "api", TLSMode: "auto"}, &certDomains, expiry)
+		svc.events = events.NewBus(10)
+
+		svc.checkNode(context.Background())
+		svc.checkNode(context.Background())
+
+		var warnings []events.Event
+		for _, e := range svc.events.Recent(-1) {
+			if e.Type == events.NodeCertExpiring {
+				warnings = append(warnings, e)
+			}
+		}
+		require.Len(t, warnings, 1)
+		assert.Equal(t, "api", warnings[0].Service)
+		assert.True(t, expiry.Equal(warnings[0].Data["expires_at"].(time.Time)))
+		assert.Equal(t, float64(expiry.Unix()), testutil.ToFloat64(svc.metricsCollector.NodeCertExpiry.WithLabelValues("api")))
+	})
+
+	t.Run("certificate far from expiry is not published", func(t *testing.T) {
+		var certDomains []string
+		svc := nodeMetricsService(t, config.Service{Name: "api", TLSMode: "auto"}, &certDomains, time.Now().AddDate(0, 2, 0))
+		svc.events = events.NewBus(10)
+
+		svc.checkNode(context.Background())
+
+		for _, e := range svc.events.Recent(-1) {
+			assert.NotEqual(t, events.NodeCertExpiring, e.Type)
+		}
+	})
+}
+
+func TestCheckBackendHealth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	svc := &Service{
+		Name:   "api",
+		Config: config.Service{Name: "api", BackendAddr: addr},
+		events: events.NewBus(10),
+	}
+
+	// Nothing listens on the backend address yet
+	svc.checkBackendHealth(context.Background())
+	svc.checkBackendHealth(context.Background())
+
+	ln, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer ln.Close()
+	svc.checkBackendHealth(context.Background())
+	svc.checkBackendHealth(context.Background())
+
+	published := svc.events.Recent(-1)
+	require.Len(t, published, 2, "only changes in health are published")
+	assert.Equal(t, events.BackendUnhealthy, published[0].Type)
+	assert.NotEmpty(t, published[0].Error)
+	assert.Equal(t, map[string]any{"backend": addr}, published[0].Data)
+	assert.Equal(t, events.BackendHealthy, published[1].Type)
+
+	t.Run("cancelled checks are not published", func(t *testing.T) {
+		svc := &Service{
+			Name:   "api",
+			Config: config.Service{Name: "api", BackendAddr: "localhost:1"},
+			events: events.NewBus(10),
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		svc.checkBackendHealth(ctx)
+		assert.Empty(t, svc.events.Recent(-1))
+	})
+
+	t.Run("stopped on-demand containers are not checked", func(t *testing.T) {
+		svc := &Service{
+			Name:     "wiki",
+			Config:   config.Service{Name: "wiki", BackendAddr: unusedAddr(t)},
+			events:   events.NewBus(10),
+			onDemand: newOnDemand("wiki", &fakeContainers{}, time.Hour, time.Millisecond),
+		}
+		defer svc.onDemand.close()
+		svc.checkBackendHealth(context.Background())
+		assert.Empty(t, svc.events.Recent(-1))
+		assert.False(t, svc.backendUnhealthy)
+	})
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// ContainerController starts and stops the containers behind on-demand
+// services. The Docker provider implements it.
+type ContainerController interface {
+	StartContainer(ctx context.Context, service string) error
+	StopContainer(ctx context.Context, service string) error
+}
+
+// errContainerStopped is returned to requests that were waiting for a
+// container that was stopped again before they could be served
+var errContainerStopped = errors.New("container was stopped")
+
+// onDemand starts the container of an on-demand service when a request arrives
+// while it is stopped, and stops it again once the service has gone
+// idleTimeout without requests. It is shared with services that replace this
+// one in place.
+type onDemand struct {
+	name         string
+	controller   ContainerController
+	idleTimeout  time.Duration
+	pollInterval time.Duration // Wait between probes of a backend that is starting
+
+	mu       sync.Mutex
+	running  bool          // The container was started and its backend accepts connections
+	starting chan struct{} // Closed once the container being started is ready, nil when not starting
+	stopping chan struct{} // Closed once the container being stopped has stopped, nil when not stopping
+	startErr error         // Why the container last failed to start
+	active   int           // Requests being served
+	idle     *time.Timer   // Stops the container once the service is idle
+	closed   bool          // The service stopped, so the container is left as it is
+}
+
+// newOnDemand returns the on-demand state of the service name. Whether its
+// container is running is not known, so it is stopped if no request arrives
+// within idleTimeout.
+func newOnDemand(name string, controller ContainerController, idleTimeout, pollInterval time.Duration) *onDemand {
+	o := &onDemand{
+		name:         name,
+		controller:   controller,
+		idleTimeout:  idleTimeout,
+		pollInterval: pollInterval,
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.armIdle()
+	return o
+}
+
+// isRunning returns whether requests are passed straight to the backend
+func (o *onDemand) isRunning() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.running
+}
+
+// begin starts the container unless it is running or already starting, and
+// returns a channel closed once it is ready
+func (o *onDemand) begin(addr string) <-chan struct{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		ready := make(chan struct{})
+		close(ready)
+		return ready
+	}
+	if o.starting == nil {
+		o.starting = make(chan struct{})
+		go o.start(addr, o.starting, o.stopping)
+	}
+	return o.starting
+}
+
+// acquire waits for the container to be ready and counts a request as being
+// served until release is called
+func (o *onDemand) acquire(ctx context.Context, addr string) error {
+	select {
+	case <-o.begin(addr):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.running {
+		if o.startErr != nil {
+			return o.startErr
+		}
+		return errContainerStopped
+	}
+	o.active++
+	if o.idle != nil {
+		o.idle.Stop()
+	}
+	return nil
+}
+
+// release counts a request as finished, stopping the container after
+// idleTimeout if no other request arrives
+func (o *onDemand) release() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.active--
+	if o.active == 0 {
+		o.armIdle()
+	}
+}
+
+// start starts the container, once one being stopped has stopped, and waits
+// for its backend at addr to accept connections before closing ready
+func (o *onDemand) start(addr string, ready chan struct{}, stopping <-chan struct{}) {
+	if stopping != nil {
+		<-stopping
+	}
+
+	startTime := time.Now()
+	slog.Info("starting container of on-demand service", "service", o.name)
+	ctx, cancel := context.WithTimeout(context.Background(), constants.OnDemandStartTimeout)
+	defer cancel()
+	err := o.controller.StartContainer(ctx, o.name)
+	if err == nil {
+		err = waitUntilReachable(ctx, addr, o.pollInterval)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.starting = nil
+	o.startErr = err
+	if err != nil {
+		slog.Error("failed to start container of on-demand service", "service", o.name, "error", err)
+	} else if !o.closed {
+		o.running = true
+		o.armIdle()
+		slog.Info("started container of on-demand service", "service", o.name, "duration", time.Since(startTime))
+	}
+	close(ready)
+}
+
+// armIdle stops the container once the service has been idle for
+// idleTimeout. Callers hold o.mu.
+func (o *onDemand) armIdle() {
+	if o.closed {
+		return
+	}
+	if o.idle != nil {
+		o.idle.Stop()
+	}
+	o.idle = time.AfterFunc(o.idleTimeout, o.stopIfIdle)
+}
+
+// stopIfIdle stops the container unless a request arrived or it started
+// again in the meantime
+func (o *onDemand) stopIfIdle() {
+	o.mu.Lock()
+	if o.closed || o.active > 0 || o.starting != nil || o.stopping != nil {
+		o.mu.Unlock()
+		return
+	}
+	o.running = false
+	stopped := make(chan struct{})
+	o.stopping = stopped
+	o.mu.Unlock()
+
+	slog.Info("stopping idle container of on-demand service", "service", o.name, "idle_timeout", o.idleTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), constants.OnDemandStopTimeout)
+	defer cancel()
+	if err := o.controller.StopContainer(ctx, o.name); err != nil {
+		slog.Warn("failed to stop container of on-demand service", "service", o.name, "error", err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stopping = nil
+	close(stopped)
+}
+
+// close stops managing the container when the service stops, leaving it
+// running or stopped as it is
+func (o *onDemand) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	if o.idle != nil {
+		o.idle.Stop()
+	}
+}
+
+// waitUntilReachable probes the backend at addr every interval until it
+// accepts connections or ctx is done
+func waitUntilReachable(ctx context.Context, addr string, interval time.Duration) error {
+	for {
+		err := dialBackend(ctx, addr)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return fmt.Errorf("backend did not accept connections: %w", err)
+		}
+	}
+}
+
+// wantsStartingPage returns whether r is a browser loading a page, which is
+// shown a page saying the service is starting rather than being held
+func wantsStartingPage(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get("Upgrade") == "" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// onDemandMiddleware starts the container of an on-demand service that is
+// stopped. Browsers are shown a page that reloads until it is ready, while
+// other requests are held until it is.
+func (s *Service) onDemandMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.onDemand == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !s.onDemand.isRunning() && wantsStartingPage(r) {
+			s.onDemand.begin(s.Config.BackendAddr)
+			serveStartingPage(w, s.Name)
+			return
+		}
+
+		if err := s.onDemand.acquire(r.Context(), s.Config.BackendAddr); err != nil {
+			if r.Context().Err() == nil {
+				http.Error(w, "Service failed to start", http.StatusServiceUnavailable)
+			}
+			return
+		}
+		defer s.onDemand.release()
+		next.ServeHTTP(w, r)
+	})
+}
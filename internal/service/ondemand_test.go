@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+)
+
+// fakeContainers runs a backend on addr while its container is started
+type fakeContainers struct {
+	addr     string
+	startErr error
+
+	mu      sync.Mutex
+	backend *httptest.Server
+	starts  int
+	stops   int
+}
+
+func (f *fakeContainers) StartContainer(ctx context.Context, service string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starts++
+	if f.startErr != nil {
+		return f.startErr
+	}
+	if f.backend != nil {
+		return nil
+	}
+	l, err := net.Listen("tcp", f.addr)
+	if err != nil {
+		return err
+	}
+	f.backend = &httptest.Server{
+		Listener: l,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "ok")
+		})},
+	}
+	f.backend.Start()
+	return nil
+}
+
+func (f *fakeContainers) StopContainer(ctx context.Context, service string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stops++
+	if f.backend != nil {
+		f.backend.Close()
+		f.backend = nil
+	}
+	return nil
+}
+
+func (f *fakeContainers) counts() (starts, stops int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.starts, f.stops
+}
+
+// onDemandService returns a service proxying to a container managed by
+// containers, stopped after idleTimeout without requests
+func onDemandService(t *testing.T, containers *fakeContainers, idleTimeout time.Duration) http.Handler {
+	t.Helper()
+	svc := &Service{
+		Name:   "wiki",
+		Config: config.Service{Name: "wiki", BackendAddr: containers.addr},
+	}
+	svc.onDemand = newOnDemand("wiki", containers, idleTimeout, time.Millisecond)
+	handler, err := svc.CreateHandler()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		svc.onDemand.close()
+		_ = containers.StopContainer(context.Background(), "wiki")
+	})
+	return handler
+}
+
+func TestOnDemand_HoldsRequestsUntilStarted(t *testing.T) {
+	containers := &fakeContainers{addr: unusedAddr(t)}
+	handler := onDemandService(t, containers, time.Hour)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "ok", rec.Body.String())
+		})
+	}
+	wg.Wait()
+
+	starts, _ := containers.counts()
+	assert.Equal(t, 1, starts, "container started more than once")
+}
+
+func TestOnDemand_BrowsersSeeStartingPage(t *testing.T) {
+	containers := &fakeContainers{addr: unusedAddr(t)}
+	handler := onDemandService(t, containers, time.Hour)
+
+	page := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := page()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "wiki is starting")
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// The page reloads into the service once its container is up
+	assert.Eventually(t, func() bool { return page().Code == http.StatusOK }, 5*time.Second, time.Millisecond)
+	starts, _ := containers.counts()
+	assert.Equal(t, 1, starts)
+}
+
+func TestOnDemand_StopsIdleContainer(t *testing.T) {
+	containers := &fakeContainers{addr: unusedAddr(t)}
+	handler := onDemandService(t, containers, 20*time.Millisecond)
+
+	// A container that might have been running is stopped when nothing uses it
+	assert.Eventually(t, func() bool {
+		_, stops := containers.counts()
+		return stops == 1
+	}, 5*time.Second, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Eventually(t, func() bool {
+		_, stops := containers.counts()
+		return stops == 2
+	}, 5*time.Second, time.Millisecond)
+
+	// The next request starts it again
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	starts, _ := containers.counts()
+	assert.Equal(t, 2, starts)
+}
+
+func TestOnDemand_NotStoppedWhileServing(t *testing.T) {
+	o := newOnDemand("wiki", &fakeContainers{addr: unusedAddr(t)}, 10*time.Millisecond, time.Millisecond)
+	defer o.close()
+	containers := o.controller.(*fakeContainers)
+	t.Cleanup(func() { _ = containers.StopContainer(context.Background(), "wiki") })
+
+	require.NoError(t, o.acquire(t.Context(), containers.addr))
+	time.Sleep(50 * time.Millisecond)
+	_, stops := containers.counts()
+	assert.Zero(t, stops, "container stopped while a request was being served")
+	assert.True(t, o.isRunning())
+
+	o.release()
+	assert.Eventually(t, func() bool { return !o.isRunning() }, 5*time.Second, time.Millisecond)
+}
+
+func TestOnDemand_StartFailure(t *testing.T) {
+	containers := &fakeContainers{addr: unusedAddr(t), startErr: assert.AnError}
+	handler := onDemandService(t, containers, time.Hour)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Service failed to start")
+
+	// Each request tries again
+	containers.mu.Lock()
+	containers.startErr = nil
+	containers.mu.Unlock()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestOnDemand_StatusWhileStopped(t *testing.T) {
+	containers := &fakeContainers{addr: unusedAddr(t)}
+	collector := metrics.NewCollector()
+	svc := &Service{
+		Name:             "wiki",
+		Config:           config.Service{Name: "wiki", BackendAddr: containers.addr},
+		metricsCollector: collector,
+	}
+	svc.onDemand = newOnDemand("wiki", containers, time.Hour, time.Millisecond)
+	t.Cleanup(func() {
+		svc.onDemand.close()
+		_ = containers.StopContainer(context.Background(), "wiki")
+	})
+
+	status := svc.status(context.Background())
+	assert.True(t, status.Idle)
+	assert.False(t, status.Healthy)
+	assert.Empty(t, status.HealthError, "the stopped backend is not checked")
+	assert.Zero(t, testutil.CollectAndCount(collector.BackendHealth), "backend health is not recorded while stopped")
+
+	// Once a request starts the container its backend is checked again
+	handler, err := svc.CreateHandler()
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	status = svc.status(context.Background())
+	assert.False(t, status.Idle)
+	assert.True(t, status.Healthy)
+}
+
+func TestRegistry_OnDemandRequiresContainerController(t *testing.T) {
+	svcCfg := config.Service{Name: "wiki", BackendAddr: "localhost:8080", TLSMode: "off", OnDemand: new(true)}
+
+	registry := gatedRegistry(t, func(string) error { return nil }, svcCfg)
+	require.NoError(t, registry.StartServices())
+	svc, ok := registry.GetService("wiki")
+	require.True(t, ok)
+	assert.Nil(t, svc.onDemand)
+
+	registry = gatedRegistry(t, func(string) error { return nil }, svcCfg)
+	registry.SetContainerController(&fakeContainers{addr: unusedAddr(t)})
+	require.NoError(t, registry.StartServices())
+	svc, ok = registry.GetService("wiki")
+	require.True(t, ok)
+	require.NotNil(t, svc.onDemand)
+	assert.Equal(t, 15*time.Minute, svc.onDemand.idleTimeout)
+}
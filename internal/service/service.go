@@ -6,24 +6,30 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"log/slog"
 
+	"github.com/jtdowney/tsbridge/internal/accesslog"
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
 	"github.com/jtdowney/tsbridge/internal/funnel"
+	"github.com/jtdowney/tsbridge/internal/logging"
 	"github.com/jtdowney/tsbridge/internal/metrics"
 	"github.com/jtdowney/tsbridge/internal/middleware"
 	"github.com/jtdowney/tsbridge/internal/proxy"
 	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Registry manages all services
@@ -31,7 +37,18 @@ type Registry struct {
 	config           *config.Config
 	tsServer         *tailscale.Server
 	services         map[string]*Service
+	running          atomic.Pointer[map[string]*Service] // Copy of services for readers that must not wait on r.mu
+	progress         atomic.Int64                        // Unix nanoseconds when r.mu was last seen free or a service last changed
 	metricsCollector *metrics.Collector
+	tracerProvider   trace.TracerProvider
+	accessLogSink    *accesslog.Logger
+	events           *events.Bus
+	states           map[string]string   // Supervisor state of each service, one of constants.ServiceState*
+	retries          map[string]*retry   // Services that are not running, being started or started again after a backoff
+	retryInterval    time.Duration       // Wait before a service is first started again
+	waitInterval     time.Duration       // Wait between checks of whether a waiting service is ready
+	containers       ContainerController // Starts and stops the containers of on-demand services, nil without the Docker provider
+	stopped          bool                // Shutdown was called, so no service is retried
 	mu               sync.RWMutex
 }
 
@@ -44,7 +61,34 @@ type Service struct {
 	server           *http.Server
 	tsServer         *tailscale.Server // Reference to Tailscale server for WhoIs
 	metricsCollector *metrics.Collector
-	handler          http.Handler // Pre-created handler to catch config errors early
+	tracerProvider   trace.TracerProvider // Records request spans, nil when tracing is disabled
+	accessLogSink    *accesslog.Logger    // Dedicated access log, nil when requests are logged to the main log
+	handler          http.Handler         // Pre-created handler to catch config errors early
+	serving          *swappableHandler    // The server's handler, shared with services that replace this one in place
+	conns            *connTracker         // Hijacked connections and event streams, shared like serving
+	startedAt        time.Time
+	accessLog        atomic.Bool                // Runtime access logging switch, initialised from config
+	draining         atomic.Bool                // Reject new requests while in-flight ones finish
+	inFlight         atomic.Int64               // Requests currently being served
+	recent           *middleware.RecentRequests // Latest access log entries, shown on the dashboard
+	events           *events.Bus                // Receives lifecycle events, nil to discard them
+	nodeMetrics      bool                       // Record the status of the service's node as metrics
+	nodeState        string                     // Last backend state of the node seen by the node monitor
+	certWarned       time.Time                  // Expiry of the certificate node.cert_expiring was last published for
+	backendUnhealthy bool                       // The last backend check by the node monitor failed
+	stopNodeMonitor  func()                     // Stops the node monitor, nil when not monitoring
+	backendDown      atomic.Bool                // Serve the starting page until the backend accepts connections
+	stopBackendWait  func()                     // Stops waiting for the backend, nil when not waiting
+	waitInterval     time.Duration              // Wait between checks of whether the backend is ready
+	onDemand         *onDemand                  // Starts and stops the container of an on-demand service, shared like conns
+}
+
+// ErrNotFound is wrapped by errors about services that are not in the registry
+var ErrNotFound = errors.New("not found")
+
+// notFoundError returns an error reporting that the named service is not in the registry
+func notFoundError(name string) error {
+	return fmt.Errorf("service %s %w", name, ErrNotFound)
 }
 
 // handlerWithClose wraps an http.Handler and preserves the Close method from the underlying proxy.Handler
@@ -61,13 +105,37 @@ func (h *handlerWithClose) Close() error {
 	return nil
 }
 
+// swappableHandler serves requests with a handler that can be replaced while
+// the server keeps running. Requests already being served finish on the
+// handler they started on.
+type swappableHandler struct {
+	current atomic.Pointer[http.Handler]
+}
+
+// ServeHTTP passes the request to the current handler
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
+
+// Store makes next the handler of new requests
+func (h *swappableHandler) Store(next http.Handler) {
+	h.current.Store(&next)
+}
+
 // NewRegistry creates a new service registry
 func NewRegistry(cfg *config.Config, tsServer *tailscale.Server) *Registry {
-	return &Registry{
+	r := &Registry{
 		config:   cfg,
 		tsServer: tsServer,
 		services: make(map[string]*Service, len(cfg.Services)),
+		states:   make(map[string]string, len(cfg.Services)),
+		retries:  make(map[string]*retry),
+
+		retryInterval: constants.ServiceRetryInitialInterval,
+		waitInterval:  constants.ServiceWaitInterval,
 	}
+	r.progress.Store(time.Now().UnixNano())
+	return r
 }
 
 // SetMetricsCollector sets the metrics collector for the registry
@@ -84,6 +152,35 @@ func (r *Registry) GetMetricsCollector() *metrics.Collector {
 	return r.metricsCollector
 }
 
+// SetTracerProvider sets the tracer provider for services started afterwards
+func (r *Registry) SetTracerProvider(tp trace.TracerProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tracerProvider = tp
+}
+
+// SetAccessLogSink sets the dedicated access log for services started afterwards
+func (r *Registry) SetAccessLogSink(sink *accesslog.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accessLogSink = sink
+}
+
+// SetEventBus sets the bus that service lifecycle events are published to
+func (r *Registry) SetEventBus(bus *events.Bus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = bus
+}
+
+// SetContainerController sets what starts and stops the containers of
+// on-demand services started afterwards
+func (r *Registry) SetContainerController(controller ContainerController) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.containers = controller
+}
+
 // GetService returns a service by name
 func (r *Registry) GetService(name string) (*Service, bool) {
 	r.mu.RLock()
@@ -92,10 +189,37 @@ func (r *Registry) GetService(name string) (*Service, bool) {
 	return svc, exists
 }
 
-// StartServices starts all configured services
+// setRunning registers svc as the running service name, or unregisters it
+// when svc is nil. Callers hold r.mu.
+func (r *Registry) setRunning(name string, svc *Service) {
+	if svc == nil {
+		delete(r.services, name)
+	} else {
+		r.services[name] = svc
+	}
+	running := maps.Clone(r.services)
+	r.running.Store(&running)
+	r.progress.Store(time.Now().UnixNano())
+}
+
+// runningService returns a running service by name without waiting for
+// changes to the registry in progress, which can take as long as a service's
+// startup or stop timeout
+func (r *Registry) runningService(name string) (*Service, bool) {
+	running := r.running.Load()
+	if running == nil {
+		return nil, false
+	}
+	svc, ok := (*running)[name]
+	return svc, ok
+}
+
+// StartServices starts all configured services. Services that fail to start
+// are retried in the background with exponential backoff. Services that depend
+// on others, or wait for their backend, are started in the background once they
+// are ready.
 func (r *Registry) StartServices() error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
 
 	startTime := time.Now()
 	totalServices := len(r.config.Services)
@@ -104,34 +228,50 @@ func (r *Registry) StartServices() error {
 
 	slog.Debug("starting all configured services",
 		"total_services", totalServices,
+		"concurrency", r.startupConcurrency(),
 	)
 
-	for i, svcCfg := range r.config.Services {
-		serviceStart := time.Now()
-		slog.Debug("starting service",
-			"service", svcCfg.Name,
-			"index", i+1,
-			"of", totalServices,
-		)
+	for _, svcCfg := range r.config.Services {
+		r.setState(svcCfg.Name, constants.ServiceStatePending)
+	}
+	var toStart []*retry
+	waitingCount := 0
+	for _, svcCfg := range r.config.Services {
+		if r.mustWait(svcCfg) {
+			r.wait(svcCfg)
+			waitingCount++
+			continue
+		}
+		toStart = append(toStart, r.startLater(svcCfg))
+	}
+	r.mu.Unlock()
 
-		svc, err := r.startService(svcCfg)
+	// The lock is not held while nodes start, which can take as long as their
+	// startup timeout
+	r.startConcurrently(toStart, func(i int, svcCfg config.Service, svc *Service, err error, duration time.Duration) {
 		if err != nil {
 			slog.Debug("service start failed",
 				"service", svcCfg.Name,
-				"duration", time.Since(serviceStart),
+				"duration", duration,
 				"error", err,
 			)
 			failedServices[svcCfg.Name] = err
-			continue // Skip failed services as per spec
+			r.startFailed(svcCfg, err)
+			return // Skip failed services as per spec
 		}
-		r.services[svcCfg.Name] = svc
+		delete(r.retries, svcCfg.Name)
+		r.setRunning(svcCfg.Name, svc)
+		r.setState(svcCfg.Name, constants.ServiceStateRunning)
 		slog.Info("started service", "service", svcCfg.Name)
 		slog.Debug("service started successfully",
 			"service", svcCfg.Name,
-			"duration", time.Since(serviceStart),
+			"duration", duration,
 		)
 		successfulCount++
-	}
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	// Update active services count if metrics collector is available
 	if r.metricsCollector != nil {
@@ -144,6 +284,7 @@ func (r *Registry) StartServices() error {
 		"total_services", totalServices,
 		"successful", successfulCount,
 		"failed", failedCount,
+		"waiting", waitingCount,
 		"total_duration", time.Since(startTime),
 	)
 
@@ -154,8 +295,14 @@ func (r *Registry) StartServices() error {
 	return nil
 }
 
-// startService starts a single service
-func (r *Registry) startService(svcCfg config.Service) (*Service, error) {
+// startService starts a single service, publishing whether it started
+func (r *Registry) startService(svcCfg config.Service) (_ *Service, err error) {
+	defer func() {
+		if err != nil {
+			r.events.Publish(events.Event{Type: events.ServiceStartFailed, Service: svcCfg.Name, Error: err.Error()})
+		}
+	}()
+
 	phaseStart := time.Now()
 	slog.Debug("creating listener for service",
 		"service", svcCfg.Name,
@@ -182,6 +329,10 @@ func (r *Registry) startService(svcCfg config.Service) (*Service, error) {
 		listener:         listener,
 		tsServer:         r.tsServer,
 		metricsCollector: r.metricsCollector,
+		tracerProvider:   r.tracerProvider,
+		accessLogSink:    r.accessLogSink,
+		events:           r.events,
+		waitInterval:     r.waitInterval,
 	}
 
 	// Create handler early to catch configuration errors
@@ -214,9 +365,13 @@ func (r *Registry) startService(svcCfg config.Service) (*Service, error) {
 		"duration", time.Since(handlerStart),
 	)
 
-	// Create HTTP server with timeouts
+	// Create HTTP server with timeouts. Its handler can be swapped by
+	// in-place updates.
+	svc.serving = &swappableHandler{}
+	svc.serving.Store(svc.handler)
+	svc.conns = newConnTracker(svcCfg.Name, svc.serving)
 	svc.server = &http.Server{
-		Handler:           svc.handler,
+		Handler:           svc.conns,
 		ReadHeaderTimeout: constants.DefaultReadHeaderTimeout, // Set default to satisfy linter
 	}
 
@@ -243,14 +398,45 @@ func (r *Registry) startService(svcCfg config.Service) (*Service, error) {
 		"idle_timeout", svc.server.IdleTimeout,
 	)
 
+	// Answer with a starting page until the backend is ready
+	if svcCfg.WaitForBackend == constants.WaitForBackendUnavailable {
+		svc.waitForBackend()
+	}
+
+	// Start the container of an on-demand service on the first request
+	if svcCfg.OnDemand != nil && *svcCfg.OnDemand {
+		if r.containers != nil {
+			idleTimeout := constants.DefaultOnDemandIdleTimeout
+			if svcCfg.OnDemandIdleTimeout != nil {
+				idleTimeout = *svcCfg.OnDemandIdleTimeout
+			}
+			svc.onDemand = newOnDemand(svcCfg.Name, r.containers, idleTimeout, r.waitInterval)
+		} else {
+			slog.Warn("on_demand requires the docker provider, ignoring it", "service", svcCfg.Name)
+		}
+	}
+
 	// Start serving in background
+	svc.startedAt = time.Now()
 	go func() {
 		slog.Debug("service listening", "service", svcCfg.Name, "address", listener.Addr())
 		if err := svc.server.Serve(listener); err != nil && err != http.ErrServerClosed {
-			slog.Error("service serve error", "service", svcCfg.Name, "error", err)
+			r.serveFailed(svcCfg.Name, svc.server, err)
 		}
 	}()
 
+	// Watch the service's tailnet node for state changes and collect node
+	// metrics when they are exported. The dashboard only needs request metrics.
+	svc.nodeMetrics = svc.metricsCollector != nil && r.config.Global.MetricsAddr != ""
+	if svc.tsServer != nil && (svc.nodeMetrics || svc.events != nil) {
+		svc.startNodeMonitor()
+	}
+
+	r.events.Publish(events.Event{
+		Type:    events.ServiceStarted,
+		Service: svcCfg.Name,
+		Data:    map[string]any{"backend": svcCfg.BackendAddr, "address": listener.Addr().String()},
+	})
 	return svc, nil
 }
 
@@ -307,6 +493,7 @@ func (s *Service) CreateHandler() (http.Handler, error) {
 		RemoveDownstream:   s.Config.RemoveDownstream,
 		FlushInterval:      s.Config.FlushInterval,
 		InsecureSkipVerify: s.Config.InsecureSkipVerify != nil && *s.Config.InsecureSkipVerify,
+		TracerProvider:     s.tracerProvider,
 	})
 	if err != nil {
 		return nil, err
@@ -346,7 +533,14 @@ func (s *Service) CreateHandler() (http.Handler, error) {
 			// Create a whois client adapter for the tsnet server
 			whoisClient := tailscale.NewWhoisClientAdapter(serviceServer)
 			// Use the whois middleware with internalized cache
-			httpHandler = middleware.Whois(whoisClient, whoisEnabled, whoisTimeout, constants.DefaultWhoisCacheSize, constants.DefaultWhoisCacheTTL)(httpHandler)
+			httpHandler = middleware.NewWhois(whoisClient, middleware.WhoisOptions{
+				Enabled:     whoisEnabled,
+				Timeout:     whoisTimeout,
+				CacheSize:   constants.DefaultWhoisCacheSize,
+				CacheTTL:    constants.DefaultWhoisCacheTTL,
+				Collector:   s.metricsCollector,
+				ServiceName: s.Config.Name,
+			})(httpHandler)
 		}
 	}
 
@@ -360,11 +554,40 @@ func (s *Service) CreateHandler() (http.Handler, error) {
 		httpHandler = s.metricsCollector.Middleware(s.Config.Name, httpHandler)
 	}
 
-	// Wrap with access logging middleware if enabled
-	if s.isAccessLogEnabled() {
-		httpHandler = middleware.AccessLog(slog.Default(), s.Config.Name)(httpHandler)
+	// Wrap with tracing middleware if enabled, outside whois so the caller's
+	// identity can be recorded on the span
+	if s.tracerProvider != nil {
+		httpHandler = middleware.Tracing(s.tracerProvider, s.Config.Name)(httpHandler)
 	}
 
+	// Wrap with access logging middleware. It is always installed so logging
+	// can be toggled at runtime through the admin API.
+	s.accessLog.Store(s.isAccessLogEnabled())
+	unlogged := httpHandler
+	if s.recent == nil {
+		s.recent = middleware.NewRecentRequests(constants.DashboardRecentRequests)
+	}
+	logged := middleware.NewAccessLog(s.Config.Name, middleware.AccessLogOptions{
+		Logger: logging.Logger(logging.ComponentProxy),
+		Sink:   s.accessLogSink,
+		Recent: s.recent,
+	})(httpHandler)
+	httpHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.accessLog.Load() {
+			logged.ServeHTTP(w, r)
+			return
+		}
+		unlogged.ServeHTTP(w, r)
+	})
+
+	// Serve a starting page until the backend is ready when configured to,
+	// and start the container of an on-demand service
+	httpHandler = s.backendGateMiddleware(httpHandler)
+	httpHandler = s.onDemandMiddleware(httpHandler)
+
+	// Track in-flight requests and turn new ones away while draining
+	httpHandler = s.drainMiddleware(httpHandler)
+
 	// Create a wrapper that preserves the Close method from the original handler
 	return &handlerWithClose{
 		Handler:      httpHandler,
@@ -402,8 +625,19 @@ func (s *Service) getMaxRequestBodySize() int64 {
 	return constants.DefaultMaxRequestBodySize
 }
 
-// Stop gracefully stops the service
+// Stop gracefully stops the service. WebSocket and event stream clients are
+// asked to go away and given drain_timeout to do so before the server shuts
+// down.
 func (s *Service) Stop(ctx context.Context) error {
+	s.stopNodeMonitoring()
+	s.stopWaitingForBackend()
+	if s.onDemand != nil {
+		s.onDemand.close()
+	}
+
+	if s.conns != nil {
+		s.conns.drain(ctx, s.drainTimeout())
+	}
 	if s.server != nil {
 		if err := s.server.Shutdown(ctx); err != nil {
 			return err
@@ -420,16 +654,47 @@ func (s *Service) Stop(ctx context.Context) error {
 		}
 	}
 
-	// Close the handler if it implements Close
-	if s.handler != nil {
-		if closer, ok := s.handler.(io.Closer); ok {
-			if err := closer.Close(); err != nil {
-				slog.Warn("failed to close handler", "service", s.Config.Name, "error", err)
-			}
+	s.closeHandler()
+	s.events.Publish(events.Event{Type: events.ServiceStopped, Service: s.Name})
+	return nil
+}
+
+// drainTimeout returns how long hijacked connections and event streams get
+// to close when the service stops
+func (s *Service) drainTimeout() time.Duration {
+	if s.globalConfig != nil && s.globalConfig.Global.DrainTimeout != nil {
+		return *s.globalConfig.Global.DrainTimeout
+	}
+	return constants.DefaultDrainTimeout
+}
+
+// stopTimeout returns how long stopping the service may take: drain_timeout
+// for hijacked connections and event streams to close, and then
+// constants.ServiceStopTimeout for the server to shut down
+func (s *Service) stopTimeout() time.Duration {
+	return s.drainTimeout() + constants.ServiceStopTimeout
+}
+
+// closeHandler closes the service's handler if it implements Close
+func (s *Service) closeHandler() {
+	if s.handler == nil {
+		return
+	}
+	if closer, ok := s.handler.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Warn("failed to close handler", "service", s.Config.Name, "error", err)
 		}
 	}
+}
 
-	return nil
+// retire closes the handler of a service replaced by an in-place update once
+// the requests it was serving have finished, or after timeout
+func (s *Service) retire(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for s.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(constants.RetiredHandlerPollInterval)
+	}
+	s.closeHandler()
 }
 
 // Shutdown gracefully shuts down all services
@@ -437,6 +702,12 @@ func (r *Registry) Shutdown(ctx context.Context) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	// Stop retrying services that are not running
+	r.stopped = true
+	for name := range r.retries {
+		r.cancelRetry(name)
+	}
+
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(r.services))
 
@@ -467,42 +738,78 @@ func (r *Registry) Shutdown(ctx context.Context) error {
 }
 
 // AddService dynamically starts and registers a new service.
-// Returns an error if the service already exists or fails to start.
+// Returns an error if the service already exists or fails to start, in which
+// case it is retried in the background until removed.
 // Thread-safe.
 func (r *Registry) AddService(svcCfg config.Service) error {
-	start := time.Now()
+	return r.AddServices([]config.Service{svcCfg})[0]
+}
 
+// AddServices creates and starts new services, up to startup_concurrency at
+// a time. It returns the error of each service in the order of svcs, nil for
+// those that started or are waiting for their dependencies or backend. A
+// service that fails to start is retried in the background until it is removed.
+// Thread-safe.
+func (r *Registry) AddServices(svcs []config.Service) []error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
 
-	// Check if service already exists
-	if _, exists := r.services[svcCfg.Name]; exists {
-		if r.metricsCollector != nil {
-			r.metricsCollector.RecordServiceOperation("add", false, time.Since(start))
+	errs := make([]error, len(svcs))
+	var toStart []*retry
+	var indexes []int // Index in svcs of each service in toStart
+	for i, svcCfg := range svcs {
+		if _, exists := r.services[svcCfg.Name]; exists {
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("add", false, 0)
+			}
+			errs[i] = fmt.Errorf("service %s already exists", svcCfg.Name)
+			continue
 		}
-		return fmt.Errorf("service %s already exists", svcCfg.Name)
-	}
 
-	// Start the service
-	svc, err := r.startService(svcCfg)
-	if err != nil {
-		if r.metricsCollector != nil {
-			r.metricsCollector.RecordServiceOperation("add", false, time.Since(start))
+		// Replace any earlier configuration still being retried
+		if r.takeOver(svcCfg) {
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("add", true, 0)
+			}
+			continue
+		}
+		r.cancelRetry(svcCfg.Name)
+		if r.mustWait(svcCfg) {
+			r.wait(svcCfg)
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("add", true, 0)
+			}
+			continue
 		}
-		return fmt.Errorf("failed to start service %s: %w", svcCfg.Name, err)
+		toStart = append(toStart, r.startLater(svcCfg))
+		indexes = append(indexes, i)
 	}
+	r.mu.Unlock()
+
+	r.startConcurrently(toStart, func(i int, svcCfg config.Service, svc *Service, err error, duration time.Duration) {
+		if err != nil {
+			r.startFailed(svcCfg, err)
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("add", false, duration)
+			}
+			errs[indexes[i]] = fmt.Errorf("failed to start service %s: %w", svcCfg.Name, err)
+			return
+		}
 
-	// Add to registry
-	r.services[svcCfg.Name] = svc
+		delete(r.retries, svcCfg.Name)
+		r.setRunning(svcCfg.Name, svc)
+		r.setState(svcCfg.Name, constants.ServiceStateRunning)
+		if r.metricsCollector != nil {
+			r.metricsCollector.RecordServiceOperation("add", true, duration)
+		}
+		slog.Info("added service", "service", svcCfg.Name)
+	})
 
-	// Record metrics
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.metricsCollector != nil {
-		r.metricsCollector.RecordServiceOperation("add", true, time.Since(start))
 		r.metricsCollector.SetActiveServices(len(r.services))
 	}
-
-	slog.Info("added service", "service", svcCfg.Name)
-	return nil
+	return errs
 }
 
 // RemoveService stops and removes a service from the registry.
@@ -515,34 +822,50 @@ func (r *Registry) RemoveService(name string) error {
 
 	svc, exists := r.services[name]
 	if !exists {
+		// A service that is not running only needs to stop being retried
+		if _, retrying := r.retries[name]; retrying {
+			r.forget(name)
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("remove", true, time.Since(start))
+			}
+			slog.Info("removed service", "service", name)
+			return nil
+		}
 		if r.metricsCollector != nil {
 			r.metricsCollector.RecordServiceOperation("remove", false, time.Since(start))
 		}
-		return fmt.Errorf("service %s not found", name)
+		return notFoundError(name)
 	}
 
-	// Stop the service (this will close listener and handler)
-	ctx, cancel := context.WithTimeout(context.Background(), constants.ServiceStopTimeout)
-	defer cancel()
+	// Remove from registry, keeping the service busy until it has stopped so
+	// that adding it again meanwhile starts it once it is done
+	r.setRunning(name, nil)
+	rt := newRetry(svc.Config, r.retryInterval)
+	rt.busy = true
+	rt.removing = true
+	r.retries[name] = rt
+
+	// The lock is not held while the service stops, which can take as long
+	// as its drain and stop timeouts
+	r.mu.Unlock()
+	err := r.stopUpdated(svc)
+	r.mu.Lock()
+	if r.reclaim(rt, svc.Config, nil) {
+		if rt.removing {
+			r.forget(name)
+		} else {
+			r.startAgain(rt)
+		}
+	}
 
-	if err := svc.Stop(ctx); err != nil {
+	if err != nil {
 		if r.metricsCollector != nil {
 			r.metricsCollector.RecordServiceOperation("remove", false, time.Since(start))
+			r.metricsCollector.SetActiveServices(len(r.services))
 		}
 		return fmt.Errorf("failed to stop service %s: %w", name, err)
 	}
 
-	// Close the tsnet server for this service
-	if r.tsServer != nil {
-		if err := r.tsServer.CloseService(name); err != nil {
-			slog.Error("failed to close tsnet server for service", "service", name, "error", err)
-			// Continue with removal even if tsnet close fails
-		}
-	}
-
-	// Remove from registry
-	delete(r.services, name)
-
 	// Record metrics
 	if r.metricsCollector != nil {
 		r.metricsCollector.RecordServiceOperation("remove", true, time.Since(start))
@@ -553,6 +876,15 @@ func (r *Registry) RemoveService(name string) error {
 	return nil
 }
 
+// ValidateService checks a service config for the errors UpdateService rejects
+// before stopping anything
+func (r *Registry) ValidateService(cfg config.Service) error {
+	if err := r.validateServiceConfig(cfg); err != nil {
+		return fmt.Errorf("invalid service configuration: %w", err)
+	}
+	return nil
+}
+
 // validateServiceConfig checks service config for common errors before updating.
 //
 // Validates:
@@ -618,9 +950,20 @@ func (r *Registry) validateServiceConfig(cfg config.Service) error {
 }
 
 // UpdateService updates an existing service with new configuration at runtime.
+// When only handler-level settings changed, the new handler is swapped in behind
+// the running node and in-flight requests finish on the old one. Otherwise the
+// service is stopped and started again, recreating its node.
 // Minimizes downtime by validating config before stopping the old service.
+// A service that is not running because it failed to start is started straight
+// away with the new configuration.
 // Thread-safe. Returns error if service not found, config invalid, stop/start fails.
 func (r *Registry) UpdateService(name string, newCfg config.Service) error {
+	return r.updateService(name, newCfg, false)
+}
+
+// updateService implements UpdateService, always recreating the service's
+// node when restartNode is set
+func (r *Registry) updateService(name string, newCfg config.Service, restartNode bool) error {
 	start := time.Now()
 
 	r.mu.Lock()
@@ -628,11 +971,12 @@ func (r *Registry) UpdateService(name string, newCfg config.Service) error {
 
 	// Check if service exists
 	oldSvc, exists := r.services[name]
-	if !exists {
+	_, retrying := r.retries[name]
+	if !exists && !retrying {
 		if r.metricsCollector != nil {
 			r.metricsCollector.RecordServiceOperation("update", false, time.Since(start))
 		}
-		return fmt.Errorf("service %s not found", name)
+		return notFoundError(name)
 	}
 
 	// Validate the new configuration as much as possible before stopping the old service
@@ -644,34 +988,139 @@ func (r *Registry) UpdateService(name string, newCfg config.Service) error {
 		return fmt.Errorf("invalid service configuration: %w", err)
 	}
 
+	// A service that is not running is started straight away with the new
+	// configuration instead of waiting for its next retry
+	if !exists {
+		if r.takeOver(newCfg) {
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("update", true, time.Since(start))
+			}
+			return nil
+		}
+		r.cancelRetry(name)
+		return r.startUpdated(name, newCfg, start)
+	}
+
 	// Store old service config for logging/debugging
 	oldConfig := oldSvc.Config
 
-	// Stop the old service
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if !restartNode && oldSvc.serving != nil && config.ServiceNodeEqual(oldConfig, newCfg) {
+		newSvc, err := r.replaceHandler(oldSvc, newCfg)
+		if err != nil {
+			// The old handler keeps serving
+			if r.metricsCollector != nil {
+				r.metricsCollector.RecordServiceOperation("update", false, time.Since(start))
+			}
+			return fmt.Errorf("failed to update service %s: %w", name, err)
+		}
+		r.setRunning(name, newSvc)
+
+		if r.metricsCollector != nil {
+			r.metricsCollector.RecordServiceOperation("update", true, time.Since(start))
+		}
+
+		slog.Info("updated service in place",
+			"service", name,
+			"old_backend", oldConfig.BackendAddr,
+			"new_backend", newCfg.BackendAddr,
+		)
+		return nil
+	}
 
-	if err := oldSvc.Stop(ctx); err != nil {
+	// Remove the old service, keeping it busy until the new configuration has
+	// started so that changes made meanwhile are applied once it is done
+	r.setRunning(name, nil)
+	rt := newRetry(newCfg, r.retryInterval)
+	rt.busy = true
+	r.retries[name] = rt
+	r.setState(name, constants.ServiceStateStarting)
+
+	// The lock is not held while the old service stops, which can take as
+	// long as its drain and stop timeouts
+	r.mu.Unlock()
+	err := r.stopUpdated(oldSvc)
+	r.mu.Lock()
+	if !r.reclaim(rt, newCfg, nil) {
+		if r.metricsCollector != nil {
+			r.metricsCollector.RecordServiceOperation("update", err == nil, time.Since(start))
+		}
+		return nil
+	}
+	if err != nil {
+		// The new configuration is started once the old service is retried
+		r.startFailed(newCfg, err)
 		if r.metricsCollector != nil {
 			r.metricsCollector.RecordServiceOperation("update", false, time.Since(start))
+			r.metricsCollector.SetActiveServices(len(r.services))
 		}
 		return fmt.Errorf("failed to stop service %s: %w", name, err)
 	}
 
+	if err := r.startUpdated(name, newCfg, start); err != nil {
+		return err
+	}
+
+	slog.Info("updated service",
+		"service", name,
+		"old_backend", oldConfig.BackendAddr,
+		"new_backend", newCfg.BackendAddr,
+	)
+	return nil
+}
+
+// stopUpdated stops a service that was removed from the registry and closes
+// its node, returning an error if it did not stop cleanly. Callers do not
+// hold r.mu.
+func (r *Registry) stopUpdated(svc *Service) error {
+	ctx, cancel := context.WithTimeout(context.Background(), svc.stopTimeout())
+	defer cancel()
+	if err := svc.Stop(ctx); err != nil {
+		return err
+	}
+
 	// Close the tsnet server for this service
 	if r.tsServer != nil {
-		if err := r.tsServer.CloseService(name); err != nil {
-			slog.Error("failed to close tsnet server for service", "service", name, "error", err)
-			// Continue with update even if tsnet close fails
+		if err := r.tsServer.CloseService(svc.Name); err != nil {
+			slog.Error("failed to close tsnet server for service", "service", svc.Name, "error", err)
+			// Continue even if tsnet close fails
+		}
+	}
+	return nil
+}
+
+// startUpdated starts a service that is not running with its updated
+// configuration, retrying it in the background if it fails to start, or
+// waits to start it if it is not ready. Callers hold r.mu, which is released
+// while the service starts.
+func (r *Registry) startUpdated(name string, newCfg config.Service, start time.Time) error {
+	if r.mustWait(newCfg) {
+		r.wait(newCfg)
+		if r.metricsCollector != nil {
+			r.metricsCollector.RecordServiceOperation("update", true, time.Since(start))
+			r.metricsCollector.SetActiveServices(len(r.services))
 		}
+		return nil
 	}
 
-	// Start the new service configuration
+	rt := r.startLater(newCfg)
+	r.setState(name, constants.ServiceStateStarting)
+
+	// The lock is not held while the node starts, which can take as long as
+	// its startup timeout
+	r.mu.Unlock()
 	newSvc, err := r.startService(newCfg)
+	r.mu.Lock()
+	if !r.reclaim(rt, newCfg, newSvc) {
+		// Changed or removed while starting, which is applied instead
+		if r.metricsCollector != nil {
+			r.metricsCollector.RecordServiceOperation("update", err == nil, time.Since(start))
+			r.metricsCollector.SetActiveServices(len(r.services))
+		}
+		return nil
+	}
+	delete(r.retries, name)
 	if err != nil {
-		// If we fail to start the new service, remove it from registry
-		// to avoid leaving a stopped service in the registry
-		delete(r.services, name)
+		r.startFailed(newCfg, err)
 
 		// Record failure metric
 		if r.metricsCollector != nil {
@@ -682,19 +1131,77 @@ func (r *Registry) UpdateService(name string, newCfg config.Service) error {
 		return fmt.Errorf("failed to start updated service %s: %w", name, err)
 	}
 
-	// Replace in registry
-	r.services[name] = newSvc
+	r.setRunning(name, newSvc)
+	r.setState(name, constants.ServiceStateRunning)
 
 	// Record success metric
 	if r.metricsCollector != nil {
 		r.metricsCollector.RecordServiceOperation("update", true, time.Since(start))
-		// Active services count doesn't change on update
+		r.metricsCollector.SetActiveServices(len(r.services))
 	}
-
-	slog.Info("updated service",
-		"service", name,
-		"old_backend", oldConfig.BackendAddr,
-		"new_backend", newCfg.BackendAddr,
-	)
 	return nil
 }
+
+// replaceHandler returns a service for newCfg, whose node-level settings match
+// old's, that serves behind old's node, listener and HTTP server. New requests
+// go to the new handler as soon as it is swapped in, while requests old is
+// serving finish on its handler, which is closed afterwards. If the new handler
+// cannot be created, old is left serving.
+func (r *Registry) replaceHandler(old *Service, newCfg config.Service) (*Service, error) {
+	svc := &Service{
+		Name:             newCfg.Name,
+		Config:           newCfg,
+		globalConfig:     r.config,
+		listener:         old.listener,
+		server:           old.server,
+		serving:          old.serving,
+		conns:            old.conns,
+		onDemand:         old.onDemand,
+		tsServer:         old.tsServer,
+		metricsCollector: r.metricsCollector,
+		tracerProvider:   r.tracerProvider,
+		accessLogSink:    r.accessLogSink,
+		events:           r.events,
+		startedAt:        old.startedAt,
+		recent:           old.recent,
+		nodeMetrics:      old.nodeMetrics,
+		waitInterval:     r.waitInterval,
+	}
+
+	handler, err := svc.CreateHandler()
+	if err != nil {
+		return nil, err
+	}
+	svc.handler = handler
+
+	// Keep what was changed through the admin API, unless the update changes
+	// the service's access_log setting
+	svc.draining.Store(old.draining.Load())
+	oldSetting, newSetting := old.Config.AccessLog, newCfg.AccessLog
+	if (oldSetting == nil && newSetting == nil) || (oldSetting != nil && newSetting != nil && *oldSetting == *newSetting) {
+		svc.accessLog.Store(old.accessLog.Load())
+	}
+
+	// Take over watching the node from what the old monitor last saw
+	if old.stopNodeMonitor != nil {
+		old.stopNodeMonitor()
+		old.stopNodeMonitor = nil
+		svc.nodeState = old.nodeState
+		svc.certWarned = old.certWarned
+		svc.backendUnhealthy = old.backendUnhealthy
+		svc.startNodeMonitor()
+	}
+
+	// Keep waiting for a backend that is not ready yet, unless the new
+	// configuration no longer waits for it
+	if old.stopBackendWait != nil {
+		old.stopWaitingForBackend()
+		if old.backendDown.Load() && newCfg.WaitForBackend == constants.WaitForBackendUnavailable {
+			svc.waitForBackend()
+		}
+	}
+
+	svc.serving.Store(handler)
+	go old.retire(constants.RetiredHandlerTimeout)
+	return svc, nil
+}
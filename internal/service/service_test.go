@@ -14,6 +14,7 @@ import (
 
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/events"
 	"github.com/jtdowney/tsbridge/internal/metrics"
 	"github.com/jtdowney/tsbridge/internal/middleware"
 	"github.com/jtdowney/tsbridge/internal/proxy"
@@ -279,12 +280,10 @@ func TestServiceStartupPartialFailures(t *testing.T) {
 		}
 
 		// For this test, we need a custom factory that will fail listener creation for service1
-		serviceCount := 0
 		failService1Factory := func(serviceName string) tsnet.TSNetServer {
-			serviceCount++
 			mock := tsnet.NewMockTSNetServer()
-			// Only fail for the first service (service1)
-			if serviceCount == 1 {
+			// Only fail for service1
+			if serviceName == "service1" {
 				mock.ListenFunc = func(network, addr string) (net.Listener, error) {
 					return nil, errors.NewResourceError("mock error for service1")
 				}
@@ -1901,6 +1900,107 @@ func TestRegistry_UpdateService_ValidationFailureKeepsOldService(t *testing.T) {
 	assert.Equal(t, "localhost:8001", svc.Config.BackendAddr) // Original config preserved
 }
 
+// TestRegistry_UpdateService_InPlace verifies that handler-level changes swap the
+// handler behind the running listener and let in-flight requests finish on the old one
+func TestRegistry_UpdateService_InPlace(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{})
+	oldBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(received)
+			<-release
+		}
+		_, _ = io.WriteString(w, "old")
+	}))
+	defer oldBackend.Close()
+	newBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "new")
+	}))
+	defer newBackend.Close()
+
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: oldBackend.Listener.Addr().String(), TLSMode: "off"})
+	before, ok := registry.GetService("web")
+	require.True(t, ok)
+
+	// A request that is still being served when the update happens
+	slow := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		before.server.Handler.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-received
+
+	updated := before.Config
+	updated.BackendAddr = newBackend.Listener.Addr().String()
+	updated.UpstreamHeaders = map[string]string{"X-Env": "test"}
+	require.NoError(t, registry.UpdateService("web", updated))
+
+	after, ok := registry.GetService("web")
+	require.True(t, ok)
+	assert.NotSame(t, before, after)
+	assert.Same(t, before.listener, after.listener, "the node's listener is kept")
+	assert.Same(t, before.server, after.server, "the HTTP server is kept")
+	assert.Equal(t, updated, after.Config)
+
+	// New requests go to the new handler
+	rec := httptest.NewRecorder()
+	after.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "new", rec.Body.String())
+
+	// The in-flight request finishes on the old handler
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusOK, slow.Code)
+	assert.Equal(t, "old", slow.Body.String())
+	assert.Eventually(t, func() bool { return before.inFlight.Load() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+// TestRegistry_UpdateService_InPlaceKeepsAdminChanges verifies that draining
+// and access logging changed through the admin API survive an in-place update
+func TestRegistry_UpdateService_InPlaceKeepsAdminChanges(t *testing.T) {
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off"})
+	require.NoError(t, registry.DrainService("web", true))
+	require.NoError(t, registry.SetAccessLog("web", false))
+
+	svc, ok := registry.GetService("web")
+	require.True(t, ok)
+	updated := svc.Config
+	updated.UpstreamHeaders = map[string]string{"X-Env": "test"}
+	require.NoError(t, registry.UpdateService("web", updated))
+
+	status, err := registry.Status(context.Background(), "web")
+	require.NoError(t, err)
+	assert.True(t, status.Draining, "update undrained the service")
+	assert.False(t, status.AccessLog, "update reset access logging")
+
+	// Changing access_log itself takes effect
+	updated.AccessLog = new(true)
+	require.NoError(t, registry.UpdateService("web", updated))
+	status, err = registry.Status(context.Background(), "web")
+	require.NoError(t, err)
+	assert.True(t, status.Draining)
+	assert.True(t, status.AccessLog)
+}
+
+// TestRegistry_UpdateService_NodeChange verifies that node-level changes recreate the node
+func TestRegistry_UpdateService_NodeChange(t *testing.T) {
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off"})
+	before, ok := registry.GetService("web")
+	require.True(t, ok)
+
+	updated := before.Config
+	updated.ListenAddr = ":8080"
+	updated.BackendAddr = "localhost:9090"
+	require.NoError(t, registry.UpdateService("web", updated))
+
+	after, ok := registry.GetService("web")
+	require.True(t, ok)
+	assert.NotSame(t, before.listener, after.listener)
+	assert.NotSame(t, before.server, after.server)
+	assert.Equal(t, updated, after.Config)
+}
+
 // TestRegistry_UpdateService_Concurrent verifies concurrent updates don't cause issues
 func TestRegistry_UpdateService_Concurrent(t *testing.T) {
 	cfg := &config.Config{
@@ -2684,3 +2784,36 @@ func TestStartService_FunnelDisabled_NoConnContext(t *testing.T) {
 	require.True(t, exists)
 	assert.Nil(t, svc.server.ConnContext, "ConnContext should not be set for non-Funnel service")
 }
+
+func TestRegistry_PublishesLifecycleEvents(t *testing.T) {
+	tsServer, err := testTailscaleServerFactory()
+	require.NoError(t, err)
+	defer tsServer.Close()
+
+	cfg := &config.Config{
+		// Start one service at a time so events are published in order
+		Global: config.Global{StartupConcurrency: new(1)},
+		Services: []config.Service{
+			{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"},
+			{Name: "broken", BackendAddr: "", TLSMode: "off"},
+		},
+	}
+	bus := events.NewBus(10)
+	registry := NewRegistry(cfg, tsServer)
+	registry.SetEventBus(bus)
+
+	require.Error(t, registry.StartServices())
+	require.NoError(t, registry.RemoveService("api"))
+
+	var types, services []string
+	for _, e := range bus.Recent(-1) {
+		types = append(types, e.Type)
+		services = append(services, e.Service)
+	}
+	assert.Equal(t, []string{events.ServiceStarted, events.ServiceStartFailed, events.ServiceStopped}, types)
+	assert.Equal(t, []string{"api", "broken", "api"}, services)
+
+	started := bus.Recent(-1)[0]
+	assert.Equal(t, "localhost:8080", started.Data["backend"])
+	assert.NotEmpty(t, bus.Recent(-1)[1].Error)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// startupConcurrency returns how many services are started at once
+func (r *Registry) startupConcurrency() int {
+	if r.config.Global.StartupConcurrency == nil {
+		return constants.DefaultStartupConcurrency
+	}
+	return *r.config.Global.StartupConcurrency
+}
+
+// startConcurrently starts the services of rts, which are busy, up to
+// startup_concurrency at a time. Each service is bounded by its own
+// startup_timeout rather than sharing one for the batch, and is started with
+// the configuration it has when its turn comes. Once it and every service
+// before it have finished, done is called with r.mu held and the outcome of
+// rts[i], in the order of rts, so that services are registered and logged in
+// a predictable order. Services that were changed or removed while starting
+// are taken back by reclaim instead. Callers do not hold r.mu, which is only
+// taken between starts so that status and admin requests are not held up.
+func (r *Registry) startConcurrently(rts []*retry, done func(i int, cfg config.Service, svc *Service, err error, duration time.Duration)) {
+	type result struct {
+		cfg      config.Service
+		svc      *Service
+		err      error
+		duration time.Duration
+		started  bool // Whether the service was still to be started when its turn came
+	}
+	type finish struct {
+		index  int
+		result result
+	}
+
+	finished := make(chan finish)
+	results := make([]*result, len(rts))
+	concurrency := r.startupConcurrency()
+	next, running, reported := 0, 0, 0
+	for reported < len(rts) {
+		for ; next < len(rts) && running < concurrency; next++ {
+			i, rt := next, rts[next]
+			r.mu.Lock()
+			cfg := rt.config
+			started := !r.stopped && r.retries[cfg.Name] == rt
+			if started {
+				r.setState(cfg.Name, constants.ServiceStateStarting)
+			}
+			r.mu.Unlock()
+
+			running++
+			go func() {
+				res := result{cfg: cfg, started: started}
+				if started {
+					slog.Debug("starting service",
+						"service", cfg.Name,
+						"index", i+1,
+						"of", len(rts),
+					)
+					start := time.Now()
+					res.svc, res.err = r.startService(cfg)
+					res.duration = time.Since(start)
+				}
+				finished <- finish{i, res}
+			}()
+		}
+
+		f := <-finished
+		running--
+		results[f.index] = &f.result
+		r.mu.Lock()
+		for ; reported < len(rts) && results[reported] != nil; reported++ {
+			res := results[reported]
+			if res.started && r.reclaim(rts[reported], res.cfg, res.svc) {
+				done(reported, res.cfg, res.svc, res.err, res.duration)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// startLater registers a service with cfg as busy to be started by
+// startConcurrently, so that changes to it while it starts are applied once it
+// is done. Callers hold r.mu.
+func (r *Registry) startLater(cfg config.Service) *retry {
+	rt := newRetry(cfg, r.retryInterval)
+	rt.busy = true
+	r.retries[cfg.Name] = rt
+	return rt
+}
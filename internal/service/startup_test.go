@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/jtdowney/tsbridge/internal/tsnet"
+)
+
+// concurrentRegistry returns a registry starting concurrency services at once
+// with names, calling listen before each service's node listens
+func concurrentRegistry(t *testing.T, concurrency int, listen func(name string) error, names ...string) *Registry {
+	t.Helper()
+	cfg := &config.Config{Global: config.Global{StartupConcurrency: new(concurrency)}}
+	for _, name := range names {
+		cfg.Services = append(cfg.Services, config.Service{Name: name, BackendAddr: "localhost:8080", TLSMode: "off"})
+	}
+
+	factory := func(serviceName string) tsnet.TSNetServer {
+		mock := tsnet.NewMockTSNetServer()
+		next := mock.ListenFunc
+		mock.ListenFunc = func(network, addr string) (net.Listener, error) {
+			if err := listen(serviceName); err != nil {
+				return nil, err
+			}
+			return next(network, addr)
+		}
+		return mock
+	}
+	tsServer, err := tailscale.NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, factory)
+	require.NoError(t, err)
+
+	registry := NewRegistry(cfg, tsServer)
+	registry.retryInterval = time.Hour
+	t.Cleanup(func() {
+		_ = registry.Shutdown(context.Background())
+	})
+	return registry
+}
+
+// inFlight tracks how many services are starting at once
+type inFlight struct {
+	current atomic.Int32
+	max     atomic.Int32
+}
+
+// enter records a service starting and waits up to a second for want
+// services to be starting at the same time
+func (f *inFlight) enter(want int32) {
+	current := f.current.Add(1)
+	for {
+		seen := f.max.Load()
+		if current <= seen || f.max.CompareAndSwap(seen, current) {
+			break
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for f.max.Load() < want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (f *inFlight) leave() {
+	f.current.Add(-1)
+}
+
+func TestRegistry_StartServicesConcurrently(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		wantMax     int32
+	}{
+		{name: "one at a time", concurrency: 1, wantMax: 1},
+		{name: "bounded", concurrency: 2, wantMax: 2},
+		{name: "more slots than services", concurrency: 10, wantMax: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flight := &inFlight{}
+			registry := concurrentRegistry(t, tt.concurrency, func(string) error {
+				flight.enter(tt.wantMax)
+				flight.leave()
+				return nil
+			}, "a", "b", "c", "d")
+
+			require.NoError(t, registry.StartServices())
+			assert.Equal(t, tt.wantMax, flight.max.Load())
+			for _, name := range []string{"a", "b", "c", "d"} {
+				state, _ := registry.State(name)
+				assert.Equal(t, constants.ServiceStateRunning, state, name)
+			}
+		})
+	}
+}
+
+func TestRegistry_StartServicesReportsInOrder(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	// The first service is the last to finish starting, and the second fails
+	lastStarted := make(chan struct{})
+	var waited atomic.Bool
+	registry := concurrentRegistry(t, 3, func(name string) error {
+		switch name {
+		case "first":
+			select {
+			case <-lastStarted:
+				waited.Store(true)
+			case <-time.After(5 * time.Second):
+			}
+		case "second":
+			return errors.NewResourceError("control server unreachable")
+		case "third":
+			close(lastStarted)
+		}
+		return nil
+	}, "first", "second", "third")
+
+	err := registry.StartServices()
+	startupErr, ok := errors.AsServiceStartupError(err)
+	require.True(t, ok, "expected ServiceStartupError, got %v", err)
+	assert.Equal(t, 2, startupErr.Successful)
+	assert.Contains(t, startupErr.Failures, "second")
+	assert.True(t, waited.Load(), "services did not start concurrently")
+
+	started := regexp.MustCompile(`msg="started service" service=(\w+)`).FindAllStringSubmatch(logs.String(), -1)
+	require.Len(t, started, 2)
+	assert.Equal(t, "first", started[0][1])
+	assert.Equal(t, "third", started[1][1])
+	state, _ := registry.State("second")
+	assert.Equal(t, constants.ServiceStateBackoff, state)
+}
+
+func TestRegistry_AddServices(t *testing.T) {
+	flight := &inFlight{}
+	registry := concurrentRegistry(t, 3, func(name string) error {
+		if name == "existing" {
+			return nil
+		}
+		flight.enter(3)
+		defer flight.leave()
+		if name == "broken" {
+			return errors.NewResourceError("control server unreachable")
+		}
+		return nil
+	}, "existing")
+	require.NoError(t, registry.StartServices())
+
+	errs := registry.AddServices([]config.Service{
+		{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off"},
+		{Name: "existing", BackendAddr: "localhost:8080", TLSMode: "off"},
+		{Name: "broken", BackendAddr: "localhost:8080", TLSMode: "off"},
+		{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"},
+	})
+
+	require.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.ErrorContains(t, errs[1], "service existing already exists")
+	assert.ErrorContains(t, errs[2], "failed to start service broken")
+	assert.NoError(t, errs[3])
+	assert.Equal(t, int32(3), flight.max.Load())
+
+	for _, name := range []string{"web", "api", "existing"} {
+		_, ok := registry.GetService(name)
+		assert.True(t, ok, name)
+	}
+	state, _ := registry.State("broken")
+	assert.Equal(t, constants.ServiceStateBackoff, state)
+}
+
+func TestRegistry_StartServicesWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	registry := concurrentRegistry(t, 1, func(name string) error {
+		if name == "slow" {
+			<-release
+		}
+		return nil
+	}, "slow", "removed", "changed")
+
+	started := make(chan error)
+	go func() { started <- registry.StartServices() }()
+
+	// The registry answers and takes changes while a node starts
+	assertState(t, registry, "slow", constants.ServiceStateStarting)
+	assert.Equal(t, 0, registry.Count())
+	require.NoError(t, registry.RemoveService("removed"))
+	require.NoError(t, registry.UpdateService("changed", config.Service{Name: "changed", BackendAddr: "localhost:9090", TLSMode: "off"}))
+	close(release)
+	require.NoError(t, <-started)
+
+	_, ok := registry.State("removed")
+	assert.False(t, ok, "removed service was started")
+	assert.Nil(t, registry.tsServer.GetServiceServer("removed"))
+	svc, ok := registry.GetService("changed")
+	require.True(t, ok)
+	assert.Equal(t, "localhost:9090", svc.Config.BackendAddr)
+}
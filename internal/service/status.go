@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/middleware"
+)
+
+// Status is a point-in-time view of a running service
+type Status struct {
+	Name          string    `json:"name"`
+	FQDN          string    `json:"fqdn,omitempty"` // Tailnet DNS name of the service's node
+	IPs           []string  `json:"ips,omitempty"`  // Tailscale IP addresses of the service's node
+	URL           string    `json:"url,omitempty"`  // Address the service is reached at on the tailnet
+	Funnel        bool      `json:"funnel"`         // The service is exposed to the internet through Funnel
+	Backend       string    `json:"backend"`
+	Healthy       bool      `json:"healthy"`                // The backend accepted a connection
+	HealthError   string    `json:"health_error,omitempty"` // Why the backend is unhealthy
+	Idle          bool      `json:"idle"`                   // The on-demand container is stopped until the next request
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	AccessLog     bool      `json:"access_log"`
+	Draining      bool      `json:"draining"`
+	InFlight      int64     `json:"in_flight"` // Requests currently being served
+}
+
+// Statuses returns the status of every running service, sorted by name. Backends
+// are checked concurrently.
+func (r *Registry) Statuses(ctx context.Context) []Status {
+	r.mu.RLock()
+	services := make([]*Service, 0, len(r.services))
+	for _, svc := range r.services {
+		services = append(services, svc)
+	}
+	r.mu.RUnlock()
+
+	statuses := make([]Status, len(services))
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		wg.Go(func() {
+			statuses[i] = svc.status(ctx)
+		})
+	}
+	wg.Wait()
+
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return statuses
+}
+
+// Configs returns the configuration of every running service by name
+func (r *Registry) Configs() map[string]config.Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	configs := make(map[string]config.Service, len(r.services))
+	for name, svc := range r.services {
+		configs[name] = svc.Config
+	}
+	return configs
+}
+
+// serviceConfig returns the configuration of a service that is running or
+// being retried
+func (r *Registry) serviceConfig(name string) (config.Service, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if svc, ok := r.services[name]; ok {
+		return svc.Config, true
+	}
+	if rt, ok := r.retries[name]; ok {
+		return rt.config, true
+	}
+	return config.Service{}, false
+}
+
+// Count returns the number of running services
+func (r *Registry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.services)
+}
+
+// Responsive returns an error if the registry looks deadlocked, without
+// waiting for it. Services stop and start without r.mu held, so the registry
+// counts as stuck once it has stayed locked with no service changing for
+// longer than RegistryStallTimeout.
+func (r *Registry) Responsive() error {
+	if r.mu.TryRLock() {
+		r.mu.RUnlock()
+		r.progress.Store(time.Now().UnixNano())
+		return nil
+	}
+
+	stalled := time.Since(time.Unix(0, r.progress.Load()))
+	if stalled > constants.RegistryStallTimeout {
+		return fmt.Errorf("service registry locked without progress for %s", stalled.Round(time.Second))
+	}
+	return nil
+}
+
+// Status returns the status of a single running service
+func (r *Registry) Status(ctx context.Context, name string) (Status, error) {
+	svc, ok := r.GetService(name)
+	if !ok {
+		return Status{}, notFoundError(name)
+	}
+	return svc.status(ctx), nil
+}
+
+// RestartService stops a service and starts it again with its current
+// configuration, recreating its tsnet node. A service that is waiting to be
+// retried is started straight away.
+func (r *Registry) RestartService(name string) error {
+	cfg, ok := r.serviceConfig(name)
+	if !ok {
+		return notFoundError(name)
+	}
+	if err := r.updateService(name, cfg, true); err != nil {
+		return err
+	}
+	slog.Info("restarted service", "service", name)
+	return nil
+}
+
+// DrainService starts or stops draining a service. A draining service answers
+// new requests with 503 Service Unavailable while in-flight requests finish.
+// Draining lasts until the service is next restarted.
+func (r *Registry) DrainService(name string, drain bool) error {
+	svc, ok := r.GetService(name)
+	if !ok {
+		return notFoundError(name)
+	}
+	svc.draining.Store(drain)
+	slog.Info("set service draining", "service", name, "draining", drain)
+	return nil
+}
+
+// SetAccessLog turns access logging for a service on or off until the service
+// is next restarted or a reload changes its access_log setting
+func (r *Registry) SetAccessLog(name string, enabled bool) error {
+	svc, ok := r.GetService(name)
+	if !ok {
+		return notFoundError(name)
+	}
+	svc.accessLog.Store(enabled)
+	slog.Info("set service access logging", "service", name, "access_log", enabled)
+	return nil
+}
+
+// NodeState returns the Tailscale backend state of a running service's node,
+// which is "Running" once the node is connected to the tailnet. It does not
+// wait for changes to the registry in progress, so readiness probes are
+// answered during a reload.
+func (r *Registry) NodeState(ctx context.Context, name string) (string, error) {
+	svc, ok := r.runningService(name)
+	if !ok {
+		return "", notFoundError(name)
+	}
+	if svc.tsServer == nil {
+		return "", fmt.Errorf("service %s has no tailscale server", name)
+	}
+	info, err := svc.tsServer.ServiceNodeInfo(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return info.BackendState, nil
+}
+
+// RecentRequests returns the latest access log entries of a service, newest
+// first. Requests are only recorded while access logging is on.
+func (r *Registry) RecentRequests(name string) ([]middleware.AccessEntry, error) {
+	svc, ok := r.GetService(name)
+	if !ok {
+		return nil, notFoundError(name)
+	}
+	if svc.recent == nil {
+		return nil, nil
+	}
+	return svc.recent.Entries(), nil
+}
+
+// status builds the current Status of the service
+func (s *Service) status(ctx context.Context) Status {
+	status := Status{
+		Name:      s.Name,
+		Backend:   s.Config.BackendAddr,
+		Funnel:    s.Config.FunnelEnabled != nil && *s.Config.FunnelEnabled,
+		StartedAt: s.startedAt,
+		AccessLog: s.accessLog.Load(),
+		Draining:  s.draining.Load(),
+		InFlight:  s.inFlight.Load(),
+	}
+	if !s.startedAt.IsZero() {
+		status.UptimeSeconds = int64(time.Since(s.startedAt).Seconds())
+	}
+
+	if s.tsServer != nil {
+		info, err := s.tsServer.ServiceNodeInfo(ctx, s.Name)
+		if err != nil {
+			slog.Debug("failed to get node info", "service", s.Name, "error", err)
+		} else {
+			status.FQDN = info.FQDN
+			status.IPs = info.IPs
+			status.URL = s.url(info.FQDN)
+		}
+	}
+
+	// The backend of an on-demand service is not checked while its container
+	// is stopped, as it is meant to be unreachable until the next request
+	if s.onDemand != nil && !s.onDemand.isRunning() {
+		status.Idle = true
+		return status
+	}
+	if err := s.CheckBackend(ctx); err != nil {
+		status.HealthError = err.Error()
+	} else {
+		status.Healthy = true
+	}
+	return status
+}
+
+// CheckBackend reports whether the service's backend accepts connections and
+// records the result in the backend health metric
+func (s *Service) CheckBackend(ctx context.Context) error {
+	err := dialBackend(ctx, s.Config.BackendAddr)
+	if s.metricsCollector != nil {
+		s.metricsCollector.SetBackendHealth(s.Name, err == nil)
+	}
+	return err
+}
+
+// dialBackend reports whether the backend at addr accepts connections
+func dialBackend(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, constants.BackendHealthCheckTimeout)
+	defer cancel()
+
+	network, address := backendDialTarget(addr)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err == nil {
+		_ = conn.Close()
+	}
+	return err
+}
+
+// url returns the address the service is reached at through the node named fqdn
+func (s *Service) url(fqdn string) string {
+	// Funnel always serves HTTPS on 443
+	if s.Config.FunnelEnabled != nil && *s.Config.FunnelEnabled {
+		return "https://" + fqdn
+	}
+
+	scheme, defaultPort := "https", "443"
+	if s.Config.TLSMode == constants.TLSModeOff {
+		scheme, defaultPort = "http", "80"
+	}
+	host := fqdn
+	if _, port, err := net.SplitHostPort(s.Config.ListenAddr); err == nil && port != "" && port != defaultPort {
+		host = net.JoinHostPort(fqdn, port)
+	}
+	return scheme + "://" + host
+}
+
+// backendDialTarget returns the network and address to dial to reach a backend
+// address in any of the forms accepted by config.ValidateBackendAddress
+func backendDialTarget(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return "unix", path
+	}
+
+	if u, err := url.Parse(addr); err == nil && u.Host != "" && strings.Contains(addr, "://") {
+		if u.Port() != "" {
+			return "tcp", u.Host
+		}
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		return "tcp", net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return "tcp", addr
+}
+
+// drainMiddleware counts in-flight requests and rejects new requests while the
+// service is draining
+func (s *Service) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "Service is draining", http.StatusServiceUnavailable)
+			return
+		}
+
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
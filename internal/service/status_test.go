@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startStatusRegistry starts a registry with the given services on mock tsnet servers
+func startStatusRegistry(t *testing.T, services ...config.Service) *Registry {
+	t.Helper()
+	cfg := &config.Config{
+		Tailscale: config.Tailscale{AuthKey: "test-key"},
+		Services:  services,
+	}
+	cfg.SetDefaults()
+
+	tsServer, err := testTailscaleServerFactory()
+	require.NoError(t, err)
+
+	registry := NewRegistry(cfg, tsServer)
+	require.NoError(t, registry.StartServices())
+	t.Cleanup(func() {
+		_ = registry.Shutdown(context.Background())
+	})
+	return registry
+}
+
+// closedAddr returns a loopback address with nothing listening on it
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	return addr
+}
+
+func TestRegistry_Statuses(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	downAddr := closedAddr(t)
+
+	registry := startStatusRegistry(t,
+		config.Service{Name: "web", BackendAddr: backend.Listener.Addr().String(), TLSMode: "off"},
+		config.Service{Name: "api", BackendAddr: downAddr, TLSMode: "off"},
+	)
+
+	statuses := registry.Statuses(context.Background())
+	require.Len(t, statuses, 2)
+
+	assert.Equal(t, "api", statuses[0].Name)
+	assert.Equal(t, downAddr, statuses[0].Backend)
+	assert.False(t, statuses[0].Healthy)
+	assert.NotEmpty(t, statuses[0].HealthError)
+
+	assert.Equal(t, "web", statuses[1].Name)
+	assert.True(t, statuses[1].Healthy)
+	assert.Empty(t, statuses[1].HealthError)
+	assert.True(t, statuses[1].AccessLog)
+	assert.False(t, statuses[1].StartedAt.IsZero())
+
+	t.Run("single service", func(t *testing.T) {
+		status, err := registry.Status(context.Background(), "web")
+		require.NoError(t, err)
+		assert.Equal(t, "web", status.Name)
+
+		_, err = registry.Status(context.Background(), "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRegistry_DrainService(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: backend.URL, TLSMode: "off"})
+	svc, ok := registry.GetService("web")
+	require.True(t, ok)
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		svc.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec
+	}
+
+	require.NoError(t, registry.DrainService("web", true))
+	rec := serve()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "close", rec.Header().Get("Connection"))
+
+	status, err := registry.Status(context.Background(), "web")
+	require.NoError(t, err)
+	assert.True(t, status.Draining)
+
+	require.NoError(t, registry.DrainService("web", false))
+	assert.Equal(t, http.StatusNoContent, serve().Code)
+
+	assert.ErrorIs(t, registry.DrainService("missing", true), ErrNotFound)
+}
+
+func TestRegistry_SetAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	registry := startStatusRegistry(t, config.Service{
+		Name:        "web",
+		BackendAddr: backend.URL,
+		TLSMode:     "off",
+		AccessLog:   new(false),
+	})
+	svc, ok := registry.GetService("web")
+	require.True(t, ok)
+
+	serve := func(path string) {
+		svc.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	serve("/before")
+	require.NoError(t, registry.SetAccessLog("web", true))
+	serve("/after")
+
+	assert.NotContains(t, logs.String(), "/before")
+	assert.Contains(t, logs.String(), "/after")
+
+	// Only logged requests are kept for the dashboard
+	recent, err := registry.RecentRequests("web")
+	require.NoError(t, err)
+	require.Len(t, recent, 1)
+	assert.Equal(t, "/after", recent[0].Path)
+
+	assert.ErrorIs(t, registry.SetAccessLog("missing", true), ErrNotFound)
+	_, err = registry.RecentRequests("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_URL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Service
+		want string
+	}{
+		{name: "tls", cfg: config.Service{TLSMode: "auto"}, want: "https://web.example.ts.net"},
+		{name: "tls default port", cfg: config.Service{TLSMode: "auto", ListenAddr: ":443"}, want: "https://web.example.ts.net"},
+		{name: "tls custom port", cfg: config.Service{TLSMode: "auto", ListenAddr: ":8443"}, want: "https://web.example.ts.net:8443"},
+		{name: "plain", cfg: config.Service{TLSMode: "off"}, want: "http://web.example.ts.net"},
+		{name: "plain custom port", cfg: config.Service{TLSMode: "off", ListenAddr: "0.0.0.0:8080"}, want: "http://web.example.ts.net:8080"},
+		{name: "funnel", cfg: config.Service{TLSMode: "auto", ListenAddr: ":8443", FunnelEnabled: new(true)}, want: "https://web.example.ts.net"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &Service{Config: tt.cfg}
+			assert.Equal(t, tt.want, svc.url("web.example.ts.net"))
+		})
+	}
+}
+
+func TestRegistry_RestartService(t *testing.T) {
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off"})
+	before, ok := registry.GetService("web")
+	require.True(t, ok)
+	require.NoError(t, registry.DrainService("web", true))
+
+	require.NoError(t, registry.RestartService("web"))
+
+	after, ok := registry.GetService("web")
+	require.True(t, ok)
+	assert.NotSame(t, before, after)
+	assert.NotSame(t, before.listener, after.listener, "a restart recreates the node")
+	assert.Equal(t, before.Config, after.Config)
+	assert.False(t, after.draining.Load(), "runtime state is reset by a restart")
+
+	assert.ErrorIs(t, registry.RestartService("missing"), ErrNotFound)
+}
+
+func TestBackendDialTarget(t *testing.T) {
+	tests := []struct {
+		addr        string
+		wantNetwork string
+		wantAddress string
+	}{
+		{"localhost:8080", "tcp", "localhost:8080"},
+		{"unix:///var/run/app.sock", "unix", "/var/run/app.sock"},
+		{"http://backend:9000", "tcp", "backend:9000"},
+		{"http://backend", "tcp", "backend:80"},
+		{"https://backend", "tcp", "backend:443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			network, address := backendDialTarget(tt.addr)
+			assert.Equal(t, tt.wantNetwork, network)
+			assert.Equal(t, tt.wantAddress, address)
+		})
+	}
+}
+
+func TestRegistry_NodeStateDuringChange(t *testing.T) {
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off"})
+
+	// A reload holds the registry while services stop and start
+	registry.mu.Lock()
+	done := make(chan error)
+	go func() {
+		_, err := registry.NodeState(context.Background(), "web")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		// The mock node has no status, but the service was found
+		assert.NotErrorIs(t, err, ErrNotFound)
+	case <-time.After(5 * time.Second):
+		t.Fatal("node state waited for the registry")
+	}
+	registry.mu.Unlock()
+
+	require.NoError(t, registry.RemoveService("web"))
+	_, err := registry.NodeState(context.Background(), "web")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRegistry_Responsive(t *testing.T) {
+	registry := startStatusRegistry(t, config.Service{Name: "web", BackendAddr: "localhost:8080", TLSMode: "off"})
+	assert.NoError(t, registry.Responsive())
+
+	// Holding the lock briefly is not a deadlock
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	assert.NoError(t, registry.Responsive())
+
+	// Unless nothing has changed for longer than the lock is ever held
+	registry.progress.Store(time.Now().Add(-constants.RegistryStallTimeout - time.Second).UnixNano())
+	assert.ErrorContains(t, registry.Responsive(), "locked without progress")
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+)
+
+// retry is a service that is not running and is started again after a
+// backoff, or once it is ready when it is waiting
+type retry struct {
+	config   config.Service
+	waiting  bool // Waiting for its dependencies or backend rather than retrying
+	busy     bool // Being started or stopped without r.mu held
+	removing bool // Being stopped to be removed, cleared if it is added again meanwhile
+	attempts int  // Failed attempts to start the service since it last ran
+	backoff  *backoff.ExponentialBackOff
+	timer    *time.Timer // Makes the next attempt, nil once the service has failed for good
+	err      error       // Why the service last failed
+}
+
+// newRetry returns a retry of a service with cfg that has not failed yet,
+// first waiting around interval
+func newRetry(cfg config.Service, interval time.Duration) *retry {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = interval
+	b.MaxInterval = constants.ServiceRetryMaxInterval
+	b.MaxElapsedTime = 0 // Retry for as long as max_start_attempts allows
+	b.Multiplier = constants.RetryMultiplier
+	b.RandomizationFactor = constants.ServiceRetryRandomizationFactor
+	b.Reset()
+	return &retry{config: cfg, backoff: b}
+}
+
+// setState records the supervisor state of a service. Callers hold r.mu.
+func (r *Registry) setState(name, state string) {
+	previous := r.states[name]
+	if previous == state {
+		return
+	}
+	r.states[name] = state
+	r.progress.Store(time.Now().UnixNano())
+	if r.metricsCollector != nil {
+		r.metricsCollector.SetServiceState(name, state)
+	}
+	slog.Debug("service state changed", "service", name, "from", previous, "to", state)
+	if state == constants.ServiceStateRunning {
+		r.wakeDependents(name)
+	}
+}
+
+// forget stops supervising a service that is no longer configured. Callers
+// hold r.mu.
+func (r *Registry) forget(name string) {
+	r.cancelRetry(name)
+	delete(r.states, name)
+	if r.metricsCollector != nil {
+		r.metricsCollector.DeleteServiceState(name)
+	}
+}
+
+// cancelRetry stops retrying a service that is not running. Callers hold r.mu.
+func (r *Registry) cancelRetry(name string) {
+	rt, ok := r.retries[name]
+	if !ok {
+		return
+	}
+	if rt.timer != nil {
+		rt.timer.Stop()
+	}
+	delete(r.retries, name)
+}
+
+// State returns the supervisor state of a service: one of pending, waiting,
+// starting, running, backoff or failed
+func (r *Registry) State(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	state, ok := r.states[name]
+	return state, ok
+}
+
+// maxStartAttempts returns how many attempts are made to start a service
+// before giving up on it, or 0 to retry forever
+func (r *Registry) maxStartAttempts() int {
+	if r.config.Global.MaxStartAttempts == nil {
+		return 0
+	}
+	return *r.config.Global.MaxStartAttempts
+}
+
+// startFailed schedules another attempt to start a service with cfg that
+// failed to start with err. Callers hold r.mu.
+func (r *Registry) startFailed(cfg config.Service, err error) {
+	rt, ok := r.retries[cfg.Name]
+	if !ok {
+		rt = newRetry(cfg, r.retryInterval)
+		r.retries[cfg.Name] = rt
+	}
+	rt.config = cfg
+	rt.attempts++
+	rt.err = err
+	r.scheduleRetry(rt)
+}
+
+// scheduleRetry starts the service of rt again after its next backoff, or
+// gives up on it once max_start_attempts is reached. Callers hold r.mu.
+func (r *Registry) scheduleRetry(rt *retry) {
+	name := rt.config.Name
+	if r.stopped {
+		return
+	}
+
+	if maxAttempts := r.maxStartAttempts(); maxAttempts > 0 && rt.attempts >= maxAttempts {
+		rt.timer = nil
+		r.setState(name, constants.ServiceStateFailed)
+		slog.Error("giving up on starting service",
+			"service", name,
+			"attempts", rt.attempts,
+			"error", rt.err,
+		)
+		return
+	}
+
+	delay := rt.backoff.NextBackOff()
+	r.setState(name, constants.ServiceStateBackoff)
+	slog.Warn("service will be started again",
+		"service", name,
+		"failed_attempts", rt.attempts,
+		"retry_in", delay,
+		"error", rt.err,
+	)
+	rt.timer = time.AfterFunc(delay, func() { r.retryStart(rt) })
+}
+
+// retryStart makes another attempt to start the service of rt, unless it was
+// changed or removed, or the registry shut down, since it was scheduled
+func (r *Registry) retryStart(rt *retry) {
+	name := rt.config.Name
+	r.mu.Lock()
+	if r.stopped || r.retries[name] != rt {
+		r.mu.Unlock()
+		return
+	}
+	rt.timer = nil
+	rt.busy = true
+	cfg := rt.config
+	if r.metricsCollector != nil {
+		r.metricsCollector.RecordServiceStartRetry(name)
+	}
+	r.setState(name, constants.ServiceStateStarting)
+	r.mu.Unlock()
+
+	// The lock is not held while the node starts, which can take as long as
+	// its startup timeout
+	svc, err := r.startService(cfg)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.reclaim(rt, cfg, svc) {
+		return
+	}
+	if err != nil {
+		rt.attempts++
+		rt.err = err
+		r.scheduleRetry(rt)
+		return
+	}
+
+	delete(r.retries, name)
+	r.setRunning(name, svc)
+	r.setState(name, constants.ServiceStateRunning)
+	if r.metricsCollector != nil {
+		r.metricsCollector.SetActiveServices(len(r.services))
+	}
+	slog.Info("started service after retrying", "service", name, "failed_attempts", rt.attempts)
+}
+
+// reclaim takes back the busy service of rt once it was started with cfg as
+// svc, nil if it failed to start, or stopped. It returns false after stopping
+// svc if the service was removed or the registry shut down in the meantime,
+// or after starting it again if its configuration was changed. Callers hold
+// r.mu.
+func (r *Registry) reclaim(rt *retry, cfg config.Service, svc *Service) bool {
+	name := cfg.Name
+	if !r.stopped && r.retries[name] == rt && config.ServiceConfigEqual(rt.config, cfg) {
+		rt.busy = false
+		return true
+	}
+
+	if svc != nil {
+		r.mu.Unlock()
+		r.stopService(svc)
+		r.mu.Lock()
+	}
+	if !r.stopped && r.retries[name] == rt {
+		rt.busy = false
+		r.startAgain(rt)
+	}
+	return false
+}
+
+// takeOver hands cfg to a service that is busy being started or stopped, which
+// is started again with it once it is done. It returns false if the service
+// is not busy. Callers hold r.mu.
+func (r *Registry) takeOver(cfg config.Service) bool {
+	rt, ok := r.retries[cfg.Name]
+	if !ok || !rt.busy {
+		return false
+	}
+	rt.config = cfg
+	rt.removing = false
+	slog.Info("service is busy, its new configuration is applied once it is done", "service", cfg.Name)
+	return true
+}
+
+// startAgain starts the service of rt straight away, or once it is ready,
+// after its configuration was changed while it was busy. Callers hold r.mu.
+func (r *Registry) startAgain(rt *retry) {
+	rt.waiting = r.mustWait(rt.config)
+	if rt.waiting {
+		r.setState(rt.config.Name, constants.ServiceStateWaiting)
+		rt.timer = time.AfterFunc(0, func() { r.checkWaiting(rt) })
+		return
+	}
+	rt.timer = time.AfterFunc(0, func() { r.retryStart(rt) })
+}
+
+// stopService stops a service that is no longer in the registry and closes
+// its node. Callers do not hold r.mu.
+func (r *Registry) stopService(svc *Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), svc.stopTimeout())
+	defer cancel()
+	if err := svc.Stop(ctx); err != nil {
+		slog.Warn("failed to stop service", "service", svc.Name, "error", err)
+	}
+	if r.tsServer != nil {
+		if err := r.tsServer.CloseService(svc.Name); err != nil {
+			slog.Error("failed to close tsnet server for service", "service", svc.Name, "error", err)
+		}
+	}
+}
+
+// serveFailed handles the HTTP server of a running service stopping with err
+// when it was not shut down, such as when its tailnet node's listener fails.
+// The service is stopped and started again after a backoff.
+func (r *Registry) serveFailed(name string, server *http.Server, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The service may have been stopped or replaced in the meantime
+	svc, ok := r.services[name]
+	if r.stopped || !ok || svc.server != server {
+		return
+	}
+	slog.Error("service stopped unexpectedly", "service", name, "error", err)
+
+	r.setRunning(name, nil)
+	if r.metricsCollector != nil {
+		r.metricsCollector.SetActiveServices(len(r.services))
+	}
+	rt := newRetry(svc.Config, r.retryInterval)
+	rt.err = err
+	rt.busy = true
+	r.retries[name] = rt
+
+	// The lock is not held while the service stops, which can take as long
+	// as its stop timeout
+	r.mu.Unlock()
+	r.stopService(svc)
+	r.mu.Lock()
+	if r.reclaim(rt, svc.Config, nil) {
+		r.scheduleRetry(rt)
+	}
+}
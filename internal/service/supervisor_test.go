@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/jtdowney/tsbridge/internal/tailscale"
+	"github.com/jtdowney/tsbridge/internal/tsnet"
+)
+
+// flakyListens counts attempts to listen and fails the first failures of them
+type flakyListens struct {
+	attempts atomic.Int32
+	failures atomic.Int32
+}
+
+// supervisedRegistry returns a registry that retries services after a
+// millisecond, whose nodes fail to listen while listens has failures left
+func supervisedRegistry(t *testing.T, listens *flakyListens, maxStartAttempts *int) (*Registry, *metrics.Collector) {
+	t.Helper()
+	cfg := &config.Config{
+		Global: config.Global{MaxStartAttempts: maxStartAttempts},
+		Services: []config.Service{
+			{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"},
+		},
+	}
+
+	factory := func(serviceName string) tsnet.TSNetServer {
+		mock := tsnet.NewMockTSNetServer()
+		listen := mock.ListenFunc
+		mock.ListenFunc = func(network, addr string) (net.Listener, error) {
+			listens.attempts.Add(1)
+			if listens.failures.Add(-1) >= 0 {
+				return nil, errors.NewResourceError("control server unreachable")
+			}
+			return listen(network, addr)
+		}
+		return mock
+	}
+	tsServer, err := tailscale.NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, factory)
+	require.NoError(t, err)
+
+	collector := metrics.NewCollector()
+	require.NoError(t, collector.Register(prometheus.NewRegistry()))
+
+	registry := NewRegistry(cfg, tsServer)
+	registry.SetMetricsCollector(collector)
+	registry.retryInterval = time.Millisecond
+	t.Cleanup(func() {
+		_ = registry.Shutdown(context.Background())
+	})
+	return registry, collector
+}
+
+// assertState waits for a service to reach state
+func assertState(t *testing.T, registry *Registry, name, state string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		current, _ := registry.State(name)
+		return current == state
+	}, 5*time.Second, time.Millisecond, "service %s never reached state %s", name, state)
+}
+
+func TestRegistry_RetriesFailedStart(t *testing.T) {
+	listens := &flakyListens{}
+	listens.failures.Store(3)
+	registry, collector := supervisedRegistry(t, listens, nil)
+
+	err := registry.StartServices()
+	startupErr, ok := errors.AsServiceStartupError(err)
+	require.True(t, ok, "expected ServiceStartupError, got %v", err)
+	assert.True(t, startupErr.AllFailed())
+
+	assertState(t, registry, "api", constants.ServiceStateRunning)
+	_, running := registry.GetService("api")
+	assert.True(t, running)
+	assert.Equal(t, int32(4), listens.attempts.Load())
+	assert.Equal(t, 3.0, testutil.ToFloat64(collector.ServiceStartRetries.WithLabelValues("api")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.ServiceState.WithLabelValues("api", constants.ServiceStateRunning)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.ServicesActive))
+}
+
+func TestRegistry_GivesUpAfterMaxStartAttempts(t *testing.T) {
+	listens := &flakyListens{}
+	listens.failures.Store(100)
+	registry, collector := supervisedRegistry(t, listens, new(3))
+
+	require.Error(t, registry.StartServices())
+	assertState(t, registry, "api", constants.ServiceStateFailed)
+	assert.Equal(t, int32(3), listens.attempts.Load())
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.ServiceState.WithLabelValues("api", constants.ServiceStateFailed)))
+
+	// Nothing is retried once the service has failed
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(3), listens.attempts.Load())
+
+	t.Run("restart starts it straight away", func(t *testing.T) {
+		listens.failures.Store(0)
+		require.NoError(t, registry.RestartService("api"))
+		state, _ := registry.State("api")
+		assert.Equal(t, constants.ServiceStateRunning, state)
+	})
+}
+
+func TestRegistry_RestartsCrashedService(t *testing.T) {
+	listens := &flakyListens{}
+	registry, collector := supervisedRegistry(t, listens, nil)
+	require.NoError(t, registry.StartServices())
+	first, ok := registry.GetService("api")
+	require.True(t, ok)
+
+	// The node's listener failing stops the HTTP server without a shutdown
+	listens.failures.Store(1)
+	require.NoError(t, first.listener.Close())
+
+	assert.Eventually(t, func() bool {
+		svc, ok := registry.GetService("api")
+		return ok && svc != first
+	}, 5*time.Second, time.Millisecond)
+	assertState(t, registry, "api", constants.ServiceStateRunning)
+	assert.Equal(t, int32(3), listens.attempts.Load())
+	assert.Equal(t, 2.0, testutil.ToFloat64(collector.ServiceStartRetries.WithLabelValues("api")))
+}
+
+func TestRegistry_ChangesToRetriedService(t *testing.T) {
+	failingRegistry := func(t *testing.T) (*Registry, *flakyListens) {
+		listens := &flakyListens{}
+		listens.failures.Store(100)
+		registry, _ := supervisedRegistry(t, listens, new(1))
+		require.Error(t, registry.StartServices())
+		assertState(t, registry, "api", constants.ServiceStateFailed)
+		return registry, listens
+	}
+
+	t.Run("remove stops retrying", func(t *testing.T) {
+		registry, _ := failingRegistry(t)
+		require.NoError(t, registry.RemoveService("api"))
+		_, ok := registry.State("api")
+		assert.False(t, ok)
+		assert.ErrorIs(t, registry.RemoveService("api"), ErrNotFound)
+	})
+
+	t.Run("update starts the new configuration", func(t *testing.T) {
+		registry, listens := failingRegistry(t)
+		listens.failures.Store(0)
+		newCfg := config.Service{Name: "api", BackendAddr: "localhost:9090", TLSMode: "off"}
+		require.NoError(t, registry.UpdateService("api", newCfg))
+
+		svc, ok := registry.GetService("api")
+		require.True(t, ok)
+		assert.Equal(t, "localhost:9090", svc.Config.BackendAddr)
+		state, _ := registry.State("api")
+		assert.Equal(t, constants.ServiceStateRunning, state)
+	})
+
+	t.Run("failed update is retried with the new configuration", func(t *testing.T) {
+		registry, listens := failingRegistry(t)
+		newCfg := config.Service{Name: "api", BackendAddr: "localhost:9090", TLSMode: "off"}
+		require.Error(t, registry.UpdateService("api", newCfg))
+
+		registry.mu.RLock()
+		rt := registry.retries["api"]
+		registry.mu.RUnlock()
+		require.NotNil(t, rt)
+		assert.Equal(t, newCfg, rt.config)
+		assert.Equal(t, 1, rt.attempts)
+		assert.Equal(t, int32(2), listens.attempts.Load())
+	})
+}
+
+func TestRegistry_ShutdownStopsRetries(t *testing.T) {
+	listens := &flakyListens{}
+	listens.failures.Store(100)
+	registry, _ := supervisedRegistry(t, listens, nil)
+	registry.retryInterval = time.Hour
+
+	require.Error(t, registry.StartServices())
+	state, _ := registry.State("api")
+	assert.Equal(t, constants.ServiceStateBackoff, state)
+
+	require.NoError(t, registry.Shutdown(context.Background()))
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	assert.Empty(t, registry.retries)
+}
+
+func TestRegistry_ChangesToServiceBeingRetried(t *testing.T) {
+	// startingRegistry returns a registry retrying a service that failed to
+	// start, whose node blocks listening until release is closed
+	startingRegistry := func(t *testing.T) (*Registry, chan struct{}) {
+		release := make(chan struct{})
+		var attempts atomic.Int32
+		registry := gatedRegistry(t, func(string) error {
+			if attempts.Add(1) == 1 {
+				return assert.AnError
+			}
+			<-release
+			return nil
+		}, config.Service{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"})
+		registry.retryInterval = time.Millisecond
+
+		require.Error(t, registry.StartServices())
+		// The registry is not locked while the service starts
+		assertState(t, registry, "api", constants.ServiceStateStarting)
+		return registry, release
+	}
+
+	t.Run("remove stops the service once started", func(t *testing.T) {
+		registry, release := startingRegistry(t)
+		require.NoError(t, registry.RemoveService("api"))
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			return registry.tsServer.GetServiceServer("api") == nil
+		}, 5*time.Second, time.Millisecond, "node of removed service was not closed")
+		_, ok := registry.GetService("api")
+		assert.False(t, ok)
+		_, ok = registry.State("api")
+		assert.False(t, ok)
+	})
+
+	t.Run("update is applied once started", func(t *testing.T) {
+		registry, release := startingRegistry(t)
+		newCfg := config.Service{Name: "api", BackendAddr: "localhost:9090", TLSMode: "off"}
+		require.NoError(t, registry.UpdateService("api", newCfg))
+		close(release)
+
+		assertState(t, registry, "api", constants.ServiceStateRunning)
+		svc, ok := registry.GetService("api")
+		require.True(t, ok)
+		assert.Equal(t, "localhost:9090", svc.Config.BackendAddr)
+	})
+}
+
+func TestRegistry_ChangesWhileServiceStops(t *testing.T) {
+	// stoppingRegistry returns a running registry whose first node of api
+	// blocks closing until release is closed, and reports on closing once
+	// it has begun
+	stoppingRegistry := func(t *testing.T) (registry *Registry, closing, release chan struct{}) {
+		closing, release = make(chan struct{}), make(chan struct{})
+		var nodes atomic.Int32
+		factory := func(serviceName string) tsnet.TSNetServer {
+			mock := tsnet.NewMockTSNetServer()
+			if nodes.Add(1) == 1 {
+				mock.CloseFunc = func() error {
+					close(closing)
+					<-release
+					return nil
+				}
+			}
+			return mock
+		}
+		tsServer, err := tailscale.NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, factory)
+		require.NoError(t, err)
+
+		registry = NewRegistry(&config.Config{Services: []config.Service{
+			{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"},
+		}}, tsServer)
+		registry.retryInterval = time.Hour
+		t.Cleanup(func() {
+			_ = registry.Shutdown(context.Background())
+		})
+		require.NoError(t, registry.StartServices())
+		return registry, closing, release
+	}
+
+	// assertAnswers checks that the registry answers while the node closes
+	assertAnswers := func(t *testing.T, registry *Registry) {
+		t.Helper()
+		answered := make(chan struct{})
+		go func() {
+			defer close(answered)
+			_, _ = registry.GetService("api")
+			registry.Statuses(context.Background())
+			registry.Configs()
+		}()
+		select {
+		case <-answered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("registry waited for the service to stop")
+		}
+		assert.NoError(t, registry.Responsive())
+	}
+
+	t.Run("remove", func(t *testing.T) {
+		registry, closing, release := stoppingRegistry(t)
+		removed := make(chan error)
+		go func() { removed <- registry.RemoveService("api") }()
+		<-closing
+
+		assertAnswers(t, registry)
+		close(release)
+		require.NoError(t, <-removed)
+		_, ok := registry.State("api")
+		assert.False(t, ok)
+	})
+
+	t.Run("add again while removing", func(t *testing.T) {
+		registry, closing, release := stoppingRegistry(t)
+		removed := make(chan error)
+		go func() { removed <- registry.RemoveService("api") }()
+		<-closing
+
+		require.NoError(t, registry.AddService(config.Service{Name: "api", BackendAddr: "localhost:8080", TLSMode: "off"}))
+		close(release)
+		require.NoError(t, <-removed)
+		assertState(t, registry, "api", constants.ServiceStateRunning)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		registry, closing, release := stoppingRegistry(t)
+		newCfg := config.Service{Name: "api", BackendAddr: "localhost:9090", ListenAddr: ":8080", TLSMode: "off"}
+		updated := make(chan error)
+		go func() { updated <- registry.UpdateService("api", newCfg) }()
+		<-closing
+
+		assertAnswers(t, registry)
+		close(release)
+		require.NoError(t, <-updated)
+		svc, ok := registry.GetService("api")
+		require.True(t, ok)
+		assert.Equal(t, "localhost:9090", svc.Config.BackendAddr)
+	})
+}
@@ -0,0 +1,158 @@
+// Package systemd implements the sd_notify protocol for reporting readiness,
+// reloads, status and watchdog keep-alives to systemd.
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Notification states understood by systemd
+const (
+	StateReady    = "READY=1"
+	StateStopping = "STOPPING=1"
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// Reloading returns a RELOADING= notification stamped with the current
+// CLOCK_MONOTONIC time, which systemd requires of Type=notify-reload services
+func Reloading() string {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return "RELOADING=1"
+	}
+	return fmt.Sprintf("RELOADING=1\nMONOTONIC_USEC=%d", ts.Nano()/int64(time.Microsecond))
+}
+
+// Status returns a STATUS= notification with a free-form description of the
+// service state, shown by systemctl status
+func Status(status string) string {
+	return "STATUS=" + status
+}
+
+// Notifier sends notifications to the socket systemd passes in NOTIFY_SOCKET.
+// A Notifier without a socket ignores every notification, so callers don't need
+// to check whether they run under systemd.
+type Notifier struct {
+	socket   string        // Path of the notification socket, "@" prefixed for the abstract namespace
+	watchdog time.Duration // Watchdog timeout, 0 when the watchdog is disabled
+}
+
+// NewNotifier creates a notifier for the given socket and watchdog timeout.
+// An empty socket disables notifications.
+func NewNotifier(socket string, watchdog time.Duration) *Notifier {
+	return &Notifier{socket: socket, watchdog: watchdog}
+}
+
+// NewNotifierFromEnv creates a notifier from the NOTIFY_SOCKET, WATCHDOG_USEC
+// and WATCHDOG_PID variables systemd sets for Type=notify services
+func NewNotifierFromEnv() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+
+	var watchdog time.Duration
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		watchdog = time.Duration(usec) * time.Microsecond
+	}
+	// The watchdog is meant for another process, such as a wrapper script
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		watchdog = 0
+	}
+
+	return NewNotifier(socket, watchdog)
+}
+
+// Enabled reports whether notifications are sent anywhere
+func (n *Notifier) Enabled() bool {
+	return n.socket != ""
+}
+
+// WatchdogInterval returns how often the watchdog must be pinged, or 0 when
+// systemd is not watching
+func (n *Notifier) WatchdogInterval() time.Duration {
+	if !n.Enabled() {
+		return 0
+	}
+	return n.watchdog
+}
+
+// Notify sends one notification made up of the given state lines
+func (n *Notifier) Notify(states ...string) error {
+	if !n.Enabled() || len(states) == 0 {
+		return nil
+	}
+
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	if strings.HasPrefix(addr.Name, "@") {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return fmt.Errorf("connecting to systemd notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("sending systemd notification: %w", err)
+	}
+	return nil
+}
+
+// RunWatchdog pings the watchdog at half its timeout, as systemd recommends,
+// until ctx is cancelled. Each ping is only sent once alive returns nil within
+// the interval, so systemd restarts a service that stopped responding rather
+// than one that merely kept its ticker running. It returns at once if the
+// watchdog is disabled.
+func (n *Notifier) RunWatchdog(ctx context.Context, alive func(context.Context) error) {
+	interval := n.WatchdogInterval() / 2
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var pending chan error // Result of a check that has not returned yet
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// A check that is still running is waited on rather than started again,
+		// so a deadlock doesn't leave a goroutine behind on every tick
+		if pending == nil {
+			pending = make(chan error, 1)
+			go func(result chan<- error) {
+				checkCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+				result <- alive(checkCtx)
+			}(pending)
+		}
+
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err = <-pending:
+			pending = nil
+		case <-time.After(interval):
+			err = fmt.Errorf("liveness check did not return within %s", interval)
+		}
+		if err != nil {
+			slog.Warn("skipping systemd watchdog ping", "error", err)
+			continue
+		}
+
+		if err := n.Notify(StateWatchdog); err != nil {
+			slog.Warn("failed to ping systemd watchdog", "error", err)
+		}
+	}
+}
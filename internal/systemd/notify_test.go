@@ -0,0 +1,158 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenNotifySocket stands in for systemd's notification socket and returns
+// its path along with a channel of the notifications received
+func listenNotifySocket(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	// Keep the path short enough for the unix socket limit
+	dir, err := os.MkdirTemp("", "tsbridge")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	return path, messages
+}
+
+// receive waits for the next notification
+func receive(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return ""
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	socket, messages := listenNotifySocket(t)
+	n := NewNotifier(socket, 0)
+	require.True(t, n.Enabled())
+
+	require.NoError(t, n.Notify(StateReady, Status("Serving 2 services")))
+	assert.Equal(t, "READY=1\nSTATUS=Serving 2 services", receive(t, messages))
+
+	require.NoError(t, n.Notify(Reloading()))
+	assert.Regexp(t, `^RELOADING=1\nMONOTONIC_USEC=[1-9][0-9]*$`, receive(t, messages))
+
+	require.NoError(t, n.Notify(StateStopping))
+	assert.Equal(t, "STOPPING=1", receive(t, messages))
+
+	t.Run("missing socket", func(t *testing.T) {
+		n := NewNotifier(filepath.Join(t.TempDir(), "missing.sock"), 0)
+		assert.ErrorContains(t, n.Notify(StateReady), "connecting to systemd notify socket")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		n := NewNotifier("", time.Second)
+		assert.False(t, n.Enabled())
+		assert.Zero(t, n.WatchdogInterval())
+		assert.NoError(t, n.Notify(StateReady))
+	})
+}
+
+func TestNotifier_RunWatchdog(t *testing.T) {
+	socket, messages := listenNotifySocket(t)
+	n := NewNotifier(socket, 20*time.Millisecond)
+	alive := func(context.Context) error { return nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.RunWatchdog(ctx, alive)
+		close(done)
+	}()
+
+	assert.Equal(t, "WATCHDOG=1", receive(t, messages))
+	assert.Equal(t, "WATCHDOG=1", receive(t, messages))
+	cancel()
+	<-done
+
+	t.Run("disabled watchdog returns at once", func(t *testing.T) {
+		NewNotifier(socket, 0).RunWatchdog(context.Background(), alive)
+	})
+
+	for _, tt := range []struct {
+		name  string
+		alive func(context.Context) error
+	}{
+		{name: "failed check skips the ping", alive: func(context.Context) error { return assert.AnError }},
+		{name: "hung check skips the ping", alive: func(ctx context.Context) error {
+			select {} // Never returns, like a deadlocked service
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			socket, messages := listenNotifySocket(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			NewNotifier(socket, 20*time.Millisecond).RunWatchdog(ctx, tt.alive)
+
+			select {
+			case msg := <-messages:
+				t.Fatalf("unexpected notification %q", msg)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestNewNotifierFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		usec         string
+		pid          string
+		wantWatchdog time.Duration
+	}{
+		{name: "no watchdog", wantWatchdog: 0},
+		{name: "watchdog", usec: "30000000", wantWatchdog: 30 * time.Second},
+		{name: "watchdog for this process", usec: "1000000", pid: strconv.Itoa(os.Getpid()), wantWatchdog: time.Second},
+		{name: "watchdog for another process", usec: "1000000", pid: "1", wantWatchdog: 0},
+		{name: "invalid watchdog", usec: "soon", wantWatchdog: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_SOCKET", "/run/systemd/notify")
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			n := NewNotifierFromEnv()
+			assert.True(t, n.Enabled())
+			assert.Equal(t, tt.wantWatchdog, n.WatchdogInterval())
+		})
+	}
+
+	t.Run("not under systemd", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+		assert.False(t, NewNotifierFromEnv().Enabled())
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -16,6 +15,7 @@ import (
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/metrics"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
@@ -51,10 +51,11 @@ type authKeyResponse struct {
 	Created time.Time `json:"created"`
 }
 
-// generateAuthKeyWithOAuth generates a Tailscale auth key using OAuth2 client credentials with retry logic
-func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tags []string, ephemeral bool, preauthorized bool) (string, error) {
+// generateAuthKeyWithOAuth generates a Tailscale auth key using OAuth2 client credentials with retry logic,
+// recording each attempt and the outcome in collector, if not nil
+func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tags []string, ephemeral bool, preauthorized bool, collector *metrics.Collector) (string, error) {
 	start := time.Now()
-	slog.Debug("starting OAuth authentication for auth key generation",
+	componentLogger().Debug("starting OAuth authentication for auth key generation",
 		"api_base", apiBaseURL,
 		"tags", tags,
 		"ephemeral", ephemeral,
@@ -78,7 +79,7 @@ func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tag
 		attemptCount++
 		attemptStart := time.Now()
 
-		slog.Debug("attempting OAuth auth key generation",
+		componentLogger().Debug("attempting OAuth auth key generation",
 			"attempt", attemptCount,
 			"max_attempts", constants.RetryMaxAttempts+1,
 		)
@@ -87,7 +88,7 @@ func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tag
 		authKey, err = generateAuthKeyWithOAuthDirect(oauthConfig, apiBaseURL, tags, ephemeral, preauthorized)
 
 		if err != nil {
-			slog.Debug("OAuth auth key generation attempt failed",
+			componentLogger().Debug("OAuth auth key generation attempt failed",
 				"attempt", attemptCount,
 				"duration", time.Since(attemptStart),
 				"error", err,
@@ -95,7 +96,7 @@ func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tag
 				"is_retryable", isRetryableError(err),
 			)
 		} else {
-			slog.Debug("OAuth auth key generation attempt succeeded",
+			componentLogger().Debug("OAuth auth key generation attempt succeeded",
 				"attempt", attemptCount,
 				"duration", time.Since(attemptStart),
 			)
@@ -103,27 +104,33 @@ func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tag
 
 		// Only retry on network errors and 5xx server errors
 		if err != nil && (tserrors.IsNetwork(err) || isRetryableError(err)) {
+			recordOAuthAttempt(collector, "retryable_error")
 			return err
 		}
 
 		// Don't retry on config errors, auth errors, or other non-retryable errors
 		if err != nil {
+			recordOAuthAttempt(collector, "error")
 			return backoff.Permanent(err)
 		}
 
+		recordOAuthAttempt(collector, "success")
 		return nil
 	}
 
 	err := backoff.Retry(operation, backoffWithRetries)
+	if collector != nil {
+		collector.RecordOAuthKeyGeneration(err == nil, attemptCount)
+	}
 
 	if err != nil {
-		slog.Debug("OAuth auth key generation failed after all attempts",
+		componentLogger().Debug("OAuth auth key generation failed after all attempts",
 			"total_attempts", attemptCount,
 			"total_duration", time.Since(start),
 			"error", err,
 		)
 	} else {
-		slog.Debug("OAuth auth key generation completed successfully",
+		componentLogger().Debug("OAuth auth key generation completed successfully",
 			"total_attempts", attemptCount,
 			"total_duration", time.Since(start),
 		)
@@ -132,6 +139,14 @@ func generateAuthKeyWithOAuth(oauthConfig *oauth2.Config, apiBaseURL string, tag
 	return authKey, err
 }
 
+// recordOAuthAttempt records the result of an auth key generation attempt in
+// collector, if not nil
+func recordOAuthAttempt(collector *metrics.Collector, result string) {
+	if collector != nil {
+		collector.RecordOAuthAttempt(result)
+	}
+}
+
 // isRetryableError determines if an error is worth retrying
 func isRetryableError(err error) bool {
 	if err == nil {
@@ -163,7 +178,7 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 
 	start := time.Now()
 
-	slog.Debug("starting OAuth token exchange",
+	componentLogger().Debug("starting OAuth token exchange",
 		"token_url", oauthConfig.Endpoint.TokenURL,
 		"client_id", oauthConfig.ClientID,
 	)
@@ -178,7 +193,7 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 	// Get HTTP client with automatic token refresh
 	tokenStart := time.Now()
 	client := ccConfig.Client(ctx)
-	slog.Debug("OAuth client created",
+	componentLogger().Debug("OAuth client created",
 		"duration", time.Since(tokenStart),
 	)
 
@@ -200,7 +215,7 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 		return "", tserrors.WrapInternal(err, "marshaling auth key request")
 	}
 
-	slog.Debug("sending auth key creation request",
+	componentLogger().Debug("sending auth key creation request",
 		"url", apiBaseURL+"/api/v2/tailnet/-/keys",
 		"request_size", len(body),
 		"ephemeral", ephemeral,
@@ -221,7 +236,7 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 	apiStart := time.Now()
 	resp, err := client.Do(httpReq) //nolint:gosec // G704
 	if err != nil {
-		slog.Debug("auth key API request failed",
+		componentLogger().Debug("auth key API request failed",
 			"duration", time.Since(apiStart),
 			"error", err,
 		)
@@ -229,11 +244,11 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			slog.Debug("failed to close response body", "error", err)
+			componentLogger().Debug("failed to close response body", "error", err)
 		}
 	}()
 
-	slog.Debug("auth key API response received",
+	componentLogger().Debug("auth key API response received",
 		"duration", time.Since(apiStart),
 		"status_code", resp.StatusCode,
 		"headers", resp.Header,
@@ -243,7 +258,7 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 	if resp.StatusCode != http.StatusOK {
 		var errResp map[string]any
 		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		slog.Debug("auth key API error response",
+		componentLogger().Debug("auth key API error response",
 			"status_code", resp.StatusCode,
 			"error_response", errResp,
 			"total_duration", time.Since(start),
@@ -257,7 +272,7 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 		return "", tserrors.WrapInternal(err, "decoding response")
 	}
 
-	slog.Debug("auth key created successfully",
+	componentLogger().Debug("auth key created successfully",
 		"total_duration", time.Since(start),
 		"key_created_at", authKeyResp.Created,
 		"has_key", authKeyResp.Key != "",
@@ -266,14 +281,15 @@ func generateAuthKeyWithOAuthDirect(oauthConfig *oauth2.Config, apiBaseURL strin
 	return authKeyResp.Key, nil
 }
 
-// generateOrResolveAuthKey generates an auth key using OAuth if configured, otherwise uses the resolved auth key
-func generateOrResolveAuthKey(cfg config.Config, svc config.Service) (string, error) {
+// generateOrResolveAuthKey generates an auth key using OAuth if configured, otherwise uses the resolved auth key.
+// OAuth key generation is recorded in collector, if not nil.
+func generateOrResolveAuthKey(cfg config.Config, svc config.Service, collector *metrics.Collector) (string, error) {
 	// Config package has already resolved all secrets, so we can use them directly
 	clientID := cfg.Tailscale.OAuthClientID
 	clientSecret := cfg.Tailscale.OAuthClientSecret.Value()
 	authKey := cfg.Tailscale.AuthKey.Value()
 
-	slog.Debug("resolving authentication method for service",
+	componentLogger().Debug("resolving authentication method for service",
 		"service", svc.Name,
 		"has_oauth_client_id", clientID != "",
 		"has_oauth_client_secret", clientSecret != "",
@@ -282,7 +298,7 @@ func generateOrResolveAuthKey(cfg config.Config, svc config.Service) (string, er
 
 	// If OAuth is configured, use it to generate auth key
 	if clientID != "" && clientSecret != "" {
-		slog.Debug("using OAuth authentication for service",
+		componentLogger().Debug("using OAuth authentication for service",
 			"service", svc.Name,
 		)
 
@@ -292,7 +308,7 @@ func generateOrResolveAuthKey(cfg config.Config, svc config.Service) (string, er
 		if testEndpoint := os.Getenv("TSBRIDGE_OAUTH_ENDPOINT"); testEndpoint != "" {
 			tokenURL = testEndpoint + "/api/v2/oauth/token"
 			apiBase = testEndpoint
-			slog.Debug("using custom OAuth endpoint",
+			componentLogger().Debug("using custom OAuth endpoint",
 				"service", svc.Name,
 				"endpoint", testEndpoint,
 			)
@@ -315,14 +331,14 @@ func generateOrResolveAuthKey(cfg config.Config, svc config.Service) (string, er
 			preauthorized = *cfg.Tailscale.OAuthPreauthorized
 		}
 
-		authKey, err := generateAuthKeyWithOAuth(oauthConfig, apiBase, svc.Tags, svc.Ephemeral, preauthorized)
+		authKey, err := generateAuthKeyWithOAuth(oauthConfig, apiBase, svc.Tags, svc.Ephemeral, preauthorized, collector)
 		if err != nil {
 			// Error from generateAuthKeyWithOAuth is already typed
 			return "", err
 		}
 
 		// Log auth key generation for audit trail
-		slog.Info("Generated Tailscale auth key for service registration",
+		componentLogger().Info("Generated Tailscale auth key for service registration",
 			"service", svc.Name,
 		)
 
@@ -331,14 +347,14 @@ func generateOrResolveAuthKey(cfg config.Config, svc config.Service) (string, er
 
 	// Otherwise, use the resolved auth key
 	if authKey != "" {
-		slog.Debug("using pre-configured auth key for service",
+		componentLogger().Debug("using pre-configured auth key for service",
 			"service", svc.Name,
 		)
 		return authKey, nil
 	}
 
 	// No auth method configured
-	slog.Debug("no authentication method configured for service",
+	componentLogger().Debug("no authentication method configured for service",
 		"service", svc.Name,
 	)
 	return "", tserrors.NewConfigError("no authentication method configured")
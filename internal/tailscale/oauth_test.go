@@ -14,6 +14,8 @@ import (
 
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
@@ -93,7 +95,7 @@ func TestGenerateAuthKeyWithOAuth(t *testing.T) {
 	}
 
 	// Generate auth key using OAuth
-	authKey, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+	authKey, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 	if err != nil {
 		t.Fatalf("failed to generate auth key: %v", err)
 	}
@@ -169,12 +171,12 @@ func TestOAuthTokenRefresh(t *testing.T) {
 	}
 
 	// Generate auth key twice to simulate token usage
-	key1, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+	key1, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	key2, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+	key2, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -242,7 +244,7 @@ func TestOAuthErrorHandling(t *testing.T) {
 			}
 
 			// Try to generate auth key
-			_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+			_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 
 			if tt.expectError && err == nil {
 				t.Error("expected error but got none")
@@ -270,7 +272,7 @@ func TestOAuthErrorTypes(t *testing.T) {
 			},
 		}
 
-		_, err := generateAuthKeyWithOAuth(oauthConfig, "http://example.com", []string{"tag:test"}, false, true)
+		_, err := generateAuthKeyWithOAuth(oauthConfig, "http://example.com", []string{"tag:test"}, false, true, nil)
 		if err == nil {
 			t.Error("expected error for non-200 response without error body")
 		}
@@ -297,7 +299,7 @@ func TestOAuthErrorTypes(t *testing.T) {
 			},
 		}
 
-		_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+		_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 		if err == nil {
 			t.Error("expected error for non-200 response without error body")
 		}
@@ -324,7 +326,7 @@ func TestOAuthErrorTypes(t *testing.T) {
 			},
 		}
 
-		_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+		_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 		if err == nil {
 			t.Error("expected error for invalid JSON response")
 		}
@@ -378,7 +380,7 @@ func TestGenerateOrResolveAuthKeyWithServiceTags(t *testing.T) {
 		Tags: []string{"tag:api", "tag:prod"},
 	}
 
-	authKey, err := generateOrResolveAuthKey(cfg, svc)
+	authKey, err := generateOrResolveAuthKey(cfg, svc, nil)
 	if err != nil {
 		t.Fatalf("failed to generate auth key: %v", err)
 	}
@@ -454,7 +456,7 @@ func TestOAuthEphemeralFlag(t *testing.T) {
 			}
 
 			// Generate auth key with ephemeral flag
-			_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, tt.ephemeral, true)
+			_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, tt.ephemeral, true, nil)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -549,7 +551,7 @@ func TestOAuthPreauthorizedFlag(t *testing.T) {
 			}
 
 			// Generate auth key with preauthorized flag
-			_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, tt.preauthorized)
+			_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, tt.preauthorized, nil)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -609,7 +611,7 @@ func TestAuthKeyNonReusable(t *testing.T) {
 	}
 
 	// Generate auth key
-	_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+	_, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -685,7 +687,7 @@ func TestAuthKeyGenerationLogging(t *testing.T) {
 	}
 
 	// Generate auth key using OAuth
-	authKey, err := generateOrResolveAuthKey(cfg, svc)
+	authKey, err := generateOrResolveAuthKey(cfg, svc, nil)
 	if err != nil {
 		t.Fatalf("failed to generate auth key: %v", err)
 	}
@@ -780,8 +782,9 @@ func TestOAuthRetryBehavior(t *testing.T) {
 			}
 
 			// Attempt to generate auth key with retry
+			collector := metrics.NewCollector()
 			start := time.Now()
-			authKey, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true)
+			authKey, err := generateAuthKeyWithOAuth(oauthConfig, apiServer.URL, []string{"tag:test"}, false, true, collector)
 			duration := time.Since(start)
 
 			// Verify results
@@ -797,6 +800,16 @@ func TestOAuthRetryBehavior(t *testing.T) {
 			// Verify expected number of API calls
 			assert.Equal(t, tt.expectedCalls, apiCallCount)
 
+			// Verify the attempts and retries were recorded
+			successes, outcome := 0.0, "failure"
+			if tt.shouldSucceed {
+				successes, outcome = 1, "success"
+			}
+			assert.Equal(t, float64(min(tt.failureCount, tt.expectedCalls)), testutil.ToFloat64(collector.OAuthAttempts.WithLabelValues("retryable_error")))
+			assert.Equal(t, successes, testutil.ToFloat64(collector.OAuthAttempts.WithLabelValues("success")))
+			assert.Equal(t, float64(tt.expectedCalls-1), testutil.ToFloat64(collector.OAuthRetries))
+			assert.Equal(t, 1.0, testutil.ToFloat64(collector.OAuthRefreshTotal.WithLabelValues(outcome)))
+
 			// Verify retry timing (should have delays for retries)
 			if tt.expectedCalls > 1 {
 				minExpectedDuration := time.Duration(tt.expectedCalls-1) * constants.RetryMinTestDelay
@@ -1,7 +1,6 @@
 package tailscale
 
 import (
-	"log/slog"
 	"os"
 	"path/filepath"
 )
@@ -11,7 +10,7 @@ func hasExistingState(stateDir string, serviceName string) bool {
 	// Each service has its own subdirectory under the state directory
 	serviceStateDir := filepath.Join(stateDir, serviceName)
 
-	slog.Debug("checking for existing tsnet state",
+	componentLogger().Debug("checking for existing tsnet state",
 		"service", serviceName,
 		"state_dir", stateDir,
 		"service_state_dir", serviceStateDir,
@@ -21,12 +20,12 @@ func hasExistingState(stateDir string, serviceName string) bool {
 	info, err := os.Stat(serviceStateDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			slog.Debug("state directory does not exist",
+			componentLogger().Debug("state directory does not exist",
 				"service", serviceName,
 				"service_state_dir", serviceStateDir,
 			)
 		} else {
-			slog.Debug("error checking state directory",
+			componentLogger().Debug("error checking state directory",
 				"service", serviceName,
 				"service_state_dir", serviceStateDir,
 				"error", err,
@@ -36,14 +35,14 @@ func hasExistingState(stateDir string, serviceName string) bool {
 	}
 
 	if !info.IsDir() {
-		slog.Debug("state path exists but is not a directory",
+		componentLogger().Debug("state path exists but is not a directory",
 			"service", serviceName,
 			"service_state_dir", serviceStateDir,
 		)
 		return false
 	}
 
-	slog.Debug("state directory exists",
+	componentLogger().Debug("state directory exists",
 		"service", serviceName,
 		"service_state_dir", serviceStateDir,
 	)
@@ -54,24 +53,24 @@ func hasExistingState(stateDir string, serviceName string) bool {
 	// - tailscaled.log.conf: Logging configuration
 	stateFile := filepath.Join(serviceStateDir, "tailscaled.state")
 
-	slog.Debug("checking for state file",
+	componentLogger().Debug("checking for state file",
 		"service", serviceName,
 		"state_file", stateFile,
 	)
 
 	if _, err := os.Stat(stateFile); err == nil {
-		slog.Debug("existing state file found",
+		componentLogger().Debug("existing state file found",
 			"service", serviceName,
 			"state_file", stateFile,
 		)
 		return true
 	} else if os.IsNotExist(err) {
-		slog.Debug("state file does not exist",
+		componentLogger().Debug("state file does not exist",
 			"service", serviceName,
 			"state_file", stateFile,
 		)
 	} else {
-		slog.Debug("error checking state file",
+		componentLogger().Debug("error checking state file",
 			"service", serviceName,
 			"state_file", stateFile,
 			"error", err,
@@ -4,6 +4,8 @@ package tailscale
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -19,9 +21,16 @@ import (
 	"github.com/jtdowney/tsbridge/internal/config"
 	"github.com/jtdowney/tsbridge/internal/constants"
 	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"github.com/jtdowney/tsbridge/internal/logging"
+	"github.com/jtdowney/tsbridge/internal/metrics"
 	tsnetpkg "github.com/jtdowney/tsbridge/internal/tsnet"
 )
 
+// componentLogger returns the logger for the tailscale component
+func componentLogger() *slog.Logger {
+	return logging.Logger(logging.ComponentTailscale)
+}
+
 // Server wraps a tsnet.Server with tsbridge-specific functionality
 type Server struct {
 	config config.Tailscale
@@ -29,7 +38,9 @@ type Server struct {
 	serviceServers map[string]tsnetpkg.TSNetServer
 	// serverFactory creates new TSNetServer instances
 	serverFactory tsnetpkg.TSNetServerFactory
-	// mu protects serviceServers map
+	// metricsCollector records OAuth key generation, if not nil
+	metricsCollector *metrics.Collector
+	// mu protects serviceServers map and metricsCollector
 	mu sync.Mutex
 }
 
@@ -61,13 +72,19 @@ func NewServer(cfg config.Tailscale) (*Server, error) {
 	return NewServerWithFactory(cfg, factory)
 }
 
-// Listen creates a listener for a specific service using its full configuration
-func (s *Server) Listen(svc config.Service, tlsMode string, funnelEnabled bool) (net.Listener, error) {
+// SetMetricsCollector sets the collector that OAuth auth key generation is
+// recorded in
+func (s *Server) SetMetricsCollector(collector *metrics.Collector) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.metricsCollector = collector
+}
 
+// Listen creates a listener for a specific service using its full configuration.
+// Listeners for different services can be created concurrently.
+func (s *Server) Listen(svc config.Service, tlsMode string, funnelEnabled bool) (net.Listener, error) {
 	listenStart := time.Now()
-	slog.Debug("starting listener creation for service",
+	componentLogger().Debug("starting listener creation for service",
 		"service", svc.Name,
 		"tls_mode", tlsMode,
 		"funnel_enabled", funnelEnabled,
@@ -81,14 +98,14 @@ func (s *Server) Listen(svc config.Service, tlsMode string, funnelEnabled bool)
 
 	if s.config.ControlURL != "" {
 		serviceServer.SetControlURL(s.config.ControlURL)
-		slog.Debug("control URL set for service", "service", svc.Name, "control_url", s.config.ControlURL)
+		componentLogger().Debug("control URL set for service", "service", svc.Name, "control_url", s.config.ControlURL)
 	}
 
 	// Resolve base state directory and set service-specific path
 	baseStateDir, stateDirSource := s.resolveBaseStateDir()
 	serviceStateDir := filepath.Join(baseStateDir, svc.Name)
 	serviceServer.SetDir(serviceStateDir)
-	slog.Debug("state directory resolved for service",
+	componentLogger().Debug("state directory resolved for service",
 		"service", svc.Name,
 		"state_dir", serviceStateDir,
 		"source", stateDirSource,
@@ -100,7 +117,9 @@ func (s *Server) Listen(svc config.Service, tlsMode string, funnelEnabled bool)
 	}
 
 	// Store the service server for later operations
+	s.mu.Lock()
 	s.serviceServers[svc.Name] = serviceServer
+	s.mu.Unlock()
 
 	// Start the service server before listening
 	startupTimeout := constants.DefaultStartupTimeout
@@ -108,9 +127,9 @@ func (s *Server) Listen(svc config.Service, tlsMode string, funnelEnabled bool)
 		startupTimeout = *svc.StartupTimeout
 	}
 	if err := s.startServiceServer(serviceServer, svc.Name, startupTimeout); err != nil {
-		delete(s.serviceServers, svc.Name)
+		s.removeServer(svc.Name, serviceServer)
 		if closeErr := serviceServer.Close(); closeErr != nil {
-			slog.Debug("failed to close server after start failure", "service", svc.Name, "error", closeErr)
+			componentLogger().Debug("failed to close server after start failure", "service", svc.Name, "error", closeErr)
 		}
 		return nil, err
 	}
@@ -118,18 +137,35 @@ func (s *Server) Listen(svc config.Service, tlsMode string, funnelEnabled bool)
 	// Create the appropriate listener (funnel, TLS, or plain)
 	listener, err := s.createServiceListener(serviceServer, svc, tlsMode, funnelEnabled, listenStart)
 	if err != nil {
-		slog.Debug("listener creation failed", "service", svc.Name, "error", err)
-		delete(s.serviceServers, svc.Name)
+		componentLogger().Debug("listener creation failed", "service", svc.Name, "error", err)
+		s.removeServer(svc.Name, serviceServer)
 		if closeErr := serviceServer.Close(); closeErr != nil {
-			slog.Debug("failed to close server after listener failure", "service", svc.Name, "error", closeErr)
+			componentLogger().Debug("failed to close server after listener failure", "service", svc.Name, "error", closeErr)
 		}
 		return nil, err
 	}
-	slog.Debug("listener created successfully", "service", svc.Name, "total_duration", time.Since(listenStart))
+	componentLogger().Debug("listener created successfully", "service", svc.Name, "total_duration", time.Since(listenStart))
 
 	return listener, nil
 }
 
+// removeServer forgets the tsnet server of a service that failed to start,
+// unless it has been replaced since
+func (s *Server) removeServer(serviceName string, server tsnetpkg.TSNetServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.serviceServers[serviceName] == server {
+		delete(s.serviceServers, serviceName)
+	}
+}
+
+// collector returns the collector OAuth auth key generation is recorded in
+func (s *Server) collector() *metrics.Collector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metricsCollector
+}
+
 // resolveBaseStateDir determines the base state directory and its source.
 func (s *Server) resolveBaseStateDir() (string, string) {
 	stateDir := s.config.StateDir
@@ -165,14 +201,14 @@ func (s *Server) prepareServiceAuth(serviceServer tsnetpkg.TSNetServer, svc conf
 	if svc.Ephemeral {
 		needsAuthKey = true
 		authKeyReason = "ephemeral service"
-		slog.Debug("skipping state check for ephemeral service", "service", svc.Name)
+		componentLogger().Debug("skipping state check for ephemeral service", "service", svc.Name)
 	} else {
 		hasState := hasExistingState(baseStateDir, svc.Name)
 		needsAuthKey = !hasState
 		if needsAuthKey {
 			authKeyReason = "no existing state found"
 		}
-		slog.Debug("checking for existing state",
+		componentLogger().Debug("checking for existing state",
 			"service", svc.Name,
 			"has_existing_state", hasState,
 			"state_dir", baseStateDir,
@@ -185,23 +221,23 @@ func (s *Server) prepareServiceAuth(serviceServer tsnetpkg.TSNetServer, svc conf
 			return false, tserrors.WrapConfig(err, fmt.Sprintf("service %q needs authentication but %s", svc.Name, err.Error()))
 		}
 
-		slog.Debug("generating auth key", "service", svc.Name, "reason", authKeyReason)
+		componentLogger().Debug("generating auth key", "service", svc.Name, "reason", authKeyReason)
 		cfg := config.Config{Tailscale: s.config}
-		authKey, err := generateOrResolveAuthKey(cfg, svc)
+		authKey, err := generateOrResolveAuthKey(cfg, svc, s.collector())
 		if err != nil {
 			return false, tserrors.WrapConfig(err, fmt.Sprintf("resolving auth key for service %q", svc.Name))
 		}
 		serviceServer.SetAuthKey(authKey)
-		slog.Debug("auth key set for service", "service", svc.Name)
+		componentLogger().Debug("auth key set for service", "service", svc.Name)
 	} else {
-		slog.Debug("using existing state, no auth key needed", "service", svc.Name)
+		componentLogger().Debug("using existing state, no auth key needed", "service", svc.Name)
 	}
 	return needsAuthKey, nil
 }
 
 // startServiceServer starts the tsnet server for a service.
 func (s *Server) startServiceServer(serviceServer tsnetpkg.TSNetServer, serviceName string, timeout time.Duration) error {
-	slog.Debug("starting tsnet server", "service", serviceName)
+	componentLogger().Debug("starting tsnet server", "service", serviceName)
 	return s.startServerWithTimeout(serviceServer, serviceName, timeout)
 }
 
@@ -216,7 +252,7 @@ func (s *Server) startServerWithTimeout(server tsnetpkg.TSNetServer, serviceName
 	_, err := server.Up(ctx)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			slog.Warn("tsnet server start timed out",
+			componentLogger().Warn("tsnet server start timed out",
 				"service", serviceName,
 				"timeout", timeout,
 				"duration", time.Since(start),
@@ -226,7 +262,7 @@ func (s *Server) startServerWithTimeout(server tsnetpkg.TSNetServer, serviceName
 		return tserrors.WrapResource(err, fmt.Sprintf("starting tsnet server for service %q", serviceName))
 	}
 
-	slog.Debug("tsnet server started successfully", "service", serviceName, "duration", time.Since(start))
+	componentLogger().Debug("tsnet server started successfully", "service", serviceName, "duration", time.Since(start))
 	return nil
 }
 
@@ -251,17 +287,17 @@ func (s *Server) createServiceListener(serviceServer tsnetpkg.TSNetServer, svc c
 // createFunnelListener creates a funnel listener.
 func (s *Server) createFunnelListener(serviceServer tsnetpkg.TSNetServer, serviceName string, listenStart time.Time) (net.Listener, error) {
 	listenerStart := time.Now()
-	slog.Debug("creating funnel listener", "service", serviceName, "address", ":443")
+	componentLogger().Debug("creating funnel listener", "service", serviceName, "address", ":443")
 	listener, err := serviceServer.ListenFunnel("tcp", ":443")
 	if err != nil {
-		slog.Debug("funnel listener creation failed",
+		componentLogger().Debug("funnel listener creation failed",
 			"service", serviceName,
 			"duration", time.Since(listenerStart),
 			"error", err,
 		)
 		return nil, err
 	}
-	slog.Debug("funnel listener created successfully",
+	componentLogger().Debug("funnel listener created successfully",
 		"service", serviceName,
 		"duration", time.Since(listenerStart),
 		"total_duration", time.Since(listenStart),
@@ -272,17 +308,17 @@ func (s *Server) createFunnelListener(serviceServer tsnetpkg.TSNetServer, servic
 // createTLSListener creates a TLS listener with certificate priming.
 func (s *Server) createTLSListener(serviceServer tsnetpkg.TSNetServer, serviceName, listenAddr string, listenStart time.Time) (net.Listener, error) {
 	listenerStart := time.Now()
-	slog.Debug("creating TLS listener", "service", serviceName, "address", listenAddr)
+	componentLogger().Debug("creating TLS listener", "service", serviceName, "address", listenAddr)
 	listener, err := serviceServer.ListenTLS("tcp", listenAddr)
 	if err != nil {
-		slog.Debug("TLS listener creation failed",
+		componentLogger().Debug("TLS listener creation failed",
 			"service", serviceName,
 			"duration", time.Since(listenerStart),
 			"error", err,
 		)
 		return nil, err
 	}
-	slog.Debug("TLS listener created successfully",
+	componentLogger().Debug("TLS listener created successfully",
 		"service", serviceName,
 		"duration", time.Since(listenerStart),
 		"total_duration", time.Since(listenStart),
@@ -294,9 +330,9 @@ func (s *Server) createTLSListener(serviceServer tsnetpkg.TSNetServer, serviceNa
 		defer cancel()
 		start := time.Now()
 		if err := s.primeCertificate(ctx, serviceServer, serviceName); err != nil {
-			slog.Warn("certificate priming failed", "service", serviceName, "error", err, "duration", time.Since(start))
+			componentLogger().Warn("certificate priming failed", "service", serviceName, "error", err, "duration", time.Since(start))
 		} else {
-			slog.Debug("certificate primed successfully", "service", serviceName, "duration", time.Since(start))
+			componentLogger().Debug("certificate primed successfully", "service", serviceName, "duration", time.Since(start))
 		}
 	}()
 
@@ -306,17 +342,17 @@ func (s *Server) createTLSListener(serviceServer tsnetpkg.TSNetServer, serviceNa
 // createPlainListener creates a plain (non-TLS) listener.
 func (s *Server) createPlainListener(serviceServer tsnetpkg.TSNetServer, serviceName, listenAddr string, listenStart time.Time) (net.Listener, error) {
 	listenerStart := time.Now()
-	slog.Debug("creating plain listener", "service", serviceName, "address", listenAddr)
+	componentLogger().Debug("creating plain listener", "service", serviceName, "address", listenAddr)
 	listener, err := serviceServer.Listen("tcp", listenAddr)
 	if err != nil {
-		slog.Debug("plain listener creation failed",
+		componentLogger().Debug("plain listener creation failed",
 			"service", serviceName,
 			"duration", time.Since(listenerStart),
 			"error", err,
 		)
 		return nil, err
 	}
-	slog.Debug("plain listener created successfully",
+	componentLogger().Debug("plain listener created successfully",
 		"service", serviceName,
 		"duration", time.Since(listenerStart),
 		"total_duration", time.Since(listenStart),
@@ -345,6 +381,114 @@ func (s *Server) GetServiceServer(serviceName string) tsnetpkg.TSNetServer {
 	return s.serviceServers[serviceName]
 }
 
+// NodeInfo describes the tailnet node of a service
+type NodeInfo struct {
+	FQDN         string    // MagicDNS name without the trailing dot
+	IPs          []string  // Tailscale IP addresses of the node
+	BackendState string    // Tailscale backend state, such as "Running" or "NeedsLogin"
+	KeyExpiry    time.Time // When the node key expires, zero if it does not
+}
+
+// ServiceNodeInfo returns the tailnet DNS name and addresses of a service's node
+func (s *Server) ServiceNodeInfo(ctx context.Context, serviceName string) (NodeInfo, error) {
+	lc, err := s.serviceLocalClient(serviceName)
+	if err != nil {
+		return NodeInfo{}, err
+	}
+
+	status, err := lc.StatusWithoutPeers(ctx)
+	if err != nil {
+		return NodeInfo{}, fmt.Errorf("getting status: %w", err)
+	}
+	if status == nil || status.Self == nil {
+		return NodeInfo{}, fmt.Errorf("no self peer in status for service %q", serviceName)
+	}
+
+	info := NodeInfo{
+		FQDN:         strings.TrimSuffix(status.Self.DNSName, "."),
+		BackendState: status.BackendState,
+	}
+	if status.Self.KeyExpiry != nil {
+		info.KeyExpiry = *status.Self.KeyExpiry
+	}
+	for _, ip := range status.Self.TailscaleIPs {
+		info.IPs = append(info.IPs, ip.String())
+	}
+	return info, nil
+}
+
+// PeerConnectivity counts the active peers of a node by how they are reached
+type PeerConnectivity struct {
+	Direct  int // Peers reached over a direct connection
+	Relayed int // Peers reached through a DERP relay
+}
+
+// ServicePeerConnectivity returns how the active peers of a service's node are
+// reached. Idle peers have no connection and are not counted.
+func (s *Server) ServicePeerConnectivity(ctx context.Context, serviceName string) (PeerConnectivity, error) {
+	lc, err := s.serviceLocalClient(serviceName)
+	if err != nil {
+		return PeerConnectivity{}, err
+	}
+
+	status, err := lc.Status(ctx)
+	if err != nil {
+		return PeerConnectivity{}, fmt.Errorf("getting status: %w", err)
+	}
+
+	var conn PeerConnectivity
+	if status == nil {
+		return conn, nil
+	}
+	for _, peer := range status.Peer {
+		switch {
+		case !peer.Active:
+		case peer.CurAddr != "":
+			conn.Direct++
+		case peer.Relay != "":
+			conn.Relayed++
+		}
+	}
+	return conn, nil
+}
+
+// ServiceCertExpiry returns when the TLS certificate a service's node holds
+// for fqdn expires
+func (s *Server) ServiceCertExpiry(ctx context.Context, serviceName, fqdn string) (time.Time, error) {
+	lc, err := s.serviceLocalClient(serviceName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	certPEM, _, err := lc.CertPair(ctx, fqdn)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("getting certificate: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, fmt.Errorf("no certificate for %s", fqdn)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing certificate: %w", err)
+	}
+	return cert.NotAfter, nil
+}
+
+// serviceLocalClient returns the LocalClient of a service's node
+func (s *Server) serviceLocalClient(serviceName string) (tsnetpkg.LocalClient, error) {
+	serviceServer := s.GetServiceServer(serviceName)
+	if serviceServer == nil {
+		return nil, fmt.Errorf("no tsnet server for service %q", serviceName)
+	}
+
+	lc, err := serviceServer.LocalClient()
+	if err != nil {
+		return nil, fmt.Errorf("getting local client: %w", err)
+	}
+	return lc, nil
+}
+
 // Close shuts down the server and all service servers
 func (s *Server) Close() error {
 	s.mu.Lock()
@@ -356,7 +500,7 @@ func (s *Server) Close() error {
 
 	// Close all service servers with timeout
 	for serviceName, server := range servers {
-		slog.Debug("closing tsnet server", "service", serviceName)
+		componentLogger().Debug("closing tsnet server", "service", serviceName)
 		if err := s.closeServerWithTimeout(server, serviceName, constants.TsnetServerCloseTimeout); err != nil {
 			closeErrors = append(closeErrors, err)
 		}
@@ -386,10 +530,10 @@ func (s *Server) closeServerWithTimeout(server tsnetpkg.TSNetServer, serviceName
 		if err != nil {
 			return tserrors.WrapResource(err, fmt.Sprintf("closing service %q", serviceName))
 		}
-		slog.Debug("tsnet server closed successfully", "service", serviceName, "duration", time.Since(start))
+		componentLogger().Debug("tsnet server closed successfully", "service", serviceName, "duration", time.Since(start))
 		return nil
 	case <-timer.C:
-		slog.Warn("tsnet server close timed out, forcing shutdown", "service", serviceName, "timeout", timeout, "duration", time.Since(start))
+		componentLogger().Warn("tsnet server close timed out, forcing shutdown", "service", serviceName, "timeout", timeout, "duration", time.Since(start))
 		return tserrors.NewTimeoutError(fmt.Sprintf("closing service %q", serviceName), timeout)
 	}
 }
@@ -500,7 +644,7 @@ func (s *Server) primeCertificate(ctx context.Context, serviceServer tsnetpkg.TS
 	// Always use the Tailscale IP to avoid DNS resolution issues
 	url := fmt.Sprintf("https://%s", tsIP)
 
-	slog.Info("priming TLS certificate",
+	componentLogger().Info("priming TLS certificate",
 		"service", serviceName,
 		"url", url,
 		"sni", fqdn)
@@ -515,7 +659,7 @@ func (s *Server) primeCertificate(ctx context.Context, serviceServer tsnetpkg.TS
 	resp, err := client.Do(req)
 	if err != nil {
 		// This is expected if the backend isn't ready yet
-		slog.Info("certificate priming request completed (certificate will be provisioned on first request)",
+		componentLogger().Info("certificate priming request completed (certificate will be provisioned on first request)",
 			"service", serviceName,
 			"url", url,
 			"sni", fqdn,
@@ -524,7 +668,7 @@ func (s *Server) primeCertificate(ctx context.Context, serviceServer tsnetpkg.TS
 	}
 	resp.Body.Close()
 
-	slog.Info("TLS certificate primed successfully",
+	componentLogger().Info("TLS certificate primed successfully",
 		"service", serviceName,
 		"url", url,
 		"sni", fqdn)
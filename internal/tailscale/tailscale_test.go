@@ -2,9 +2,16 @@ package tailscale
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -17,6 +24,7 @@ import (
 	"time"
 
 	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
 
 	"github.com/jtdowney/tsbridge/internal/config"
 	tserrors "github.com/jtdowney/tsbridge/internal/errors"
@@ -702,6 +710,146 @@ func TestGetServiceServer(t *testing.T) {
 	assert.Nil(t, result)
 }
 
+func TestServiceNodeInfo(t *testing.T) {
+	server, err := NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, func(string) tsnet.TSNetServer {
+		return tsnet.NewMockTSNetServer()
+	})
+	require.NoError(t, err)
+
+	keyExpiry := time.Date(2027, 4, 1, 12, 0, 0, 0, time.UTC)
+	mockServer := tsnet.NewMockTSNetServer()
+	mockServer.LocalClientFunc = func() (tsnet.LocalClient, error) {
+		return &tsnet.MockLocalClient{
+			StatusWithoutPeersFunc: func(ctx context.Context) (*ipnstate.Status, error) {
+				return &ipnstate.Status{
+					BackendState: "Running",
+					Self: &ipnstate.PeerStatus{
+						DNSName:   "api.tailnet.ts.net.",
+						KeyExpiry: &keyExpiry,
+						TailscaleIPs: []netip.Addr{
+							netip.MustParseAddr("100.64.0.1"),
+							netip.MustParseAddr("fd7a:115c:a1e0::1"),
+						},
+					},
+				}, nil
+			},
+		}, nil
+	}
+	server.serviceServers["api"] = mockServer
+
+	info, err := server.ServiceNodeInfo(context.Background(), "api")
+	require.NoError(t, err)
+	assert.Equal(t, NodeInfo{
+		FQDN:         "api.tailnet.ts.net",
+		IPs:          []string{"100.64.0.1", "fd7a:115c:a1e0::1"},
+		BackendState: "Running",
+		KeyExpiry:    keyExpiry,
+	}, info)
+
+	t.Run("unknown service", func(t *testing.T) {
+		_, err := server.ServiceNodeInfo(context.Background(), "missing")
+		assert.ErrorContains(t, err, `no tsnet server for service "missing"`)
+	})
+
+	t.Run("no self peer", func(t *testing.T) {
+		server.serviceServers["empty"] = tsnet.NewMockTSNetServer()
+		_, err := server.ServiceNodeInfo(context.Background(), "empty")
+		assert.ErrorContains(t, err, "no self peer")
+	})
+}
+
+// serverWithLocalClient returns a Server whose "api" service node has lc as
+// its LocalClient
+func serverWithLocalClient(t *testing.T, lc *tsnet.MockLocalClient) *Server {
+	t.Helper()
+	server, err := NewServerWithFactory(config.Tailscale{AuthKey: "test-key"}, func(string) tsnet.TSNetServer {
+		return tsnet.NewMockTSNetServer()
+	})
+	require.NoError(t, err)
+
+	mockServer := tsnet.NewMockTSNetServer()
+	mockServer.LocalClientFunc = func() (tsnet.LocalClient, error) {
+		return lc, nil
+	}
+	server.serviceServers["api"] = mockServer
+	return server
+}
+
+func TestServicePeerConnectivity(t *testing.T) {
+	peers := []*ipnstate.PeerStatus{
+		{Active: true, CurAddr: "203.0.113.5:41641", Relay: "nyc"},
+		{Active: true, CurAddr: "198.51.100.7:41641"},
+		{Active: true, Relay: "fra"},
+		{Active: false, Relay: "nyc"},
+	}
+	server := serverWithLocalClient(t, &tsnet.MockLocalClient{
+		StatusFunc: func(ctx context.Context) (*ipnstate.Status, error) {
+			status := &ipnstate.Status{Peer: make(map[key.NodePublic]*ipnstate.PeerStatus)}
+			for _, peer := range peers {
+				status.Peer[key.NewNode().Public()] = peer
+			}
+			return status, nil
+		},
+	})
+
+	conn, err := server.ServicePeerConnectivity(context.Background(), "api")
+	require.NoError(t, err)
+	assert.Equal(t, PeerConnectivity{Direct: 2, Relayed: 1}, conn)
+
+	t.Run("status error", func(t *testing.T) {
+		server := serverWithLocalClient(t, &tsnet.MockLocalClient{
+			StatusFunc: func(ctx context.Context) (*ipnstate.Status, error) {
+				return nil, errors.New("not running")
+			},
+		})
+		_, err := server.ServicePeerConnectivity(context.Background(), "api")
+		assert.ErrorContains(t, err, "not running")
+	})
+}
+
+func TestServiceCertExpiry(t *testing.T) {
+	notAfter := time.Date(2026, 12, 30, 0, 0, 0, 0, time.UTC)
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "api.tailnet.ts.net"},
+		DNSNames:     []string{"api.tailnet.ts.net"},
+		NotBefore:    notAfter.AddDate(0, -3, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	var requested string
+	server := serverWithLocalClient(t, &tsnet.MockLocalClient{
+		CertPairFunc: func(ctx context.Context, domain string) ([]byte, []byte, error) {
+			requested = domain
+			if domain != "api.tailnet.ts.net" {
+				return nil, nil, errors.New("invalid domain")
+			}
+			return certPEM, []byte("key"), nil
+		},
+	})
+
+	expiry, err := server.ServiceCertExpiry(context.Background(), "api", "api.tailnet.ts.net")
+	require.NoError(t, err)
+	assert.Equal(t, "api.tailnet.ts.net", requested)
+	assert.True(t, notAfter.Equal(expiry), "got %v", expiry)
+
+	t.Run("certificate error", func(t *testing.T) {
+		_, err := server.ServiceCertExpiry(context.Background(), "api", "other.tailnet.ts.net")
+		assert.ErrorContains(t, err, "invalid domain")
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		server := serverWithLocalClient(t, &tsnet.MockLocalClient{})
+		_, err := server.ServiceCertExpiry(context.Background(), "api", "api.tailnet.ts.net")
+		assert.ErrorContains(t, err, "no certificate")
+	})
+}
+
 func TestValidateTailscaleSecrets(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1066,7 +1214,7 @@ func TestGenerateOrResolveAuthKey(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := generateOrResolveAuthKey(tt.cfg, tt.svc)
+			result, err := generateOrResolveAuthKey(tt.cfg, tt.svc, nil)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -1123,7 +1271,7 @@ func TestGenerateOrResolveAuthKeyOAuthFailure(t *testing.T) {
 	}
 
 	// OAuth failures should return an error
-	result, err := generateOrResolveAuthKey(cfg, svc)
+	result, err := generateOrResolveAuthKey(cfg, svc, nil)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "internal server error")
 	assert.Empty(t, result, "should return empty key on OAuth failure")
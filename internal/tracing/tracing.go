@@ -0,0 +1,108 @@
+// Package tracing exports OpenTelemetry traces of proxied requests to an OTLP
+// collector and defines the span attributes tsbridge records.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	tserrors "github.com/jtdowney/tsbridge/internal/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+)
+
+// Span attributes specific to tsbridge. Standard HTTP attributes use the
+// OpenTelemetry semantic convention names.
+const (
+	AttrService   = attribute.Key("tsbridge.service")     // Name of the service handling the request
+	AttrFunnel    = attribute.Key("tsbridge.funnel")      // Whether the request arrived through Tailscale Funnel
+	AttrUserLogin = attribute.Key("tailscale.user.login") // Login name of the caller, from a whois lookup
+	AttrNodeName  = attribute.Key("tailscale.node.name")  // Tailnet node the caller connected from, from a whois lookup
+)
+
+// defaultHTTPPath is the OTLP/HTTP path spans are sent to when the endpoint has none
+const defaultHTTPPath = "/v1/traces"
+
+// Propagator reads and writes W3C trace context and baggage headers
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Options configures the tracer provider
+type Options struct {
+	Endpoint    string   // OTLP collector URL
+	Protocol    string   // "grpc" (default) or "http"
+	SampleRatio *float64 // Fraction of new traces to sample (default: 1)
+	Version     string   // tsbridge version recorded on every span
+}
+
+// NewProvider creates a tracer provider that batches spans to the collector at
+// opts.Endpoint. Spans of requests that arrive with a trace context follow the
+// caller's sampling decision; new traces are sampled at opts.SampleRatio.
+// Shutdown must be called to flush spans that have not been exported yet.
+func NewProvider(ctx context.Context, opts Options) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName("tsbridge"),
+			semconv.ServiceVersion(opts.Version),
+		),
+	)
+	if err != nil {
+		return nil, tserrors.WrapConfig(err, "failed to create tracing resource")
+	}
+
+	ratio := constants.DefaultTracingSampleRatio
+	if opts.SampleRatio != nil {
+		ratio = *opts.SampleRatio
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	), nil
+}
+
+// newExporter creates an OTLP exporter for the configured protocol. Neither
+// exporter connects until spans are sent, so an unreachable collector does not
+// stop tsbridge from starting.
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+	switch opts.Protocol {
+	case "", constants.TracingProtocolGRPC:
+		exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(opts.Endpoint))
+		if err != nil {
+			return nil, tserrors.WrapConfig(err, "failed to create OTLP gRPC exporter")
+		}
+		return exporter, nil
+	case constants.TracingProtocolHTTP:
+		endpoint, err := url.Parse(opts.Endpoint)
+		if err != nil {
+			return nil, tserrors.WrapConfig(err, "invalid tracing endpoint")
+		}
+		if endpoint.Path == "" || endpoint.Path == "/" {
+			endpoint.Path = defaultHTTPPath
+		}
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint.String()))
+		if err != nil {
+			return nil, tserrors.WrapConfig(err, "failed to create OTLP HTTP exporter")
+		}
+		return exporter, nil
+	default:
+		return nil, tserrors.NewConfigError(fmt.Sprintf("unsupported tracing protocol %q", opts.Protocol))
+	}
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector stands in for an OTLP collector, handing over the spans it receives
+type collector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	spans chan *tracepb.ResourceSpans
+}
+
+func newCollector() *collector {
+	return &collector{spans: make(chan *tracepb.ResourceSpans, 16)}
+}
+
+// Export implements the OTLP gRPC trace service
+func (c *collector) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	for _, spans := range req.GetResourceSpans() {
+		c.spans <- spans
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// ServeHTTP implements the OTLP/HTTP trace endpoint
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != defaultHTTPPath {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, _ := c.Export(r.Context(), &req)
+	out, _ := proto.Marshal(resp)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(out)
+}
+
+// startGRPCCollector serves c over gRPC and returns its endpoint URL
+func startGRPCCollector(t *testing.T, c *collector) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, c)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return "http://" + listener.Addr().String()
+}
+
+// startHTTPCollector serves c over HTTP and returns its endpoint URL
+func startHTTPCollector(t *testing.T, c *collector) string {
+	t.Helper()
+	server := httptest.NewServer(c)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		start    func(*testing.T, *collector) string
+	}{
+		{name: "grpc by default", start: startGRPCCollector},
+		{name: "grpc", protocol: constants.TracingProtocolGRPC, start: startGRPCCollector},
+		{name: "http", protocol: constants.TracingProtocolHTTP, start: startHTTPCollector},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCollector()
+			tp, err := NewProvider(t.Context(), Options{
+				Endpoint: tt.start(t, c),
+				Protocol: tt.protocol,
+				Version:  "1.2.3",
+			})
+			require.NoError(t, err)
+
+			_, span := tp.Tracer(constants.TracerName).Start(t.Context(), "GET api")
+			span.SetAttributes(AttrService.String("api"))
+			span.End()
+			require.NoError(t, tp.Shutdown(context.Background()))
+
+			received := <-c.spans
+			resource := make(map[string]string)
+			for _, attr := range received.GetResource().GetAttributes() {
+				resource[attr.GetKey()] = attr.GetValue().GetStringValue()
+			}
+			assert.Equal(t, "tsbridge", resource["service.name"])
+			assert.Equal(t, "1.2.3", resource["service.version"])
+
+			require.Len(t, received.GetScopeSpans(), 1)
+			scope := received.GetScopeSpans()[0]
+			assert.Equal(t, constants.TracerName, scope.GetScope().GetName())
+			require.Len(t, scope.GetSpans(), 1)
+			assert.Equal(t, "GET api", scope.GetSpans()[0].GetName())
+		})
+	}
+}
+
+func TestNewProviderSampling(t *testing.T) {
+	tp, err := NewProvider(t.Context(), Options{
+		Endpoint:    startGRPCCollector(t, newCollector()),
+		SampleRatio: new(0.0),
+	})
+	require.NoError(t, err)
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer(constants.TracerName)
+
+	t.Run("new traces use the ratio", func(t *testing.T) {
+		_, span := tracer.Start(t.Context(), "GET api")
+		defer span.End()
+		assert.False(t, span.SpanContext().IsSampled())
+	})
+
+	t.Run("propagated traces follow the caller", func(t *testing.T) {
+		header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+		ctx := Propagator.Extract(t.Context(), propagation.HeaderCarrier(header))
+		_, span := tracer.Start(ctx, "GET api")
+		defer span.End()
+		assert.True(t, span.SpanContext().IsSampled())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	})
+}
+
+func TestNewProviderUnsupportedProtocol(t *testing.T) {
+	_, err := NewProvider(t.Context(), Options{Endpoint: "http://127.0.0.1:4317", Protocol: "thrift"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported tracing protocol "thrift"`)
+}
@@ -3,8 +3,8 @@ package tsnet
 
 import (
 	"context"
-	"log/slog"
 	"net"
+	"sync"
 	"time"
 
 	"tailscale.com/client/local"
@@ -62,6 +62,11 @@ type LocalClient interface {
 	WhoIs(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error)
 	// StatusWithoutPeers returns the status without peer information.
 	StatusWithoutPeers(ctx context.Context) (*ipnstate.Status, error)
+	// Status returns the status including peers.
+	Status(ctx context.Context) (*ipnstate.Status, error)
+	// CertPair returns the PEM encoded TLS certificate and key for domain,
+	// from the node's cache while it is still valid.
+	CertPair(ctx context.Context, domain string) (certPEM, keyPEM []byte, err error)
 }
 
 // RealTSNetServer wraps a real tsnet.Server to implement TSNetServer.
@@ -81,7 +86,7 @@ func NewRealTSNetServer(serviceName string) *RealTSNetServer {
 // Listen implements TSNetServer.
 func (s *RealTSNetServer) Listen(network, addr string) (net.Listener, error) {
 	start := time.Now()
-	slog.Debug("tsnet Listen() called",
+	componentLogger().Debug("tsnet Listen() called",
 		"hostname", s.Hostname,
 		"network", network,
 		"addr", addr,
@@ -90,13 +95,13 @@ func (s *RealTSNetServer) Listen(network, addr string) (net.Listener, error) {
 	listener, err := s.Server.Listen(network, addr)
 
 	if err != nil {
-		slog.Debug("tsnet Listen() failed",
+		componentLogger().Debug("tsnet Listen() failed",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"error", err,
 		)
 	} else {
-		slog.Debug("tsnet Listen() succeeded",
+		componentLogger().Debug("tsnet Listen() succeeded",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"listener_addr", listener.Addr(),
@@ -109,7 +114,7 @@ func (s *RealTSNetServer) Listen(network, addr string) (net.Listener, error) {
 // ListenTLS implements TSNetServer.
 func (s *RealTSNetServer) ListenTLS(network, addr string) (net.Listener, error) {
 	start := time.Now()
-	slog.Debug("tsnet ListenTLS() called",
+	componentLogger().Debug("tsnet ListenTLS() called",
 		"hostname", s.Hostname,
 		"network", network,
 		"addr", addr,
@@ -118,13 +123,13 @@ func (s *RealTSNetServer) ListenTLS(network, addr string) (net.Listener, error)
 	listener, err := s.Server.ListenTLS(network, addr)
 
 	if err != nil {
-		slog.Debug("tsnet ListenTLS() failed",
+		componentLogger().Debug("tsnet ListenTLS() failed",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"error", err,
 		)
 	} else {
-		slog.Debug("tsnet ListenTLS() succeeded",
+		componentLogger().Debug("tsnet ListenTLS() succeeded",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"listener_addr", listener.Addr(),
@@ -137,7 +142,7 @@ func (s *RealTSNetServer) ListenTLS(network, addr string) (net.Listener, error)
 // ListenFunnel implements TSNetServer.
 func (s *RealTSNetServer) ListenFunnel(network, addr string) (net.Listener, error) {
 	start := time.Now()
-	slog.Debug("tsnet ListenFunnel() called",
+	componentLogger().Debug("tsnet ListenFunnel() called",
 		"hostname", s.Hostname,
 		"network", network,
 		"addr", addr,
@@ -146,13 +151,13 @@ func (s *RealTSNetServer) ListenFunnel(network, addr string) (net.Listener, erro
 	listener, err := s.Server.ListenFunnel(network, addr)
 
 	if err != nil {
-		slog.Debug("tsnet ListenFunnel() failed",
+		componentLogger().Debug("tsnet ListenFunnel() failed",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"error", err,
 		)
 	} else {
-		slog.Debug("tsnet ListenFunnel() succeeded",
+		componentLogger().Debug("tsnet ListenFunnel() succeeded",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"listener_addr", listener.Addr(),
@@ -170,7 +175,7 @@ func (s *RealTSNetServer) Close() error {
 // Start implements TSNetServer.
 func (s *RealTSNetServer) Start() error {
 	start := time.Now()
-	slog.Debug("tsnet server Start() called",
+	componentLogger().Debug("tsnet server Start() called",
 		"hostname", s.Hostname,
 		"ephemeral", s.Ephemeral,
 		"dir", s.Dir,
@@ -180,13 +185,13 @@ func (s *RealTSNetServer) Start() error {
 	err := s.Server.Start()
 
 	if err != nil {
-		slog.Debug("tsnet server Start() failed",
+		componentLogger().Debug("tsnet server Start() failed",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"error", err,
 		)
 	} else {
-		slog.Debug("tsnet server Start() succeeded",
+		componentLogger().Debug("tsnet server Start() succeeded",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 		)
@@ -198,7 +203,7 @@ func (s *RealTSNetServer) Start() error {
 // Up implements TSNetServer with context support for timeout/cancellation.
 func (s *RealTSNetServer) Up(ctx context.Context) (*ipnstate.Status, error) {
 	start := time.Now()
-	slog.Debug("tsnet server Up() called",
+	componentLogger().Debug("tsnet server Up() called",
 		"hostname", s.Hostname,
 		"ephemeral", s.Ephemeral,
 		"dir", s.Dir,
@@ -208,13 +213,13 @@ func (s *RealTSNetServer) Up(ctx context.Context) (*ipnstate.Status, error) {
 	status, err := s.Server.Up(ctx)
 
 	if err != nil {
-		slog.Debug("tsnet server Up() failed",
+		componentLogger().Debug("tsnet server Up() failed",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 			"error", err,
 		)
 	} else {
-		slog.Debug("tsnet server Up() succeeded",
+		componentLogger().Debug("tsnet server Up() succeeded",
 			"hostname", s.Hostname,
 			"duration", time.Since(start),
 		)
@@ -272,6 +277,16 @@ func (c *RealLocalClient) StatusWithoutPeers(ctx context.Context) (*ipnstate.Sta
 	return c.lc.StatusWithoutPeers(ctx)
 }
 
+// Status implements LocalClient.
+func (c *RealLocalClient) Status(ctx context.Context) (*ipnstate.Status, error) {
+	return c.lc.Status(ctx)
+}
+
+// CertPair implements LocalClient.
+func (c *RealLocalClient) CertPair(ctx context.Context, domain string) ([]byte, []byte, error) {
+	return c.lc.CertPair(ctx, domain)
+}
+
 // MockTSNetServer is a mock implementation of TSNetServer for testing.
 type MockTSNetServer struct {
 	Hostname  string
@@ -294,15 +309,15 @@ func NewMockTSNetServer() *MockTSNetServer {
 	return &MockTSNetServer{
 		ListenFunc: func(network, addr string) (net.Listener, error) {
 			// Return a mock listener
-			return &mockListener{addr: addr}, nil
+			return newMockListener(addr), nil
 		},
 		ListenTLSFunc: func(network, addr string) (net.Listener, error) {
 			// Return a mock listener for TLS
-			return &mockListener{addr: addr}, nil
+			return newMockListener(addr), nil
 		},
 		ListenFunnelFunc: func(network, addr string) (net.Listener, error) {
 			// Return a mock listener for Funnel
-			return &mockListener{addr: addr}, nil
+			return newMockListener(addr), nil
 		},
 		CloseFunc: func() error {
 			return nil
@@ -319,16 +334,26 @@ func NewMockTSNetServer() *MockTSNetServer {
 	}
 }
 
-// mockListener is a simple mock implementation of net.Listener
+// mockListener is a simple mock implementation of net.Listener that accepts
+// no connections until it is closed
 type mockListener struct {
-	addr string
+	addr   string
+	closed chan struct{}
+	once   sync.Once
+}
+
+// newMockListener returns an open mockListener
+func newMockListener(addr string) *mockListener {
+	return &mockListener{addr: addr, closed: make(chan struct{})}
 }
 
 func (m *mockListener) Accept() (net.Conn, error) {
+	<-m.closed
 	return nil, net.ErrClosed
 }
 
 func (m *mockListener) Close() error {
+	m.once.Do(func() { close(m.closed) })
 	return nil
 }
 
@@ -364,7 +389,7 @@ func (m *MockTSNetServer) ListenFunnel(network, addr string) (net.Listener, erro
 	if m.ListenFunnelFunc != nil {
 		return m.ListenFunnelFunc(network, addr)
 	}
-	return &mockListener{addr: addr}, nil
+	return newMockListener(addr), nil
 }
 
 // Close implements TSNetServer.
@@ -416,6 +441,8 @@ func (m *MockTSNetServer) SetControlURL(controlURL string) {
 type MockLocalClient struct {
 	WhoIsFunc              func(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error)
 	StatusWithoutPeersFunc func(ctx context.Context) (*ipnstate.Status, error)
+	StatusFunc             func(ctx context.Context) (*ipnstate.Status, error)
+	CertPairFunc           func(ctx context.Context, domain string) ([]byte, []byte, error)
 }
 
 // WhoIs implements LocalClient.
@@ -434,5 +461,21 @@ func (m *MockLocalClient) StatusWithoutPeers(ctx context.Context) (*ipnstate.Sta
 	return nil, nil
 }
 
+// Status implements LocalClient.
+func (m *MockLocalClient) Status(ctx context.Context) (*ipnstate.Status, error) {
+	if m.StatusFunc != nil {
+		return m.StatusFunc(ctx)
+	}
+	return nil, nil
+}
+
+// CertPair implements LocalClient.
+func (m *MockLocalClient) CertPair(ctx context.Context, domain string) ([]byte, []byte, error) {
+	if m.CertPairFunc != nil {
+		return m.CertPairFunc(ctx, domain)
+	}
+	return nil, nil, nil
+}
+
 // TSNetServerFactory is a function that creates new TSNetServer instances.
 type TSNetServerFactory func(serviceName string) TSNetServer
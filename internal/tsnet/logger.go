@@ -4,17 +4,25 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/jtdowney/tsbridge/internal/logging"
+
 	"tailscale.com/types/logger"
 )
 
+// componentLogger returns the logger for the tsnet component
+func componentLogger() *slog.Logger {
+	return logging.Logger(logging.ComponentTSNet)
+}
+
 // tsnetLogAdapter converts tsnet's printf-style logging to structured slog logging.
-// All TSNet logs are treated as debug level to reduce log chattiness.
+// All TSNet logs are treated as debug level to reduce log chattiness, and are
+// tagged with the tsnet component so log_levels.tsnet can raise or lower them.
 func tsnetLogAdapter(serviceName string) logger.Logf {
 	return func(format string, args ...any) {
 		// Simply format the message using standard printf formatting
 		msg := fmt.Sprintf(format, args...)
 
 		// Log at debug level with service context
-		slog.Debug(msg, slog.String("service", serviceName))
+		componentLogger().Debug(msg, slog.String("service", serviceName))
 	}
 }
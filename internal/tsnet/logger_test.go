@@ -28,7 +28,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "tsnet starting",
 			expectedAttrs: map[string]any{
-				"service": "test-service",
+				"component": "tsnet",
+				"service":   "test-service",
 			},
 		},
 		{
@@ -39,7 +40,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "tsnet starting with hostname \"transmission\"",
 			expectedAttrs: map[string]any{
-				"service": "transmission",
+				"component": "tsnet",
+				"service":   "transmission",
 			},
 		},
 		{
@@ -50,7 +52,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "tsnet failed to start: connection timeout",
 			expectedAttrs: map[string]any{
-				"service": "test-service",
+				"component": "tsnet",
+				"service":   "test-service",
 			},
 		},
 		{
@@ -61,7 +64,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "tsnet running state path /var/lib/tsbridge/transmission/tailscaled.state",
 			expectedAttrs: map[string]any{
-				"service": "transmission",
+				"component": "tsnet",
+				"service":   "transmission",
 			},
 		},
 		{
@@ -72,7 +76,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "To authenticate, visit: https://login.tailscale.com/a/abc123",
 			expectedAttrs: map[string]any{
-				"service": "test-service",
+				"component": "tsnet",
+				"service":   "test-service",
 			},
 		},
 		{
@@ -83,7 +88,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "magicsock: received packet from peer",
 			expectedAttrs: map[string]any{
-				"service": "test-service",
+				"component": "tsnet",
+				"service":   "test-service",
 			},
 		},
 		{
@@ -94,7 +100,8 @@ func TestTSNetLogAdapter(t *testing.T) {
 			expectedLevel: slog.LevelDebug,
 			expectedMsg:   "wgengine: updating peer endpoints",
 			expectedAttrs: map[string]any{
-				"service": "test-service",
+				"component": "tsnet",
+				"service":   "test-service",
 			},
 		},
 	}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/events"
+)
+
+// jsonPayload is the body of json format webhooks
+type jsonPayload struct {
+	events.Event
+	Message string `json:"message"`
+}
+
+// slackPayload is the body of slack format webhooks, which Slack, Mattermost
+// and Discord's Slack-compatible endpoints accept
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// payload builds the request body and headers posting e in the webhook's format
+func (h *hook) payload(e events.Event) ([]byte, http.Header, error) {
+	header := http.Header{}
+	header.Set("User-Agent", "tsbridge")
+	header.Set("X-Tsbridge-Event", e.Type)
+	header.Set("X-Tsbridge-Delivery", strconv.FormatUint(e.ID, 10))
+
+	var body []byte
+	var err error
+	switch h.config.Format {
+	case constants.WebhookFormatSlack:
+		header.Set("Content-Type", "application/json")
+		body, err = json.Marshal(slackPayload{Text: "tsbridge: " + Message(e)})
+	case constants.WebhookFormatNtfy:
+		// ntfy takes the message as a plain text body and everything else as headers
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Title", "tsbridge: "+e.Type)
+		if slices.Contains(DefaultEvents, e.Type) {
+			header.Set("Priority", "high")
+			header.Set("Tags", "warning")
+		} else {
+			header.Set("Priority", "default")
+			header.Set("Tags", "information_source")
+		}
+		body = []byte(Message(e))
+	default:
+		header.Set("Content-Type", "application/json")
+		body, err = json.Marshal(jsonPayload{Event: e, Message: Message(e)})
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding %s webhook payload: %w", e.Type, err)
+	}
+	return body, header, nil
+}
+
+// Sign returns the signature header value of a request sent at timestamp, in
+// Unix seconds, with body: "sha256=" followed by the hex-encoded HMAC-SHA256
+// of the timestamp, a dot and body, keyed with secret. Signing the timestamp
+// lets receivers reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Message describes e in a sentence for chat and push notifications
+func Message(e events.Event) string {
+	var msg string
+	switch e.Type {
+	case events.ServiceStarted:
+		msg = fmt.Sprintf("service %q started", e.Service)
+	case events.ServiceStartFailed:
+		msg = fmt.Sprintf("service %q failed to start", e.Service)
+	case events.ServiceStopped:
+		msg = fmt.Sprintf("service %q stopped", e.Service)
+	case events.NodeStateChanged:
+		msg = fmt.Sprintf("tailnet node of service %q changed state", e.Service)
+	case events.NodeCertExpiring:
+		msg = fmt.Sprintf("TLS certificate of service %q expires soon", e.Service)
+		if expires, ok := e.Data["expires_at"]; ok {
+			msg = fmt.Sprintf("TLS certificate of service %q expires at %v", e.Service, expires)
+		}
+	case events.BackendUnhealthy:
+		msg = fmt.Sprintf("backend of service %q is unhealthy", e.Service)
+	case events.BackendHealthy:
+		msg = fmt.Sprintf("backend of service %q is healthy again", e.Service)
+	case events.ConfigLoaded:
+		msg = "configuration loaded"
+	case events.ConfigReloaded:
+		msg = "configuration reloaded"
+	case events.ConfigReloadFailed:
+		msg = "configuration reload failed"
+	default:
+		msg = e.Type
+		if e.Service != "" {
+			msg += fmt.Sprintf(" for service %q", e.Service)
+		}
+	}
+
+	if e.Error != "" {
+		msg += ": " + e.Error
+	}
+	return msg
+}
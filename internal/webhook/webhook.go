@@ -0,0 +1,221 @@
+// Package webhook posts lifecycle events to outbound webhooks, such as Slack
+// or ntfy, retrying failed deliveries with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/events"
+)
+
+// DefaultEvents are the event types posted by webhooks that do not list their own
+var DefaultEvents = []string{
+	events.ServiceStartFailed,
+	events.BackendUnhealthy,
+	events.ConfigReloadFailed,
+	events.NodeCertExpiring,
+}
+
+// Notifier is an events.Sink that posts matching events to each configured
+// webhook. Every webhook delivers its events in order on its own goroutine, so
+// a slow or failing receiver delays neither publishers nor other webhooks.
+type Notifier struct {
+	hooks  []*hook
+	ctx    context.Context // Cancelled by Close to abandon deliveries
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	closed bool
+	mu     sync.Mutex
+}
+
+// hook delivers events to a single webhook
+type hook struct {
+	name       string
+	config     config.Webhook
+	events     []string
+	maxRetries int
+	backoff    time.Duration // Wait before the first retry
+	client     *http.Client
+	queue      chan events.Event
+}
+
+// New creates a Notifier for webhooks and starts delivering to them
+func New(webhooks map[string]config.Webhook) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{ctx: ctx, cancel: cancel}
+
+	for _, name := range slices.Sorted(maps.Keys(webhooks)) {
+		cfg := webhooks[name]
+		h := &hook{
+			name:       name,
+			config:     cfg,
+			events:     cfg.Events,
+			maxRetries: constants.DefaultWebhookMaxRetries,
+			backoff:    constants.WebhookInitialBackoff,
+			client:     &http.Client{Timeout: constants.DefaultWebhookTimeout},
+			queue:      make(chan events.Event, constants.WebhookQueueSize),
+		}
+		if len(h.events) == 0 {
+			h.events = DefaultEvents
+		}
+		if cfg.MaxRetries != nil {
+			h.maxRetries = *cfg.MaxRetries
+		}
+		if cfg.Timeout != nil {
+			h.client.Timeout = *cfg.Timeout
+		}
+		n.hooks = append(n.hooks, h)
+	}
+
+	for _, h := range n.hooks {
+		n.wg.Go(func() {
+			for e := range h.queue {
+				if err := h.deliver(n.ctx, e); err != nil {
+					slog.Warn("webhook delivery failed", "webhook", h.name, "event", e.Type, "error", err)
+				}
+			}
+		})
+	}
+	return n
+}
+
+// Record queues e for every webhook that wants it, dropping it for webhooks
+// whose queue is full
+func (n *Notifier) Record(e events.Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+
+	for _, h := range n.hooks {
+		if !e.Match(h.events) {
+			continue
+		}
+		select {
+		case h.queue <- e:
+		default:
+			slog.Warn("webhook queue full, dropping event", "webhook", h.name, "event", e.Type)
+		}
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be delivered,
+// abandoning those still pending when ctx is done
+func (n *Notifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		for _, h := range n.hooks {
+			close(h.queue)
+		}
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	n.cancel()
+	<-done
+	return err
+}
+
+// deliver posts e to the webhook, retrying failures that may be temporary
+func (h *hook) deliver(ctx context.Context, e events.Event) error {
+	body, header, err := h.payload(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := h.backoff
+	for attempt := 0; ; attempt++ {
+		err := h.send(ctx, body, header)
+		if err == nil {
+			return nil
+		}
+		if attempt >= h.maxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		slog.Debug("retrying webhook delivery", "webhook", h.name, "event", e.Type, "attempt", attempt+1, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff = min(backoff*2, constants.WebhookMaxBackoff)
+	}
+}
+
+// send makes a single delivery attempt, signed with the time it is made so a
+// retry is not rejected as a replay
+func (h *hook) send(ctx context.Context, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL.Value(), bytes.NewReader(body))
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+	req.Header = header.Clone()
+	if secret := h.config.Secret.Value(); secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(constants.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(constants.WebhookSignatureHeader, Sign(secret, timestamp, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// Leave out the URL, which may be secret
+		if urlErr, ok := errors.AsType[*url.Error](err); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// statusError is returned when a webhook answers with a non-2xx status
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.code)
+}
+
+// retryable reports whether a failed delivery may succeed if tried again.
+// Network errors, rate limiting and server errors are retried; other
+// responses mean the request itself was rejected.
+func retryable(err error) bool {
+	if statusErr, ok := errors.AsType[*statusError](err); ok {
+		return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+	return true
+}
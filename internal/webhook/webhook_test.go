@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jtdowney/tsbridge/internal/config"
+	"github.com/jtdowney/tsbridge/internal/constants"
+	"github.com/jtdowney/tsbridge/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request is a webhook delivery seen by a receiver
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver starts an httptest server answering deliveries with the given
+// statuses in turn, then 200, and passes each request on the returned channel
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 16)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header.Clone(), body: body}
+		if i := int(calls.Add(1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// notifier creates a Notifier for a single webhook with a short retry backoff
+func notifier(t *testing.T, hook config.Webhook) *Notifier {
+	t.Helper()
+	n := New(map[string]config.Webhook{"test": hook})
+	n.hooks[0].backoff = time.Millisecond
+	t.Cleanup(func() { _ = n.Close(context.Background()) })
+	return n
+}
+
+func receive(t *testing.T, requests <-chan request) request {
+	t.Helper()
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for webhook delivery")
+		return request{}
+	}
+}
+
+func startFailed() events.Event {
+	return events.Event{
+		ID:      7,
+		Time:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Type:    events.ServiceStartFailed,
+		Service: "api",
+		Error:   "listen: address in use",
+	}
+}
+
+func TestNotifierFormats(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		server, requests := receiver(t)
+		n := notifier(t, config.Webhook{URL: config.RedactedString(server.URL + "/hook"), Format: constants.WebhookFormatJSON})
+
+		n.Record(startFailed())
+		r := receive(t, requests)
+
+		assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+		assert.Equal(t, events.ServiceStartFailed, r.header.Get("X-Tsbridge-Event"))
+		assert.Equal(t, "7", r.header.Get("X-Tsbridge-Delivery"))
+		assert.Empty(t, r.header.Get(constants.WebhookSignatureHeader))
+		assert.Empty(t, r.header.Get(constants.WebhookTimestampHeader))
+
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal(r.body, &payload))
+		assert.Equal(t, events.ServiceStartFailed, payload["type"])
+		assert.Equal(t, "api", payload["service"])
+		assert.Equal(t, "listen: address in use", payload["error"])
+		assert.Equal(t, `service "api" failed to start: listen: address in use`, payload["message"])
+	})
+
+	t.Run("slack", func(t *testing.T) {
+		server, requests := receiver(t)
+		n := notifier(t, config.Webhook{URL: config.RedactedString(server.URL), Format: constants.WebhookFormatSlack})
+
+		n.Record(startFailed())
+		r := receive(t, requests)
+
+		assert.JSONEq(t, `{"text": "tsbridge: service \"api\" failed to start: listen: address in use"}`, string(r.body))
+	})
+
+	t.Run("ntfy", func(t *testing.T) {
+		server, requests := receiver(t)
+		n := notifier(t, config.Webhook{
+			URL:    config.RedactedString(server.URL),
+			Format: constants.WebhookFormatNtfy,
+			Events: []string{"service"},
+		})
+
+		n.Record(startFailed())
+		r := receive(t, requests)
+		assert.Equal(t, `service "api" failed to start: listen: address in use`, string(r.body))
+		assert.Equal(t, "tsbridge: service.start_failed", r.header.Get("Title"))
+		assert.Equal(t, "high", r.header.Get("Priority"))
+		assert.Equal(t, "warning", r.header.Get("Tags"))
+
+		n.Record(events.Event{ID: 8, Type: events.ServiceStarted, Service: "api"})
+		r = receive(t, requests)
+		assert.Equal(t, `service "api" started`, string(r.body))
+		assert.Equal(t, "default", r.header.Get("Priority"))
+	})
+}
+
+func TestNotifierSignature(t *testing.T) {
+	server, requests := receiver(t)
+	n := notifier(t, config.Webhook{URL: config.RedactedString(server.URL), Secret: "s3cret"})
+
+	before := time.Now().Unix()
+	n.Record(startFailed())
+	r := receive(t, requests)
+
+	timestamp, err := strconv.ParseInt(r.header.Get(constants.WebhookTimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, before, timestamp, 5)
+
+	signature := r.header.Get(constants.WebhookSignatureHeader)
+	assert.Equal(t, Sign("s3cret", timestamp, r.body), signature)
+	assert.NotEqual(t, Sign("other", timestamp, r.body), signature)
+	assert.NotEqual(t, Sign("s3cret", timestamp-1, r.body), signature)
+	assert.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+}
+
+func TestNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		attempts int
+	}{
+		{name: "server error then success", statuses: []int{500, 503}, retries: 3, attempts: 3},
+		{name: "rate limited", statuses: []int{429}, retries: 3, attempts: 2},
+		{name: "client error not retried", statuses: []int{400, 400}, retries: 3, attempts: 1},
+		{name: "gives up after max retries", statuses: []int{500, 500, 500, 500}, retries: 2, attempts: 3},
+		{name: "no retries", statuses: []int{500, 500}, retries: 0, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := receiver(t, tt.statuses...)
+			n := New(map[string]config.Webhook{"test": {URL: config.RedactedString(server.URL), MaxRetries: new(tt.retries)}})
+			n.hooks[0].backoff = time.Millisecond
+
+			n.Record(startFailed())
+			require.NoError(t, n.Close(context.Background()))
+
+			assert.Len(t, requests, tt.attempts)
+			for range tt.attempts {
+				r := <-requests
+				assert.Equal(t, "7", r.header.Get("X-Tsbridge-Delivery"), "retries should resend the same delivery")
+			}
+		})
+	}
+}
+
+func TestNotifierEventFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		sent   []string
+	}{
+		{
+			name:   "defaults",
+			events: nil,
+			sent:   []string{events.ServiceStartFailed, events.BackendUnhealthy, events.ConfigReloadFailed, events.NodeCertExpiring},
+		},
+		{
+			name:   "exact type",
+			events: []string{events.ServiceStarted},
+			sent:   []string{events.ServiceStarted},
+		},
+		{
+			name:   "category",
+			events: []string{"backend"},
+			sent:   []string{events.BackendUnhealthy, events.BackendHealthy},
+		},
+	}
+
+	published := []string{
+		events.ServiceStarted,
+		events.ServiceStartFailed,
+		events.BackendUnhealthy,
+		events.BackendHealthy,
+		events.ConfigReloaded,
+		events.ConfigReloadFailed,
+		events.NodeCertExpiring,
+		events.AdminRestart,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := receiver(t)
+			n := New(map[string]config.Webhook{"test": {URL: config.RedactedString(server.URL), Events: tt.events}})
+
+			for i, eventType := range published {
+				n.Record(events.Event{ID: uint64(i + 1), Type: eventType, Service: "api"})
+			}
+			require.NoError(t, n.Close(context.Background()))
+
+			var sent []string
+			for len(requests) > 0 {
+				sent = append(sent, (<-requests).header.Get("X-Tsbridge-Event"))
+			}
+			assert.Equal(t, tt.sent, sent)
+		})
+	}
+}
+
+func TestNotifierBus(t *testing.T) {
+	server, requests := receiver(t)
+	n := notifier(t, config.Webhook{URL: config.RedactedString(server.URL)})
+
+	bus := events.NewBus(10)
+	bus.AddSink(n)
+	bus.Publish(events.Event{Type: events.ConfigReloadFailed, Error: "bad config"})
+
+	r := receive(t, requests)
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(r.body, &payload))
+	assert.Equal(t, "configuration reload failed: bad config", payload["message"])
+}
+
+func TestNotifierClose(t *testing.T) {
+	t.Run("drops events after close", func(t *testing.T) {
+		server, requests := receiver(t)
+		n := New(map[string]config.Webhook{"test": {URL: config.RedactedString(server.URL)}})
+
+		require.NoError(t, n.Close(context.Background()))
+		require.NoError(t, n.Close(context.Background()))
+		n.Record(startFailed())
+		assert.Empty(t, requests)
+	})
+
+	t.Run("abandons pending deliveries when context is done", func(t *testing.T) {
+		server, requests := receiver(t, 500, 500, 500, 500)
+		n := New(map[string]config.Webhook{"test": {URL: config.RedactedString(server.URL)}})
+		n.hooks[0].backoff = time.Hour
+
+		n.Record(startFailed())
+		receive(t, requests)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := n.Close(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestSendLeavesOutURL(t *testing.T) {
+	h := &hook{
+		config: config.Webhook{URL: "http://127.0.0.1:1/secret-token"},
+		client: &http.Client{Timeout: time.Second},
+	}
+	err := h.send(context.Background(), nil, http.Header{})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-token")
+}